@@ -0,0 +1,99 @@
+package core
+
+import (
+	"sync"
+	"time"
+
+	"github.com/byteplus-sdk/byteplus-sdk-go-rec-core/logs"
+	"github.com/byteplus-sdk/byteplus-sdk-go-rec-core/metrics"
+)
+
+const (
+	defaultAdaptiveCompressMinSavings   = 0.1
+	defaultAdaptiveCompressEvalInterval = 5 * time.Minute
+	adaptiveCompressSampleCount         = 10
+)
+
+// compressionAdvisor decides whether to compress the request body of each path.
+// It compresses the first requests of a path as samples, and disables compression
+// for the path if gzip saves less than minSavings of the bytes. The decision is
+// re-evaluated with new samples after evalInterval.
+type compressionAdvisor struct {
+	projectID    string
+	minSavings   float64
+	evalInterval time.Duration
+	lock         sync.Mutex
+	paths        map[string]*pathCompression
+}
+
+type pathCompression struct {
+	compress        bool
+	decidedAt       time.Time
+	samples         int
+	originBytes     int64
+	compressedBytes int64
+}
+
+func newCompressionAdvisor(projectID string, minSavings float64, evalInterval time.Duration) *compressionAdvisor {
+	if minSavings <= 0 {
+		minSavings = defaultAdaptiveCompressMinSavings
+	}
+	if evalInterval <= 0 {
+		evalInterval = defaultAdaptiveCompressEvalInterval
+	}
+	return &compressionAdvisor{
+		projectID:    projectID,
+		minSavings:   minSavings,
+		evalInterval: evalInterval,
+		paths:        make(map[string]*pathCompression),
+	}
+}
+
+// shouldCompress returns true if the path is sampling or decided to be compressed
+func (a *compressionAdvisor) shouldCompress(path string) bool {
+	a.lock.Lock()
+	defer a.lock.Unlock()
+	state, exist := a.paths[path]
+	if !exist || state.decidedAt.IsZero() {
+		return true
+	}
+	if time.Since(state.decidedAt) > a.evalInterval {
+		// start sampling again
+		state.decidedAt = time.Time{}
+		return true
+	}
+	return state.compress
+}
+
+// record the sizes of a compressed body, and decides when there are enough samples
+func (a *compressionAdvisor) record(path string, originSize, compressedSize int) {
+	a.lock.Lock()
+	defer a.lock.Unlock()
+	state, exist := a.paths[path]
+	if !exist {
+		state = &pathCompression{}
+		a.paths[path] = state
+	}
+	if !state.decidedAt.IsZero() {
+		return
+	}
+	state.samples++
+	state.originBytes += int64(originSize)
+	state.compressedBytes += int64(compressedSize)
+	if state.samples < adaptiveCompressSampleCount || state.originBytes == 0 {
+		return
+	}
+	savings := 1 - float64(state.compressedBytes)/float64(state.originBytes)
+	state.compress = savings >= a.minSavings
+	state.decidedAt = time.Now()
+	state.samples, state.originBytes, state.compressedBytes = 0, 0, 0
+	if !state.compress {
+		metricsTags := []string{
+			"type:disable_compression",
+			"project_id:" + a.projectID,
+			"path:" + escapeMetricsTagValue(path),
+		}
+		metrics.Counter(metricsKeyCommonInfo, 1, metricsTags...)
+		logs.Info("disable compression for path:%s savings:%.2f", path, savings)
+	}
+}
@@ -0,0 +1,27 @@
+package core
+
+import (
+	"testing"
+	"time"
+)
+
+func TestCompressionAdvisor(t *testing.T) {
+	advisor := newCompressionAdvisor("", 0.1, time.Hour)
+	for i := 0; i < adaptiveCompressSampleCount; i++ {
+		if !advisor.shouldCompress("/embedding") {
+			t.Fatalf("sample %d should be compressed", i)
+		}
+		advisor.record("/embedding", 100, 95)
+		advisor.record("/text", 100, 20)
+	}
+	if advisor.shouldCompress("/embedding") {
+		t.Errorf("/embedding saves 5%%, should not be compressed")
+	}
+	if !advisor.shouldCompress("/text") {
+		t.Errorf("/text saves 80%%, should be compressed")
+	}
+	advisor.paths["/embedding"].decidedAt = time.Now().Add(-2 * time.Hour)
+	if !advisor.shouldCompress("/embedding") {
+		t.Errorf("/embedding should be sampled again after eval interval")
+	}
+}
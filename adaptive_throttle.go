@@ -0,0 +1,109 @@
+package core
+
+import (
+	"math/rand"
+	"sync"
+	"time"
+
+	"github.com/byteplus-sdk/byteplus-sdk-go-rec-core/coreerr"
+)
+
+const (
+	defaultAdaptiveThrottleK = 2.0
+	throttleBucketCount      = 10
+	throttleBucketDuration   = 3 * time.Second
+)
+
+// ErrClientThrottled is returned without sending the request, when the request
+// is rejected by client because the server keeps responding 429 for the path
+var ErrClientThrottled = newClassifiedError("request is throttled by client because of too many 429 responses", coreerr.Throttled)
+
+// adaptiveThrottler rejects requests of a path on client side with probability
+// max(0, (requests - k*accepts) / (requests + 1)), counted in the recent
+// throttleBucketCount*throttleBucketDuration. The allowed requests probe the
+// server, and the rejection probability goes down as they are accepted again
+type adaptiveThrottler struct {
+	k     float64
+	lock  sync.Mutex
+	paths map[string]*throttleWindow
+	// for test
+	random func() float64
+}
+
+type throttleBucket struct {
+	start    time.Time
+	requests int
+	accepts  int
+}
+
+type throttleWindow struct {
+	buckets [throttleBucketCount]throttleBucket
+}
+
+func newAdaptiveThrottler(k float64) *adaptiveThrottler {
+	if k <= 0 {
+		k = defaultAdaptiveThrottleK
+	}
+	return &adaptiveThrottler{
+		k:      k,
+		paths:  make(map[string]*throttleWindow),
+		random: rand.Float64,
+	}
+}
+
+// allow returns false if the request of path should be rejected, the rejected
+// request is counted, the allowed one is counted by record once it is responded
+func (t *adaptiveThrottler) allow(path string) bool {
+	t.lock.Lock()
+	defer t.lock.Unlock()
+	window := t.getOrCreateWindow(path)
+	requests, accepts := window.sum(time.Now())
+	rejectRatio := (float64(requests) - t.k*float64(accepts)) / float64(requests+1)
+	if rejectRatio <= 0 || t.random() >= rejectRatio {
+		return true
+	}
+	window.current(time.Now()).requests++
+	return false
+}
+
+// record counts a request of path responded by server, it is accepted unless
+// the server responds 429. The requests failed without response, such as the
+// network errors, are not recorded, as they tell nothing about the throttling
+func (t *adaptiveThrottler) record(path string, throttled bool) {
+	t.lock.Lock()
+	defer t.lock.Unlock()
+	bucket := t.getOrCreateWindow(path).current(time.Now())
+	bucket.requests++
+	if !throttled {
+		bucket.accepts++
+	}
+}
+
+func (t *adaptiveThrottler) getOrCreateWindow(path string) *throttleWindow {
+	window, exist := t.paths[path]
+	if !exist {
+		window = &throttleWindow{}
+		t.paths[path] = window
+	}
+	return window
+}
+
+func (w *throttleWindow) current(now time.Time) *throttleBucket {
+	start := now.Truncate(throttleBucketDuration)
+	bucket := &w.buckets[start.UnixNano()/int64(throttleBucketDuration)%throttleBucketCount]
+	if !bucket.start.Equal(start) {
+		*bucket = throttleBucket{start: start}
+	}
+	return bucket
+}
+
+func (w *throttleWindow) sum(now time.Time) (requests, accepts int) {
+	expired := now.Add(-throttleBucketCount * throttleBucketDuration)
+	for _, bucket := range w.buckets {
+		if bucket.start.After(expired) {
+			requests += bucket.requests
+			accepts += bucket.accepts
+		}
+	}
+	return requests, accepts
+}
@@ -0,0 +1,37 @@
+package core
+
+import "testing"
+
+func TestAdaptiveThrottler(t *testing.T) {
+	throttler := newAdaptiveThrottler(2)
+	throttler.random = func() float64 { return 0.5 }
+	// accepted requests are never rejected
+	for i := 0; i < 100; i++ {
+		if !throttler.allow("/predict") {
+			t.Fatalf("request %d should be allowed", i)
+		}
+		throttler.record("/predict", false)
+	}
+	// requests are rejected after enough 429s
+	rejected := 0
+	for i := 0; i < 1000; i++ {
+		if !throttler.allow("/write") {
+			rejected++
+			continue
+		}
+		throttler.record("/write", true)
+	}
+	if rejected == 0 {
+		t.Errorf("requests should be rejected when no one is accepted")
+	}
+	// other paths are not affected
+	if !throttler.allow("/predict") {
+		t.Errorf("requests of other paths should be allowed")
+	}
+	// requests failed without response, which are not recorded, are not rejected
+	for i := 0; i < 1000; i++ {
+		if !throttler.allow("/user") {
+			t.Fatalf("request %d failed by network should not be rejected", i)
+		}
+	}
+}
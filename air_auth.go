@@ -0,0 +1,164 @@
+package core
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"net/url"
+	"strconv"
+
+	"github.com/google/uuid"
+	"github.com/valyala/fasthttp"
+)
+
+const (
+	defaultAirAuthNonceLength = 8
+	maxAirAuthNonceLength     = 64
+	airAuthVersionHeader      = "Tenant-Auth-Version"
+	// the v1 signature additionally covers the request path
+	airAuthVersionV1Path = "v1-path"
+)
+
+// AirAuthVersion is the version of air auth signature algorithm, it is sent in
+// the "Tenant-Auth-Version" header except v1, so the server knows how to verify
+type AirAuthVersion string
+
+const (
+	// AirAuthVersionV1 SHA-256 of token, body, tenant id, ts and nonce concatenated
+	AirAuthVersionV1 AirAuthVersion = "v1"
+	// AirAuthVersionV2 HMAC-SHA256 keyed by token over the canonical request,
+	// which covers method, path, query, tenant id, ts, nonce and body hash
+	AirAuthVersionV2 AirAuthVersion = "v2"
+)
+
+type AirAuthConfig struct {
+	// The signature algorithm version, default is AirAuthVersionV1,
+	// only use the newer version when the tenant is enabled by the server
+	Version AirAuthVersion
+	// The length of nonce, default is 8, max is 64
+	NonceLength int
+	// Generates the nonce with the specified length, default is random hex string from crypto/rand
+	NonceSource func(length int) string
+	// Include the request path in the v1 signed material, which prevents a signed
+	// request from being replayed to another path. The server recognizes it by
+	// the "Tenant-Auth-Version" header, only enable it when the server supports.
+	// The path is always signed in AirAuthVersionV2
+	SignPath bool
+}
+
+func fillDefaultAirAuthConfig(config *AirAuthConfig) (*AirAuthConfig, error) {
+	if config == nil {
+		config = &AirAuthConfig{}
+	}
+	if config.NonceLength <= 0 {
+		config.NonceLength = defaultAirAuthNonceLength
+	}
+	if config.NonceLength > maxAirAuthNonceLength {
+		return nil, errors.New("air auth nonce length is over " + strconv.Itoa(maxAirAuthNonceLength))
+	}
+	if config.NonceSource == nil {
+		config.NonceSource = randomHexNonce
+	}
+	switch config.Version {
+	case "":
+		config.Version = AirAuthVersionV1
+	case AirAuthVersionV1, AirAuthVersionV2:
+	default:
+		return nil, errors.New("unsupported air auth version: " + string(config.Version))
+	}
+	return config, nil
+}
+
+func randomHexNonce(length int) string {
+	buf := make([]byte, (length+1)/2)
+	if _, err := rand.Read(buf); err != nil {
+		// crypto/rand should never fail, fallback to uuid which is also random
+		return uuid.NewString()[:length]
+	}
+	return hex.EncodeToString(buf)[:length]
+}
+
+func (c *httpCaller) withAirAuthHeaders(req *fasthttp.Request, payload signPayload, token string) {
+	var (
+		// Gets the second-level timestamp of the current time.
+		// The server only supports the second-level timestamp.
+		// The 'ts' must be the current time.
+		// When current time exceeds a certain time, such as 5 seconds, of 'ts',
+		// the signature will be invalid and cannot pass authentication
+		ts = strconv.FormatInt(c.signingTime().Unix(), 10)
+		// too long nonce will be wasted.
+		nonce     = c.airAuthConfig.NonceSource(c.airAuthConfig.NonceLength)
+		signature string
+	)
+	// calculate the authentication signature
+	switch {
+	case c.airAuthConfig.Version == AirAuthVersionV2:
+		req.Header.Set(airAuthVersionHeader, string(AirAuthVersionV2))
+		canonicalRequest := buildAirAuthCanonicalRequestV2Of(req, c.tenantID, ts, nonce, payload.hash())
+		signature = CalAirAuthSignatureV2(token, canonicalRequest)
+	case c.airAuthConfig.SignPath:
+		req.Header.Set(airAuthVersionHeader, airAuthVersionV1Path)
+		signature = calAirAuthSignature(token, c.tenantID, payload, ts, nonce, string(req.URI().Path()))
+	default:
+		signature = calAirAuthSignature(token, c.tenantID, payload, ts, nonce, "")
+	}
+	req.Header.Set("Tenant-Ts", ts)
+	req.Header.Set("Tenant-Nonce", nonce)
+	req.Header.Set("Tenant-Signature", signature)
+}
+
+// CalAirAuthSignature calculates the air auth signature, the path is signed only if it is not empty.
+// It can be used by the verification side to recalculate the signature of a request.
+func CalAirAuthSignature(token, tenantID string, reqBytes []byte, ts, nonce, path string) string {
+	return calAirAuthSignature(token, tenantID, bytesPayload(reqBytes), ts, nonce, path)
+}
+
+func calAirAuthSignature(token, tenantID string, payload signPayload, ts, nonce, path string) string {
+	// Splice in the order of "token", "HTTPBody", "tenant_id", "ts", "nonce" and "path".
+	// The order must not be mistaken.
+	// String need to be encoded as byte arrays by UTF-8
+	shaHash := sha256.New()
+	shaHash.Write([]byte(token))
+	payload.write(shaHash)
+	shaHash.Write([]byte(tenantID))
+	shaHash.Write([]byte(ts))
+	shaHash.Write([]byte(nonce))
+	if path != "" {
+		shaHash.Write([]byte(path))
+	}
+	return fmt.Sprintf("%x", shaHash.Sum(nil))
+}
+
+// buildAirAuthCanonicalRequestV2
+// lines of method, path, sorted and escaped query, tenant id, ts, nonce, and hex sha256 of body
+func buildAirAuthCanonicalRequestV2(req *fasthttp.Request, tenantID, ts, nonce string, reqBytes []byte) string {
+	return buildAirAuthCanonicalRequestV2Of(req, tenantID, ts, nonce, hashSHA256(reqBytes))
+}
+
+// buildAirAuthCanonicalRequestV2Of is buildAirAuthCanonicalRequestV2 with the hex sha256 of body
+func buildAirAuthCanonicalRequestV2Of(req *fasthttp.Request, tenantID, ts, nonce, bodyHash string) string {
+	urlQuery := url.Values{}
+	req.URI().QueryArgs().VisitAll(func(key, value []byte) {
+		urlQuery.Add(string(key), string(value))
+	})
+	path := string(req.URI().Path())
+	if path == "" {
+		path = "/"
+	}
+	return concat("\n", string(req.Header.Method()), normURI(path), normQuery(urlQuery.Encode()),
+		tenantID, ts, nonce, bodyHash)
+}
+
+// CalAirAuthSignatureV2 calculates the AirAuthVersionV2 signature of the canonical request
+func CalAirAuthSignatureV2(token, canonicalRequest string) string {
+	return hex.EncodeToString(hmacSHA256([]byte(token), canonicalRequest))
+}
+
+// EqualAirAuthSignature compares signatures in constant time, so that the
+// verification side does not leak the expected signature by timing
+func EqualAirAuthSignature(expected, actual string) bool {
+	return subtle.ConstantTimeCompare([]byte(expected), []byte(actual)) == 1
+}
@@ -0,0 +1,59 @@
+package core
+
+import (
+	"testing"
+
+	"github.com/valyala/fasthttp"
+)
+
+func TestCalAirAuthSignature(t *testing.T) {
+	tests := []struct {
+		name string
+		path string
+		want string
+	}{
+		{
+			name: "without_path",
+			path: "",
+			want: "b8aa5b357865f7545a95430d20bed935da70956da12a79817b1c45d3476e716a",
+		},
+		{
+			name: "with_path",
+			path: "/predict/api/x",
+			want: "bfdfa27ac1d8ca9fb48b3c560e3cf8b5bdf7c23963eab3ff1ca49ff573afa881",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := CalAirAuthSignature("token", "tenant", []byte("{}"), "1700000000", "abcd1234", tt.path)
+			if !EqualAirAuthSignature(tt.want, got) {
+				t.Errorf("CalAirAuthSignature() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestRandomHexNonce(t *testing.T) {
+	for _, length := range []int{1, 8, 15, 64} {
+		if got := randomHexNonce(length); len(got) != length {
+			t.Errorf("randomHexNonce(%d) = %v, length mismatch", length, got)
+		}
+	}
+}
+
+func TestCalAirAuthSignatureV2(t *testing.T) {
+	req := fasthttp.AcquireRequest()
+	defer fasthttp.ReleaseRequest(req)
+	req.Header.SetMethod(fasthttp.MethodPost)
+	req.SetRequestURI("https://byteplus.com/predict/api/x?b=2+3&a=1")
+	canonicalRequest := buildAirAuthCanonicalRequestV2(req, "tenant", "1700000000", "abcd1234", []byte("{}"))
+	wantCanonicalRequest := "POST\n/predict/api/x\na=1&b=2%203\ntenant\n1700000000\nabcd1234\n" +
+		"44136fa355b3678a1146ad16f7e8649e94fb4fc21fe77e8310c060f61caaff8a"
+	if canonicalRequest != wantCanonicalRequest {
+		t.Errorf("buildAirAuthCanonicalRequestV2() = %q, want %q", canonicalRequest, wantCanonicalRequest)
+	}
+	want := "f926227de96d44e5ffebaa0ded44813a8100fd651f8007ef791c50e5465363f8"
+	if got := CalAirAuthSignatureV2("token", canonicalRequest); got != want {
+		t.Errorf("CalAirAuthSignatureV2() = %v, want %v", got, want)
+	}
+}
@@ -0,0 +1,208 @@
+package core
+
+import (
+	"bytes"
+	"errors"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/byteplus-sdk/byteplus-sdk-go-rec-core/logs"
+	"github.com/byteplus-sdk/byteplus-sdk-go-rec-core/metrics"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/reflect/protoreflect"
+)
+
+// BatchMerger merges the bodies of the requests with the same path and content type
+// into the body of one request
+type BatchMerger func(path, contentType string, bodies [][]byte) ([]byte, error)
+
+var errBatchNotMergeable = errors.New("bodies of batch are not mergeable")
+
+// MergeBatchBodies merges the json array bodies into one array, so that the merged
+// body has the same schema as the merged ones. The other bodies, including the pb
+// bodies, whose schema is unknown here, are not mergeable
+func MergeBatchBodies(path, contentType string, bodies [][]byte) ([]byte, error) {
+	if !strings.Contains(contentType, "json") {
+		return nil, errBatchNotMergeable
+	}
+	elements := make([][]byte, 0, len(bodies))
+	for _, body := range bodies {
+		body = bytes.TrimSpace(body)
+		if len(body) < 2 || body[0] != '[' || body[len(body)-1] != ']' {
+			return nil, errBatchNotMergeable
+		}
+		if body = bytes.TrimSpace(body[1 : len(body)-1]); len(body) > 0 {
+			elements = append(elements, body)
+		}
+	}
+	result := append([]byte{'['}, bytes.Join(elements, []byte{','})...)
+	return append(result, ']'), nil
+}
+
+// mergeBatchMessages merges the pb requests of the same message type whose fields
+// are the same except the repeated ones, the repeated fields are appended
+func mergeBatchMessages(messages []proto.Message) ([]byte, error) {
+	shape := batchMessageShape(messages[0])
+	merged := proto.Clone(messages[0])
+	for _, message := range messages[1:] {
+		if !proto.Equal(shape, batchMessageShape(message)) {
+			return nil, errBatchNotMergeable
+		}
+		proto.Merge(merged, message)
+	}
+	return proto.Marshal(merged)
+}
+
+// batchMessageShape returns the message without the repeated fields
+func batchMessageShape(message proto.Message) proto.Message {
+	shape := proto.Clone(message)
+	shape.ProtoReflect().Range(func(fd protoreflect.FieldDescriptor, _ protoreflect.Value) bool {
+		if fd.IsList() {
+			shape.ProtoReflect().Clear(fd)
+		}
+		return true
+	})
+	return shape
+}
+
+// mergeBatch merges the bodies of items by BatchMerger, or by the default merger
+func (q *AsyncWriteQueue) mergeBatch(items []*asyncWriteItem) ([]byte, error) {
+	first := items[0]
+	if q.config.BatchMerger == nil && first.message != nil {
+		messages := make([]proto.Message, len(items))
+		for i, item := range items {
+			if item.message == nil {
+				return nil, errBatchNotMergeable
+			}
+			messages[i] = item.message
+		}
+		return mergeBatchMessages(messages)
+	}
+	bodies := make([][]byte, len(items))
+	for i, item := range items {
+		bodies[i] = item.reqBytes
+	}
+	if q.config.BatchMerger == nil {
+		return MergeBatchBodies(first.path, first.contentType, bodies)
+	}
+	return q.config.BatchMerger(first.path, first.contentType, bodies)
+}
+
+// workBatches collects the requests into batches by path, content type and options,
+// a batch is sent once it is full, or at most FlushInterval later
+func (q *AsyncWriteQueue) workBatches() {
+	batches := make(map[string][]*asyncWriteItem)
+	add := func(item *asyncWriteItem) {
+		key := asyncWriteBatchKey(item)
+		batches[key] = append(batches[key], item)
+		if len(batches[key]) >= q.config.BatchSize {
+			q.sendBatch(batches[key])
+			delete(batches, key)
+		}
+	}
+	flush := func() {
+		for key, items := range batches {
+			q.sendBatch(items)
+			delete(batches, key)
+		}
+	}
+	ticker := time.NewTicker(q.config.FlushInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case item := <-q.items:
+			add(item)
+		case <-ticker.C:
+			flush()
+		case <-q.drain:
+			for {
+				select {
+				case item := <-q.items:
+					add(item)
+				default:
+					flush()
+					return
+				}
+			}
+		}
+	}
+}
+
+// sendBatch merges the requests into one and sends it, the requests are
+// sent one by one if they can not be merged
+func (q *AsyncWriteQueue) sendBatch(items []*asyncWriteItem) {
+	if len(items) == 1 {
+		q.send(items[0])
+		return
+	}
+	first := items[0]
+	var callbacks []AsyncWriteCallback
+	reqIDs := make([]string, len(items))
+	for i, item := range items {
+		callbacks = append(callbacks, item.callbacks...)
+		reqIDs[i] = item.options.RequestID
+	}
+	reqBytes, err := q.mergeBatch(items)
+	if err != nil {
+		logs.Warn("merge async write batch fail, send one by one, path:%s err:%v", first.path, err)
+		for _, item := range items {
+			q.send(item)
+		}
+		return
+	}
+	options := copyOptions(first.options)
+	// the batch is a new request, its id is derived from the id of the first request, so
+	// that it can be correlated with the merged requests, and is idempotent when resent
+	options.RequestID = first.options.RequestID + "-batch"
+	metricsTags := []string{
+		"project_id:" + q.client.projectID,
+		"path:" + escapeMetricsTagValue(first.path),
+	}
+	metrics.Counter(metricsKeyAsyncWriteBatch, int64(len(items)), metricsTags...)
+	logs.Debug("send async write batch, path:%s size:%d request_id:%s merged_request_ids:%v",
+		first.path, len(items), options.RequestID, reqIDs)
+	q.send(&asyncWriteItem{
+		path:        first.path,
+		contentType: first.contentType,
+		reqBytes:    reqBytes,
+		options:     options,
+		callbacks:   callbacks,
+	})
+}
+
+// asyncWriteBatchKey returns the key of the requests which can be merged,
+// they have the same path, content type, credentials and options except request id
+func asyncWriteBatchKey(item *asyncWriteItem) string {
+	parts := []string{item.path, item.contentType,
+		strconv.FormatInt(int64(item.options.Timeout), 10),
+		strconv.FormatInt(int64(item.options.ServerTimeout), 10),
+		item.options.Method,
+		"a:" + item.options.AirAuthToken}
+	if cred := item.options.Credential; cred != nil {
+		parts = append(parts, "c:"+hashSHA256([]byte(cred.AccessKeyID+"\n"+cred.SecretAccessKey+"\n"+cred.SessionToken)))
+	}
+	parts = append(parts, sortedPairs("h:", item.options.Headers)...)
+	parts = append(parts, sortedPairs("q:", item.options.Queries)...)
+	queryNames := make([]string, 0, len(item.options.QueryValues))
+	for name := range item.options.QueryValues {
+		queryNames = append(queryNames, name)
+	}
+	sort.Strings(queryNames)
+	for _, name := range queryNames {
+		for _, v := range item.options.QueryValues[name] {
+			parts = append(parts, "qv:"+name+"="+v)
+		}
+	}
+	return strings.Join(parts, "\n")
+}
+
+func sortedPairs(prefix string, values map[string]string) []string {
+	pairs := make([]string, 0, len(values))
+	for k, v := range values {
+		pairs = append(pairs, prefix+k+"="+v)
+	}
+	sort.Strings(pairs)
+	return pairs
+}
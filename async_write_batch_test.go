@@ -0,0 +1,116 @@
+package core
+
+import (
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/byteplus-sdk/byteplus-sdk-go-rec-core/option"
+	"github.com/valyala/fasthttp"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/types/descriptorpb"
+)
+
+func TestMergeBatchBodies(t *testing.T) {
+	tests := []struct {
+		name        string
+		contentType string
+		bodies      []string
+		want        string
+		wantErr     bool
+	}{
+		{"json arrays", "application/json", []string{`[{"a":1},{"b":2}]`, `[]`, ` [{"c":3}] `},
+			`[{"a":1},{"b":2},{"c":3}]`, false},
+		// the merged array is of a different schema from the objects
+		{"json objects", "application/json", []string{`[{"a":1}]`, `{"b":2}`}, "", true},
+		{"pb", "application/x-protobuf", []string{"ab", "cd"}, "", true},
+		{"csv", "text/csv", []string{"a", "b"}, "", true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			bodies := make([][]byte, len(tt.bodies))
+			for i, body := range tt.bodies {
+				bodies[i] = []byte(body)
+			}
+			got, err := MergeBatchBodies("/write", tt.contentType, bodies)
+			if (err != nil) != tt.wantErr || string(got) != tt.want {
+				t.Errorf("MergeBatchBodies() = %s, %v, want %s", got, err, tt.want)
+			}
+		})
+	}
+
+}
+
+func TestMergeBatchMessages(t *testing.T) {
+	newRequest := func(name string, messageTypes ...string) *descriptorpb.FileDescriptorProto {
+		request := &descriptorpb.FileDescriptorProto{Name: proto.String(name)}
+		for _, messageType := range messageTypes {
+			request.MessageType = append(request.MessageType, &descriptorpb.DescriptorProto{Name: proto.String(messageType)})
+		}
+		return request
+	}
+	merged, err := mergeBatchMessages([]proto.Message{newRequest("a", "x"), newRequest("a", "y", "z")})
+	request := &descriptorpb.FileDescriptorProto{}
+	if err != nil || proto.Unmarshal(merged, request) != nil || !proto.Equal(request, newRequest("a", "x", "y", "z")) {
+		t.Errorf("mergeBatchMessages() = %v, err:%v, want the repeated fields appended", request, err)
+	}
+	// the requests with different fields other than the repeated ones are not merged
+	if _, err = mergeBatchMessages([]proto.Message{newRequest("a", "x"), newRequest("b", "y")}); err == nil {
+		t.Errorf("mergeBatchMessages() of different shapes should fail")
+	}
+}
+
+func TestAsyncWriteQueue_batch(t *testing.T) {
+	var lock sync.Mutex
+	var bodies, reqIDs []string
+	transport := TransportFunc(func(request *fasthttp.Request, response *fasthttp.Response, deadline time.Time) error {
+		lock.Lock()
+		bodies = append(bodies, string(request.Body()))
+		reqIDs = append(reqIDs, string(request.Header.Peek("Request-Id")))
+		lock.Unlock()
+		response.SetStatusCode(fasthttp.StatusOK)
+		return nil
+	})
+	airAuthConfig, _ := fillDefaultAirAuthConfig(nil)
+	availabler := &staticHostAvailabler{hosts: []string{"host"}}
+	client := &HTTPClient{
+		cli: newHTTPCaller("project", "tenant", true, "token", airAuthConfig, credential{},
+			availabler, &CallerConfig{DisableCompression: true}, "http", false, transport),
+		hostAvailabler: availabler,
+		schema:         "http",
+		projectID:      "project",
+	}
+	queue, err := NewAsyncWriteQueue(client, &AsyncWriteQueueConfig{BatchSize: 2, FlushInterval: 20 * time.Millisecond})
+	if err != nil {
+		t.Fatalf("NewAsyncWriteQueue() error = %v", err)
+	}
+	var succeeded int64
+	callback := func(err error) {
+		if err == nil {
+			atomic.AddInt64(&succeeded, 1)
+		}
+	}
+	for i := 0; i < 3; i++ {
+		err = queue.EnqueueJSONRequest("/write", []map[string]int{{"i": i}},
+			&option.Options{RequestID: strconv.Itoa(i)}, callback)
+		if err != nil {
+			t.Fatalf("EnqueueJSONRequest() error = %v", err)
+		}
+	}
+	// the last request is sent alone after the flush interval
+	time.Sleep(100 * time.Millisecond)
+	queue.Shutdown()
+	lock.Lock()
+	defer lock.Unlock()
+	if len(bodies) != 2 || bodies[0] != `[{"i":0},{"i":1}]` || bodies[1] != `[{"i":2}]` {
+		t.Errorf("sent bodies = %v", bodies)
+	}
+	if len(reqIDs) != 2 || reqIDs[0] != "0-batch" || reqIDs[1] != "2" {
+		t.Errorf("sent request ids = %v, want the batch correlated with its first request", reqIDs)
+	}
+	if got := atomic.LoadInt64(&succeeded); got != 3 {
+		t.Errorf("succeeded callbacks = %d, want 3", got)
+	}
+}
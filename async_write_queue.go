@@ -0,0 +1,417 @@
+package core
+
+import (
+	"encoding/json"
+	"errors"
+	"sync"
+	"time"
+
+	"github.com/byteplus-sdk/byteplus-sdk-go-rec-core/logs"
+	"github.com/byteplus-sdk/byteplus-sdk-go-rec-core/metrics"
+	"github.com/byteplus-sdk/byteplus-sdk-go-rec-core/option"
+	"github.com/google/uuid"
+	"google.golang.org/protobuf/proto"
+)
+
+const (
+	defaultAsyncWriteQueueSize     = 1000
+	defaultAsyncWriteWorkers       = 1
+	defaultAsyncWriteDrainTimeout  = 5 * time.Second
+	defaultAsyncWriteFlushInterval = time.Second
+)
+
+var (
+	ErrAsyncWriteQueueFull   = errors.New("async write queue is full")
+	ErrAsyncWriteQueueClosed = errors.New("async write queue is closed")
+	// ErrAsyncWriteDropped is passed to the callback of the request dropped from the queue
+	ErrAsyncWriteDropped = errors.New("async write request is dropped")
+	// ErrAsyncWriteSpooled is passed to the callback of the request persisted to spool
+	// instead of being sent, it is replayed later without calling the callback again
+	ErrAsyncWriteSpooled = errors.New("async write request is spooled")
+	// ErrAsyncWriteCredentialOverride is returned when enqueueing the request with the
+	// credentials of option.WithCredential or option.WithAirAuthToken into the queue
+	// with spool, the spooled requests would be replayed with the client's credentials
+	ErrAsyncWriteCredentialOverride = errors.New("async write request with credential override can not be spooled")
+)
+
+// AsyncWriteCallback is called once the enqueued request is sent, err is nil if it
+// succeeds. It is called by the worker goroutines, so it should not block. The requests
+// persisted to spool are called with ErrAsyncWriteSpooled, and replayed later without
+// calling it again
+type AsyncWriteCallback func(err error)
+
+// OverflowPolicy decides what to do when enqueueing into a full AsyncWriteQueue
+type OverflowPolicy int
+
+const (
+	// OverflowPolicyBlock blocks the caller until there is room in the queue
+	OverflowPolicyBlock OverflowPolicy = iota
+	// OverflowPolicyDropOldest drops the oldest pending request to make room
+	OverflowPolicyDropOldest
+	// OverflowPolicyError rejects the request with ErrAsyncWriteQueueFull
+	OverflowPolicyError
+)
+
+type AsyncWriteQueueConfig struct {
+	// The max number of pending requests, default is 1000
+	QueueSize int
+	// The number of goroutines sending requests, default is 1
+	Workers int
+	// Behavior when the queue is full, default is OverflowPolicyBlock
+	OverflowPolicy OverflowPolicy
+	// The max time Shutdown waits for pending requests to be sent, default is 5s
+	DrainTimeout time.Duration
+	// The directory of the on-disk spool, spool is disabled if empty.
+	// When enabled, the requests failed to send, dropped by OverflowPolicyDropOldest,
+	// or still pending after DrainTimeout are persisted to the spool, and are
+	// replayed periodically, including after the process restarts. The spooled requests
+	// are replayed with the client's credentials, so the requests with the credentials
+	// of option.WithCredential and option.WithAirAuthToken are rejected with
+	// ErrAsyncWriteCredentialOverride
+	SpoolDir string
+	// The max bytes of a spool segment file, default is 64MB
+	SpoolSegmentMaxBytes int64
+	// The interval of replaying spooled requests, default is 30s
+	SpoolReplayInterval time.Duration
+	// The max times of replaying a spooled request failed by itself, such as rejected
+	// as invalid, it is moved aside into the ".dead" file of its segment then, so that
+	// the later requests are not blocked. The failures of network, throttling and auth
+	// are not counted. Default is 5
+	SpoolMaxReplayTimes int
+	// The max number of requests merged into one request by BatchMerger,
+	// default is 1, means the requests are sent one by one
+	BatchSize int
+	// The max time a request waits for the batch to be full, default is 1s
+	FlushInterval time.Duration
+	// BatchMerger merges the bodies of the batched requests. By default, the pb
+	// requests of the same message type and the same fields except the repeated
+	// ones are merged by appending their repeated fields, and the json array bodies
+	// are merged by MergeBatchBodies. The others are sent one by one
+	BatchMerger BatchMerger
+}
+
+func fillDefaultAsyncWriteQueueConfig(config *AsyncWriteQueueConfig) *AsyncWriteQueueConfig {
+	if config == nil {
+		config = &AsyncWriteQueueConfig{}
+	}
+	if config.QueueSize <= 0 {
+		config.QueueSize = defaultAsyncWriteQueueSize
+	}
+	if config.Workers <= 0 {
+		config.Workers = defaultAsyncWriteWorkers
+	}
+	if config.DrainTimeout <= 0 {
+		config.DrainTimeout = defaultAsyncWriteDrainTimeout
+	}
+	if config.SpoolReplayInterval <= 0 {
+		config.SpoolReplayInterval = defaultSpoolReplayInterval
+	}
+	if config.BatchSize <= 0 {
+		config.BatchSize = 1
+	}
+	if config.FlushInterval <= 0 {
+		config.FlushInterval = defaultAsyncWriteFlushInterval
+	}
+	return config
+}
+
+type asyncWriteItem struct {
+	path        string
+	contentType string
+	reqBytes    []byte
+	options     *option.Options
+	// not persisted by spool
+	callbacks []AsyncWriteCallback
+	// the pb request merged by the default batch merger, not persisted by spool
+	message proto.Message
+}
+
+// AsyncWriteQueue is a bounded queue sending write requests in background,
+// so that the callers are not blocked by network io.
+type AsyncWriteQueue struct {
+	client       *HTTPClient
+	config       *AsyncWriteQueueConfig
+	items        chan *asyncWriteItem
+	spool        *writeSpool
+	lock         sync.RWMutex
+	closed       bool
+	stop         chan struct{}
+	drain        chan struct{}
+	shutdownOnce sync.Once
+	wg           sync.WaitGroup
+	// waits for the replay of spool, so that it does not race the close of spool
+	replayWG sync.WaitGroup
+}
+
+func NewAsyncWriteQueue(client *HTTPClient, config *AsyncWriteQueueConfig) (*AsyncWriteQueue, error) {
+	config = fillDefaultAsyncWriteQueueConfig(config)
+	queue := &AsyncWriteQueue{
+		client: client,
+		config: config,
+		items:  make(chan *asyncWriteItem, config.QueueSize),
+		stop:   make(chan struct{}),
+		drain:  make(chan struct{}),
+	}
+	if config.SpoolDir != "" {
+		spool, err := newWriteSpool(config.SpoolDir, config.SpoolSegmentMaxBytes, config.SpoolMaxReplayTimes)
+		if err != nil {
+			return nil, err
+		}
+		queue.spool = spool
+		queue.replayWG.Add(1)
+		AsyncExecute(queue.scheduleReplaySpool)
+	}
+	for i := 0; i < config.Workers; i++ {
+		queue.wg.Add(1)
+		AsyncExecute(queue.work)
+	}
+	return queue, nil
+}
+
+// EnqueuePBRequest marshals the request and puts it into the queue,
+// callbacks are called once the request is sent or dropped
+func (q *AsyncWriteQueue) EnqueuePBRequest(path string, request proto.Message, options *option.Options,
+	callbacks ...AsyncWriteCallback) error {
+	reqBytes, err := proto.Marshal(request)
+	if err != nil {
+		return err
+	}
+	var message proto.Message
+	if q.config.BatchSize > 1 && q.config.BatchMerger == nil {
+		// the caller may reuse the request after enqueueing
+		message = proto.Clone(request)
+	}
+	return q.enqueue(path, "application/x-protobuf", reqBytes, message, options, callbacks)
+}
+
+// EnqueueJSONRequest marshals the request and puts it into the queue,
+// callbacks are called once the request is sent or dropped
+func (q *AsyncWriteQueue) EnqueueJSONRequest(path string, request interface{}, options *option.Options,
+	callbacks ...AsyncWriteCallback) error {
+	reqBytes, err := json.Marshal(request)
+	if err != nil {
+		return err
+	}
+	return q.enqueue(path, "application/json", reqBytes, nil, options, callbacks)
+}
+
+// Len returns the number of pending requests
+func (q *AsyncWriteQueue) Len() int {
+	return len(q.items)
+}
+
+func (q *AsyncWriteQueue) enqueue(path, contentType string, reqBytes []byte, message proto.Message,
+	options *option.Options, callbacks []AsyncWriteCallback) error {
+	if q.spool != nil && options != nil && (options.Credential != nil || options.AirAuthToken != "") {
+		return ErrAsyncWriteCredentialOverride
+	}
+	item := &asyncWriteItem{
+		path:        path,
+		contentType: contentType,
+		reqBytes:    reqBytes,
+		options:     q.detachOptions(options),
+		callbacks:   callbacks,
+		message:     message,
+	}
+	q.lock.RLock()
+	defer q.lock.RUnlock()
+	if q.closed {
+		return ErrAsyncWriteQueueClosed
+	}
+	switch q.config.OverflowPolicy {
+	case OverflowPolicyDropOldest:
+		for {
+			select {
+			case q.items <- item:
+				return nil
+			default:
+			}
+			select {
+			case dropped := <-q.items:
+				q.reportDropped(dropped, "drop_oldest")
+			default:
+			}
+		}
+	case OverflowPolicyError:
+		select {
+		case q.items <- item:
+			return nil
+		default:
+			q.reportDropped(item, "queue_full")
+			return ErrAsyncWriteQueueFull
+		}
+	default:
+		select {
+		case q.items <- item:
+			return nil
+		case <-q.stop:
+			return ErrAsyncWriteQueueClosed
+		}
+	}
+}
+
+// detachOptions
+// the options are used after the caller returns, so keep a private copy without
+// the caller's context, and fix the request id, so that the request is idempotent when resent
+func (q *AsyncWriteQueue) detachOptions(options *option.Options) *option.Options {
+	result := copyOptions(options)
+	result.Context = nil
+	if result.RequestID == "" {
+		result.RequestID = uuid.NewString()
+	}
+	return result
+}
+
+func (q *AsyncWriteQueue) work() {
+	defer q.wg.Done()
+	if q.config.BatchSize > 1 {
+		q.workBatches()
+		return
+	}
+	for {
+		select {
+		case item := <-q.items:
+			q.send(item)
+		case <-q.drain:
+			for {
+				select {
+				case item := <-q.items:
+					q.send(item)
+				default:
+					return
+				}
+			}
+		}
+	}
+}
+
+func (q *AsyncWriteQueue) send(item *asyncWriteItem) {
+	err := q.doSend(item)
+	if err != nil {
+		metricsTags := []string{
+			"type:async_write_fail",
+			"project_id:" + q.client.projectID,
+			"path:" + escapeMetricsTagValue(item.path),
+		}
+		metrics.Counter(metricsKeyCommonError, 1, metricsTags...)
+		metrics.Error(item.options.RequestID, "[ByteplusSDK] async write fail, project_id:%s, path:%s, err:%v",
+			q.client.projectID, item.path, err)
+		logs.Error("async write fail, path:%s request_id:%s err:%v", item.path, item.options.RequestID, err)
+		q.saveToSpool(item)
+	}
+	item.callback(err)
+}
+
+func (item *asyncWriteItem) callback(err error) {
+	for _, callback := range item.callbacks {
+		callback(err)
+	}
+}
+
+func (q *AsyncWriteQueue) doSend(item *asyncWriteItem) error {
+	_, err := q.client.DoRawRequest(item.path, item.contentType, item.reqBytes, item.options)
+	return err
+}
+
+// saveToSpool persists the request if spool is enabled, returns false if the request is lost
+func (q *AsyncWriteQueue) saveToSpool(item *asyncWriteItem) bool {
+	if q.spool == nil {
+		return false
+	}
+	if err := q.spool.append(item); err != nil {
+		metricsTags := []string{
+			"type:async_write_spool_fail",
+			"project_id:" + q.client.projectID,
+			"path:" + escapeMetricsTagValue(item.path),
+		}
+		metrics.Counter(metricsKeyCommonError, 1, metricsTags...)
+		logs.Error("save request to spool fail, path:%s request_id:%s err:%v",
+			item.path, item.options.RequestID, err)
+		return false
+	}
+	metricsTags := []string{
+		"project_id:" + q.client.projectID,
+		"path:" + escapeMetricsTagValue(item.path),
+	}
+	metrics.Counter(metricsKeyAsyncWriteSpooled, 1, metricsTags...)
+	return true
+}
+
+func (q *AsyncWriteQueue) scheduleReplaySpool() {
+	defer q.replayWG.Done()
+	q.spool.replay(q.doSend, q.stop)
+	ticker := time.NewTicker(q.config.SpoolReplayInterval)
+	for {
+		select {
+		case <-q.stop:
+			ticker.Stop()
+			return
+		case <-ticker.C:
+			q.spool.replay(q.doSend, q.stop)
+		}
+	}
+}
+
+func (q *AsyncWriteQueue) reportDropped(item *asyncWriteItem, reason string) {
+	if reason != "queue_full" && q.saveToSpool(item) {
+		item.callback(ErrAsyncWriteSpooled)
+		return
+	}
+	// the caller gets ErrAsyncWriteQueueFull directly
+	if reason != "queue_full" {
+		item.callback(ErrAsyncWriteDropped)
+	}
+	metricsTags := []string{
+		"type:" + reason,
+		"project_id:" + q.client.projectID,
+		"path:" + escapeMetricsTagValue(item.path),
+	}
+	metrics.Counter(metricsKeyAsyncWriteDropped, 1, metricsTags...)
+	logs.Warn("async write request is dropped, reason:%s path:%s request_id:%s",
+		reason, item.path, item.options.RequestID)
+}
+
+// spoolPending persists the requests which are not sent in time
+func (q *AsyncWriteQueue) spoolPending() {
+	if q.spool == nil {
+		return
+	}
+	for {
+		select {
+		case item := <-q.items:
+			if q.saveToSpool(item) {
+				item.callback(ErrAsyncWriteSpooled)
+			}
+		default:
+			return
+		}
+	}
+}
+
+// Shutdown stops accepting new requests, and waits for pending requests
+// to be sent at most DrainTimeout, the unsent requests are persisted if spool is enabled
+func (q *AsyncWriteQueue) Shutdown() {
+	q.shutdownOnce.Do(func() {
+		// wake up the callers blocked on a full queue
+		close(q.stop)
+		q.lock.Lock()
+		q.closed = true
+		q.lock.Unlock()
+		close(q.drain)
+		done := make(chan struct{})
+		go func() {
+			q.wg.Wait()
+			close(done)
+		}()
+		select {
+		case <-done:
+		case <-time.After(q.config.DrainTimeout):
+			logs.Warn("async write queue drain timeout, %d requests are pending", q.Len())
+			q.spoolPending()
+		}
+		if q.spool != nil {
+			q.replayWG.Wait()
+			q.spool.close()
+		}
+	})
+}
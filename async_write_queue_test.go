@@ -0,0 +1,140 @@
+package core
+
+import (
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/byteplus-sdk/byteplus-sdk-go-rec-core/option"
+	"github.com/valyala/fasthttp"
+)
+
+// newBlockingQueueClient returns the client whose requests are blocked until release is closed
+func newBlockingQueueClient(sent chan<- string, release <-chan struct{}) *HTTPClient {
+	transport := TransportFunc(func(request *fasthttp.Request, response *fasthttp.Response, deadline time.Time) error {
+		<-release
+		sent <- string(request.Header.Peek("Request-Id"))
+		response.SetStatusCode(fasthttp.StatusOK)
+		return nil
+	})
+	airAuthConfig, _ := fillDefaultAirAuthConfig(nil)
+	availabler := &staticHostAvailabler{hosts: []string{"host"}}
+	return &HTTPClient{
+		cli: newHTTPCaller("project", "tenant", true, "token", airAuthConfig, credential{},
+			availabler, &CallerConfig{DisableCompression: true}, "http", false, transport),
+		hostAvailabler: availabler,
+		schema:         "http",
+		projectID:      "project",
+	}
+}
+
+func TestAsyncWriteQueue_overflow(t *testing.T) {
+	tests := []struct {
+		name    string
+		policy  OverflowPolicy
+		spool   bool
+		wantErr error
+		// the request ids sent, the first one is taken by the worker before overflow
+		wantSent    []string
+		wantDropped []error
+	}{
+		{"error", OverflowPolicyError, false, ErrAsyncWriteQueueFull, []string{"0", "1"}, nil},
+		{"drop oldest", OverflowPolicyDropOldest, false, nil, []string{"0", "2"},
+			[]error{ErrAsyncWriteDropped}},
+		{"drop oldest to spool", OverflowPolicyDropOldest, true, nil, []string{"0", "2"},
+			[]error{ErrAsyncWriteSpooled}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			sent := make(chan string, 3)
+			release := make(chan struct{})
+			config := &AsyncWriteQueueConfig{QueueSize: 1, OverflowPolicy: tt.policy}
+			if tt.spool {
+				config.SpoolDir = t.TempDir()
+			}
+			queue, err := NewAsyncWriteQueue(newBlockingQueueClient(sent, release), config)
+			if err != nil {
+				t.Fatalf("NewAsyncWriteQueue() error = %v", err)
+			}
+			var lock sync.Mutex
+			var dropped []error
+			callback := func(err error) {
+				if err != nil {
+					lock.Lock()
+					dropped = append(dropped, err)
+					lock.Unlock()
+				}
+			}
+			_ = queue.EnqueueJSONRequest("/write", 0, &option.Options{RequestID: "0"}, callback)
+			// wait for the worker to take the first request
+			for queue.Len() > 0 {
+				time.Sleep(time.Millisecond)
+			}
+			_ = queue.EnqueueJSONRequest("/write", 1, &option.Options{RequestID: "1"}, callback)
+			err = queue.EnqueueJSONRequest("/write", 2, &option.Options{RequestID: "2"}, callback)
+			if err != tt.wantErr {
+				t.Errorf("enqueue into full queue error = %v, want %v", err, tt.wantErr)
+			}
+			close(release)
+			queue.Shutdown()
+			close(sent)
+			var got []string
+			for reqID := range sent {
+				got = append(got, reqID)
+			}
+			if len(got) != len(tt.wantSent) || got[0] != tt.wantSent[0] || got[1] != tt.wantSent[1] {
+				t.Errorf("sent = %v, want %v", got, tt.wantSent)
+			}
+			lock.Lock()
+			defer lock.Unlock()
+			if len(dropped) != len(tt.wantDropped) || (len(dropped) > 0 && dropped[0] != tt.wantDropped[0]) {
+				t.Errorf("dropped callbacks = %v, want %v", dropped, tt.wantDropped)
+			}
+		})
+	}
+}
+
+func TestAsyncWriteQueue_shutdown(t *testing.T) {
+	sent := make(chan string, 2)
+	release := make(chan struct{})
+	close(release)
+	queue, err := NewAsyncWriteQueue(newBlockingQueueClient(sent, release), nil)
+	if err != nil {
+		t.Fatalf("NewAsyncWriteQueue() error = %v", err)
+	}
+	for _, reqID := range []string{"0", "1"} {
+		if err = queue.EnqueueJSONRequest("/write", reqID, &option.Options{RequestID: reqID}); err != nil {
+			t.Fatalf("EnqueueJSONRequest() error = %v", err)
+		}
+	}
+	// the pending requests are drained before Shutdown returns
+	queue.Shutdown()
+	if len(sent) != 2 {
+		t.Errorf("sent %d requests, want 2 drained", len(sent))
+	}
+	if err = queue.EnqueueJSONRequest("/write", 2, &option.Options{}); err != ErrAsyncWriteQueueClosed {
+		t.Errorf("enqueue after shutdown error = %v, want ErrAsyncWriteQueueClosed", err)
+	}
+}
+
+func TestAsyncWriteQueue_credentialOverride(t *testing.T) {
+	sent := make(chan string, 1)
+	release := make(chan struct{})
+	close(release)
+	queue, err := NewAsyncWriteQueue(newBlockingQueueClient(sent, release),
+		&AsyncWriteQueueConfig{SpoolDir: t.TempDir()})
+	if err != nil {
+		t.Fatalf("NewAsyncWriteQueue() error = %v", err)
+	}
+	defer queue.Shutdown()
+	// the spooled requests are replayed with the client's credentials
+	for _, opt := range []option.Option{
+		option.WithSessionCredential("ak", "sk", "token"),
+		option.WithAirAuthToken("token"),
+	} {
+		err = queue.EnqueueJSONRequest("/write", 0, option.Conv2Options(opt))
+		if err != ErrAsyncWriteCredentialOverride {
+			t.Errorf("EnqueueJSONRequest() error = %v, want ErrAsyncWriteCredentialOverride", err)
+		}
+	}
+}
@@ -0,0 +1,216 @@
+package core
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/byteplus-sdk/byteplus-sdk-go-rec-core/logs"
+	"github.com/byteplus-sdk/byteplus-sdk-go-rec-core/metrics"
+	"github.com/valyala/fasthttp"
+)
+
+// AuditRecord records one attempt of request sent to server. The records are
+// hash-chained: Hash covers PrevHash and all the other fields, so modifying,
+// inserting or deleting a record breaks the chain, see VerifyAuditLog
+type AuditRecord struct {
+	Timestamp time.Time `json:"timestamp"`
+	// The access key id, or "air_auth" for the requests authorized by token
+	Identity  string `json:"identity"`
+	TenantID  string `json:"tenant_id"`
+	ProjectID string `json:"project_id"`
+	Path      string `json:"path"`
+	// The hex sha256 of the request body before compression
+	RequestHash string `json:"request_hash"`
+	// The http status of the response, it is 0 if no response is received
+	Status    int    `json:"status"`
+	RequestID string `json:"request_id"`
+	PrevHash  string `json:"prev_hash"`
+	Hash      string `json:"hash,omitempty"`
+}
+
+// AuditSink persists the audit records, Write is called with one record at a time in order
+type AuditSink interface {
+	Write(record *AuditRecord) error
+}
+
+// AuditChainResumer is implemented by the AuditSink which keeps records of the
+// previous processes, the chain continues from its LastHash
+type AuditChainResumer interface {
+	LastHash() string
+}
+
+func (r *AuditRecord) computeHash() string {
+	copied := *r
+	copied.Hash = ""
+	data, _ := json.Marshal(&copied)
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:])
+}
+
+type auditLogger struct {
+	sink     AuditSink
+	lock     sync.Mutex
+	lastHash string
+}
+
+func newAuditLogger(sink AuditSink) *auditLogger {
+	logger := &auditLogger{sink: sink}
+	if resumer, ok := sink.(AuditChainResumer); ok {
+		logger.lastHash = resumer.LastHash()
+	}
+	return logger
+}
+
+func (l *auditLogger) log(record *AuditRecord) error {
+	l.lock.Lock()
+	defer l.lock.Unlock()
+	record.PrevHash = l.lastHash
+	record.Hash = record.computeHash()
+	if err := l.sink.Write(record); err != nil {
+		return err
+	}
+	l.lastHash = record.Hash
+	return nil
+}
+
+// auditAttempt records an attempt of request, err is the error of the attempt
+func (c *httpCaller) auditAttempt(reqID, url string, reqBytes []byte,
+	rspHeader *fasthttp.ResponseHeader, err error) {
+	// the requests rejected on client side are not sent
+	if c.audit == nil || err == ErrClientThrottled || err == ErrQuotaRejected {
+		return
+	}
+	identity := c.credentials.accessKeyID
+	if c.credentialsCache != nil {
+		identity = c.credentialsCache.accessKeyID()
+	}
+	if c.useAirAuth {
+		identity = "air_auth"
+	}
+	if c.tokenSource != nil {
+		identity = "bearer_token"
+	}
+	status := fasthttp.StatusOK
+	var statusErr *StatusError
+	if errors.As(err, &statusErr) {
+		status = statusErr.Status
+	} else if err != nil {
+		status = 0
+	} else if rspHeader != nil {
+		status = rspHeader.StatusCode()
+	}
+	requestHash := sha256.Sum256(reqBytes)
+	record := &AuditRecord{
+		Timestamp:   time.Now(),
+		Identity:    identity,
+		TenantID:    c.tenantID,
+		ProjectID:   c.projectID,
+		Path:        pathOfURL(url),
+		RequestHash: hex.EncodeToString(requestHash[:]),
+		Status:      status,
+		RequestID:   reqID,
+	}
+	if auditErr := c.audit.log(record); auditErr != nil {
+		metricsTags := []string{
+			"type:write_audit_record_fail",
+			"project_id:" + c.projectID,
+		}
+		metrics.Counter(metricsKeyCommonError, 1, metricsTags...)
+		logs.Error("write audit record fail, request_id:%s err:%v", reqID, auditErr)
+	}
+}
+
+// FileAuditSink appends the records to a file as json lines
+type FileAuditSink struct {
+	lock     sync.Mutex
+	file     *os.File
+	lastHash string
+}
+
+// NewFileAuditSink opens or creates the file, the chain continues from its last record
+func NewFileAuditSink(path string) (*FileAuditSink, error) {
+	file, err := os.OpenFile(path, os.O_CREATE|os.O_RDWR|os.O_APPEND, 0600)
+	if err != nil {
+		return nil, err
+	}
+	sink := &FileAuditSink{file: file}
+	scanner := bufio.NewScanner(file)
+	scanner.Buffer(nil, 1<<20)
+	for scanner.Scan() {
+		line := bytes.TrimSpace(scanner.Bytes())
+		if len(line) == 0 {
+			continue
+		}
+		record := &AuditRecord{}
+		if err = json.Unmarshal(line, record); err != nil {
+			file.Close()
+			return nil, fmt.Errorf("invalid audit record in %s: %w", path, err)
+		}
+		sink.lastHash = record.Hash
+	}
+	if err = scanner.Err(); err != nil {
+		file.Close()
+		return nil, err
+	}
+	return sink, nil
+}
+
+func (s *FileAuditSink) LastHash() string {
+	return s.lastHash
+}
+
+func (s *FileAuditSink) Write(record *AuditRecord) error {
+	data, err := json.Marshal(record)
+	if err != nil {
+		return err
+	}
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	if _, err = s.file.Write(append(data, '\n')); err != nil {
+		return err
+	}
+	s.lastHash = record.Hash
+	return nil
+}
+
+func (s *FileAuditSink) Close() error {
+	return s.file.Close()
+}
+
+// VerifyAuditLog verifies the hash chain of the json lines audit log,
+// returns the number of verified records, and the error at the first broken record
+func VerifyAuditLog(reader io.Reader) (int, error) {
+	scanner := bufio.NewScanner(reader)
+	scanner.Buffer(nil, 1<<20)
+	count := 0
+	prevHash := ""
+	for scanner.Scan() {
+		line := bytes.TrimSpace(scanner.Bytes())
+		if len(line) == 0 {
+			continue
+		}
+		record := &AuditRecord{}
+		if err := json.Unmarshal(line, record); err != nil {
+			return count, fmt.Errorf("invalid audit record %d: %w", count+1, err)
+		}
+		// the first record may continue the chain of a rotated log
+		if count > 0 && record.PrevHash != prevHash {
+			return count, fmt.Errorf("audit record %d is not chained to the previous one", count+1)
+		}
+		if record.computeHash() != record.Hash {
+			return count, fmt.Errorf("audit record %d is modified", count+1)
+		}
+		prevHash = record.Hash
+		count++
+	}
+	return count, scanner.Err()
+}
@@ -0,0 +1,45 @@
+package core
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestAuditLog(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "audit.log")
+	sink, err := NewFileAuditSink(path)
+	if err != nil {
+		t.Fatalf("NewFileAuditSink() err:%v", err)
+	}
+	c := &httpCaller{tenantID: "t", projectID: "p", credentials: credential{accessKeyID: "ak"},
+		audit: newAuditLogger(sink)}
+	c.auditAttempt("req-1", "https://byteplus.com/predict", []byte("body"), nil, nil)
+	c.auditAttempt("req-2", "https://byteplus.com/predict", []byte("body"), nil, &StatusError{Status: 429})
+	sink.Close()
+	// the chain continues after reopen
+	sink, err = NewFileAuditSink(path)
+	if err != nil {
+		t.Fatalf("NewFileAuditSink() err:%v", err)
+	}
+	c.audit = newAuditLogger(sink)
+	c.auditAttempt("req-3", "https://byteplus.com/predict", nil, nil, ErrClientThrottled)
+	c.auditAttempt("req-4", "https://byteplus.com/write", nil, nil, os.ErrDeadlineExceeded)
+	sink.Close()
+
+	data, _ := os.ReadFile(path)
+	count, err := VerifyAuditLog(bytes.NewReader(data))
+	if err != nil || count != 3 {
+		t.Fatalf("VerifyAuditLog() = %d, %v, want 3 records", count, err)
+	}
+	tampered := strings.Replace(string(data), `"status":429`, `"status":200`, 1)
+	if _, err = VerifyAuditLog(strings.NewReader(tampered)); err == nil {
+		t.Errorf("modified record should be detected")
+	}
+	lines := strings.SplitN(string(data), "\n", 2)
+	if _, err = VerifyAuditLog(strings.NewReader(lines[0] + "\n" + lines[1][strings.Index(lines[1], "\n")+1:])); err == nil {
+		t.Errorf("deleted record should be detected")
+	}
+}
@@ -5,6 +5,7 @@ import (
 	"crypto/sha256"
 	"encoding/hex"
 	"fmt"
+	"io"
 	"net/url"
 	"sort"
 	"strings"
@@ -41,11 +42,26 @@ var now = func() time.Time {
 func sign(req *fasthttp.Request, cred credential) *fasthttp.Request {
 	prepareRequestV4(req)
 
+	explanation, meta := calSignatureV4(req, cred)
+
+	req.Header.Set("Authorization", buildAuthHeader(explanation.Signature, meta, cred))
+
+	if cred.sessionToken != "" {
+		req.Header.Set("X-Security-Token", cred.sessionToken)
+	}
+
+	return req
+}
+
+// calSignatureV4 calculates the signature of the prepared request, and keeps
+// the intermediate results for troubleshooting
+func calSignatureV4(req *fasthttp.Request, cred credential) (*SignatureExplanation, *metadata) {
 	meta := &metadata{}
 	meta.service, meta.region = cred.service, cred.region
 
 	// Task 1
-	hashedCanonReq := hashedCanonicalRequestV4(req, meta)
+	canonicalRequest := canonicalRequestV4(req, meta)
+	hashedCanonReq := hashSHA256([]byte(canonicalRequest))
 
 	// Task 2
 	stringToSignRet := stringToSign(req, hashedCanonReq, meta)
@@ -55,13 +71,13 @@ func sign(req *fasthttp.Request, cred credential) *fasthttp.Request {
 	signingKeyRet := signingKey(cred.secretAccessKey, meta.date, meta.region, meta.service)
 	signatureRet := signature(signingKeyRet, stringToSignRet)
 
-	req.Header.Set("Authorization", buildAuthHeader(signatureRet, meta, cred))
-
-	if cred.sessionToken != "" {
-		req.Header.Set("X-Security-Token", cred.sessionToken)
-	}
-
-	return req
+	return &SignatureExplanation{
+		Algorithm:        meta.algorithm,
+		CanonicalRequest: canonicalRequest,
+		StringToSign:     stringToSignRet,
+		SignedHeaders:    meta.signedHeaders,
+		Signature:        signatureRet,
+	}, meta
 }
 
 func prepareRequestV4(req *fasthttp.Request) *fasthttp.Request {
@@ -87,14 +103,24 @@ func timestampV4() string {
 	return now().Format(timeFormatV4)
 }
 
-func hashedCanonicalRequestV4(req *fasthttp.Request, meta *metadata) string {
-	payload := req.Body()
-	payloadHash := hashSHA256(payload)
-	req.Header.Set("X-Content-Sha256", payloadHash)
+func canonicalRequestV4(req *fasthttp.Request, meta *metadata) string {
+	// the hash of the streamed body is computed when it is spooled,
+	// reading the stream here would load the whole body into memory
+	payloadHash := string(req.Header.Peek("X-Content-Sha256"))
+	if !req.IsBodyStream() || payloadHash == "" {
+		payloadHash = hashSHA256(req.Body())
+		req.Header.Set("X-Content-Sha256", payloadHash)
+	}
 
-	req.Header.Set("Host", string(req.URI().Host()))
+	// the Host header differs from the host of url if it is sent to a gateway, see mainHostTransport
+	if len(req.Header.Host()) == 0 {
+		req.Header.SetHostBytes(req.URI().Host())
+	}
 
 	var sortedHeaderKeys []string
+	// collect values while visiting, Peek can not find the lowercase key
+	// when header names normalizing is disabled
+	headerValues := make(map[string]string)
 	req.Header.VisitAll(func(keyBytes, valueBytes []byte) {
 		key := strings.ToLower(string(keyBytes))
 		switch key {
@@ -105,12 +131,13 @@ func hashedCanonicalRequestV4(req *fasthttp.Request, meta *metadata) string {
 			}
 		}
 		sortedHeaderKeys = append(sortedHeaderKeys, key)
+		headerValues[key] = string(valueBytes)
 	})
 	sort.Strings(sortedHeaderKeys)
 
 	var headersToSign string
 	for _, key := range sortedHeaderKeys {
-		value := strings.TrimSpace(string(req.Header.Peek(key)))
+		value := strings.TrimSpace(headerValues[key])
 		if key == "host" {
 			if strings.Contains(value, ":") {
 				split := strings.Split(value, ":")
@@ -133,7 +160,31 @@ func hashedCanonicalRequestV4(req *fasthttp.Request, meta *metadata) string {
 		normURI(string(req.URI().Path())), normQuery(urlQuery.Encode()),
 		headersToSign, meta.signedHeaders, payloadHash)
 
-	return hashSHA256([]byte(canonicalRequest))
+	return canonicalRequest
+}
+
+// signPayload is the body being signed, write writes the body into w, so that
+// the body spooled to a file is signed without being loaded into memory
+type signPayload struct {
+	write func(w io.Writer)
+	// the hex sha256 of the body if it is known, it is computed by write otherwise
+	sum string
+}
+
+func bytesPayload(body []byte) signPayload {
+	return signPayload{write: func(w io.Writer) {
+		_, _ = w.Write(body)
+	}}
+}
+
+// hash returns the hex sha256 of the body
+func (p signPayload) hash() string {
+	if p.sum != "" {
+		return p.sum
+	}
+	h := sha256.New()
+	p.write(h)
+	return fmt.Sprintf("%x", h.Sum(nil))
 }
 
 func hashSHA256(content []byte) string {
@@ -0,0 +1,108 @@
+package core
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/byteplus-sdk/byteplus-sdk-go-rec-core/option"
+	"github.com/valyala/fasthttp"
+)
+
+func TestSign(t *testing.T) {
+	originNow := now
+	now = func() time.Time {
+		return time.Date(2023, 11, 10, 8, 0, 0, 0, time.UTC)
+	}
+	defer func() {
+		now = originNow
+	}()
+	cred := credential{
+		accessKeyID:     "ak",
+		secretAccessKey: "sk",
+		region:          "ap-singapore-1",
+		service:         "air",
+	}
+	tests := []struct {
+		name        string
+		method      string
+		url         string
+		contentType string
+		body        []byte
+		want        string
+	}{
+		{
+			name:   "get_with_query",
+			method: fasthttp.MethodGet,
+			url:    "https://byteplus.com/data/api/sdk/host?project_id=1&b=x+y",
+			want:   "609a16dd3acd473951bf4eec02d6d3d726c4d78feda0194d3fb78b6718ec2de7",
+		},
+		{
+			name:   "delete_with_escaped_query",
+			method: fasthttp.MethodDelete,
+			url:    "https://byteplus.com/data/api/item?id=a%26b%3Dc",
+			want:   "ed9c129cac74fa5b1caa18624a8f98e58ce4a039b2fa8bc627a9eb69525061cd",
+		},
+		{
+			name:        "post_with_body",
+			method:      fasthttp.MethodPost,
+			url:         "https://byteplus.com/predict/api/x",
+			contentType: "application/json",
+			body:        []byte("{}"),
+			want:        "dd690d7207657165972e55fa31b3519e9a12ff05e6f71d253ad476cc7a012387",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := fasthttp.AcquireRequest()
+			defer fasthttp.ReleaseRequest(req)
+			req.Header.SetMethod(tt.method)
+			req.SetRequestURI(tt.url)
+			if tt.contentType != "" {
+				req.Header.Set("Content-Type", tt.contentType)
+			}
+			req.SetBodyRaw(tt.body)
+			sign(req, cred)
+			authorization := string(req.Header.Peek("Authorization"))
+			if !strings.HasSuffix(authorization, "Signature="+tt.want) {
+				t.Errorf("sign() authorization = %v, want signature %v", authorization, tt.want)
+			}
+		})
+	}
+}
+
+func TestHTTPCaller_authOverride(t *testing.T) {
+	var authorization, signature, ts, nonce string
+	transport := TransportFunc(func(request *fasthttp.Request, response *fasthttp.Response, deadline time.Time) error {
+		authorization = string(request.Header.Peek("Authorization"))
+		signature = string(request.Header.Peek("Tenant-Signature"))
+		ts = string(request.Header.Peek("Tenant-Ts"))
+		nonce = string(request.Header.Peek("Tenant-Nonce"))
+		response.SetStatusCode(fasthttp.StatusOK)
+		return nil
+	})
+	availabler := &staticHostAvailabler{hosts: []string{"host"}}
+	cred := credential{accessKeyID: "ak", secretAccessKey: "sk", region: "cn-north-1", service: "air"}
+	c := newHTTPCaller("project", "tenant", false, "", nil, cred, availabler, &CallerConfig{}, "http", false, transport)
+	options := option.Conv2Options(option.WithCredential("tenant-ak", "tenant-sk"))
+	if _, err := c.doHTTPRequest("req", "http://host/path", map[string]string{}, nil, options, nil); err != nil {
+		t.Fatalf("doHTTPRequest() error = %v", err)
+	}
+	if !strings.Contains(authorization, "Credential=tenant-ak/") {
+		t.Errorf("Authorization = %s, want signed by tenant-ak", authorization)
+	}
+
+	airAuthConfig, _ := fillDefaultAirAuthConfig(nil)
+	c = newHTTPCaller("project", "tenant", true, "token", airAuthConfig, credential{}, availabler,
+		&CallerConfig{DisableCompression: true}, "http", false, transport)
+	options = option.Conv2Options(option.WithAirAuthToken("tenant-token"))
+	if _, err := c.doHTTPRequest("req", "http://host/path", map[string]string{}, []byte("{}"), options, nil); err != nil {
+		t.Fatalf("doHTTPRequest() error = %v", err)
+	}
+	if want := CalAirAuthSignature("tenant-token", "tenant", []byte("{}"), ts, nonce, ""); signature != want {
+		t.Errorf("signature = %s, want signed by tenant-token", signature)
+	}
+	if requestKey(options, "http://host/path", nil) == requestKey(&option.Options{}, "http://host/path", nil) {
+		t.Errorf("requests of different tokens should not share the key")
+	}
+}
@@ -1,62 +1,98 @@
 package core
 
 import (
+	"context"
 	"encoding/json"
 	"errors"
 	"fmt"
 	"sort"
+	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/google/uuid"
 
+	"github.com/byteplus-sdk/byteplus-sdk-go-rec-core/events"
 	"github.com/byteplus-sdk/byteplus-sdk-go-rec-core/logs"
 	"github.com/byteplus-sdk/byteplus-sdk-go-rec-core/metrics"
 	"github.com/valyala/fasthttp"
 )
 
-type HostAvailabler interface {
-	GetHosts() []string
-
-	GetHost(path string) string
-
-	Shutdown()
-}
-
-type HostScorer interface {
-	ScoreHosts(hosts []string) []*HostAvailabilityScore
-}
-
-type HostAvailabilityScore struct {
-	Host  string
-	Score float64
-}
-
 // if mainHost success rate is over mainHostAvailableScore, then use mainHost
 const mainHostAvailableScore = 0.9
 
-func (h *HostAvailabilityScore) String() string {
-	return fmt.Sprintf("%+v", *h)
-}
-
+// HostAvailablerBase implements the host config maintenance shared by all the
+// HostAvailabler implementations: fetching host config from server, scoring
+// hosts periodically by hostScorer, and sorting hosts of each path by score.
 type HostAvailablerBase struct {
 	projectID            string
 	skipFetchHosts       bool
 	fetchHostsHTTPClient *fasthttp.Client
-	defaultHosts         []string
 	mainHost             string
-	hostConfig           map[string][]string
 	hostScorer           HostScorer
+	stateStore           StateStore
 	stop                 chan bool
+
+	// map[string][]string of path->hosts, it is replaced as an immutable snapshot
+	// so that GetHost on the request path never races with the scoring goroutine
+	hostConfig atomic.Value
+	// []string of the default hosts, they are switched by switchHosts
+	defaultHosts atomic.Value
+	// serializes the updates of hostConfig by scoring and fetching
+	updateLock sync.Mutex
+	// map[string]float64 of host->score of the latest scoring
+	hostScores atomic.Value
+	// map[string]float64 of host->success rate of the latest scoring, see HostAvailabilityScore
+	hostAvailabilities atomic.Value
+	selectionStrategy  HostSelectionStrategy
+	nextHost           uint32
+	listenersLock      sync.RWMutex
+	listeners          []HostsChangedListener
+	// hostResolver takes place of fetching hosts from server if it is set
+	hostResolver HostResolver
+	// the schema and signer of fetching hosts from server, default is anonymous http
+	fetchSchema      string
+	fetchHostsSigner FetchRequestSigner
+	// the endpoint of fetching hosts from server, see PingHostAvailablerConfig
+	fetchPath    string
+	fetchQueries map[string]string
+	fetchHost    string
+	// the host config being scored, it is set with updateLock held
+	scoringHostConfig map[string][]string
+	// the time.Duration of intervals, they can be adjusted at runtime
+	fetchInterval atomic.Value
+	scoreInterval atomic.Value
+	// the intervals are randomized by the ratio, see scheduleWithJitter
+	intervalJitter float64
+	// host->tier, the hosts of the lower tier are preferred, see preferTier
+	hostTiers     map[string]int
+	tierThreshold float64
+	// stopFetch is closed when fetching hosts stops, such as the hosts are set by user
+	stopFetch     chan bool
+	stopFetchOnce sync.Once
+	shutdownOnce  sync.Once
+	// the background goroutines, ShutdownWithContext waits for them
+	goroutines sync.WaitGroup
 }
 
 func (a *HostAvailablerBase) Init(defaultHosts []string, fetchHostInterval, scoreHostInterval time.Duration) error {
 	if len(defaultHosts) == 0 {
 		return errors.New("default hosts are empty")
 	}
+	state := a.loadState()
 	a.setHosts(defaultHosts)
+	// the hosts specified by user are always used, otherwise start with the saved config,
+	// as the first fetch may fail or take long
+	if state != nil && !a.skipFetchHosts && len(state.HostConfig["*"]) > 0 {
+		a.doScoreAndUpdateHosts(state.HostConfig)
+	}
 	a.stop = make(chan bool)
+	a.stopFetch = make(chan bool)
 	if !a.skipFetchHosts {
-		a.fetchHostsHTTPClient = &fasthttp.Client{}
+		// the implementations may set their own client, such as with proxy and tls config
+		if a.fetchHostsHTTPClient == nil {
+			a.fetchHostsHTTPClient = &fasthttp.Client{}
+		}
 		a.fetchHostsFromServer()
 		a.scheduleFetchHostsFromServer(fetchHostInterval)
 	}
@@ -72,35 +108,83 @@ func (a *HostAvailablerBase) Init(defaultHosts []string, fetchHostInterval, scor
 //   }
 // }
 func (a *HostAvailablerBase) setHosts(hosts []string) {
-	a.defaultHosts = hosts
-	a.hostConfig = map[string][]string{
+	a.defaultHosts.Store(hosts)
+	hostConfig := map[string][]string{
 		"*": hosts,
 	}
+	a.hostConfig.Store(hostConfig)
 	a.stopFetchHostsFromServer()
-	a.doScoreAndUpdateHosts(a.hostConfig)
+	a.doScoreAndUpdateHosts(hostConfig)
+}
+
+// switchHosts takes hosts as the default hosts in place of the current ones, such as
+// when RegionSelector selects another region. The host config is reset to hosts, and
+// fetched from them again unless fetching hosts is stopped
+func (a *HostAvailablerBase) switchHosts(hosts []string) {
+	select {
+	case <-a.stop:
+		return
+	default:
+	}
+	a.defaultHosts.Store(hosts)
+	a.doScoreAndUpdateHosts(map[string][]string{"*": hosts})
+	if a.skipFetchHosts {
+		return
+	}
+	select {
+	case <-a.stopFetch:
+	default:
+		a.fetchHostsFromServer()
+	}
+}
+
+func (a *HostAvailablerBase) loadDefaultHosts() []string {
+	hosts, _ := a.defaultHosts.Load().([]string)
+	return hosts
+}
+
+// loadHostConfig returns the current snapshot of host config, which must not be modified
+func (a *HostAvailablerBase) loadHostConfig() map[string][]string {
+	hostConfig, _ := a.hostConfig.Load().(map[string][]string)
+	return hostConfig
+}
+
+// GetSnapshot returns a copy of the current host config of path->hosts ordered by
+// availability, all the paths in it are of the same version
+func (a *HostAvailablerBase) GetSnapshot() map[string][]string {
+	return copyHostConfig(a.loadHostConfig())
+}
+
+func copyHostConfig(hostConfig map[string][]string) map[string][]string {
+	result := make(map[string][]string, len(hostConfig))
+	for path, hosts := range hostConfig {
+		result[path] = append([]string(nil), hosts...)
+	}
+	return result
 }
 
 func (a *HostAvailablerBase) stopFetchHostsFromServer() {
-	if a.stop != nil {
-		close(a.stop)
+	if a.stopFetch == nil {
+		return
 	}
+	a.stopFetchOnce.Do(func() {
+		close(a.stopFetch)
+	})
 }
 
 func (a *HostAvailablerBase) scheduleScoreAndUpdateHosts(scoreHostInterval time.Duration) {
-	AsyncExecute(func() {
-		ticker := time.NewTicker(scoreHostInterval)
-		for true {
-			select {
-			case <-a.stop:
-				ticker.Stop()
-				return
-			case <-ticker.C:
-				a.doScoreAndUpdateHosts(a.hostConfig)
-			}
-		}
+	a.scoreInterval.Store(scoreHostInterval)
+	a.scheduleWithJitter(a.stop, &a.scoreInterval, func() {
+		a.rescoreHosts()
 	})
 }
 
+// rescoreHosts scores the hosts of the current host config, which is loaded with updateLock
+// held, so that the host config stored by fetching meanwhile is not overwritten by a stale one
+func (a *HostAvailablerBase) rescoreHosts() {
+	a.notifyHostsChanged(a.scoreAndUpdateHostsWithLock(a.loadHostConfig))
+}
+
 // doScoreAndUpdateHosts
 // path->host_array
 // example:
@@ -114,9 +198,30 @@ func (a *HostAvailablerBase) scheduleScoreAndUpdateHosts(scoreHostInterval time.
 //   "*": ["bytedance.com", "byteplus.com"]
 // }
 func (a *HostAvailablerBase) doScoreAndUpdateHosts(hostConfig map[string][]string) {
+	a.notifyHostsChanged(a.scoreAndUpdateHostsWithLock(func() map[string][]string {
+		return hostConfig
+	}))
+}
+
+// scoreAndUpdateHostsWithLock scores the host config returned by load with updateLock held,
+// and returns the configs to notify the listeners of after releasing it, so that
+// the listeners calling back into the availabler are not deadlocked
+func (a *HostAvailablerBase) scoreAndUpdateHostsWithLock(
+	load func() map[string][]string) (oldHostConfig, newHostConfig map[string][]string) {
+	a.updateLock.Lock()
+	defer a.updateLock.Unlock()
+	return a.scoreAndUpdateHosts(load())
+}
+
+// scoreAndUpdateHosts is doScoreAndUpdateHosts with updateLock held, it returns the
+// copies of the old and new host config if the host config changes, otherwise nil
+func (a *HostAvailablerBase) scoreAndUpdateHosts(
+	hostConfig map[string][]string) (oldHostConfig, newHostConfig map[string][]string) {
 	logID := "score_" + uuid.NewString()
 	hosts := a.distinctHosts(hostConfig)
+	a.scoringHostConfig = hostConfig
 	newHostScores := a.hostScorer.ScoreHosts(hosts)
+	a.scoringHostConfig = nil
 	metrics.Info(logID, "[ByteplusSDK][Score]score hosts, project_id:%s, result:%s", a.projectID, newHostScores)
 	logs.Debug("score hosts result: %s", newHostScores)
 	if len(newHostScores) == 0 {
@@ -127,14 +232,16 @@ func (a *HostAvailablerBase) doScoreAndUpdateHosts(hostConfig map[string][]strin
 		metrics.Counter(metricsKeyCommonError, 1, metricsTags...)
 		metrics.Error(logID, "[ByteplusSDK][Score] scoring hosts return an empty list, project_id:%s", a.projectID)
 		logs.Error("scoring hosts return an empty list")
-		return
+		return nil, nil
 	}
-	newHostConfig := a.copyAndSortHost(hostConfig, newHostScores)
-	if a.isHostConfigNotUpdated(a.hostConfig, newHostConfig) {
+	newHostConfig = a.copyAndSortHost(hostConfig, newHostScores)
+	a.storeHostScores(newHostScores)
+	oldHostConfig = a.loadHostConfig()
+	if a.isHostConfigNotUpdated(oldHostConfig, newHostConfig) {
 		metrics.Info(logID, "[ByteplusSDK][Score] host order is not changed, project_id:%s, config:%+v",
 			a.projectID, newHostConfig)
 		logs.Debug("host order is not changed, %+v", newHostConfig)
-		return
+		return nil, nil
 	}
 	metricsTags := []string{
 		"type:set_new_host_config",
@@ -142,9 +249,61 @@ func (a *HostAvailablerBase) doScoreAndUpdateHosts(hostConfig map[string][]strin
 	}
 	metrics.Counter(metricsKeyCommonInfo, 1, metricsTags...)
 	metrics.Info(logID, "[ByteplusSDK][Score] set new host config: %+v, old config: %+v, project_id:%s",
-		newHostConfig, a.hostConfig, a.projectID)
-	logs.Debug("set new host config: %+v, old config: %+v", newHostConfig, a.hostConfig)
-	a.hostConfig = newHostConfig
+		newHostConfig, oldHostConfig, a.projectID)
+	logs.Debug("set new host config: %+v, old config: %+v", newHostConfig, oldHostConfig)
+	a.hostConfig.Store(newHostConfig)
+	a.publishHostConfigEvents(oldHostConfig, newHostConfig)
+	a.saveState()
+	return copyHostConfig(oldHostConfig), copyHostConfig(newHostConfig)
+}
+
+func (a *HostAvailablerBase) publishHostConfigEvents(oldHostConfig, newHostConfig map[string][]string) {
+	events.Publish(&events.Event{
+		Type:      events.HostConfigChanged,
+		ProjectID: a.projectID,
+		Message:   fmt.Sprintf("%+v", newHostConfig),
+	})
+	for path, oldHosts := range oldHostConfig {
+		newHosts := newHostConfig[path]
+		if len(oldHosts) == 0 || len(newHosts) == 0 || oldHosts[0] == newHosts[0] {
+			continue
+		}
+		events.Publish(&events.Event{
+			Type:      events.HostEjected,
+			ProjectID: a.projectID,
+			Host:      oldHosts[0],
+			Message:   fmt.Sprintf("path:%s, new host:%s", path, newHosts[0]),
+		})
+	}
+}
+
+// OnHostsChanged adds the listener called when the host config changes, such as
+// the preferred host order flips, it is called in the goroutine updating hosts
+func (a *HostAvailablerBase) OnHostsChanged(listener HostsChangedListener) {
+	a.listenersLock.Lock()
+	defer a.listenersLock.Unlock()
+	a.listeners = append(a.listeners, listener)
+}
+
+// notifyHostsChanged calls the listeners without updateLock held, it does nothing if
+// newHostConfig is nil, which means the host config is not changed
+func (a *HostAvailablerBase) notifyHostsChanged(oldHostConfig, newHostConfig map[string][]string) {
+	if newHostConfig == nil {
+		return
+	}
+	a.listenersLock.RLock()
+	listeners := a.listeners
+	a.listenersLock.RUnlock()
+	for _, listener := range listeners {
+		func() {
+			defer func() {
+				if r := recover(); r != nil {
+					logs.Error("hosts changed listener panic, project_id:%s err:%v", a.projectID, r)
+				}
+			}()
+			listener(oldHostConfig, newHostConfig)
+		}()
+	}
 }
 
 func (a *HostAvailablerBase) distinctHosts(hostConfig map[string][]string) []string {
@@ -165,13 +324,17 @@ func (a *HostAvailablerBase) distinctHosts(hostConfig map[string][]string) []str
 func (a *HostAvailablerBase) copyAndSortHost(hostConfig map[string][]string,
 	newHostScores []*HostAvailabilityScore) map[string][]string {
 	hostScoreIndex := make(map[string]float64, len(newHostScores))
+	availabilityIndex := make(map[string]float64, len(newHostScores))
 	for _, hostScore := range newHostScores {
-		// mainHost is prioritized for use when available
-		if hostScore.Host == a.mainHost && hostScore.Score >= mainHostAvailableScore {
+		score := hostScore.Score
+		// mainHost is prioritized for use when available, the boost only takes effect on
+		// the order, the real score is stored for the host selection
+		if hostScore.Host == a.mainHost && hostScore.availabilityScore() >= mainHostAvailableScore {
 			// make sure mainHost has the highest score
-			hostScore.Score = 1 + hostScore.Score
+			score = 1 + score
 		}
-		hostScoreIndex[hostScore.Host] = hostScore.Score
+		hostScoreIndex[hostScore.Host] = score
+		availabilityIndex[hostScore.Host] = hostScore.availabilityScore()
 	}
 	newHostConfig := make(map[string][]string, len(hostConfig))
 
@@ -182,6 +345,7 @@ func (a *HostAvailablerBase) copyAndSortHost(hostConfig map[string][]string,
 		sort.Slice(newHosts, func(i, j int) bool {
 			return hostScoreIndex[newHosts[i]] > hostScoreIndex[newHosts[j]]
 		})
+		a.preferTier(newHosts, availabilityIndex)
 		newHostConfig[path] = newHosts
 	}
 	return newHostConfig
@@ -197,7 +361,7 @@ func (a *HostAvailablerBase) isHostConfigNotUpdated(oldHostConfig, newHostConfig
 	if len(oldHostConfig) != len(newHostConfig) {
 		return false
 	}
-	for path, oldHosts := range a.hostConfig {
+	for path, oldHosts := range oldHostConfig {
 		newHosts := newHostConfig[path]
 		if !a.isEqualHosts(oldHosts, newHosts) {
 			return false
@@ -219,24 +383,19 @@ func (a *HostAvailablerBase) isEqualHosts(hostsA, hostsB []string) bool {
 }
 
 func (a *HostAvailablerBase) scheduleFetchHostsFromServer(fetchHostInterval time.Duration) {
-	AsyncExecute(func() {
-		ticker := time.NewTicker(fetchHostInterval)
-		for true {
-			select {
-			case <-a.stop:
-				ticker.Stop()
-				return
-			case <-ticker.C:
-				a.fetchHostsFromServer()
-			}
-		}
-	})
+	a.fetchInterval.Store(fetchHostInterval)
+	a.scheduleWithJitter(a.stopFetch, &a.fetchInterval, a.fetchHostsFromServer)
 }
 
 func (a *HostAvailablerBase) fetchHostsFromServer() {
-	url := fmt.Sprintf("http://%s/data/api/sdk/host?project_id=%s", a.defaultHosts[0], a.projectID)
+	if a.hostResolver != nil {
+		a.resolveHosts()
+		return
+	}
+	var url string
 	reqID := "fetch_" + uuid.NewString()
 	for i := 0; i < 3; i++ {
+		url = a.fetchHostsURL(i)
 		rspHostConfig := a.doFetchHostsFromServer(reqID, url)
 		if rspHostConfig == nil {
 			continue
@@ -281,11 +440,8 @@ func (a *HostAvailablerBase) doFetchHostsFromServer(reqID, url string) map[strin
 		fasthttp.ReleaseRequest(request)
 		fasthttp.ReleaseResponse(response)
 	}()
-	request.SetRequestURI(url)
-	request.Header.SetMethod(fasthttp.MethodGet)
-	request.Header.Set("Request-Id", reqID)
 	start := time.Now()
-	err := a.fetchHostsHTTPClient.DoTimeout(request, response, 5*time.Second)
+	err := a.doFetchRequest(request, response, reqID, url)
 	cost := time.Now().Sub(start)
 	if err != nil {
 		metricsTags := []string{
@@ -356,11 +512,12 @@ func (a *HostAvailablerBase) doFetchHostsFromServer(reqID, url string) map[strin
 }
 
 func (a *HostAvailablerBase) isServerHostsNotUpdated(newHostConfig map[string][]string) bool {
-	if len(newHostConfig) != len(a.hostConfig) {
+	hostConfig := a.loadHostConfig()
+	if len(newHostConfig) != len(hostConfig) {
 		return false
 	}
 	for path, newHosts := range newHostConfig {
-		oldHosts, exist := a.hostConfig[path]
+		oldHosts, exist := hostConfig[path]
 		if !exist {
 			return false
 		}
@@ -387,20 +544,52 @@ func (a *HostAvailablerBase) containsAll(hosts []string, hosts2 []string) bool {
 	return true
 }
 
+// MainHost returns the main host preferred when it is available
+func (a *HostAvailablerBase) MainHost() string {
+	return a.mainHost
+}
+
 func (a *HostAvailablerBase) GetHosts() []string {
-	return a.distinctHosts(a.hostConfig)
+	return a.distinctHosts(a.loadHostConfig())
 }
 
 func (a *HostAvailablerBase) GetHost(path string) string {
-	pathHosts, exist := a.hostConfig[path]
-	if exist && len(pathHosts) > 0 {
-		return pathHosts[0]
+	hostConfig := a.loadHostConfig()
+	pathHosts, exist := hostConfig[path]
+	if !exist || len(pathHosts) == 0 {
+		pathHosts = hostConfig["*"]
 	}
-	return a.hostConfig["*"][0]
+	return a.selectHost(pathHosts)
 }
 
-func (a *HostAvailablerBase) Shutdown() {
-	if a.stop != nil {
-		close(a.stop)
+// GetPathHosts returns all the hosts of path ordered by availability
+func (a *HostAvailablerBase) GetPathHosts(path string) []string {
+	hostConfig := a.loadHostConfig()
+	pathHosts, exist := hostConfig[path]
+	if !exist || len(pathHosts) == 0 {
+		pathHosts = hostConfig["*"]
 	}
+	result := make([]string, len(pathHosts))
+	copy(result, pathHosts)
+	return result
+}
+
+// Shutdown stops the background goroutines and waits for them, it can be called more than once
+func (a *HostAvailablerBase) Shutdown() {
+	_ = a.ShutdownWithContext(context.Background())
+}
+
+// ShutdownWithContext stops fetching and scoring hosts, waits for the running round until
+// ctx is done, then saves the state. The later calls return nil at once
+func (a *HostAvailablerBase) ShutdownWithContext(ctx context.Context) error {
+	var err error
+	a.shutdownOnce.Do(func() {
+		if a.stop != nil {
+			close(a.stop)
+		}
+		a.stopFetchHostsFromServer()
+		err = waitWithContext(ctx, &a.goroutines)
+		a.saveState()
+	})
+	return err
 }
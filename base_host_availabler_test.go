@@ -0,0 +1,244 @@
+package core
+
+import (
+	"fmt"
+	"reflect"
+	"sync"
+	"testing"
+	"time"
+)
+
+type reversingHostScorer struct{}
+
+// ScoreHosts scores the latter hosts higher, so that every scoring reverses the order
+func (reversingHostScorer) ScoreHosts(hosts []string) []*HostAvailabilityScore {
+	result := make([]*HostAvailabilityScore, len(hosts))
+	for i, host := range hosts {
+		result[i] = &HostAvailabilityScore{Host: host, Score: float64(i)}
+	}
+	return result
+}
+
+func TestHostAvailablerBase_concurrentUpdate(t *testing.T) {
+	a := &HostAvailablerBase{projectID: "1", skipFetchHosts: true, hostScorer: reversingHostScorer{}}
+	a.setHosts([]string{"a", "b"})
+	snapshot := a.GetSnapshot()
+	snapshot["*"][0] = "modified"
+	if got := a.GetPathHosts("*"); got[0] == "modified" {
+		t.Errorf("GetSnapshot() returns the host config in use")
+	}
+
+	var wg sync.WaitGroup
+	wg.Add(2)
+	go func() {
+		defer wg.Done()
+		for i := 0; i < 100; i++ {
+			a.rescoreHosts()
+		}
+	}()
+	go func() {
+		defer wg.Done()
+		for i := 0; i < 100; i++ {
+			if host := a.GetHost("Predict"); host != "a" && host != "b" {
+				t.Errorf("GetHost() = %s", host)
+			}
+			if hosts := a.GetSnapshot()["*"]; len(hosts) != 2 {
+				t.Errorf("GetSnapshot() = %v", hosts)
+			}
+		}
+	}()
+	wg.Wait()
+	if got := a.GetSnapshot(); !reflect.DeepEqual(got, a.loadHostConfig()) {
+		t.Errorf("GetSnapshot() = %v, want %v", got, a.loadHostConfig())
+	}
+}
+
+type staticHostScorer map[string]float64
+
+func (s staticHostScorer) ScoreHosts(hosts []string) []*HostAvailabilityScore {
+	result := make([]*HostAvailabilityScore, len(hosts))
+	for i, host := range hosts {
+		result[i] = &HostAvailabilityScore{Host: host, Score: s[host]}
+	}
+	return result
+}
+
+func TestHostAvailablerBase_selectionStrategy(t *testing.T) {
+	scorer := staticHostScorer{"a": 1, "b": 0.95, "c": 0.5, "d": 0}
+	tests := []struct {
+		strategy HostSelectionStrategy
+		want     map[string]bool
+	}{
+		{"", map[string]bool{"a": true}},
+		{HostSelectionTopOne, map[string]bool{"a": true}},
+		{HostSelectionRoundRobin, map[string]bool{"a": true, "b": true}},
+		{HostSelectionWeighted, map[string]bool{"a": true, "b": true, "c": true}},
+	}
+	for _, tt := range tests {
+		t.Run(string(tt.strategy), func(t *testing.T) {
+			a := &HostAvailablerBase{projectID: "1", skipFetchHosts: true, hostScorer: scorer,
+				selectionStrategy: tt.strategy}
+			a.setHosts([]string{"d", "c", "b", "a"})
+			got := make(map[string]bool)
+			for i := 0; i < 200; i++ {
+				got[a.GetHost("Predict")] = true
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("GetHost() selects %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestHostAvailablerBase_mainHost(t *testing.T) {
+	a := &HostAvailablerBase{projectID: "1", skipFetchHosts: true, mainHost: "b",
+		hostScorer: staticHostScorer{"a": 1, "b": 0.95}}
+	a.setHosts([]string{"a", "b"})
+	if got := a.GetPathHosts("Predict"); !reflect.DeepEqual(got, []string{"b", "a"}) {
+		t.Errorf("GetPathHosts() = %v, want the main host first", got)
+	}
+	if got := a.loadHostScores()["b"]; got != 0.95 {
+		t.Errorf("the stored score of main host = %v, want 0.95", got)
+	}
+}
+
+// latencyHostScorer scores the hosts by latency, the success rates of them are all 1
+type latencyHostScorer map[string]float64
+
+func (s latencyHostScorer) ScoreHosts(hosts []string) []*HostAvailabilityScore {
+	result := make([]*HostAvailabilityScore, len(hosts))
+	for i, host := range hosts {
+		result[i] = &HostAvailabilityScore{Host: host, Score: s[host], availability: 1, hasAvailability: true}
+	}
+	return result
+}
+
+func TestHostAvailablerBase_latencyScores(t *testing.T) {
+	scorer := latencyHostScorer{"a": 0.6, "b": 0.5, "c": 0.3}
+	a := &HostAvailablerBase{projectID: "1", skipFetchHosts: true, mainHost: "c", hostScorer: scorer,
+		selectionStrategy: HostSelectionRoundRobin, hostTiers: map[string]int{"b": 0, "a": 1, "c": 1},
+		tierThreshold: defaultTierFailoverThreshold}
+	a.setHosts([]string{"a", "b", "c"})
+	// the thresholds apply to the success rates, the latency scores only order the hosts
+	if got := a.GetPathHosts("Predict"); !reflect.DeepEqual(got, []string{"b", "c", "a"}) {
+		t.Errorf("GetPathHosts() = %v, want [b c a]", got)
+	}
+	// all the hosts are of the preferred tier without tiers
+	a.hostTiers = nil
+	got := make(map[string]bool)
+	for i := 0; i < 10; i++ {
+		got[a.GetHost("Predict")] = true
+	}
+	if len(got) != 3 {
+		t.Errorf("round robin selects %v, want all the available hosts", got)
+	}
+}
+
+func TestHostAvailablerBase_OnHostsChanged(t *testing.T) {
+	a := &HostAvailablerBase{projectID: "1", skipFetchHosts: true, hostScorer: reversingHostScorer{}}
+	a.setHosts([]string{"a", "b"})
+	var notified [][2]map[string][]string
+	a.OnHostsChanged(func(oldHostConfig, newHostConfig map[string][]string) {
+		notified = append(notified, [2]map[string][]string{oldHostConfig, newHostConfig})
+	})
+	a.OnHostsChanged(func(oldHostConfig, newHostConfig map[string][]string) {
+		panic("the panic of listener is recovered")
+	})
+	current := a.GetSnapshot()
+	a.rescoreHosts()
+	if len(notified) != 1 || !reflect.DeepEqual(notified[0][0], current) ||
+		!reflect.DeepEqual(notified[0][1], a.GetSnapshot()) {
+		t.Fatalf("notified = %v, want the change from %v", notified, current)
+	}
+	// the listeners are notified without updateLock, so that they can update the hosts
+	called := 0
+	a.OnHostsChanged(func(_, _ map[string][]string) {
+		called++
+		if called == 1 {
+			a.rescoreHosts()
+		}
+	})
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		a.rescoreHosts()
+	}()
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatalf("rescoreHosts() is blocked by the listener updating hosts")
+	}
+	if called != 2 {
+		t.Errorf("listener is called %d times, want 2", called)
+	}
+
+	client := &HTTPClient{hostAvailabler: &staticHostAvailabler{hosts: []string{"a"}}}
+	if err := client.OnHostsChanged(func(_, _ map[string][]string) {}); err == nil {
+		t.Errorf("OnHostsChanged() of static host availabler error = nil")
+	}
+}
+
+func TestHostAvailablerBase_preferTier(t *testing.T) {
+	tiers := map[string]int{"local1": 0, "local2": 0, "remote1": 1, "remote2": 1}
+	tests := []struct {
+		name   string
+		scorer staticHostScorer
+		want   []string
+	}{
+		{"local_healthy", staticHostScorer{"local1": 0.95, "local2": 0.5, "remote1": 1, "remote2": 0.99},
+			[]string{"local1", "local2", "remote1", "remote2"}},
+		{"local_down", staticHostScorer{"local1": 0.8, "local2": 0.5, "remote1": 0.95, "remote2": 1},
+			[]string{"remote2", "remote1", "local1", "local2"}},
+		{"all_down", staticHostScorer{"local1": 0.5, "local2": 0.8, "remote1": 0.6, "remote2": 0},
+			[]string{"local2", "remote1", "local1", "remote2"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			a := &HostAvailablerBase{projectID: "1", skipFetchHosts: true, hostScorer: tt.scorer,
+				hostTiers: tiers, tierThreshold: defaultTierFailoverThreshold}
+			a.setHosts([]string{"remote2", "remote1", "local2", "local1"})
+			if got := a.GetPathHosts("Predict"); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("GetPathHosts() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestHostAvailablerBase_roundRobinOfTiers(t *testing.T) {
+	a := &HostAvailablerBase{projectID: "1", skipFetchHosts: true,
+		hostScorer: staticHostScorer{"a": 0.95, "b": 0.2, "c": 0.99}, selectionStrategy: HostSelectionRoundRobin,
+		hostTiers: map[string]int{"a": 0, "b": 0, "c": 1}, tierThreshold: defaultTierFailoverThreshold}
+	a.setHosts([]string{"a", "b", "c"})
+	// the unhealthy host of the preferred tier is ahead of the healthy host of the other tier
+	if got := a.GetPathHosts("Predict"); !reflect.DeepEqual(got, []string{"a", "b", "c"}) {
+		t.Fatalf("GetPathHosts() = %v, want [a b c]", got)
+	}
+	for i := 0; i < 10; i++ {
+		if got := a.GetHost("Predict"); got != "a" {
+			t.Fatalf("GetHost() = %s, want only the healthy host of the preferred tier", got)
+		}
+	}
+}
+
+func TestHostAvailablerBase_GetAffinityHost(t *testing.T) {
+	scorer := staticHostScorer{"a": 1, "b": 1, "c": 1, "d": 1}
+	a := &HostAvailablerBase{projectID: "1", skipFetchHosts: true, hostScorer: scorer}
+	a.setHosts([]string{"a", "b", "c", "d"})
+	keys := make([]string, 100)
+	before := make(map[string]string, len(keys))
+	for i := range keys {
+		keys[i] = fmt.Sprintf("user_%d", i)
+		before[keys[i]] = a.GetAffinityHost("Predict", keys[i])
+		if again := a.GetAffinityHost("Predict", keys[i]); again != before[keys[i]] {
+			t.Fatalf("GetAffinityHost() = %s then %s, want the same", before[keys[i]], again)
+		}
+	}
+	scorer["b"] = 0.5
+	a.rescoreHosts()
+	for _, key := range keys {
+		got := a.GetAffinityHost("Predict", key)
+		if got == "b" || (before[key] != "b" && got != before[key]) {
+			t.Errorf("GetAffinityHost(%s) = %s after b is unhealthy, was %s", key, got, before[key])
+		}
+	}
+}
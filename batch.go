@@ -0,0 +1,88 @@
+package core
+
+import (
+	"fmt"
+	"strconv"
+	"sync"
+
+	"github.com/byteplus-sdk/byteplus-sdk-go-rec-core/option"
+	"google.golang.org/protobuf/proto"
+)
+
+const defaultBatchConcurrency = 8
+
+// BatchResult is the result of one request of DoBatch
+type BatchResult struct {
+	Response proto.Message
+	Err      error
+}
+
+// BatchError is returned by DoBatch when some of the requests fail,
+// the error of each request is in its BatchResult
+type BatchError struct {
+	Total int
+	// The indexes of the failed requests in order
+	Failed []int
+}
+
+func (e *BatchError) Error() string {
+	return fmt.Sprintf("%d of %d batch requests failed, indexes:%v", len(e.Failed), e.Total, e.Failed)
+}
+
+// DoBatch sends the pb requests to path, at most concurrency of them are in flight, default is 8.
+// newResponse allocates the response of each request. The results are in the order of requests,
+// and *BatchError is returned if any of them fails. Each request has its own request id, which is
+// the request id of options with the index as suffix if it is specified. When the context of
+// options is done, the requests not sent yet fail with its error
+func (h *HTTPClient) DoBatch(path string, requests []proto.Message, newResponse func() proto.Message,
+	concurrency int, options *option.Options) ([]*BatchResult, error) {
+	if concurrency <= 0 {
+		concurrency = defaultBatchConcurrency
+	}
+	if options == nil {
+		options = &option.Options{}
+	}
+	results := make([]*BatchResult, len(requests))
+	tokens := make(chan struct{}, concurrency)
+	var wg sync.WaitGroup
+	for i, request := range requests {
+		if options.Context != nil && options.Context.Err() != nil {
+			results[i] = &BatchResult{Err: fmt.Errorf("request canceled: %w", options.Context.Err())}
+			continue
+		}
+		tokens <- struct{}{}
+		itemOptions := copyOptions(options)
+		if options.RequestID != "" {
+			itemOptions.RequestID = options.RequestID + "-" + strconv.Itoa(i)
+		}
+		wg.Add(1)
+		index, request := i, request
+		AsyncExecute(func() {
+			defer func() {
+				<-tokens
+				wg.Done()
+			}()
+			response := newResponse()
+			err := h.DoPBRequest(path, request, response, itemOptions)
+			if err != nil {
+				response = nil
+			}
+			results[index] = &BatchResult{Response: response, Err: err}
+		})
+	}
+	wg.Wait()
+	var failed []int
+	for i, result := range results {
+		// the result is not set if the request panics
+		if result == nil {
+			results[i] = &BatchResult{Err: fmt.Errorf("batch request %d is not done", i)}
+		}
+		if results[i].Err != nil {
+			failed = append(failed, i)
+		}
+	}
+	if len(failed) > 0 {
+		return results, &BatchError{Total: len(requests), Failed: failed}
+	}
+	return results, nil
+}
@@ -0,0 +1,77 @@
+package core
+
+import (
+	"errors"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/byteplus-sdk/byteplus-sdk-go-rec-core/option"
+	"github.com/valyala/fasthttp"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/types/known/wrapperspb"
+)
+
+func TestHTTPClient_DoBatch(t *testing.T) {
+	var inflight, maxInflight int64
+	var lock sync.Mutex
+	requestIDs := make(map[string]bool)
+	transport := TransportFunc(func(request *fasthttp.Request, response *fasthttp.Response, deadline time.Time) error {
+		current := atomic.AddInt64(&inflight, 1)
+		defer atomic.AddInt64(&inflight, -1)
+		lock.Lock()
+		if current > maxInflight {
+			maxInflight = current
+		}
+		requestIDs[string(request.Header.Peek("Request-Id"))] = true
+		lock.Unlock()
+		time.Sleep(10 * time.Millisecond)
+		body := &wrapperspb.StringValue{}
+		_ = proto.Unmarshal(request.Body(), body)
+		if body.Value == "bad" {
+			response.SetStatusCode(fasthttp.StatusBadRequest)
+			return nil
+		}
+		rspBytes, _ := proto.Marshal(wrapperspb.String("hello " + body.Value))
+		response.SetStatusCode(fasthttp.StatusOK)
+		response.SetBody(rspBytes)
+		return nil
+	})
+	airAuthConfig, _ := fillDefaultAirAuthConfig(nil)
+	availabler := &staticHostAvailabler{hosts: []string{"host"}}
+	h := &HTTPClient{
+		cli: newHTTPCaller("project", "tenant", true, "token", airAuthConfig, credential{},
+			availabler, &CallerConfig{DisableCompression: true}, "http", false, transport),
+		hostAvailabler: availabler,
+		schema:         "http",
+		projectID:      "project",
+	}
+	requests := []proto.Message{
+		wrapperspb.String("a"), wrapperspb.String("bad"), wrapperspb.String("c"), wrapperspb.String("d"),
+	}
+	results, err := h.DoBatch("/predict", requests, func() proto.Message {
+		return &wrapperspb.StringValue{}
+	}, 2, option.Conv2Options(option.WithRequestID("batch")))
+	var batchErr *BatchError
+	if !errors.As(err, &batchErr) || len(batchErr.Failed) != 1 || batchErr.Failed[0] != 1 {
+		t.Fatalf("DoBatch() error = %v, want request 1 failed", err)
+	}
+	for i, want := range []string{"hello a", "", "hello c", "hello d"} {
+		if want == "" {
+			if results[i].Err == nil {
+				t.Errorf("result %d should fail", i)
+			}
+			continue
+		}
+		if results[i].Err != nil || results[i].Response.(*wrapperspb.StringValue).Value != want {
+			t.Errorf("result %d = %v, %v, want %s", i, results[i].Response, results[i].Err, want)
+		}
+	}
+	if maxInflight > 2 {
+		t.Errorf("max in-flight requests = %d, want at most 2", maxInflight)
+	}
+	if len(requestIDs) != 4 || !requestIDs["batch-0"] || !requestIDs["batch-3"] {
+		t.Errorf("request ids = %v, want batch-0 to batch-3", requestIDs)
+	}
+}
@@ -0,0 +1,51 @@
+package core
+
+import (
+	"sync/atomic"
+)
+
+// CallerStats is a snapshot of the connection and request counters of HTTPClient
+type CallerStats struct {
+	// ConnsUnknown reports whether the connection counters are unknown, it is true
+	// when the client sends requests by the transport injected into the builder, such
+	// as by FastHTTPClient, whose connections are not seen by the client. The
+	// connection counters are always 0 then
+	ConnsUnknown bool
+	// The number of connections opened since the client was created
+	OpenedConns int64
+	// The number of connections closed since the client was created
+	ClosedConns int64
+	// The number of currently open connections, including busy and idle ones
+	OpenConns int64
+	// The number of requests waiting for response
+	PendingRequests int64
+	// The number of requests sent since the client was created
+	TotalRequests int64
+	// The number of requests failed with network error or non-200 status
+	FailedRequests int64
+}
+
+type callerStats struct {
+	openedConns     int64
+	closedConns     int64
+	pendingRequests int64
+	totalRequests   int64
+	failedRequests  int64
+	// the connections are opened by an injected transport, see CallerStats.ConnsUnknown,
+	// it is after the 64-bit counters to keep them aligned for the atomic operations
+	connsUnknown bool
+}
+
+func (s *callerStats) snapshot() CallerStats {
+	openedConns := atomic.LoadInt64(&s.openedConns)
+	closedConns := atomic.LoadInt64(&s.closedConns)
+	return CallerStats{
+		ConnsUnknown:    s.connsUnknown,
+		OpenedConns:     openedConns,
+		ClosedConns:     closedConns,
+		OpenConns:       openedConns - closedConns,
+		PendingRequests: atomic.LoadInt64(&s.pendingRequests),
+		TotalRequests:   atomic.LoadInt64(&s.totalRequests),
+		FailedRequests:  atomic.LoadInt64(&s.failedRequests),
+	}
+}
@@ -0,0 +1,62 @@
+package core
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/byteplus-sdk/byteplus-sdk-go-rec-core/option"
+	"github.com/valyala/fasthttp"
+)
+
+func TestHTTPClient_Stats(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/fail" {
+			w.WriteHeader(http.StatusBadGateway)
+		}
+	}))
+	defer server.Close()
+	airAuthConfig, _ := fillDefaultAirAuthConfig(nil)
+	c := newHTTPCaller("project", "tenant", true, "token", airAuthConfig, credential{},
+		&staticHostAvailabler{hosts: []string{"host"}}, &CallerConfig{}, "http", false, nil)
+	h := &HTTPClient{cli: c}
+	for _, path := range []string{"/ok", "/fail"} {
+		_, _ = c.doHTTPRequest("req", server.URL+path, map[string]string{}, []byte("{}"),
+			&option.Options{}, nil)
+	}
+	stats := h.Stats()
+	if stats.ConnsUnknown || stats.OpenedConns != 1 || stats.OpenConns != 1 {
+		t.Errorf("Stats() = %+v, want 1 open connection", stats)
+	}
+	if stats.TotalRequests != 2 || stats.FailedRequests != 1 || stats.PendingRequests != 0 {
+		t.Errorf("Stats() = %+v, want 2 requests and 1 failure", stats)
+	}
+}
+
+func TestHTTPClient_StatsOfInjectedTransport(t *testing.T) {
+	var called bool
+	transport := TransportFunc(func(request *fasthttp.Request, response *fasthttp.Response, deadline time.Time) error {
+		called = true
+		response.SetStatusCode(fasthttp.StatusOK)
+		return nil
+	})
+	builder := NewHTTPClientBuilder().TenantID("tenant").ProjectID("project").
+		UseAirAuth(true).AirAuthToken("token").Transport(transport).
+		HostAvailabler(&staticHostAvailabler{hosts: []string{"host"}})
+	builder.region = &RegionConfig{}
+	if err := builder.checkRequiredField(); err != nil {
+		t.Fatalf("checkRequiredField() error = %v", err)
+	}
+	builder.schema = "http"
+	builder.callerConfig = &CallerConfig{}
+	h := &HTTPClient{cli: builder.newHTTPCaller()}
+	_, err := h.cli.doHTTPRequest("req", "http://host/path", map[string]string{}, nil, &option.Options{}, nil)
+	if err != nil || !called {
+		t.Fatalf("doHTTPRequest() error = %v, injected transport called:%v", err, called)
+	}
+	stats := h.Stats()
+	if !stats.ConnsUnknown || stats.OpenedConns != 0 || stats.TotalRequests != 1 {
+		t.Errorf("Stats() = %+v, want unknown connections and 1 request", stats)
+	}
+}
@@ -0,0 +1,171 @@
+package core
+
+import (
+	"sync"
+	"time"
+
+	"github.com/byteplus-sdk/byteplus-sdk-go-rec-core/logs"
+	"github.com/byteplus-sdk/byteplus-sdk-go-rec-core/metrics"
+)
+
+const (
+	defaultCircuitBreakerOpenDuration   = 30 * time.Second
+	defaultCircuitBreakerHalfOpenProbes = 1
+)
+
+type circuitState int64
+
+const (
+	circuitClosed circuitState = iota
+	circuitOpen
+	circuitHalfOpen
+)
+
+func (s circuitState) String() string {
+	switch s {
+	case circuitOpen:
+		return "open"
+	case circuitHalfOpen:
+		return "half_open"
+	default:
+		return "closed"
+	}
+}
+
+type hostCircuit struct {
+	state    circuitState
+	failures int
+	// the time of entering current state
+	openedAt time.Time
+	probes   int
+	probeOKs int
+}
+
+// circuitBreaker stops sending requests to a host after failureThreshold consecutive
+// failures, the requests go to the other hosts until openDuration passes. Then
+// halfOpenProbes requests probe the host, the circuit is closed if they all succeed,
+// otherwise it is opened again
+type circuitBreaker struct {
+	projectID        string
+	failureThreshold int
+	openDuration     time.Duration
+	halfOpenProbes   int
+	lock             sync.Mutex
+	circuits         map[string]*hostCircuit
+}
+
+func newCircuitBreaker(projectID string, failureThreshold int,
+	openDuration time.Duration, halfOpenProbes int) *circuitBreaker {
+	if openDuration <= 0 {
+		openDuration = defaultCircuitBreakerOpenDuration
+	}
+	if halfOpenProbes <= 0 {
+		halfOpenProbes = defaultCircuitBreakerHalfOpenProbes
+	}
+	return &circuitBreaker{
+		projectID:        projectID,
+		failureThreshold: failureThreshold,
+		openDuration:     openDuration,
+		halfOpenProbes:   halfOpenProbes,
+		circuits:         make(map[string]*hostCircuit),
+	}
+}
+
+// pick returns host if its circuit allows the request, otherwise the first allowed
+// host of candidates. If no host is allowed, host is returned, as sending the request
+// is better than failing it directly
+func (b *circuitBreaker) pick(host string, candidates []string) string {
+	b.lock.Lock()
+	defer b.lock.Unlock()
+	if b.allow(host) {
+		return host
+	}
+	for _, candidate := range candidates {
+		if candidate != host && b.allow(candidate) {
+			logs.Debug("circuit of host is open, use %s instead of %s", candidate, host)
+			return candidate
+		}
+	}
+	return host
+}
+
+func (b *circuitBreaker) allow(host string) bool {
+	circuit, exist := b.circuits[host]
+	if !exist {
+		return true
+	}
+	if circuit.state == circuitOpen && time.Since(circuit.openedAt) >= b.openDuration {
+		b.transit(host, circuit, circuitHalfOpen)
+	}
+	switch circuit.state {
+	case circuitOpen:
+		return false
+	case circuitHalfOpen:
+		// the probes may be never recorded, such as canceled ones, probe again then
+		if circuit.probes >= b.halfOpenProbes && time.Since(circuit.openedAt) >= b.openDuration {
+			circuit.probes = 0
+			circuit.openedAt = time.Now()
+		}
+		if circuit.probes >= b.halfOpenProbes {
+			return false
+		}
+		circuit.probes++
+		return true
+	default:
+		return true
+	}
+}
+
+// record counts the result of a request sent to host, failure is the network
+// error or the 5xx response, which means the host is unhealthy
+func (b *circuitBreaker) record(host string, failure bool) {
+	b.lock.Lock()
+	defer b.lock.Unlock()
+	circuit, exist := b.circuits[host]
+	if !exist {
+		if !failure {
+			return
+		}
+		circuit = &hostCircuit{}
+		b.circuits[host] = circuit
+	}
+	switch circuit.state {
+	case circuitClosed:
+		if !failure {
+			circuit.failures = 0
+			return
+		}
+		circuit.failures++
+		if circuit.failures >= b.failureThreshold {
+			b.transit(host, circuit, circuitOpen)
+		}
+	case circuitHalfOpen:
+		if failure {
+			b.transit(host, circuit, circuitOpen)
+			return
+		}
+		circuit.probeOKs++
+		if circuit.probeOKs >= b.halfOpenProbes {
+			b.transit(host, circuit, circuitClosed)
+		}
+	}
+}
+
+func (b *circuitBreaker) transit(host string, circuit *hostCircuit, state circuitState) {
+	circuit.state = state
+	circuit.failures = 0
+	circuit.probes = 0
+	circuit.probeOKs = 0
+	circuit.openedAt = time.Now()
+	metricsTags := []string{
+		"project_id:" + b.projectID,
+		"host:" + escapeMetricsTagValue(host),
+	}
+	metrics.Store(metricsKeyCircuitBreakerState, int64(state), metricsTags...)
+	metrics.Counter(metricsKeyCircuitBreakerTransit, 1, append(metricsTags, "state:"+state.String())...)
+	if state == circuitOpen {
+		logs.Warn("circuit of host is open, host:%s", host)
+		return
+	}
+	logs.Info("circuit of host is %s, host:%s", state, host)
+}
@@ -0,0 +1,44 @@
+package core
+
+import (
+	"testing"
+	"time"
+)
+
+func TestCircuitBreaker(t *testing.T) {
+	hosts := []string{"a.byteplus.com", "b.byteplus.com"}
+	breaker := newCircuitBreaker("project", 2, 50*time.Millisecond, 1)
+	breaker.record(hosts[0], true)
+	if got := breaker.pick(hosts[0], hosts); got != hosts[0] {
+		t.Errorf("pick() = %v, want %v before threshold", got, hosts[0])
+	}
+	breaker.record(hosts[0], true)
+	if got := breaker.pick(hosts[0], hosts); got != hosts[1] {
+		t.Errorf("pick() = %v, want %v when circuit is open", got, hosts[1])
+	}
+	breaker.record(hosts[1], true)
+	breaker.record(hosts[1], true)
+	if got := breaker.pick(hosts[0], hosts); got != hosts[0] {
+		t.Errorf("pick() = %v, want %v when all circuits are open", got, hosts[0])
+	}
+
+	time.Sleep(60 * time.Millisecond)
+	// only one probe is allowed in half open
+	if got := breaker.pick(hosts[0], hosts[:1]); got != hosts[0] {
+		t.Errorf("pick() = %v, want probe %v", got, hosts[0])
+	}
+	if breaker.allow(hosts[0]) {
+		t.Errorf("allow() = true, want false when probes are used up")
+	}
+	breaker.record(hosts[0], false)
+	if state := breaker.circuits[hosts[0]].state; state != circuitClosed {
+		t.Errorf("state = %v, want closed after probe succeeds", state)
+	}
+	if breaker.circuits[hosts[1]].state != circuitOpen || breaker.pick(hosts[1], hosts) != hosts[1] {
+		t.Errorf("host b should probe after open duration")
+	}
+	breaker.record(hosts[1], true)
+	if state := breaker.circuits[hosts[1]].state; state != circuitOpen {
+		t.Errorf("state = %v, want open after probe fails", state)
+	}
+}
@@ -0,0 +1,82 @@
+package core
+
+import (
+	"net/http"
+	"sync/atomic"
+	"time"
+
+	"github.com/byteplus-sdk/byteplus-sdk-go-rec-core/coreerr"
+	"github.com/byteplus-sdk/byteplus-sdk-go-rec-core/logs"
+	"github.com/byteplus-sdk/byteplus-sdk-go-rec-core/metrics"
+	"github.com/byteplus-sdk/byteplus-sdk-go-rec-core/option"
+	"github.com/valyala/fasthttp"
+)
+
+// the skew shorter than the tolerance is ignored, since the Date header
+// of the server is in seconds and delayed by the network
+const clockSkewTolerance = 2 * time.Second
+
+// signingTime returns the time signing the requests, which is the local time
+// corrected by the clock skew detected from the server
+func (c *httpCaller) signingTime() time.Time {
+	return time.Now().Add(c.getClockSkew())
+}
+
+// getClockSkew returns the server time minus the local time
+func (c *httpCaller) getClockSkew() time.Duration {
+	return time.Duration(atomic.LoadInt64(&c.clockSkew))
+}
+
+// correctClockSkew detects the clock skew from the Date header of the response which
+// fails the authentication, and returns true if the signing time is corrected
+func (c *httpCaller) correctClockSkew(response *fasthttp.Response) bool {
+	if c.config.DisableClockSkewCorrection || !isAuthFailureStatus(response.StatusCode()) {
+		return false
+	}
+	serverTime, err := http.ParseTime(string(response.Header.Peek("Date")))
+	if err != nil {
+		return false
+	}
+	skew := serverTime.Sub(time.Now())
+	oldSkew := c.getClockSkew()
+	if absDuration(skew-oldSkew) < clockSkewTolerance {
+		return false
+	}
+	if absDuration(skew) < clockSkewTolerance {
+		skew = 0
+	}
+	if !atomic.CompareAndSwapInt64(&c.clockSkew, int64(oldSkew), int64(skew)) {
+		// corrected by another request
+		return true
+	}
+	metricsTags := []string{
+		"project_id:" + c.projectID,
+	}
+	metrics.Store(metricsKeyClockSkew, skew.Milliseconds(), metricsTags...)
+	logs.Warn("clock skew is detected, signing time is corrected, project_id:%s skew:%s", c.projectID, skew)
+	return true
+}
+
+func absDuration(d time.Duration) time.Duration {
+	if d < 0 {
+		return -d
+	}
+	return d
+}
+
+func isAuthFailureStatus(status int) bool {
+	return status == fasthttp.StatusUnauthorized || status == fasthttp.StatusForbidden
+}
+
+// doHTTPRequestWithClockSkew sends the request, and sends it once more with the
+// corrected signing time if it fails the authentication for the clock skew
+func (c *httpCaller) doHTTPRequestWithClockSkew(reqID string, url string, headers map[string]string,
+	reqBytes []byte, options *option.Options, rspHeader *fasthttp.ResponseHeader) ([]byte, error) {
+	skew := c.getClockSkew()
+	rspBytes, err := c.doHTTPRequest(reqID, url, headers, reqBytes, options, rspHeader)
+	if err == nil || !coreerr.IsAuthFailure(err) || c.getClockSkew() == skew {
+		return rspBytes, err
+	}
+	logs.Info("re-sign request with corrected clock, request_id:%s url:%s", reqID, url)
+	return c.doHTTPRequest(reqID, url, headers, reqBytes, options, rspHeader)
+}
@@ -0,0 +1,93 @@
+package core
+
+import (
+	"bufio"
+	"net/http"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/byteplus-sdk/byteplus-sdk-go-rec-core/option"
+	"github.com/valyala/fasthttp"
+)
+
+func TestHTTPCaller_clockSkewCorrection(t *testing.T) {
+	serverTime := time.Now().Add(time.Hour)
+	tests := []struct {
+		name       string
+		useAirAuth bool
+		// the signing time of the request
+		signedAt func(request *fasthttp.Request) time.Time
+	}{
+		{
+			name:       "air_auth",
+			useAirAuth: true,
+			signedAt: func(request *fasthttp.Request) time.Time {
+				ts, _ := strconv.ParseInt(string(request.Header.Peek("Tenant-Ts")), 10, 64)
+				return time.Unix(ts, 0)
+			},
+		},
+		{
+			name: "ak_sk",
+			signedAt: func(request *fasthttp.Request) time.Time {
+				signedAt, _ := time.Parse(timeFormatV4, string(request.Header.Peek("X-Date")))
+				return signedAt
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var attempts int
+			transport := TransportFunc(func(request *fasthttp.Request, response *fasthttp.Response,
+				deadline time.Time) error {
+				attempts++
+				setResponseDate(response, serverTime)
+				if absDuration(tt.signedAt(request).Sub(serverTime)) > 5*time.Second {
+					response.SetStatusCode(fasthttp.StatusUnauthorized)
+					return nil
+				}
+				response.SetStatusCode(fasthttp.StatusOK)
+				return nil
+			})
+			airAuthConfig, _ := fillDefaultAirAuthConfig(nil)
+			c := newHTTPCaller("project", "tenant", tt.useAirAuth, "token", airAuthConfig,
+				credential{accessKeyID: "ak", secretAccessKey: "sk", region: "cn", service: "air"},
+				&staticHostAvailabler{hosts: []string{"host"}}, &CallerConfig{}, "http", false, transport)
+			for i := 0; i < 2; i++ {
+				if _, err := c.doHTTPRequestWithRetry(newRequestContext("/path", &option.Options{}), "http://host/path",
+					map[string]string{"Request-Id": "req"}, []byte("{}"), &option.Options{}, nil); err != nil {
+					t.Fatalf("doHTTPRequestWithRetry() error = %v", err)
+				}
+			}
+			// re-signed once, then the later requests are signed with the corrected time
+			if attempts != 3 {
+				t.Errorf("attempts = %d, want 3", attempts)
+			}
+			if skew := c.getClockSkew(); absDuration(skew-time.Hour) > 2*time.Second {
+				t.Errorf("clock skew = %s, want about 1h", skew)
+			}
+		})
+	}
+
+	transport := TransportFunc(func(request *fasthttp.Request, response *fasthttp.Response, deadline time.Time) error {
+		setResponseDate(response, serverTime)
+		response.SetStatusCode(fasthttp.StatusUnauthorized)
+		return nil
+	})
+	airAuthConfig, _ := fillDefaultAirAuthConfig(nil)
+	c := newHTTPCaller("project", "tenant", true, "token", airAuthConfig, credential{},
+		&staticHostAvailabler{hosts: []string{"host"}}, &CallerConfig{DisableClockSkewCorrection: true},
+		"http", false, transport)
+	_, _ = c.doHTTPRequestWithRetry(newRequestContext("/path", &option.Options{}), "http://host/path",
+		map[string]string{"Request-Id": "req"}, []byte("{}"), &option.Options{}, nil)
+	if skew := c.getClockSkew(); skew != 0 {
+		t.Errorf("clock skew with correction disabled = %s, want 0", skew)
+	}
+}
+
+// setResponseDate sets the Date header, which is managed by fasthttp and can only be parsed
+func setResponseDate(response *fasthttp.Response, date time.Time) {
+	header := "HTTP/1.1 200 OK\r\nDate: " + date.UTC().Format(http.TimeFormat) + "\r\n\r\n"
+	_ = response.Header.Read(bufio.NewReader(strings.NewReader(header)))
+}
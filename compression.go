@@ -0,0 +1,84 @@
+package core
+
+import (
+	"io"
+	"strings"
+	"sync"
+
+	"github.com/andybalholm/brotli"
+	"github.com/klauspost/compress/gzip"
+	"github.com/klauspost/compress/zlib"
+	"github.com/klauspost/compress/zstd"
+	"github.com/valyala/fasthttp"
+)
+
+// The content encodings of request and response bodies
+const (
+	ContentEncodingGzip    = "gzip"
+	ContentEncodingBrotli  = "br"
+	ContentEncodingZstd    = "zstd"
+	ContentEncodingDeflate = "deflate"
+)
+
+// the response encodings accepted, the server picks the one it prefers
+var acceptEncoding = strings.Join([]string{
+	ContentEncodingGzip, ContentEncodingZstd, ContentEncodingBrotli, ContentEncodingDeflate,
+}, ", ")
+
+var (
+	zstdOnce    sync.Once
+	zstdEncoder *zstd.Encoder
+	zstdDecoder *zstd.Decoder
+)
+
+// initZstd creates the shared encoder and decoder, their EncodeAll and DecodeAll are concurrency safe
+func initZstd() {
+	zstdOnce.Do(func() {
+		zstdEncoder, _ = zstd.NewWriter(nil)
+		zstdDecoder, _ = zstd.NewReader(nil)
+	})
+}
+
+func isSupportedContentEncoding(encoding string) bool {
+	switch encoding {
+	case ContentEncodingGzip, ContentEncodingBrotli, ContentEncodingZstd, ContentEncodingDeflate:
+		return true
+	default:
+		return false
+	}
+}
+
+// compressBody compresses body by encoding, encoding should be supported
+func compressBody(encoding string, body []byte) []byte {
+	switch encoding {
+	case ContentEncodingBrotli:
+		return fasthttp.AppendBrotliBytes(nil, body)
+	case ContentEncodingZstd:
+		initZstd()
+		return zstdEncoder.EncodeAll(body, nil)
+	case ContentEncodingDeflate:
+		return fasthttp.AppendDeflateBytes(nil, body)
+	default:
+		return fasthttp.AppendGzipBytes(nil, body)
+	}
+}
+
+// newCompressWriter returns the writer compressing the streaming body into w by encoding,
+// the encoded bytes are the same format as compressBody
+func newCompressWriter(encoding string, w io.Writer) (io.WriteCloser, error) {
+	switch encoding {
+	case ContentEncodingBrotli:
+		return brotli.NewWriter(w), nil
+	case ContentEncodingZstd:
+		return zstd.NewWriter(w)
+	case ContentEncodingDeflate:
+		return zlib.NewWriter(w), nil
+	default:
+		return gzip.NewWriter(w), nil
+	}
+}
+
+func decompressZstd(body []byte) ([]byte, error) {
+	initZstd()
+	return zstdDecoder.DecodeAll(body, nil)
+}
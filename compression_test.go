@@ -0,0 +1,156 @@
+package core
+
+import (
+	"bytes"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/byteplus-sdk/byteplus-sdk-go-rec-core/option"
+	"github.com/valyala/fasthttp"
+)
+
+func TestCompressBody(t *testing.T) {
+	body := bytes.Repeat([]byte("byteplus recommendation "), 100)
+	for _, encoding := range []string{ContentEncodingGzip, ContentEncodingBrotli,
+		ContentEncodingZstd, ContentEncodingDeflate} {
+		t.Run(encoding, func(t *testing.T) {
+			compressed := compressBody(encoding, body)
+			if len(compressed) >= len(body) {
+				t.Errorf("compressed size %d should be less than %d", len(compressed), len(body))
+			}
+			response := fasthttp.AcquireResponse()
+			defer fasthttp.ReleaseResponse(response)
+			response.Header.Set("Content-Encoding", encoding)
+			response.SetBody(compressed)
+			decompressed, err := decompressResponse("http://host/path", response)
+			if err != nil {
+				t.Fatalf("decompressResponse() error = %v", err)
+			}
+			if !bytes.Equal(decompressed, body) {
+				t.Errorf("decompressResponse() mismatches the origin body")
+			}
+		})
+	}
+}
+
+func TestFillDefaultCallerConfig_RequestContentEncoding(t *testing.T) {
+	if got := fillDefaultCallerConfig(&CallerConfig{}).RequestContentEncoding; got != ContentEncodingGzip {
+		t.Errorf("default encoding = %s, want gzip", got)
+	}
+	if got := fillDefaultCallerConfig(&CallerConfig{RequestContentEncoding: "lz4"}).RequestContentEncoding; got != ContentEncodingGzip {
+		t.Errorf("unsupported encoding falls back to %s, want gzip", got)
+	}
+	if got := fillDefaultCallerConfig(&CallerConfig{RequestContentEncoding: "zstd"}).RequestContentEncoding; got != ContentEncodingZstd {
+		t.Errorf("encoding = %s, want zstd", got)
+	}
+}
+
+func TestHTTPCaller_shouldCompress(t *testing.T) {
+	body := bytes.Repeat([]byte("a"), 512)
+	tests := []struct {
+		name   string
+		config *CallerConfig
+		body   []byte
+		want   bool
+	}{
+		{"default", &CallerConfig{}, body, true},
+		{"empty body", &CallerConfig{}, nil, false},
+		{"disabled", &CallerConfig{DisableCompression: true}, body, false},
+		{"below threshold", &CallerConfig{CompressMinBytes: 1024}, body, false},
+		{"above threshold", &CallerConfig{CompressMinBytes: 256}, body, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := &httpCaller{config: tt.config}
+			if got := c.shouldCompress("http://host/path", tt.body); got != tt.want {
+				t.Errorf("shouldCompress() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestHTTPCaller_uncompressedContentEncoding(t *testing.T) {
+	var contentEncoding string
+	transport := TransportFunc(func(request *fasthttp.Request, response *fasthttp.Response, deadline time.Time) error {
+		contentEncoding = string(request.Header.Peek("Content-Encoding"))
+		response.SetStatusCode(fasthttp.StatusOK)
+		return nil
+	})
+	airAuthConfig, _ := fillDefaultAirAuthConfig(nil)
+	c := newHTTPCaller("project", "tenant", true, "token", airAuthConfig, credential{},
+		&staticHostAvailabler{hosts: []string{"host"}}, &CallerConfig{CompressMinBytes: 1024}, "http", false, transport)
+	for _, size := range []int{100, 2048} {
+		headers := c.buildHeaders(&option.Options{}, "application/x-protobuf")
+		if _, err := c.doHTTPRequest("req", "http://host/path", headers,
+			bytes.Repeat([]byte("a"), size), &option.Options{}, nil); err != nil {
+			t.Fatalf("doHTTPRequest() error = %v", err)
+		}
+		want := ""
+		if size >= 1024 {
+			want = ContentEncodingGzip
+		}
+		if contentEncoding != want {
+			t.Errorf("Content-Encoding of %d bytes = %q, want %q", size, contentEncoding, want)
+		}
+	}
+}
+
+func TestHTTPCaller_headersNotChanged(t *testing.T) {
+	transport := TransportFunc(func(request *fasthttp.Request, response *fasthttp.Response, deadline time.Time) error {
+		response.SetStatusCode(fasthttp.StatusOK)
+		return nil
+	})
+	airAuthConfig, _ := fillDefaultAirAuthConfig(nil)
+	c := newHTTPCaller("project", "tenant", true, "token", airAuthConfig, credential{},
+		&staticHostAvailabler{hosts: []string{"host"}}, &CallerConfig{}, "http", false, transport)
+	// the headers are shared by the attempts, a body-less attempt should not drop the
+	// encoding and content type of the others
+	headers := c.buildHeaders(&option.Options{}, "application/json")
+	if _, err := c.doHTTPRequest("req", "http://host/path", headers, nil,
+		&option.Options{Method: fasthttp.MethodGet}, nil); err != nil {
+		t.Fatalf("doHTTPRequest() error = %v", err)
+	}
+	if headers["Content-Encoding"] != ContentEncodingGzip || headers["Content-Type"] != "application/json" {
+		t.Errorf("headers = %v, want unchanged", headers)
+	}
+}
+
+func TestHTTPCaller_compressMinBytesOfRetries(t *testing.T) {
+	var encodings []string
+	var bodies [][]byte
+	transport := TransportFunc(func(request *fasthttp.Request, response *fasthttp.Response, deadline time.Time) error {
+		encodings = append(encodings, string(request.Header.Peek("Content-Encoding")))
+		bodies = append(bodies, append([]byte(nil), request.Body()...))
+		if len(encodings) == 1 {
+			return errors.New("mock net error")
+		}
+		response.SetStatusCode(fasthttp.StatusOK)
+		return nil
+	})
+	airAuthConfig, _ := fillDefaultAirAuthConfig(nil)
+	c := newHTTPCaller("project", "tenant", true, "token", airAuthConfig, credential{},
+		&staticHostAvailabler{hosts: []string{"host"}},
+		&CallerConfig{CompressMinBytes: 1024, MaxRetryTimes: 1}, "http", false, transport)
+	body := bytes.Repeat([]byte("a"), 1023)
+	headers := c.buildHeaders(&option.Options{}, "application/json")
+	if _, err := c.doHTTPRequestWithRetry(&RequestContext{}, "http://host/path",
+		headers, body, &option.Options{}, nil); err != nil {
+		t.Fatalf("doHTTPRequestWithRetry() error = %v", err)
+	}
+	// each attempt of the body below threshold is sent uncompressed
+	for i := range encodings {
+		if encodings[i] != "" || !bytes.Equal(bodies[i], body) {
+			t.Errorf("attempt %d Content-Encoding = %q, body size:%d, want uncompressed", i, encodings[i], len(bodies[i]))
+		}
+	}
+	// the body of threshold size is compressed
+	encodings, bodies = nil, nil
+	if _, err := c.doHTTPRequestWithRetry(&RequestContext{}, "http://host/path",
+		headers, append(body, 'a'), &option.Options{}, nil); err != nil {
+		t.Fatalf("doHTTPRequestWithRetry() error = %v", err)
+	}
+	if len(encodings) != 2 || encodings[1] != ContentEncodingGzip {
+		t.Errorf("Content-Encoding of threshold size = %v, want gzip", encodings)
+	}
+}
@@ -0,0 +1,89 @@
+package core
+
+import (
+	"net"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/byteplus-sdk/byteplus-sdk-go-rec-core/logs"
+	"github.com/byteplus-sdk/byteplus-sdk-go-rec-core/metrics"
+)
+
+// prewarmHost opens PrewarmConnections connections to host by concurrent pings
+func (c *httpCaller) prewarmHost(host string) {
+	var wg sync.WaitGroup
+	for i := 0; i < c.config.PrewarmConnections; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			Ping(c.projectID, c.transport, defaultHTTPCallerPingURLFormat,
+				c.schema, host, defaultHTTPCallerPingTimeout)
+		}()
+	}
+	wg.Wait()
+}
+
+func (c *httpCaller) prewarmHosts() {
+	for _, host := range c.hostAvailabler.GetHosts() {
+		c.prewarmHost(host)
+	}
+}
+
+func (c *httpCaller) initDNSRefreshExecutor() {
+	c.asyncExecute(func() {
+		resolvedAddrs := make(map[string]string)
+		c.refreshDNS(resolvedAddrs)
+		ticker := time.NewTicker(c.config.DNSRefreshInterval)
+		for {
+			select {
+			case <-c.stop:
+				ticker.Stop()
+				return
+			case <-ticker.C:
+				c.refreshDNS(resolvedAddrs)
+			}
+		}
+	})
+}
+
+// refreshDNS resolves the hosts in use, and closes the idle connections if the
+// addresses of any host change, so that the following requests connect the new
+// addresses. resolvedAddrs keeps the addresses of the last refresh
+func (c *httpCaller) refreshDNS(resolvedAddrs map[string]string) {
+	changed := false
+	for _, host := range c.hostAvailabler.GetHosts() {
+		hostname := host
+		if name, _, err := net.SplitHostPort(host); err == nil {
+			hostname = name
+		}
+		addrs, err := net.LookupHost(hostname)
+		if err != nil {
+			logs.Warn("refresh dns fail, host:%s err:%v", host, err)
+			continue
+		}
+		sort.Strings(addrs)
+		joinedAddrs := strings.Join(addrs, ",")
+		lastAddrs, exist := resolvedAddrs[host]
+		resolvedAddrs[host] = joinedAddrs
+		if !exist || lastAddrs == joinedAddrs {
+			continue
+		}
+		changed = true
+		metricsTags := []string{
+			"type:dns_changed",
+			"project_id:" + c.projectID,
+			"host:" + escapeMetricsTagValue(host),
+		}
+		metrics.Counter(metricsKeyCommonInfo, 1, metricsTags...)
+		logs.Info("dns of host is changed, host:%s old:%s new:%s", host, lastAddrs, joinedAddrs)
+	}
+	if !changed {
+		return
+	}
+	c.transport.CloseIdleConnections()
+	if c.keepAlive && c.config.PrewarmConnections > 0 {
+		c.prewarmHosts()
+	}
+}
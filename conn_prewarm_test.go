@@ -0,0 +1,45 @@
+package core
+
+import (
+	"net"
+	"testing"
+
+	"github.com/valyala/fasthttp"
+)
+
+type staticHostAvailabler struct {
+	hosts []string
+}
+
+func (s *staticHostAvailabler) GetHosts() []string {
+	return s.hosts
+}
+
+func (s *staticHostAvailabler) GetHost(path string) string {
+	return s.hosts[0]
+}
+
+func (s *staticHostAvailabler) Shutdown() {
+}
+
+func TestHTTPCaller_refreshDNS(t *testing.T) {
+	if _, err := net.LookupHost("localhost"); err != nil {
+		t.Skipf("localhost can not be resolved: %v", err)
+	}
+	c := &httpCaller{
+		hostAvailabler: &staticHostAvailabler{hosts: []string{"localhost:8080"}},
+		config:         &CallerConfig{},
+		transport:      &fasthttp.Client{},
+	}
+	resolvedAddrs := map[string]string{}
+	c.refreshDNS(resolvedAddrs)
+	resolved := resolvedAddrs["localhost:8080"]
+	if resolved == "" {
+		t.Fatalf("localhost should be resolved")
+	}
+	resolvedAddrs["localhost:8080"] = "10.0.0.1"
+	c.refreshDNS(resolvedAddrs)
+	if resolvedAddrs["localhost:8080"] != resolved {
+		t.Errorf("resolved addresses = %v, want %v", resolvedAddrs["localhost:8080"], resolved)
+	}
+}
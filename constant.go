@@ -1,38 +1,60 @@
 package core
 
-import "time"
+import (
+	"time"
+
+	"github.com/byteplus-sdk/byteplus-sdk-go-rec-core/coreerr"
+)
 
 const (
 	// All requests will have a XXXResponse corresponding to them,
 	// and a‘ll XXXResponses will contain a 'Status' field.
 	// The status of this request can be determined by the value of `Status.Code`
 	// Detail error code info：https://docs.byteplus.com/docs/error-code
+	// The codes are classified by package coreerr
 
 	// StatusCodeSuccess The request was executed successfully without any exception
-	StatusCodeSuccess = 0
+	StatusCodeSuccess = coreerr.StatusCodeSuccess
 
 	// StatusCodeIdempotent A Request with the same "Request-ID" was already received. This Request was rejected
-	StatusCodeIdempotent = 409
+	StatusCodeIdempotent = coreerr.StatusCodeIdempotent
 
 	// StatusCodeOperationLoss Operation information is missing due to an unknown exception
-	StatusCodeOperationLoss = 410
+	StatusCodeOperationLoss = coreerr.StatusCodeOperationLoss
 
 	// StatusCodeTooManyRequest The server hope slow down request frequency, and this request was rejected
-	StatusCodeTooManyRequest = 429
+	StatusCodeTooManyRequest = coreerr.StatusCodeTooManyRequest
 )
 
 const (
 	// The default keepalive duration
 	defaultKeepAliveDuration     = 60 * time.Second
 	defaultKeepAlivePingInterval = 45 * time.Second
+	// The default max wait of the Retry-After hint before retrying
+	defaultMaxRetryAfter = 10 * time.Second
 )
 
 const (
 	// Metrics Key
-	metricsKeyCommonInfo       = "common.info"
-	metricsKeyCommonWarn       = "common.warn"
-	metricsKeyCommonError      = "common.err"
-	metricsKeyRequestTotalCost = "request.total.cost"
-	metricsKeyRequestCount     = "request.count"
-	metricsKeyHeartbeatCount   = "heartbeat.count"
+	metricsKeyCommonInfo               = "common.info"
+	metricsKeyCommonWarn               = "common.warn"
+	metricsKeyCommonError              = "common.err"
+	metricsKeyRequestTotalCost         = "request.total.cost"
+	metricsKeyRequestCount             = "request.count"
+	metricsKeyHeartbeatCount           = "heartbeat.count"
+	metricsKeyResponseCacheHit         = "response_cache.hit"
+	metricsKeyResponseCacheMiss        = "response_cache.miss"
+	metricsKeyResponseCacheNotModified = "response_cache.not_modified"
+	metricsKeyRequestShared            = "request.shared"
+	metricsKeyRequestHedged            = "request.hedged"
+	metricsKeyRequestHedgeWin          = "request.hedge_win"
+	metricsKeyRequestFailover          = "request.failover"
+	metricsKeyAsyncWriteDropped        = "async_write.dropped"
+	metricsKeyAsyncWriteSpooled        = "async_write.spooled"
+	metricsKeyAsyncWriteBatch          = "async_write.batch"
+	metricsKeyLoadBalancerPick         = "load_balancer.pick"
+	metricsKeyCircuitBreakerState      = "circuit_breaker.state"
+	metricsKeyCircuitBreakerTransit    = "circuit_breaker.transit"
+	metricsKeyClockSkew                = "auth.clock_skew"
+	metricsKeyHostQuarantine           = "host.quarantine"
 )
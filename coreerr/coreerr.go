@@ -0,0 +1,195 @@
+// Package coreerr classifies the errors returned by the SDK, the http status of
+// responses and the Status.Code of response messages into one taxonomy, so that
+// the wrapping SDKs and applications can decide how to handle them by predicates
+// instead of checking status codes and error strings by themselves.
+package coreerr
+
+import (
+	"context"
+	"errors"
+	"net"
+	"strings"
+)
+
+// Class is the category of an error
+type Class int
+
+const (
+	// None means no error, or the error is not classified
+	None Class = iota
+	// Transport the request fails before a response is received, such as connection
+	// failure and timeout, it is retryable
+	Transport
+	// Throttled the server or the client rejects the request for exceeding the quota,
+	// it is retryable after backoff
+	Throttled
+	// AuthFailure the server rejects the credential or signature of the request
+	AuthFailure
+	// ClientError the request is invalid, retrying it does not help
+	ClientError
+	// ServerError the server fails to process the request
+	ServerError
+	// Canceled the request is canceled by the caller's context
+	Canceled
+)
+
+func (c Class) String() string {
+	switch c {
+	case Transport:
+		return "transport"
+	case Throttled:
+		return "throttled"
+	case AuthFailure:
+		return "auth_failure"
+	case ClientError:
+		return "client_error"
+	case ServerError:
+		return "server_error"
+	case Canceled:
+		return "canceled"
+	default:
+		return "none"
+	}
+}
+
+// The values of Status.Code of response messages
+// Detail error code info：https://docs.byteplus.com/docs/error-code
+const (
+	// StatusCodeSuccess The request was executed successfully without any exception
+	StatusCodeSuccess = 0
+	// StatusCodeIdempotent A Request with the same "Request-ID" was already received. This Request was rejected
+	StatusCodeIdempotent = 409
+	// StatusCodeOperationLoss Operation information is missing due to an unknown exception
+	StatusCodeOperationLoss = 410
+	// StatusCodeTooManyRequest The server hope slow down request frequency, and this request was rejected
+	StatusCodeTooManyRequest = 429
+)
+
+// the mark of network errors in the messages of the SDK errors
+const netErrMark = "[netErr]"
+
+// Classifier is implemented by the errors knowing their own class
+type Classifier interface {
+	ErrorClass() Class
+}
+
+// HTTPStatusError is implemented by the errors of non-200 http responses
+type HTTPStatusError interface {
+	HTTPStatus() int
+}
+
+// ClassOf returns the class of err
+func ClassOf(err error) Class {
+	if err == nil {
+		return None
+	}
+	var classifier Classifier
+	if errors.As(err, &classifier) {
+		return classifier.ErrorClass()
+	}
+	var statusErr HTTPStatusError
+	if errors.As(err, &statusErr) {
+		return ClassOfHTTPStatus(statusErr.HTTPStatus())
+	}
+	if errors.Is(err, context.Canceled) {
+		return Canceled
+	}
+	if errors.Is(err, context.DeadlineExceeded) {
+		return Transport
+	}
+	var netErr net.Error
+	if errors.As(err, &netErr) {
+		return Transport
+	}
+	message := err.Error()
+	if strings.Contains(message, netErrMark) || strings.Contains(strings.ToLower(message), "timeout") {
+		return Transport
+	}
+	return None
+}
+
+// ClassOfHTTPStatus returns the class of the http status of response
+func ClassOfHTTPStatus(status int) Class {
+	switch {
+	case status >= 200 && status < 400:
+		return None
+	case status == 401 || status == 403:
+		return AuthFailure
+	case status == 429:
+		return Throttled
+	case status >= 400 && status < 500:
+		return ClientError
+	case status >= 500:
+		return ServerError
+	default:
+		return None
+	}
+}
+
+// ClassOfCode returns the class of Status.Code of response message, the codes
+// of success, including the idempotent rejection, are None
+func ClassOfCode(code int32) Class {
+	switch {
+	case code == StatusCodeSuccess || code == 200 || code == StatusCodeIdempotent:
+		return None
+	case code == StatusCodeOperationLoss:
+		return ServerError
+	default:
+		return ClassOfHTTPStatus(int(code))
+	}
+}
+
+// IsRetryable reports whether retrying err may succeed
+func IsRetryable(err error) bool {
+	switch ClassOf(err) {
+	case Transport, Throttled, ServerError:
+		return true
+	default:
+		return false
+	}
+}
+
+func IsTransport(err error) bool {
+	return ClassOf(err) == Transport
+}
+
+func IsThrottled(err error) bool {
+	return ClassOf(err) == Throttled
+}
+
+func IsAuthFailure(err error) bool {
+	return ClassOf(err) == AuthFailure
+}
+
+func IsClientError(err error) bool {
+	return ClassOf(err) == ClientError
+}
+
+func IsServerError(err error) bool {
+	return ClassOf(err) == ServerError
+}
+
+func IsCanceled(err error) bool {
+	return ClassOf(err) == Canceled
+}
+
+// IsSuccessCode reports whether the request is executed successfully by Status.Code
+func IsSuccessCode(code int32) bool {
+	return code == StatusCodeSuccess || code == 200
+}
+
+// IsUploadSuccessCode is the same as IsSuccessCode, except that the request rejected
+// for idempotent is also considered as success, as it was already received
+func IsUploadSuccessCode(code int32) bool {
+	return code == StatusCodeSuccess || code == StatusCodeIdempotent
+}
+
+// IsRetryableCode reports whether retrying the request of Status.Code may succeed
+func IsRetryableCode(code int32) bool {
+	switch ClassOfCode(code) {
+	case Throttled, ServerError:
+		return true
+	default:
+		return false
+	}
+}
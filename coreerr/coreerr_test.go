@@ -0,0 +1,87 @@
+package coreerr
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"testing"
+)
+
+type statusErr int
+
+func (e statusErr) Error() string   { return "status error" }
+func (e statusErr) HTTPStatus() int { return int(e) }
+
+type classErr Class
+
+func (e classErr) Error() string     { return "class error" }
+func (e classErr) ErrorClass() Class { return Class(e) }
+
+func TestClassOf(t *testing.T) {
+	tests := []struct {
+		name string
+		err  error
+		want Class
+	}{
+		{"nil", nil, None},
+		{"unknown", errors.New("bad request body"), None},
+		{"classifier", fmt.Errorf("wrap: %w", classErr(Throttled)), Throttled},
+		{"status 401", statusErr(401), AuthFailure},
+		{"status 403", statusErr(403), AuthFailure},
+		{"status 429", statusErr(429), Throttled},
+		{"status 400", fmt.Errorf("wrap: %w", statusErr(400)), ClientError},
+		{"status 503", statusErr(503), ServerError},
+		{"canceled", fmt.Errorf("request canceled: %w", context.Canceled), Canceled},
+		{"deadline", context.DeadlineExceeded, Transport},
+		{"net error", &net.OpError{Op: "dial", Err: errors.New("refused")}, Transport},
+		{"net mark", errors.New(netErrMark + " timeout"), Transport},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := ClassOf(tt.err); got != tt.want {
+				t.Errorf("ClassOf() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestPredicates(t *testing.T) {
+	if !IsRetryable(statusErr(502)) || !IsRetryable(statusErr(429)) || !IsRetryable(context.DeadlineExceeded) {
+		t.Error("server, throttled and transport errors should be retryable")
+	}
+	if IsRetryable(statusErr(400)) || IsRetryable(statusErr(401)) || IsRetryable(context.Canceled) || IsRetryable(nil) {
+		t.Error("client, auth and canceled errors should not be retryable")
+	}
+	if !IsThrottled(statusErr(429)) || !IsAuthFailure(statusErr(401)) || !IsClientError(statusErr(404)) ||
+		!IsServerError(statusErr(500)) || !IsTransport(context.DeadlineExceeded) || !IsCanceled(context.Canceled) {
+		t.Error("predicate mismatches class")
+	}
+}
+
+func TestClassOfCode(t *testing.T) {
+	tests := []struct {
+		code int32
+		want Class
+	}{
+		{StatusCodeSuccess, None},
+		{200, None},
+		{StatusCodeIdempotent, None},
+		{StatusCodeOperationLoss, ServerError},
+		{StatusCodeTooManyRequest, Throttled},
+		{401, AuthFailure},
+		{400, ClientError},
+		{500, ServerError},
+	}
+	for _, tt := range tests {
+		if got := ClassOfCode(tt.code); got != tt.want {
+			t.Errorf("ClassOfCode(%d) = %v, want %v", tt.code, got, tt.want)
+		}
+	}
+	if !IsUploadSuccessCode(StatusCodeIdempotent) || IsSuccessCode(StatusCodeIdempotent) {
+		t.Error("idempotent code is upload success only")
+	}
+	if !IsRetryableCode(StatusCodeOperationLoss) || !IsRetryableCode(StatusCodeTooManyRequest) || IsRetryableCode(400) {
+		t.Error("retryable code mismatches")
+	}
+}
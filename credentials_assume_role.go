@@ -0,0 +1,146 @@
+package core
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/valyala/fasthttp"
+)
+
+const (
+	defaultSTSEndpoint        = "https://sts.byteplusapi.com"
+	defaultSTSRegion          = "ap-singapore-1"
+	defaultAssumeRoleDuration = time.Hour
+	stsService                = "sts"
+	stsAPIVersion             = "2018-01-01"
+	defaultSTSTimeout         = 5 * time.Second
+)
+
+// AssumeRoleCredentialsProvider exchanges the long-lived credentials of Source for the
+// temporary credentials of a role by the STS AssumeRole api, so that the primary keys are
+// not distributed to every service. Wrap it with NewRefreshingCredentialsProvider to
+// refresh the temporary credentials before they expire
+type AssumeRoleCredentialsProvider struct {
+	// The long-lived credentials calling AssumeRole, default is DefaultCredentialsProvider
+	Source CredentialsProvider
+	// The trn of the role to assume, such as 'trn:iam::2100000000:role/recommend'
+	RoleTrn string
+	// The name of the role session, which is recorded by the audit of the role,
+	// a random one is generated if it is empty
+	RoleSessionName string
+	// How long the temporary credentials last, default is 1 hour
+	Duration time.Duration
+	// The external id required by the trust policy of the role, it is optional
+	ExternalID string
+	// The endpoint of STS, default is https://sts.byteplusapi.com
+	Endpoint string
+	// The region signing the AssumeRole request, default is ap-singapore-1
+	Region string
+	// The timeout of the AssumeRole request, default is 5s
+	Timeout time.Duration
+	fetcher credentialsFetcher
+}
+
+func (p *AssumeRoleCredentialsProvider) setTransport(transport Transport) {
+	p.fetcher.setTransport(transport)
+	if setter, ok := p.Source.(credentialsTransportSetter); ok {
+		setter.setTransport(transport)
+	}
+}
+
+type assumeRoleResponse struct {
+	ResponseMetadata struct {
+		RequestID string `json:"RequestId"`
+		Error     *struct {
+			Code    string `json:"Code"`
+			Message string `json:"Message"`
+		} `json:"Error"`
+	} `json:"ResponseMetadata"`
+	Result struct {
+		Credentials credentialsJSON `json:"Credentials"`
+	} `json:"Result"`
+}
+
+func (p *AssumeRoleCredentialsProvider) Retrieve() (*Credentials, error) {
+	if p.RoleTrn == "" {
+		return nil, errors.New("role trn is empty")
+	}
+	source := p.Source
+	if source == nil {
+		source = DefaultCredentialsProvider()
+	}
+	sourceCredentials, err := source.Retrieve()
+	if err != nil {
+		return nil, fmt.Errorf("retrieve source credentials of assume role fail: %w", err)
+	}
+	request := fasthttp.AcquireRequest()
+	response := fasthttp.AcquireResponse()
+	defer func() {
+		fasthttp.ReleaseRequest(request)
+		fasthttp.ReleaseResponse(response)
+	}()
+	p.buildRequest(request)
+	sign(request, credential{
+		accessKeyID:     sourceCredentials.AccessKeyID,
+		secretAccessKey: sourceCredentials.SecretAccessKey,
+		sessionToken:    sourceCredentials.SessionToken,
+		region:          p.region(),
+		service:         stsService,
+	})
+	timeout := p.Timeout
+	if timeout <= 0 {
+		timeout = defaultSTSTimeout
+	}
+	if err = p.fetcher.doTimeout(request, response, timeout); err != nil {
+		return nil, fmt.Errorf("assume role fail: %w", err)
+	}
+	rsp := &assumeRoleResponse{}
+	if err = json.Unmarshal(response.Body(), rsp); err != nil {
+		return nil, fmt.Errorf("parse assume role response fail, status:%d err:%w", response.StatusCode(), err)
+	}
+	if rspErr := rsp.ResponseMetadata.Error; rspErr != nil {
+		return nil, fmt.Errorf("assume role fail, request_id:%s code:%s message:%s",
+			rsp.ResponseMetadata.RequestID, rspErr.Code, rspErr.Message)
+	}
+	if response.StatusCode() != fasthttp.StatusOK {
+		return nil, &StatusError{Status: response.StatusCode()}
+	}
+	return rsp.Result.Credentials.credentials()
+}
+
+func (p *AssumeRoleCredentialsProvider) buildRequest(request *fasthttp.Request) {
+	endpoint := p.Endpoint
+	if endpoint == "" {
+		endpoint = defaultSTSEndpoint
+	}
+	duration := p.Duration
+	if duration <= 0 {
+		duration = defaultAssumeRoleDuration
+	}
+	sessionName := p.RoleSessionName
+	if sessionName == "" {
+		sessionName = "byteplus-sdk-" + uuid.NewString()[:8]
+	}
+	request.Header.SetMethod(fasthttp.MethodGet)
+	request.SetRequestURI(endpoint)
+	args := request.URI().QueryArgs()
+	args.Set("Action", "AssumeRole")
+	args.Set("Version", stsAPIVersion)
+	args.Set("RoleTrn", p.RoleTrn)
+	args.Set("RoleSessionName", sessionName)
+	args.Set("DurationSeconds", strconv.Itoa(int(duration.Seconds())))
+	if p.ExternalID != "" {
+		args.Set("ExternalId", p.ExternalID)
+	}
+}
+
+func (p *AssumeRoleCredentialsProvider) region() string {
+	if p.Region == "" {
+		return defaultSTSRegion
+	}
+	return p.Region
+}
@@ -0,0 +1,54 @@
+package core
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestAssumeRoleCredentialsProvider(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		query := r.URL.Query()
+		if !strings.Contains(r.Header.Get("Authorization"), "Credential=long-ak/") ||
+			!strings.Contains(r.Header.Get("Authorization"), "/sts/") ||
+			query.Get("Action") != "AssumeRole" || query.Get("DurationSeconds") != "900" ||
+			query.Get("ExternalId") != "external" || query.Get("RoleSessionName") != "session" {
+			fmt.Fprint(w, `{"ResponseMetadata":{"RequestId":"req","Error":{"Code":"InvalidParameter","Message":"bad"}}}`)
+			return
+		}
+		if query.Get("RoleTrn") != "trn:iam::1:role/rec" {
+			w.WriteHeader(http.StatusForbidden)
+			fmt.Fprint(w, `{"ResponseMetadata":{"RequestId":"req","Error":{"Code":"AccessDenied","Message":"denied"}}}`)
+			return
+		}
+		fmt.Fprint(w, `{"ResponseMetadata":{"RequestId":"req"},"Result":{"Credentials":{"AccessKeyId":"role-ak",`+
+			`"SecretAccessKey":"role-sk","SessionToken":"role-token","ExpiredTime":"2030-01-02T15:04:05Z"}}}`)
+	}))
+	defer server.Close()
+
+	provider := &AssumeRoleCredentialsProvider{
+		Source:          &StaticCredentialsProvider{Credentials: Credentials{AccessKeyID: "long-ak", SecretAccessKey: "sk"}},
+		RoleTrn:         "trn:iam::1:role/rec",
+		RoleSessionName: "session",
+		Duration:        15 * time.Minute,
+		ExternalID:      "external",
+		Endpoint:        server.URL,
+	}
+	credentials, err := provider.Retrieve()
+	if err != nil {
+		t.Fatalf("Retrieve() error = %v", err)
+	}
+	want := Credentials{AccessKeyID: "role-ak", SecretAccessKey: "role-sk", SessionToken: "role-token",
+		Expiration: time.Date(2030, 1, 2, 15, 4, 5, 0, time.UTC)}
+	if *credentials != want {
+		t.Errorf("Retrieve() = %+v, want %+v", *credentials, want)
+	}
+
+	provider.RoleTrn = "trn:iam::1:role/other"
+	if _, err = provider.Retrieve(); err == nil || !strings.Contains(err.Error(), "AccessDenied") {
+		t.Errorf("Retrieve() of denied role error = %v", err)
+	}
+}
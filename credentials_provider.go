@@ -0,0 +1,492 @@
+package core
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/byteplus-sdk/byteplus-sdk-go-rec-core/coreerr"
+	"github.com/byteplus-sdk/byteplus-sdk-go-rec-core/logs"
+	"github.com/byteplus-sdk/byteplus-sdk-go-rec-core/metrics"
+	"github.com/valyala/fasthttp"
+)
+
+const (
+	// The environment variables read by EnvCredentialsProvider
+	EnvAccessKeyID     = "BYTEPLUS_ACCESS_KEY_ID"
+	EnvSecretAccessKey = "BYTEPLUS_SECRET_ACCESS_KEY"
+	EnvSessionToken    = "BYTEPLUS_SESSION_TOKEN"
+	// EnvCredentialsFile overrides the default path of the credentials file
+	EnvCredentialsFile = "BYTEPLUS_SHARED_CREDENTIALS_FILE"
+	// EnvProfile overrides the default profile of the credentials file
+	EnvProfile = "BYTEPLUS_PROFILE"
+	// EnvInstanceMetadataCredentialsURL overrides the default url of the credentials
+	// in the instance metadata service
+	EnvInstanceMetadataCredentialsURL = "BYTEPLUS_INSTANCE_METADATA_CREDENTIALS_URL"
+
+	defaultCredentialsProfile = "default"
+	// the credentials file relative to the home dir
+	defaultCredentialsFile                = ".byteplus/credentials"
+	defaultInstanceMetadataCredentialsURL = "http://100.96.0.96/latest/meta-data/iam/security_credentials/"
+	defaultCredentialsFetchTimeout        = time.Second
+	// the credentials without expiration are retrieved again after the interval,
+	// so that the rotated env or credentials file take effect
+	defaultCredentialsRefreshInterval = 5 * time.Minute
+	// the credentials are refreshed the window before they expire
+	defaultCredentialsExpiryWindow = time.Minute
+	// the interval before retrying when the provider fails but the last credentials are usable
+	defaultCredentialsRetryInterval = 10 * time.Second
+)
+
+// Credentials is the AK/SK credentials signing the requests
+type Credentials struct {
+	AccessKeyID     string
+	SecretAccessKey string
+	// The session token of the temporary credentials, it is optional
+	SessionToken string
+	// When the credentials expire, zero means they never expire
+	Expiration time.Time
+}
+
+// CredentialsProvider provides the credentials signing the requests, it is called
+// again when the credentials expire, so that the rotated credentials take effect
+type CredentialsProvider interface {
+	Retrieve() (*Credentials, error)
+}
+
+// CredentialsError is returned when no credentials can be retrieved for the request
+type CredentialsError struct {
+	Err error
+}
+
+func (e *CredentialsError) Error() string {
+	return fmt.Sprintf("retrieve credentials fail: %v", e.Err)
+}
+
+func (e *CredentialsError) Unwrap() error {
+	return e.Err
+}
+
+func (e *CredentialsError) ErrorClass() coreerr.Class {
+	return coreerr.AuthFailure
+}
+
+// StaticCredentialsProvider provides the fixed credentials
+type StaticCredentialsProvider struct {
+	Credentials Credentials
+}
+
+func (p *StaticCredentialsProvider) Retrieve() (*Credentials, error) {
+	if p.Credentials.AccessKeyID == "" || p.Credentials.SecretAccessKey == "" {
+		return nil, errors.New("static credentials are empty")
+	}
+	credentials := p.Credentials
+	return &credentials, nil
+}
+
+// EnvCredentialsProvider reads the credentials from the environment variables
+// BYTEPLUS_ACCESS_KEY_ID, BYTEPLUS_SECRET_ACCESS_KEY and BYTEPLUS_SESSION_TOKEN
+type EnvCredentialsProvider struct{}
+
+func (p *EnvCredentialsProvider) Retrieve() (*Credentials, error) {
+	credentials := &Credentials{
+		AccessKeyID:     os.Getenv(EnvAccessKeyID),
+		SecretAccessKey: os.Getenv(EnvSecretAccessKey),
+		SessionToken:    os.Getenv(EnvSessionToken),
+	}
+	if credentials.AccessKeyID == "" || credentials.SecretAccessKey == "" {
+		return nil, fmt.Errorf("%s or %s is not set", EnvAccessKeyID, EnvSecretAccessKey)
+	}
+	return credentials, nil
+}
+
+// FileCredentialsProvider reads the credentials from a profile of the ini file like:
+//
+//	[default]
+//	access_key_id = AK
+//	secret_access_key = SK
+//	session_token = TOKEN
+type FileCredentialsProvider struct {
+	// The path of the file, default is BYTEPLUS_SHARED_CREDENTIALS_FILE,
+	// or ~/.byteplus/credentials if it is not set
+	Path string
+	// The profile in the file, default is BYTEPLUS_PROFILE, or 'default' if it is not set
+	Profile string
+}
+
+func (p *FileCredentialsProvider) Retrieve() (*Credentials, error) {
+	path, err := p.path()
+	if err != nil {
+		return nil, err
+	}
+	profile := p.Profile
+	if profile == "" {
+		profile = os.Getenv(EnvProfile)
+	}
+	if profile == "" {
+		profile = defaultCredentialsProfile
+	}
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+	values, err := readIniSection(file, profile)
+	if err != nil {
+		return nil, fmt.Errorf("read credentials file %s fail: %w", path, err)
+	}
+	credentials := &Credentials{
+		AccessKeyID:     values["access_key_id"],
+		SecretAccessKey: values["secret_access_key"],
+		SessionToken:    values["session_token"],
+	}
+	if credentials.AccessKeyID == "" || credentials.SecretAccessKey == "" {
+		return nil, fmt.Errorf("credentials of profile %s are not found in %s", profile, path)
+	}
+	return credentials, nil
+}
+
+func (p *FileCredentialsProvider) path() (string, error) {
+	if p.Path != "" {
+		return p.Path, nil
+	}
+	if path := os.Getenv(EnvCredentialsFile); path != "" {
+		return path, nil
+	}
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(home, defaultCredentialsFile), nil
+}
+
+// readIniSection returns the key values of section, the comments start with '#' or ';'
+func readIniSection(file *os.File, section string) (map[string]string, error) {
+	values := make(map[string]string)
+	scanner := bufio.NewScanner(file)
+	inSection := false
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") || strings.HasPrefix(line, ";") {
+			continue
+		}
+		if strings.HasPrefix(line, "[") && strings.HasSuffix(line, "]") {
+			inSection = strings.TrimSpace(line[1:len(line)-1]) == section
+			continue
+		}
+		if !inSection {
+			continue
+		}
+		if idx := strings.Index(line, "="); idx > 0 {
+			values[strings.TrimSpace(line[:idx])] = strings.TrimSpace(line[idx+1:])
+		}
+	}
+	return values, scanner.Err()
+}
+
+// InstanceMetadataCredentialsProvider retrieves the temporary credentials of the role
+// bound to the cloud instance from the instance metadata service
+type InstanceMetadataCredentialsProvider struct {
+	// The url of the credentials in the metadata service, the role name is appended to it,
+	// default is BYTEPLUS_INSTANCE_METADATA_CREDENTIALS_URL, or the url of the metadata
+	// service of BytePlus if it is not set
+	URL string
+	// The role bound to the instance, it is read from URL if it is empty
+	Role string
+	// The timeout of each request to the metadata service, default is 1s
+	Timeout time.Duration
+	fetcher credentialsFetcher
+}
+
+// credentialsJSON is the credentials responded by the instance metadata service
+// and the STS endpoints
+type credentialsJSON struct {
+	AccessKeyID     string `json:"AccessKeyId"`
+	SecretAccessKey string `json:"SecretAccessKey"`
+	SessionToken    string `json:"SessionToken"`
+	ExpiredTime     string `json:"ExpiredTime"`
+}
+
+func (p *InstanceMetadataCredentialsProvider) Retrieve() (*Credentials, error) {
+	url := p.URL
+	if url == "" {
+		url = os.Getenv(EnvInstanceMetadataCredentialsURL)
+	}
+	if url == "" {
+		url = defaultInstanceMetadataCredentialsURL
+	}
+	if !strings.HasSuffix(url, "/") {
+		url += "/"
+	}
+	role := p.Role
+	if role == "" {
+		roleBytes, err := p.get(url)
+		if err != nil {
+			return nil, fmt.Errorf("get instance role fail: %w", err)
+		}
+		role = strings.TrimSpace(strings.SplitN(string(roleBytes), "\n", 2)[0])
+		if role == "" {
+			return nil, errors.New("no role is bound to the instance")
+		}
+	}
+	rspBytes, err := p.get(url + role)
+	if err != nil {
+		return nil, fmt.Errorf("get instance credentials fail: %w", err)
+	}
+	return parseCredentialsJSON(rspBytes)
+}
+
+func (p *InstanceMetadataCredentialsProvider) get(url string) ([]byte, error) {
+	return p.fetcher.get(url, nil, p.Timeout)
+}
+
+func (p *InstanceMetadataCredentialsProvider) setTransport(transport Transport) {
+	p.fetcher.setTransport(transport)
+}
+
+func parseCredentialsJSON(data []byte) (*Credentials, error) {
+	rsp := &credentialsJSON{}
+	if err := json.Unmarshal(data, rsp); err != nil {
+		return nil, fmt.Errorf("parse credentials fail: %w", err)
+	}
+	return rsp.credentials()
+}
+
+func (c *credentialsJSON) credentials() (*Credentials, error) {
+	if c.AccessKeyID == "" || c.SecretAccessKey == "" {
+		return nil, errors.New("credentials are empty")
+	}
+	credentials := &Credentials{
+		AccessKeyID:     c.AccessKeyID,
+		SecretAccessKey: c.SecretAccessKey,
+		SessionToken:    c.SessionToken,
+	}
+	if c.ExpiredTime != "" {
+		var err error
+		credentials.Expiration, err = time.Parse(time.RFC3339, c.ExpiredTime)
+		if err != nil {
+			return nil, fmt.Errorf("parse credentials expired time fail: %w", err)
+		}
+	}
+	return credentials, nil
+}
+
+// credentialsTransportSetter is implemented by the credentials providers fetching the
+// credentials by http, HTTPClient sets its transport to them, so that they connect
+// through the proxy and with the tls config of CallerConfig
+type credentialsTransportSetter interface {
+	setTransport(transport Transport)
+}
+
+// credentialsFetcher sends the requests of the credentials providers, by the transport
+// set by HTTPClient, or by the default fasthttp client before it is set
+type credentialsFetcher struct {
+	// holds credentialsTransport
+	transport atomic.Value
+}
+
+type credentialsTransport struct {
+	Transport
+}
+
+// setTransport sets the transport if it is not set yet, the provider shared by
+// clients uses the transport of the first one
+func (f *credentialsFetcher) setTransport(transport Transport) {
+	if transport != nil {
+		f.transport.CompareAndSwap(nil, credentialsTransport{transport})
+	}
+}
+
+func (f *credentialsFetcher) doTimeout(request *fasthttp.Request, response *fasthttp.Response,
+	timeout time.Duration) error {
+	if transport, ok := f.transport.Load().(credentialsTransport); ok {
+		return transport.DoDeadline(request, response, time.Now().Add(timeout))
+	}
+	return fasthttp.DoTimeout(request, response, timeout)
+}
+
+// get gets url with headers and returns the response body
+func (f *credentialsFetcher) get(url string, headers map[string]string, timeout time.Duration) ([]byte, error) {
+	if timeout <= 0 {
+		timeout = defaultCredentialsFetchTimeout
+	}
+	request := fasthttp.AcquireRequest()
+	response := fasthttp.AcquireResponse()
+	defer func() {
+		fasthttp.ReleaseRequest(request)
+		fasthttp.ReleaseResponse(response)
+	}()
+	request.Header.SetMethod(fasthttp.MethodGet)
+	request.SetRequestURI(url)
+	for k, v := range headers {
+		request.Header.Set(k, v)
+	}
+	if err := f.doTimeout(request, response, timeout); err != nil {
+		return nil, err
+	}
+	if response.StatusCode() != fasthttp.StatusOK {
+		return nil, &StatusError{Status: response.StatusCode()}
+	}
+	return append([]byte(nil), response.Body()...), nil
+}
+
+// ChainCredentialsProvider returns the credentials of the first provider succeeding
+type ChainCredentialsProvider struct {
+	Providers []CredentialsProvider
+}
+
+// NewChainCredentialsProvider returns the provider trying providers in order
+func NewChainCredentialsProvider(providers ...CredentialsProvider) *ChainCredentialsProvider {
+	return &ChainCredentialsProvider{Providers: providers}
+}
+
+// DefaultCredentialsProvider returns the chain of the environment variables,
+// the credentials file and the instance metadata service
+func DefaultCredentialsProvider() *ChainCredentialsProvider {
+	return NewChainCredentialsProvider(
+		&EnvCredentialsProvider{},
+		&FileCredentialsProvider{},
+		&InstanceMetadataCredentialsProvider{},
+	)
+}
+
+func (p *ChainCredentialsProvider) setTransport(transport Transport) {
+	for _, provider := range p.Providers {
+		if setter, ok := provider.(credentialsTransportSetter); ok {
+			setter.setTransport(transport)
+		}
+	}
+}
+
+func (p *ChainCredentialsProvider) Retrieve() (*Credentials, error) {
+	var errs []string
+	for _, provider := range p.Providers {
+		credentials, err := provider.Retrieve()
+		if err == nil {
+			return credentials, nil
+		}
+		errs = append(errs, err.Error())
+	}
+	if len(errs) == 0 {
+		return nil, errors.New("no credentials provider")
+	}
+	return nil, fmt.Errorf("no credentials provider succeeds: [%s]", strings.Join(errs, "; "))
+}
+
+// credentialsCache caches the credentials of provider until they expire or,
+// if they never expire, until the refresh interval passes. The credentials are
+// refreshed in background while the cached ones are still valid, the callers
+// only wait for the refresh when there are no valid credentials, and the
+// concurrent callers share one refresh
+type credentialsCache struct {
+	provider CredentialsProvider
+	lock     sync.Mutex
+	current  *Credentials
+	// when the credentials are retrieved again
+	refreshAt time.Time
+	// closed when the running refresh finishes, nil if no refresh is running
+	refreshing chan struct{}
+	// the error of the last refresh
+	lastErr error
+}
+
+func newCredentialsCache(provider CredentialsProvider) *credentialsCache {
+	return &credentialsCache{provider: provider}
+}
+
+func (c *credentialsCache) get() (*Credentials, error) {
+	c.lock.Lock()
+	now := time.Now()
+	if c.current != nil && now.Before(c.refreshAt) {
+		defer c.lock.Unlock()
+		return c.current, nil
+	}
+	refreshing := c.startRefresh()
+	if c.isValid(now) {
+		defer c.lock.Unlock()
+		return c.current, nil
+	}
+	c.lock.Unlock()
+	<-refreshing
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	if c.isValid(time.Now()) {
+		return c.current, nil
+	}
+	return nil, &CredentialsError{Err: c.lastErr}
+}
+
+// isValid reports whether the current credentials are usable at now, it is called with lock
+func (c *credentialsCache) isValid(now time.Time) bool {
+	return c.current != nil && (c.current.Expiration.IsZero() || now.Before(c.current.Expiration))
+}
+
+// startRefresh starts refreshing the credentials unless it is running, it is called
+// with lock, and returns the channel closed when the refresh finishes
+func (c *credentialsCache) startRefresh() chan struct{} {
+	if c.refreshing != nil {
+		return c.refreshing
+	}
+	refreshing := make(chan struct{})
+	c.refreshing = refreshing
+	go func() {
+		defer close(refreshing)
+		c.refresh()
+	}()
+	return refreshing
+}
+
+func (c *credentialsCache) refresh() {
+	credentials, err := c.retrieve()
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	c.refreshing = nil
+	now := time.Now()
+	if err != nil {
+		metricsTags := []string{
+			"type:retrieve_credentials_fail",
+		}
+		metrics.Counter(metricsKeyCommonError, 1, metricsTags...)
+		c.lastErr = err
+		// the credentials which are about to expire are still usable
+		if c.isValid(now) {
+			logs.Warn("retrieve credentials fail, use the last ones, err:%v", err)
+			c.refreshAt = now.Add(defaultCredentialsRetryInterval)
+			return
+		}
+		logs.Error("retrieve credentials fail, err:%v", err)
+		return
+	}
+	c.current, c.lastErr = credentials, nil
+	c.refreshAt = now.Add(defaultCredentialsRefreshInterval)
+	if !credentials.Expiration.IsZero() {
+		c.refreshAt = credentials.Expiration.Add(-defaultCredentialsExpiryWindow)
+	}
+}
+
+// retrieve retrieves the credentials from provider, the panic of provider is returned as error
+func (c *credentialsCache) retrieve() (credentials *Credentials, err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			credentials, err = nil, fmt.Errorf("credentials provider panic: %v", r)
+		}
+	}()
+	return c.provider.Retrieve()
+}
+
+// accessKeyID returns the access key id of the last credentials
+func (c *credentialsCache) accessKeyID() string {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	if c.current == nil {
+		return ""
+	}
+	return c.current.AccessKeyID
+}
@@ -0,0 +1,236 @@
+package core
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/byteplus-sdk/byteplus-sdk-go-rec-core/coreerr"
+	"github.com/byteplus-sdk/byteplus-sdk-go-rec-core/option"
+	"github.com/valyala/fasthttp"
+)
+
+func TestCredentialsProviders(t *testing.T) {
+	t.Setenv(EnvAccessKeyID, "env-ak")
+	t.Setenv(EnvSecretAccessKey, "env-sk")
+	path := filepath.Join(t.TempDir(), "credentials")
+	content := "[default]\naccess_key_id = file-ak\nsecret_access_key = file-sk\n\n" +
+		"# comment\n[prod]\naccess_key_id=prod-ak\nsecret_access_key=prod-sk\nsession_token=prod-token\n"
+	if err := os.WriteFile(path, []byte(content), 0600); err != nil {
+		t.Fatal(err)
+	}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/credentials/":
+			fmt.Fprint(w, "role\n")
+		case "/credentials/role":
+			fmt.Fprint(w, `{"AccessKeyId":"ecs-ak","SecretAccessKey":"ecs-sk","SessionToken":"ecs-token",`+
+				`"ExpiredTime":"2030-01-02T15:04:05Z"}`)
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer server.Close()
+
+	tests := []struct {
+		name     string
+		provider CredentialsProvider
+		want     Credentials
+	}{
+		{"env", &EnvCredentialsProvider{}, Credentials{AccessKeyID: "env-ak", SecretAccessKey: "env-sk"}},
+		{"file_default", &FileCredentialsProvider{Path: path},
+			Credentials{AccessKeyID: "file-ak", SecretAccessKey: "file-sk"}},
+		{"file_profile", &FileCredentialsProvider{Path: path, Profile: "prod"},
+			Credentials{AccessKeyID: "prod-ak", SecretAccessKey: "prod-sk", SessionToken: "prod-token"}},
+		{"instance_metadata", &InstanceMetadataCredentialsProvider{URL: server.URL + "/credentials"},
+			Credentials{AccessKeyID: "ecs-ak", SecretAccessKey: "ecs-sk", SessionToken: "ecs-token",
+				Expiration: time.Date(2030, 1, 2, 15, 4, 5, 0, time.UTC)}},
+		{"chain_skips_failed", NewChainCredentialsProvider(&FileCredentialsProvider{Path: path + ".missing"},
+			&FileCredentialsProvider{Path: path}), Credentials{AccessKeyID: "file-ak", SecretAccessKey: "file-sk"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := tt.provider.Retrieve()
+			if err != nil {
+				t.Fatalf("Retrieve() error = %v", err)
+			}
+			if *got != tt.want {
+				t.Errorf("Retrieve() = %+v, want %+v", *got, tt.want)
+			}
+		})
+	}
+
+	_, err := NewChainCredentialsProvider(&FileCredentialsProvider{Path: path, Profile: "none"}).Retrieve()
+	if err == nil || !strings.Contains(err.Error(), "none") {
+		t.Errorf("Retrieve() of missing profile error = %v", err)
+	}
+}
+
+type countingCredentialsProvider struct {
+	calls       int
+	credentials []*Credentials
+	err         error
+}
+
+func (p *countingCredentialsProvider) Retrieve() (*Credentials, error) {
+	p.calls++
+	if p.err != nil {
+		return nil, p.err
+	}
+	return p.credentials[(p.calls-1)%len(p.credentials)], nil
+}
+
+func TestCredentialsCache(t *testing.T) {
+	provider := &countingCredentialsProvider{credentials: []*Credentials{
+		{AccessKeyID: "ak1", SecretAccessKey: "sk1", Expiration: time.Now().Add(time.Hour)},
+		{AccessKeyID: "ak2", SecretAccessKey: "sk2", Expiration: time.Now().Add(time.Hour)},
+	}}
+	cache := newCredentialsCache(provider)
+	for i := 0; i < 3; i++ {
+		if got, err := cache.get(); err != nil || got.AccessKeyID != "ak1" {
+			t.Fatalf("get() = %v, %v, want ak1", got, err)
+		}
+	}
+	if provider.calls != 1 {
+		t.Errorf("provider calls = %d, want 1", provider.calls)
+	}
+	// expiring within the window, the valid credentials are returned while
+	// they are retrieved again in background
+	cache.current.Expiration = time.Now().Add(time.Second)
+	cache.refreshAt = time.Now()
+	if got, _ := cache.get(); got.AccessKeyID != "ak1" {
+		t.Errorf("get() after expiring = %v, want ak1 before refreshed", got.AccessKeyID)
+	}
+	waitCredentialsRefreshed(cache)
+	if got, _ := cache.get(); got.AccessKeyID != "ak2" {
+		t.Errorf("get() after refreshed = %v, want ak2", got.AccessKeyID)
+	}
+	// the last credentials are used while the provider fails before they expire
+	provider.err = errors.New("unavailable")
+	cache.refreshAt = time.Now()
+	if got, err := cache.get(); err != nil || got.AccessKeyID != "ak2" {
+		t.Errorf("get() when provider fails = %v, %v, want ak2", got, err)
+	}
+	waitCredentialsRefreshed(cache)
+	cache.current.Expiration = time.Now().Add(-time.Second)
+	cache.refreshAt = time.Now()
+	if _, err := cache.get(); !coreerr.IsAuthFailure(err) {
+		t.Errorf("get() of expired credentials error = %v, want auth failure", err)
+	}
+}
+
+func waitCredentialsRefreshed(cache *credentialsCache) {
+	cache.lock.Lock()
+	refreshing := cache.refreshing
+	cache.lock.Unlock()
+	if refreshing != nil {
+		<-refreshing
+	}
+}
+
+// blockingCredentialsProvider blocks Retrieve until release is closed
+type blockingCredentialsProvider struct {
+	calls   int32
+	release chan struct{}
+}
+
+func (p *blockingCredentialsProvider) Retrieve() (*Credentials, error) {
+	atomic.AddInt32(&p.calls, 1)
+	<-p.release
+	return &Credentials{AccessKeyID: "ak", SecretAccessKey: "sk", Expiration: time.Now().Add(time.Hour)}, nil
+}
+
+func TestCredentialsCache_refresh(t *testing.T) {
+	provider := &blockingCredentialsProvider{release: make(chan struct{})}
+	cache := newCredentialsCache(provider)
+	// the valid credentials are returned without waiting for the refresh
+	cache.current = &Credentials{AccessKeyID: "old", SecretAccessKey: "sk", Expiration: time.Now().Add(time.Minute)}
+	if got, err := cache.get(); err != nil || got.AccessKeyID != "old" {
+		t.Fatalf("get() = %v, %v, want the valid old credentials", got, err)
+	}
+	// the callers without valid credentials share one refresh
+	cache.lock.Lock()
+	cache.current = nil
+	cache.lock.Unlock()
+	var wg sync.WaitGroup
+	for i := 0; i < 3; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if got, err := cache.get(); err != nil || got.AccessKeyID != "ak" {
+				t.Errorf("get() = %v, %v, want ak", got, err)
+			}
+		}()
+	}
+	time.Sleep(20 * time.Millisecond)
+	close(provider.release)
+	wg.Wait()
+	if calls := atomic.LoadInt32(&provider.calls); calls != 1 {
+		t.Errorf("provider calls = %d, want 1", calls)
+	}
+}
+
+func TestCredentialsFetcher(t *testing.T) {
+	var requested []string
+	transport := TransportFunc(func(request *fasthttp.Request, response *fasthttp.Response, deadline time.Time) error {
+		requested = append(requested, string(request.URI().FullURI()))
+		if strings.HasSuffix(string(request.URI().Path()), "/credentials/") {
+			response.SetBodyString("role\n")
+			return nil
+		}
+		response.SetBodyString(`{"AccessKeyId":"ak","SecretAccessKey":"sk"}`)
+		return nil
+	})
+	// the url of metadata service is overridden by env
+	t.Setenv(EnvInstanceMetadataCredentialsURL, "http://127.0.0.1:1/credentials/")
+	if _, err := (&InstanceMetadataCredentialsProvider{}).Retrieve(); err == nil {
+		t.Errorf("Retrieve() without transport should fail on the unreachable url")
+	}
+	// the providers in chain fetch by the transport of client
+	provider := NewChainCredentialsProvider(&InstanceMetadataCredentialsProvider{})
+	provider.setTransport(transport)
+	credentials, err := provider.Retrieve()
+	if err != nil || credentials.AccessKeyID != "ak" || len(requested) != 2 ||
+		requested[1] != "http://127.0.0.1:1/credentials/role" {
+		t.Errorf("Retrieve() = %v, %v, requested:%v", credentials, err, requested)
+	}
+}
+
+func TestHTTPCaller_credentialsProvider(t *testing.T) {
+	var authorization string
+	transport := TransportFunc(func(request *fasthttp.Request, response *fasthttp.Response, deadline time.Time) error {
+		authorization = string(request.Header.Peek("Authorization"))
+		response.SetStatusCode(fasthttp.StatusOK)
+		return nil
+	})
+	airAuthConfig, _ := fillDefaultAirAuthConfig(nil)
+	provider := &countingCredentialsProvider{credentials: []*Credentials{{AccessKeyID: "rotated-ak", SecretAccessKey: "sk"}}}
+	c := newHTTPCaller("project", "tenant", false, "", airAuthConfig, credential{region: "cn", service: "air"},
+		&staticHostAvailabler{hosts: []string{"host"}}, &CallerConfig{}, "http", false, transport)
+	c.credentialsCache = newCredentialsCache(provider)
+	if _, err := c.doHTTPRequest("req", "http://host/path", map[string]string{"Request-Id": "req"},
+		[]byte("{}"), &option.Options{}, nil); err != nil {
+		t.Fatalf("doHTTPRequest() error = %v", err)
+	}
+	if !strings.Contains(authorization, "Credential=rotated-ak/") {
+		t.Errorf("Authorization = %q, want signed by rotated-ak", authorization)
+	}
+
+	provider.err = errors.New("unavailable")
+	c.credentialsCache = newCredentialsCache(provider)
+	authorization = ""
+	_, err := c.doHTTPRequest("req", "http://host/path", map[string]string{"Request-Id": "req"},
+		[]byte("{}"), &option.Options{}, nil)
+	var credentialsErr *CredentialsError
+	if !errors.As(err, &credentialsErr) || !coreerr.IsAuthFailure(err) || authorization != "" {
+		t.Errorf("doHTTPRequest() without credentials error = %v, sent = %v", err, authorization != "")
+	}
+}
@@ -0,0 +1,116 @@
+package core
+
+import (
+	"errors"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/byteplus-sdk/byteplus-sdk-go-rec-core/logs"
+	"github.com/byteplus-sdk/byteplus-sdk-go-rec-core/metrics"
+)
+
+const (
+	// the temporary credentials are refreshed the duration before they expire
+	defaultCredentialsRefreshBefore = 5 * time.Minute
+)
+
+// STSEndpointCredentialsProvider retrieves the temporary credentials from an endpoint
+// of the user, which responds the json like:
+//
+//	{"AccessKeyId":"AK","SecretAccessKey":"SK","SessionToken":"TOKEN","ExpiredTime":"2006-01-02T15:04:05Z"}
+type STSEndpointCredentialsProvider struct {
+	URL string
+	// The headers of the request to the endpoint, such as the authorization of the endpoint
+	Headers map[string]string
+	// The timeout of each request to the endpoint, default is 1s
+	Timeout time.Duration
+	fetcher credentialsFetcher
+}
+
+func (p *STSEndpointCredentialsProvider) Retrieve() (*Credentials, error) {
+	if p.URL == "" {
+		return nil, errors.New("sts endpoint is empty")
+	}
+	rspBytes, err := p.fetcher.get(p.URL, p.Headers, p.Timeout)
+	if err != nil {
+		return nil, err
+	}
+	return parseCredentialsJSON(rspBytes)
+}
+
+func (p *STSEndpointCredentialsProvider) setTransport(transport Transport) {
+	p.fetcher.setTransport(transport)
+}
+
+// RefreshingCredentialsProvider keeps the temporary credentials of provider, such as the
+// STS session tokens, and refreshes them in background before they expire, so that the
+// requests are never blocked by refreshing except the first one
+type RefreshingCredentialsProvider struct {
+	// the unix nano of the last background refresh, the failed refresh is retried after an
+	// interval. It is the first field, so that it is 64-bit aligned for the atomic operations
+	// on 32-bit platforms
+	lastRefresh   int64
+	provider      CredentialsProvider
+	refreshBefore time.Duration
+	current       atomic.Value
+	refreshing    int32
+	// serializes the blocking refreshes
+	lock sync.Mutex
+}
+
+// NewRefreshingCredentialsProvider returns the provider refreshing the credentials of
+// provider refreshBefore they expire, default is 5 minutes if refreshBefore is not positive
+func NewRefreshingCredentialsProvider(provider CredentialsProvider,
+	refreshBefore time.Duration) *RefreshingCredentialsProvider {
+	if refreshBefore <= 0 {
+		refreshBefore = defaultCredentialsRefreshBefore
+	}
+	return &RefreshingCredentialsProvider{provider: provider, refreshBefore: refreshBefore}
+}
+
+func (p *RefreshingCredentialsProvider) setTransport(transport Transport) {
+	if setter, ok := p.provider.(credentialsTransportSetter); ok {
+		setter.setTransport(transport)
+	}
+}
+
+func (p *RefreshingCredentialsProvider) Retrieve() (*Credentials, error) {
+	credentials, _ := p.current.Load().(*Credentials)
+	now := time.Now()
+	if credentials == nil || (!credentials.Expiration.IsZero() && !now.Before(credentials.Expiration)) {
+		return p.refresh(credentials)
+	}
+	if !credentials.Expiration.IsZero() && now.Add(p.refreshBefore).After(credentials.Expiration) &&
+		now.Sub(time.Unix(0, atomic.LoadInt64(&p.lastRefresh))) >= defaultCredentialsRetryInterval &&
+		atomic.CompareAndSwapInt32(&p.refreshing, 0, 1) {
+		atomic.StoreInt64(&p.lastRefresh, now.UnixNano())
+		go func() {
+			defer atomic.StoreInt32(&p.refreshing, 0)
+			_, _ = p.refresh(credentials)
+		}()
+	}
+	return credentials, nil
+}
+
+// refresh retrieves the credentials from provider unless the credentials
+// have been swapped from old by another refresh
+func (p *RefreshingCredentialsProvider) refresh(old *Credentials) (*Credentials, error) {
+	p.lock.Lock()
+	defer p.lock.Unlock()
+	if credentials, _ := p.current.Load().(*Credentials); credentials != old {
+		return credentials, nil
+	}
+	credentials, err := p.provider.Retrieve()
+	if err != nil {
+		metricsTags := []string{
+			"type:refresh_credentials_fail",
+		}
+		metrics.Counter(metricsKeyCommonError, 1, metricsTags...)
+		logs.Error("refresh credentials fail, err:%v", err)
+		return nil, err
+	}
+	p.current.Store(credentials)
+	logs.Info("refresh credentials, access_key_id:%s expiration:%s", credentials.AccessKeyID, credentials.Expiration)
+	return credentials, nil
+}
@@ -0,0 +1,60 @@
+package core
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestRefreshingCredentialsProvider(t *testing.T) {
+	var calls int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") != "Bearer sts" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		n := atomic.AddInt32(&calls, 1)
+		// the first credentials expire within the refresh window
+		expiration := time.Now().Add(time.Minute)
+		if n > 1 {
+			expiration = time.Now().Add(time.Hour)
+		}
+		fmt.Fprintf(w, `{"AccessKeyId":"ak%d","SecretAccessKey":"sk","SessionToken":"token%d","ExpiredTime":"%s"}`,
+			n, n, expiration.UTC().Format(time.RFC3339))
+	}))
+	defer server.Close()
+
+	provider := NewRefreshingCredentialsProvider(&STSEndpointCredentialsProvider{
+		URL:     server.URL,
+		Headers: map[string]string{"Authorization": "Bearer sts"},
+	}, 5*time.Minute)
+	credentials, err := provider.Retrieve()
+	if err != nil || credentials.AccessKeyID != "ak1" || credentials.SessionToken != "token1" {
+		t.Fatalf("Retrieve() = %+v, %v, want ak1", credentials, err)
+	}
+	// the expiring credentials are still returned while refreshing in background
+	if credentials, _ = provider.Retrieve(); credentials.AccessKeyID != "ak1" {
+		t.Errorf("Retrieve() while refreshing = %s, want ak1", credentials.AccessKeyID)
+	}
+	deadline := time.Now().Add(time.Second)
+	for time.Now().Before(deadline) {
+		if credentials, _ = provider.Retrieve(); credentials.AccessKeyID == "ak2" {
+			break
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	if credentials.AccessKeyID != "ak2" {
+		t.Errorf("Retrieve() after refreshing = %s, want ak2", credentials.AccessKeyID)
+	}
+	if got := atomic.LoadInt32(&calls); got != 2 {
+		t.Errorf("sts calls = %d, want 2", got)
+	}
+
+	_, err = (&STSEndpointCredentialsProvider{URL: server.URL}).Retrieve()
+	if err == nil {
+		t.Errorf("Retrieve() without authorization error = nil")
+	}
+}
@@ -0,0 +1,193 @@
+package core
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/byteplus-sdk/byteplus-sdk-go-rec-core/coreerr"
+)
+
+// classifiedError is the error of SDK knowing its class in coreerr
+type classifiedError struct {
+	message string
+	class   coreerr.Class
+}
+
+func newClassifiedError(message string, class coreerr.Class) error {
+	return &classifiedError{message: message, class: class}
+}
+
+func (e *classifiedError) Error() string {
+	return e.message
+}
+
+func (e *classifiedError) ErrorClass() coreerr.Class {
+	return e.class
+}
+
+// RetryAfterError is implemented by the errors carrying the backoff hint of server,
+// the applications retrying by themselves should wait RetryAfter before next try
+type RetryAfterError interface {
+	error
+	// RetryAfter returns the backoff hint, it is 0 if server gives no hint
+	RetryAfter() time.Duration
+}
+
+// StatusError is returned when the server responds with a status other than 200
+type StatusError struct {
+	// The http status of the response
+	Status     int
+	retryAfter time.Duration
+}
+
+func (e *StatusError) Error() string {
+	return netErrMark + "http status not 200"
+}
+
+// RetryAfter returns the backoff hint of Retry-After or rate-limit reset headers
+func (e *StatusError) RetryAfter() time.Duration {
+	return e.retryAfter
+}
+
+// HTTPStatus returns the http status of the response, it is classified by coreerr
+func (e *StatusError) HTTPStatus() int {
+	return e.Status
+}
+
+// RequestError is returned when a http request is sent but fails, such as the network
+// errors, timeout and non-200 responses. It unwraps to the cause, so that errors.As
+// finds *StatusError, and the predicates of coreerr, such as coreerr.IsThrottled and
+// coreerr.IsAuthFailure, work with it
+type RequestError struct {
+	// The http status of the response, it is 0 if no response is received
+	HTTPStatus int
+	RequestID  string
+	URL        string
+	// The body of the non-200 response as it is received
+	RawBody []byte
+	// Whether retrying the request may succeed
+	Retryable bool
+	Err       error
+}
+
+func newRequestError(reqID, url string, status int, rawBody []byte, err error) *RequestError {
+	requestErr := &RequestError{
+		HTTPStatus: status,
+		RequestID:  reqID,
+		URL:        url,
+		RawBody:    rawBody,
+		Err:        err,
+	}
+	requestErr.Retryable = coreerr.IsRetryable(requestErr)
+	return requestErr
+}
+
+func (e *RequestError) Error() string {
+	return fmt.Sprintf("%v, request_id:%s url:%s", e.Err, e.RequestID, e.URL)
+}
+
+func (e *RequestError) Unwrap() error {
+	return e.Err
+}
+
+// ErrorClass returns the class of the cause, the unknown errors without
+// response are the errors of transport, such as the closed connections
+func (e *RequestError) ErrorClass() coreerr.Class {
+	if e.Err == nil {
+		return coreerr.ClassOfHTTPStatus(e.HTTPStatus)
+	}
+	class := coreerr.ClassOf(e.Err)
+	if class == coreerr.None && e.HTTPStatus == 0 {
+		return coreerr.Transport
+	}
+	return class
+}
+
+// parseRetryAfter parses the backoff hint from the headers of response, peek returns
+// the value of header. Retry-After is either delay seconds or a http date, the
+// rate-limit reset headers are delay seconds
+func parseRetryAfter(peek func(key string) string, now time.Time) time.Duration {
+	if value := strings.TrimSpace(peek("Retry-After")); value != "" {
+		if seconds, err := strconv.Atoi(value); err == nil && seconds > 0 {
+			return time.Duration(seconds) * time.Second
+		}
+		if date, err := time.Parse(time.RFC1123, value); err == nil && date.After(now) {
+			return date.Sub(now)
+		}
+	}
+	for _, key := range []string{"RateLimit-Reset", "X-RateLimit-Reset"} {
+		value := strings.TrimSpace(peek(key))
+		if seconds, err := strconv.Atoi(value); err == nil && seconds > 0 {
+			return time.Duration(seconds) * time.Second
+		}
+	}
+	return 0
+}
+
+// RetryAfter returns the backoff hint carried by err, it is 0 if there is no hint
+func RetryAfter(err error) time.Duration {
+	var retryAfterErr RetryAfterError
+	if errors.As(err, &retryAfterErr) {
+		return retryAfterErr.RetryAfter()
+	}
+	return 0
+}
+
+// AttemptError is the error of one attempt of a request
+type AttemptError struct {
+	// The attempt number, starts from 1
+	Attempt int
+	Host    string
+	Cost    time.Duration
+	Err     error
+}
+
+func (e *AttemptError) Error() string {
+	return fmt.Sprintf("attempt:%d host:%s cost:%dms err:%v", e.Attempt, e.Host, e.Cost.Milliseconds(), e.Err)
+}
+
+func (e *AttemptError) Unwrap() error {
+	return e.Err
+}
+
+// AttemptErrors is returned when all the attempts of a request with retries fail,
+// it keeps the error of every attempt in order
+type AttemptErrors []*AttemptError
+
+func (e AttemptErrors) Error() string {
+	parts := make([]string, len(e))
+	for i, attemptErr := range e {
+		parts[i] = "[" + attemptErr.Error() + "]"
+	}
+	return fmt.Sprintf("all %d attempts failed: %s", len(e), strings.Join(parts, " "))
+}
+
+// Unwrap returns the errors of all the attempts, for errors.Is and errors.As of go1.20+
+func (e AttemptErrors) Unwrap() []error {
+	result := make([]error, len(e))
+	for i, attemptErr := range e {
+		result[i] = attemptErr
+	}
+	return result
+}
+
+// RetryAfter returns the backoff hint of the last attempt
+func (e AttemptErrors) RetryAfter() time.Duration {
+	return RetryAfter(e.Last())
+}
+
+// ErrorClass returns the class of the last attempt, as the earlier attempts are retried
+func (e AttemptErrors) ErrorClass() coreerr.Class {
+	return coreerr.ClassOf(e.Last())
+}
+
+// Last returns the error of the last attempt
+func (e AttemptErrors) Last() error {
+	if len(e) == 0 {
+		return nil
+	}
+	return e[len(e)-1].Err
+}
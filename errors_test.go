@@ -0,0 +1,228 @@
+package core
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/byteplus-sdk/byteplus-sdk-go-rec-core/coreerr"
+	"github.com/byteplus-sdk/byteplus-sdk-go-rec-core/option"
+	"github.com/valyala/fasthttp"
+)
+
+func TestParseRetryAfter(t *testing.T) {
+	now := time.Date(2022, 1, 1, 0, 0, 0, 0, time.UTC)
+	tests := []struct {
+		name    string
+		headers map[string]string
+		want    time.Duration
+	}{
+		{name: "no hint", headers: map[string]string{}, want: 0},
+		{name: "seconds", headers: map[string]string{"Retry-After": "3"}, want: 3 * time.Second},
+		{name: "http date", headers: map[string]string{"Retry-After": "Sat, 01 Jan 2022 00:00:05 GMT"}, want: 5 * time.Second},
+		{name: "past date", headers: map[string]string{"Retry-After": "Fri, 31 Dec 2021 00:00:05 GMT"}, want: 0},
+		{name: "rate limit reset", headers: map[string]string{"X-RateLimit-Reset": "2"}, want: 2 * time.Second},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			peek := func(key string) string {
+				return tt.headers[key]
+			}
+			if got := parseRetryAfter(peek, now); got != tt.want {
+				t.Errorf("parseRetryAfter() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestRetryAfter(t *testing.T) {
+	statusErr := &StatusError{Status: 429, retryAfter: time.Second}
+	err := AttemptErrors{
+		{Attempt: 1, Err: fmt.Errorf("timeout")},
+		{Attempt: 2, Err: statusErr},
+	}
+	if got := RetryAfter(err); got != time.Second {
+		t.Errorf("RetryAfter(AttemptErrors) = %v, want 1s", got)
+	}
+	if got := RetryAfter(fmt.Errorf("wrapped: %w", statusErr)); got != time.Second {
+		t.Errorf("RetryAfter(wrapped) = %v, want 1s", got)
+	}
+	if got := RetryAfter(fmt.Errorf("other")); got != 0 {
+		t.Errorf("RetryAfter(other) = %v, want 0", got)
+	}
+}
+
+func TestErrorClass(t *testing.T) {
+	tests := []struct {
+		name string
+		err  error
+		want coreerr.Class
+	}{
+		{"status", &StatusError{Status: 403}, coreerr.AuthFailure},
+		{"client throttled", ErrClientThrottled, coreerr.Throttled},
+		{"quota rejected", ErrQuotaRejected, coreerr.Throttled},
+		{"request id required", ErrRequestIDRequired, coreerr.ClientError},
+		{"last attempt", AttemptErrors{
+			{Attempt: 1, Err: &StatusError{Status: 400}},
+			{Attempt: 2, Err: &StatusError{Status: 503}},
+		}, coreerr.ServerError},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := coreerr.ClassOf(tt.err); got != tt.want {
+				t.Errorf("ClassOf() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestRequestError(t *testing.T) {
+	transport := TransportFunc(func(request *fasthttp.Request, response *fasthttp.Response, deadline time.Time) error {
+		response.SetStatusCode(fasthttp.StatusTooManyRequests)
+		response.Header.Set("Retry-After", "2")
+		response.SetBodyString("slow down")
+		return nil
+	})
+	airAuthConfig, _ := fillDefaultAirAuthConfig(nil)
+	c := newHTTPCaller("project", "tenant", true, "token", airAuthConfig, credential{},
+		&staticHostAvailabler{hosts: []string{"host"}}, &CallerConfig{}, "http", false, transport)
+	_, err := c.doHTTPRequest("req-1", "http://host/path", map[string]string{}, nil, &option.Options{}, nil)
+	var requestErr *RequestError
+	if !errors.As(err, &requestErr) {
+		t.Fatalf("doHTTPRequest() error = %v, want *RequestError", err)
+	}
+	if requestErr.HTTPStatus != fasthttp.StatusTooManyRequests || requestErr.RequestID != "req-1" ||
+		requestErr.URL != "http://host/path" || string(requestErr.RawBody) != "slow down" || !requestErr.Retryable {
+		t.Errorf("RequestError = %+v", requestErr)
+	}
+	var statusErr *StatusError
+	if !errors.As(err, &statusErr) || statusErr.Status != fasthttp.StatusTooManyRequests {
+		t.Errorf("errors.As(*StatusError) fails, err:%v", err)
+	}
+	if !coreerr.IsThrottled(err) || coreerr.IsAuthFailure(err) {
+		t.Errorf("class of err = %v, want throttled", coreerr.ClassOf(err))
+	}
+	if got := RetryAfter(err); got != 2*time.Second {
+		t.Errorf("RetryAfter() = %v, want 2s", got)
+	}
+}
+
+func TestHTTPCaller_retryWait(t *testing.T) {
+	hinted := &StatusError{Status: 429, retryAfter: 3 * time.Second}
+	tests := []struct {
+		name    string
+		config  *CallerConfig
+		options *option.Options
+		lastErr error
+		want    time.Duration
+	}{
+		{"no hint", &CallerConfig{RetryInterval: time.Second}, &option.Options{}, fmt.Errorf("timeout"), time.Second},
+		{"hint", &CallerConfig{RetryInterval: time.Second}, &option.Options{}, hinted, 3 * time.Second},
+		{"capped hint", &CallerConfig{MaxRetryAfter: 2 * time.Second}, &option.Options{}, hinted, 2 * time.Second},
+		{"interval longer", &CallerConfig{RetryInterval: 5 * time.Second}, &option.Options{}, hinted, 5 * time.Second},
+		{"hint ignored", &CallerConfig{MaxRetryAfter: -1}, &option.Options{}, hinted, 0},
+		{"option backoff", &CallerConfig{RetryInterval: time.Second},
+			option.Conv2Options(option.WithRetry(3, 5*time.Second)), hinted, 5 * time.Second},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := &httpCaller{config: fillDefaultCallerConfig(tt.config)}
+			if got := c.retryWait(tt.options, tt.lastErr); got != tt.want {
+				t.Errorf("retryWait() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestHTTPCaller_retryAfterHint(t *testing.T) {
+	var attempts []time.Time
+	transport := TransportFunc(func(request *fasthttp.Request, response *fasthttp.Response, deadline time.Time) error {
+		attempts = append(attempts, time.Now())
+		if len(attempts) == 1 {
+			response.SetStatusCode(fasthttp.StatusTooManyRequests)
+			response.Header.Set("Retry-After", "1")
+			return nil
+		}
+		response.SetStatusCode(fasthttp.StatusOK)
+		return nil
+	})
+	airAuthConfig, _ := fillDefaultAirAuthConfig(nil)
+	config := &CallerConfig{MaxRetryTimes: 1, MaxRetryAfter: 50 * time.Millisecond}
+	c := newHTTPCaller("project", "tenant", true, "token", airAuthConfig, credential{},
+		&staticHostAvailabler{hosts: []string{"host"}}, config, "http", false, transport)
+	_, err := c.doHTTPRequestWithRetry(&RequestContext{}, "http://host/path",
+		map[string]string{"Request-Id": "req"}, nil, &option.Options{}, nil)
+	if err != nil {
+		t.Fatalf("doHTTPRequestWithRetry() error = %v", err)
+	}
+	if len(attempts) != 2 {
+		t.Fatalf("attempts = %d, want 2", len(attempts))
+	}
+	if wait := attempts[1].Sub(attempts[0]); wait < 50*time.Millisecond {
+		t.Errorf("wait before retrying = %v, want at least 50ms", wait)
+	}
+}
+
+func TestHTTPCaller_retryOption(t *testing.T) {
+	var attempts int
+	transport := TransportFunc(func(request *fasthttp.Request, response *fasthttp.Response, deadline time.Time) error {
+		attempts++
+		response.SetStatusCode(fasthttp.StatusServiceUnavailable)
+		return nil
+	})
+	airAuthConfig, _ := fillDefaultAirAuthConfig(nil)
+	c := newHTTPCaller("project", "tenant", true, "token", airAuthConfig, credential{},
+		&staticHostAvailabler{hosts: []string{"host"}}, &CallerConfig{MaxRetryTimes: 1}, "http", false, transport)
+	tests := []struct {
+		name    string
+		options *option.Options
+		want    int
+	}{
+		{"client config", &option.Options{}, 2},
+		{"retry option", option.Conv2Options(option.WithRetry(3, time.Millisecond)), 3},
+		{"retry disabled", option.Conv2Options(option.WithRetry(1, 0)), 1},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			attempts = 0
+			_, err := c.doHTTPRequestWithRetry(&RequestContext{}, "http://host/path",
+				map[string]string{"Request-Id": "req"}, nil, tt.options, nil)
+			if err == nil || attempts != tt.want {
+				t.Errorf("attempts = %d, err = %v, want %d attempts", attempts, err, tt.want)
+			}
+		})
+	}
+}
+
+func TestRequestError_compressedBody(t *testing.T) {
+	tests := []struct {
+		name string
+		body []byte
+		want string
+	}{
+		{"gzip", fasthttp.AppendGzipBytes(nil, []byte("slow down")), "slow down"},
+		{"broken gzip", []byte("not gzip"), "not gzip"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			transport := TransportFunc(func(request *fasthttp.Request, response *fasthttp.Response, deadline time.Time) error {
+				response.SetStatusCode(fasthttp.StatusTooManyRequests)
+				response.Header.Set("Content-Encoding", "gzip")
+				response.SetBody(tt.body)
+				return nil
+			})
+			airAuthConfig, _ := fillDefaultAirAuthConfig(nil)
+			c := newHTTPCaller("project", "tenant", true, "token", airAuthConfig, credential{},
+				&staticHostAvailabler{hosts: []string{"host"}}, &CallerConfig{}, "http", false, transport)
+			_, err := c.doHTTPRequest("req-1", "http://host/path", map[string]string{}, nil, &option.Options{}, nil)
+			var requestErr *RequestError
+			if !errors.As(err, &requestErr) {
+				t.Fatalf("doHTTPRequest() error = %v, want *RequestError", err)
+			}
+			if string(requestErr.RawBody) != tt.want {
+				t.Errorf("RawBody = %q, want %q", requestErr.RawBody, tt.want)
+			}
+		})
+	}
+}
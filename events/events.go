@@ -0,0 +1,142 @@
+package events
+
+import (
+	"sync"
+	"time"
+
+	"github.com/byteplus-sdk/byteplus-sdk-go-rec-core/logs"
+)
+
+// Type is the type of SDK lifecycle event
+type Type string
+
+const (
+	// HostConfigChanged the order or the set of hosts used for requests is changed
+	HostConfigChanged Type = "host_config_changed"
+	// HostEjected the preferred host of a path is replaced by another host,
+	// Host is the ejected host
+	HostEjected Type = "host_ejected"
+	// AuthFailure the server rejects a request with 401 or 403
+	AuthFailure Type = "auth_failure"
+	// Throttled the server rejects a request with 429
+	Throttled Type = "throttled"
+	// RetryExhausted a request still fails after all the retries
+	RetryExhausted Type = "retry_exhausted"
+	// MetricsReportFailed reporting metrics or metrics logs to server fails
+	MetricsReportFailed Type = "metrics_report_failed"
+)
+
+// the buffer size of each subscriber, events are dropped when it is full
+const subscriberBufferSize = 1024
+
+// Event is published when something happens in SDK that the application
+// may want to alert on or react to. The fields not related to Type are empty
+type Event struct {
+	Type      Type
+	Time      time.Time
+	ProjectID string
+	RequestID string
+	URL       string
+	Host      string
+	// the http status of response, it is 0 if no response is received
+	Status int
+	// Message describes the event for human, such as the new host config
+	Message string
+	Err     error
+}
+
+// Handler handles the events of subscribed types. It is called in the
+// goroutine of the subscriber, so slow handlers do not block the SDK
+type Handler func(event *Event)
+
+// Bus delivers the published events to the subscribers asynchronously.
+// Events are dropped for a subscriber whose buffer is full
+type Bus struct {
+	lock        sync.RWMutex
+	subscribers map[*subscriber]struct{}
+}
+
+type subscriber struct {
+	types   map[Type]bool
+	events  chan *Event
+	handler Handler
+	once    sync.Once
+}
+
+func NewBus() *Bus {
+	return &Bus{subscribers: make(map[*subscriber]struct{})}
+}
+
+// Default is the bus the SDK publishes events to
+var Default = NewBus()
+
+// Subscribe calls handler with the events of types, or all the events if types is empty.
+// The returned function cancels the subscription
+func (b *Bus) Subscribe(handler Handler, types ...Type) (unsubscribe func()) {
+	sub := &subscriber{
+		events:  make(chan *Event, subscriberBufferSize),
+		handler: handler,
+	}
+	if len(types) > 0 {
+		sub.types = make(map[Type]bool, len(types))
+		for _, t := range types {
+			sub.types[t] = true
+		}
+	}
+	b.lock.Lock()
+	b.subscribers[sub] = struct{}{}
+	b.lock.Unlock()
+	go sub.run()
+	return func() {
+		b.lock.Lock()
+		delete(b.subscribers, sub)
+		b.lock.Unlock()
+		sub.once.Do(func() {
+			close(sub.events)
+		})
+	}
+}
+
+// Publish delivers event to the subscribers without blocking
+func (b *Bus) Publish(event *Event) {
+	if event.Time.IsZero() {
+		event.Time = time.Now()
+	}
+	b.lock.RLock()
+	defer b.lock.RUnlock()
+	for sub := range b.subscribers {
+		if sub.types != nil && !sub.types[event.Type] {
+			continue
+		}
+		select {
+		case sub.events <- event:
+		default:
+			logs.Debug("event subscriber is full, drop event:%s", event.Type)
+		}
+	}
+}
+
+func (s *subscriber) run() {
+	for event := range s.events {
+		s.handle(event)
+	}
+}
+
+func (s *subscriber) handle(event *Event) {
+	defer func() {
+		if err := recover(); err != nil {
+			logs.Error("event handler encounter panic:%+v, event:%s", err, event.Type)
+		}
+	}()
+	s.handler(event)
+}
+
+// Subscribe subscribes the events of Default bus
+func Subscribe(handler Handler, types ...Type) (unsubscribe func()) {
+	return Default.Subscribe(handler, types...)
+}
+
+// Publish publishes event to Default bus
+func Publish(event *Event) {
+	Default.Publish(event)
+}
@@ -0,0 +1,33 @@
+package events
+
+import (
+	"testing"
+	"time"
+)
+
+func TestBus(t *testing.T) {
+	bus := NewBus()
+	received := make(chan *Event, 10)
+	unsubscribe := bus.Subscribe(func(event *Event) {
+		received <- event
+	}, Throttled)
+	bus.Publish(&Event{Type: AuthFailure})
+	bus.Publish(&Event{Type: Throttled, Status: 429})
+	select {
+	case event := <-received:
+		if event.Type != Throttled || event.Time.IsZero() {
+			t.Errorf("unexpected event: %+v", event)
+		}
+	case <-time.After(time.Second):
+		t.Fatalf("subscriber should receive the throttled event")
+	}
+	unsubscribe()
+	bus.Publish(&Event{Type: Throttled})
+	select {
+	case event := <-received:
+		t.Errorf("unexpected event after unsubscribe: %+v", event)
+	case <-time.After(10 * time.Millisecond):
+	}
+	// unsubscribe twice is allowed
+	unsubscribe()
+}
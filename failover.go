@@ -0,0 +1,61 @@
+package core
+
+import (
+	"github.com/byteplus-sdk/byteplus-sdk-go-rec-core/coreerr"
+	"github.com/byteplus-sdk/byteplus-sdk-go-rec-core/logs"
+	"github.com/byteplus-sdk/byteplus-sdk-go-rec-core/metrics"
+	"github.com/byteplus-sdk/byteplus-sdk-go-rec-core/option"
+	"github.com/valyala/fasthttp"
+)
+
+// routeFailover sets the urls of the best ranked hosts other than host, at most
+// MaxFailoverHosts, which the request is sent to in order if it fails on host
+func (h *HTTPClient) routeFailover(reqCtx *RequestContext, host string) {
+	if h.cli == nil || h.cli.config.MaxFailoverHosts <= 0 {
+		return
+	}
+	var hosts []string
+	if reader, ok := h.hostAvailabler.(PathHostsReader); ok {
+		hosts = reader.GetPathHosts(reqCtx.Path)
+	}
+	if len(hosts) == 0 {
+		hosts = h.hostAvailabler.GetHosts()
+	}
+	reqCtx.failoverURLs = nil
+	for _, failoverHost := range hosts {
+		if len(reqCtx.failoverURLs) >= h.cli.config.MaxFailoverHosts {
+			return
+		}
+		if failoverHost != host {
+			reqCtx.failoverURLs = append(reqCtx.failoverURLs, buildURL(h.schema, failoverHost, reqCtx.Path))
+		}
+	}
+}
+
+// doHTTPRequestWithFailover sends the request to url, and then to the failover urls
+// of reqCtx in order, until it succeeds or fails with an error other than the network
+// errors and timeout, which means another host does not help. The error of the last
+// host is returned if all of them fail
+func (c *httpCaller) doHTTPRequestWithFailover(reqCtx *RequestContext, url string, headers map[string]string,
+	reqBytes []byte, options *option.Options, rspHeader *fasthttp.ResponseHeader) ([]byte, error) {
+	rspBytes, err := c.doHTTPRequestWithRetry(reqCtx, url, headers, reqBytes, options, rspHeader)
+	for _, failoverURL := range reqCtx.failoverURLs {
+		if err == nil || !coreerr.IsTransport(err) {
+			return rspBytes, err
+		}
+		if options.Context != nil && options.Context.Err() != nil {
+			return rspBytes, err
+		}
+		metricsTags := []string{
+			"project_id:" + c.projectID,
+			"url:" + escapeMetricsTagValue(url),
+			"failover_host:" + escapeMetricsTagValue(hostOfURL(failoverURL)),
+		}
+		metrics.Counter(metricsKeyRequestFailover, 1, metricsTags...)
+		logs.Warn("request fails over to another host, request_id:%s url:%s failover_url:%s err:%v",
+			headers["Request-Id"], url, failoverURL, err)
+		url = failoverURL
+		rspBytes, err = c.doHTTPRequestWithRetry(reqCtx, url, headers, reqBytes, options, rspHeader)
+	}
+	return rspBytes, err
+}
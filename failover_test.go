@@ -0,0 +1,88 @@
+package core
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/byteplus-sdk/byteplus-sdk-go-rec-core/option"
+	"github.com/valyala/fasthttp"
+)
+
+func TestHTTPClient_routeFailover(t *testing.T) {
+	hosts := []string{"a.byteplus.com", "b.byteplus.com", "c.byteplus.com"}
+	tests := []struct {
+		name             string
+		maxFailoverHosts int
+		want             []string
+	}{
+		{"disabled", 0, nil},
+		{"capped", 1, []string{"http://b.byteplus.com/predict"}},
+		{"all", 5, []string{"http://b.byteplus.com/predict", "http://c.byteplus.com/predict"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			h := &HTTPClient{
+				cli:            &httpCaller{config: &CallerConfig{MaxFailoverHosts: tt.maxFailoverHosts}},
+				hostAvailabler: &staticHostAvailabler{hosts: hosts},
+				schema:         "http",
+			}
+			reqCtx := newRequestContext("/predict", nil)
+			h.routeFailover(reqCtx, "a.byteplus.com")
+			if !reflect.DeepEqual(reqCtx.failoverURLs, tt.want) {
+				t.Errorf("failoverURLs = %v, want %v", reqCtx.failoverURLs, tt.want)
+			}
+		})
+	}
+}
+
+func TestHTTPCaller_doHTTPRequestWithFailover(t *testing.T) {
+	var sent []string
+	transport := TransportFunc(func(request *fasthttp.Request, response *fasthttp.Response, deadline time.Time) error {
+		host := string(request.Host())
+		sent = append(sent, host)
+		switch host {
+		case "down.byteplus.com":
+			return errors.New("dial tcp: connection refused")
+		case "broken.byteplus.com":
+			response.SetStatusCode(fasthttp.StatusBadRequest)
+		default:
+			response.SetStatusCode(fasthttp.StatusOK)
+			response.SetBodyString(host)
+		}
+		return nil
+	})
+	airAuthConfig, _ := fillDefaultAirAuthConfig(nil)
+	c := newHTTPCaller("project", "tenant", true, "token", airAuthConfig, credential{},
+		&staticHostAvailabler{hosts: []string{"down.byteplus.com"}}, &CallerConfig{}, "http", false, transport)
+	tests := []struct {
+		name         string
+		failoverURLs []string
+		wantSent     []string
+		wantBody     string
+		wantErr      bool
+	}{
+		{"no failover", nil, []string{"down.byteplus.com"}, "", true},
+		{"fail over", []string{"http://down.byteplus.com/predict", "http://ok.byteplus.com/predict"},
+			[]string{"down.byteplus.com", "down.byteplus.com", "ok.byteplus.com"}, "ok.byteplus.com", false},
+		{"not transport error", []string{"http://broken.byteplus.com/predict", "http://ok.byteplus.com/predict"},
+			[]string{"down.byteplus.com", "broken.byteplus.com"}, "", true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			sent = nil
+			options := &option.Options{}
+			reqCtx := newRequestContext("/predict", options)
+			reqCtx.failoverURLs = tt.failoverURLs
+			rspBytes, err := c.doHTTPRequestWithFailover(reqCtx, "http://down.byteplus.com/predict",
+				map[string]string{"Request-Id": reqCtx.RequestID}, nil, options, nil)
+			if (err != nil) != tt.wantErr || string(rspBytes) != tt.wantBody {
+				t.Errorf("doHTTPRequestWithFailover() = %s, %v", rspBytes, err)
+			}
+			if !reflect.DeepEqual(sent, tt.wantSent) {
+				t.Errorf("sent to %v, want %v", sent, tt.wantSent)
+			}
+		})
+	}
+}
@@ -0,0 +1,41 @@
+package core
+
+import "errors"
+
+// the min length of HMAC key approved by FIPS 140, 112 bits
+const fipsMinHMACKeyLength = 14
+
+// fipsEnabled reports whether the crypto of go runs in FIPS 140 mode, in which
+// crypto/sha256 and crypto/hmac used by signing are FIPS-validated implementations
+var fipsEnabled = cryptoFIPSEnabled
+
+// checkFIPSMode rejects the options not approved in FIPS mode
+func (receiver *httpClientBuilder) checkFIPSMode() error {
+	if !receiver.fipsMode {
+		return nil
+	}
+	if !fipsEnabled() {
+		return errors.New("fips mode requires go crypto running in FIPS 140 mode, " +
+			"run with GODEBUG=fips140=on on go1.24+, or build with GOEXPERIMENT=boringcrypto")
+	}
+	if !receiver.useAirAuth {
+		return nil
+	}
+	// v1 signature is a plain hash of token and body, which is not an approved MAC
+	if receiver.airAuthConfig == nil || receiver.airAuthConfig.Version != AirAuthVersionV2 {
+		return errors.New("air auth v1 is not approved in fips mode, use AirAuthVersionV2")
+	}
+	if receiver.airAuthConfig.NonceSource != nil {
+		return errors.New("custom nonce source is not approved in fips mode")
+	}
+	return checkFIPSAirAuthToken(receiver.airAuthToken)
+}
+
+// checkFIPSAirAuthToken rejects the air auth token shorter than the approved HMAC key,
+// it checks the token of Build and the ones rotated by SetAirAuthToken
+func checkFIPSAirAuthToken(token string) error {
+	if len(token) < fipsMinHMACKeyLength {
+		return errors.New("air auth token is shorter than 112 bits, not approved in fips mode")
+	}
+	return nil
+}
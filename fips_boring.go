@@ -0,0 +1,9 @@
+//go:build !go1.24 && boringcrypto
+
+package core
+
+import "crypto/boring"
+
+func cryptoFIPSEnabled() bool {
+	return boring.Enabled()
+}
@@ -0,0 +1,9 @@
+//go:build go1.24
+
+package core
+
+import "crypto/fips140"
+
+func cryptoFIPSEnabled() bool {
+	return fips140.Enabled()
+}
@@ -0,0 +1,7 @@
+//go:build !go1.24 && !boringcrypto
+
+package core
+
+func cryptoFIPSEnabled() bool {
+	return false
+}
@@ -0,0 +1,34 @@
+package core
+
+import "testing"
+
+func TestCheckFIPSMode(t *testing.T) {
+	originFIPSEnabled := fipsEnabled
+	defer func() {
+		fipsEnabled = originFIPSEnabled
+	}()
+	tests := []struct {
+		name        string
+		fipsEnabled bool
+		builder     *httpClientBuilder
+		wantErr     bool
+	}{
+		{name: "not fips mode", builder: &httpClientBuilder{useAirAuth: true}},
+		{name: "crypto not fips", builder: &httpClientBuilder{fipsMode: true}, wantErr: true},
+		{name: "ak sk", fipsEnabled: true, builder: &httpClientBuilder{fipsMode: true}},
+		{name: "air auth v1", fipsEnabled: true, wantErr: true,
+			builder: &httpClientBuilder{fipsMode: true, useAirAuth: true, airAuthToken: "0123456789abcdef"}},
+		{name: "air auth v2", fipsEnabled: true, builder: &httpClientBuilder{fipsMode: true, useAirAuth: true,
+			airAuthToken: "0123456789abcdef", airAuthConfig: &AirAuthConfig{Version: AirAuthVersionV2}}},
+		{name: "short token", fipsEnabled: true, wantErr: true, builder: &httpClientBuilder{fipsMode: true,
+			useAirAuth: true, airAuthToken: "short", airAuthConfig: &AirAuthConfig{Version: AirAuthVersionV2}}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			fipsEnabled = func() bool { return tt.fipsEnabled }
+			if err := tt.builder.checkFIPSMode(); (err != nil) != tt.wantErr {
+				t.Errorf("checkFIPSMode() err = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
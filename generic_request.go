@@ -0,0 +1,40 @@
+package core
+
+import (
+	"github.com/byteplus-sdk/byteplus-sdk-go-rec-core/option"
+	"google.golang.org/protobuf/proto"
+)
+
+// Do sends the protobuf request to path, and returns the response allocated by itself,
+// so that the wrapping SDKs need not to pre-allocate the response message, example:
+//
+//	rsp, err := core.Do[*protocol.PredictRequest, *protocol.PredictResponse](client, path, req)
+func Do[TReq, TRsp proto.Message](client *HTTPClient, path string,
+	req TReq, opts ...option.Option) (TRsp, error) {
+	rsp := newMessage[TRsp]()
+	err := client.DoPBRequest(path, req, rsp, option.Conv2Options(opts...))
+	if err != nil {
+		var zero TRsp
+		return zero, err
+	}
+	return rsp, nil
+}
+
+// DoJSON is the same as Do, except that the request and response are sent in json
+func DoJSON[TReq any, TRsp proto.Message](client *HTTPClient, path string,
+	req TReq, opts ...option.Option) (TRsp, error) {
+	rsp := newMessage[TRsp]()
+	err := client.DoJSONRequest(path, req, rsp, option.Conv2Options(opts...))
+	if err != nil {
+		var zero TRsp
+		return zero, err
+	}
+	return rsp, nil
+}
+
+// newMessage allocates a message of type T, T is usually a pointer to
+// a generated message struct, whose nil value can still create new messages
+func newMessage[T proto.Message]() T {
+	var zero T
+	return zero.ProtoReflect().New().Interface().(T)
+}
@@ -0,0 +1,15 @@
+package core
+
+import (
+	"testing"
+
+	"google.golang.org/protobuf/types/known/wrapperspb"
+)
+
+func TestNewMessage(t *testing.T) {
+	msg := newMessage[*wrapperspb.StringValue]()
+	if msg == nil {
+		t.Fatalf("message should be allocated")
+	}
+	msg.Value = "byteplus"
+}
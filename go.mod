@@ -1,9 +1,23 @@
 module github.com/byteplus-sdk/byteplus-sdk-go-rec-core
 
-go 1.16
+go 1.18
 
 require (
+	github.com/andybalholm/brotli v1.0.2
 	github.com/google/uuid v1.3.0
+	github.com/klauspost/compress v1.13.4
 	github.com/valyala/fasthttp v1.31.0
-	google.golang.org/protobuf v1.27.1
+	google.golang.org/grpc v1.56.3
+	google.golang.org/protobuf v1.30.0
+	gopkg.in/yaml.v3 v3.0.1
+)
+
+require (
+	github.com/golang/protobuf v1.5.3 // indirect
+	github.com/golang/snappy v0.0.3 // indirect
+	github.com/valyala/bytebufferpool v1.0.0 // indirect
+	golang.org/x/net v0.17.0 // indirect
+	golang.org/x/sys v0.13.0 // indirect
+	golang.org/x/text v0.13.0 // indirect
+	google.golang.org/genproto v0.0.0-20230410155749-daa745c078e1 // indirect
 )
@@ -0,0 +1,398 @@
+package core
+
+import (
+	"context"
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/byteplus-sdk/byteplus-sdk-go-rec-core/coreerr"
+	"github.com/byteplus-sdk/byteplus-sdk-go-rec-core/logs"
+	"github.com/byteplus-sdk/byteplus-sdk-go-rec-core/metrics"
+	"github.com/byteplus-sdk/byteplus-sdk-go-rec-core/option"
+	"github.com/valyala/fasthttp"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/credentials/insecure"
+	grpcmetadata "google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/proto"
+)
+
+// Protocol is the protocol HTTPClient sends the pb requests by
+type Protocol string
+
+const (
+	ProtocolHTTP Protocol = "http"
+	// ProtocolGRPC the pb requests of DoPBRequest are sent by gRPC,
+	// the json and raw requests are still sent by http
+	ProtocolGRPC Protocol = "grpc"
+)
+
+// GRPCConfig is the config of sending the pb requests by gRPC
+type GRPCConfig struct {
+	// MethodResolver returns the full gRPC method of the request path, such as
+	// "/package.Service/Method", the path is used as the method if it is nil
+	MethodResolver func(path string) string
+	// The extra options of dialing hosts, the transport credentials are
+	// chosen by the schema of HTTPClient if they are not specified here
+	DialOptions []grpc.DialOption
+}
+
+// GRPCError is returned when the gRPC call fails with a status other than OK
+type GRPCError struct {
+	// The status code of gRPC
+	Code    codes.Code
+	Message string
+}
+
+func (e *GRPCError) Error() string {
+	message := "grpc status:" + e.Code.String() + " message:" + e.Message
+	if e.ErrorClass() == coreerr.Transport {
+		return netErrMark + message
+	}
+	return message
+}
+
+// ErrorClass returns the class of the gRPC status code in coreerr
+func (e *GRPCError) ErrorClass() coreerr.Class {
+	switch e.Code {
+	case codes.OK:
+		return coreerr.None
+	case codes.Unavailable, codes.DeadlineExceeded:
+		return coreerr.Transport
+	case codes.ResourceExhausted:
+		return coreerr.Throttled
+	case codes.Unauthenticated, codes.PermissionDenied:
+		return coreerr.AuthFailure
+	case codes.Canceled:
+		return coreerr.Canceled
+	case codes.InvalidArgument, codes.NotFound, codes.AlreadyExists, codes.FailedPrecondition,
+		codes.OutOfRange, codes.Unimplemented:
+		return coreerr.ClientError
+	default:
+		return coreerr.ServerError
+	}
+}
+
+// grpcCaller sends the pb requests by gRPC, the requests are signed by httpCaller
+// the same as http, and the signed headers are sent as the metadata of the call.
+// The throttler, circuit breaker, passive health and audit of httpCaller apply
+// to the calls the same as the http requests
+type grpcCaller struct {
+	caller *httpCaller
+	config *GRPCConfig
+	lock   sync.Mutex
+	conns  map[string]*grpcConn
+}
+
+// grpcConn is the conn of a host, the conn of the host removed from the host
+// config is closed once the in-flight calls on it finish
+type grpcConn struct {
+	*grpc.ClientConn
+	// the number of the in-flight calls, they are changed with the lock of grpcCaller held
+	refs    int
+	removed bool
+}
+
+func newGRPCCaller(caller *httpCaller, config *GRPCConfig) *grpcCaller {
+	if config == nil {
+		config = &GRPCConfig{}
+	}
+	return &grpcCaller{
+		caller: caller,
+		config: config,
+		conns:  make(map[string]*grpcConn),
+	}
+}
+
+func (c *grpcCaller) doPBRequest(reqCtx *RequestContext, url string, request proto.Message,
+	response proto.Message, options *option.Options) error {
+	reqBytes, err := proto.Marshal(request)
+	headers := c.caller.buildHeaders(options, "application/grpc+proto")
+	// gRPC compresses the messages by itself
+	delete(headers, "Content-Encoding")
+	delete(headers, "Accept-Encoding")
+	reqID := headers["Request-Id"]
+	reqCtx.RequestID = reqID
+	if err != nil {
+		metricsTags := []string{
+			"type:marshal_pb_request_fail",
+			"project_id:" + c.caller.projectID,
+			"url:" + escapeMetricsTagValue(url),
+		}
+		metrics.Counter(metricsKeyCommonError, 1, metricsTags...)
+		logs.Error("marshal request fail, err:%v url:%s", err, url)
+		return err
+	}
+	err = c.invokeWithRetry(reqCtx, reqID, url, headers, reqBytes, response, options)
+	if options.ResponseMetadata != nil {
+		fillGRPCResponseMetadata(options.ResponseMetadata, reqCtx, err)
+	}
+	return err
+}
+
+func (c *grpcCaller) invokeWithRetry(reqCtx *RequestContext, reqID, url string, headers map[string]string,
+	reqBytes []byte, response proto.Message, options *option.Options) error {
+	return c.caller.retry(reqCtx, reqID, url, options, func() error {
+		err := c.invoke(reqID, url, headers, reqBytes, response, options)
+		c.caller.auditAttempt(reqID, url, reqBytes, nil, err)
+		return err
+	})
+}
+
+func (c *grpcCaller) invoke(reqID, url string, headers map[string]string,
+	reqBytes []byte, response proto.Message, options *option.Options) error {
+	if c.caller.throttler != nil && !c.caller.throttler.allow(pathOfURL(url)) {
+		metricsTags := []string{
+			"type:client_throttled",
+			"project_id:" + c.caller.projectID,
+			"url:" + escapeMetricsTagValue(url),
+		}
+		metrics.Counter(metricsKeyCommonWarn, 1, metricsTags...)
+		logs.Warn("request is throttled by client, request_id:%s url:%s", reqID, url)
+		return ErrClientThrottled
+	}
+	host := hostOfURL(url)
+	conn, err := c.acquireConn(host)
+	if err != nil {
+		logs.Error("dial grpc host fail, host:%s err:%v", host, err)
+		return err
+	}
+	defer c.releaseConn(host, conn)
+	ctx := options.Context
+	if ctx == nil {
+		ctx = context.Background()
+	}
+	timeout := options.Timeout
+	if timeout <= 0 {
+		timeout = defaultTimeout
+	}
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+	md, err := c.signedMetadata(url, headers, reqBytes, options)
+	if err != nil {
+		logs.Error("sign grpc request fail, url:%s err:%v", url, err)
+		return err
+	}
+	ctx = grpcmetadata.NewOutgoingContext(ctx, md)
+
+	if c.caller.requestTracker != nil {
+		c.caller.requestTracker.RequestStarted(host)
+	}
+	start := time.Now()
+	var header grpcmetadata.MD
+	err = conn.Invoke(ctx, c.method(pathOfURL(url)), grpcRawFrame(reqBytes), response,
+		grpc.ForceCodec(grpcRawCodec{}), grpc.Header(&header))
+	if options.ResponseMetadata != nil {
+		options.ResponseMetadata.Headers = http.Header{}
+		for key, values := range header {
+			for _, value := range values {
+				options.ResponseMetadata.Headers.Add(key, value)
+			}
+		}
+	}
+	cost := time.Since(start)
+	if c.caller.requestTracker != nil {
+		c.caller.requestTracker.RequestFinished(host)
+	}
+	rspStatus, _ := status.FromError(err)
+	canceled := options.Context != nil && options.Context.Err() != nil
+	if !canceled {
+		c.recordResult(url, rspStatus.Code(), cost)
+	}
+	metricsTags := []string{
+		"project_id:" + c.caller.projectID,
+		"url:" + escapeMetricsTagValue(url),
+	}
+	metrics.Timer(metricsKeyRequestTotalCost, cost.Milliseconds(), metricsTags...)
+	metrics.Counter(metricsKeyRequestCount, 1, metricsTags...)
+	metrics.Rate(metricsKeyRequestCount, 1, metricsTags...)
+	logs.Debug("grpc url:%s, cost:%dms", url, cost.Milliseconds())
+	if err == nil {
+		return nil
+	}
+	metricsTags = []string{
+		"type:grpc_request_fail",
+		"project_id:" + c.caller.projectID,
+		"url:" + escapeMetricsTagValue(url),
+		"code:" + rspStatus.Code().String(),
+	}
+	metrics.Counter(metricsKeyCommonError, 1, metricsTags...)
+	metrics.Error(reqID, "[ByteplusSDK] do grpc request fail, project_id:%s, url:%s, cost:%dms, err:%v",
+		c.caller.projectID, url, cost.Milliseconds(), err)
+	logs.Error("do grpc request fail, err:%v url:%s cost:%s", err, url, cost)
+	if canceled {
+		return fmt.Errorf("request canceled: %w", options.Context.Err())
+	}
+	return &GRPCError{Code: rspStatus.Code(), Message: rspStatus.Message()}
+}
+
+// signedMetadata signs the request as a http request of url, and returns the signed headers
+func (c *grpcCaller) signedMetadata(url string, headers map[string]string, reqBytes []byte,
+	options *option.Options) (grpcmetadata.MD, error) {
+	request := c.caller.acquireRequest(fasthttp.MethodPost, url, headers, reqBytes)
+	defer fasthttp.ReleaseRequest(request)
+	if err := c.caller.withAuthHeaders(request, reqBytes, options); err != nil {
+		return nil, err
+	}
+	md := grpcmetadata.MD{}
+	request.Header.VisitAll(func(key, value []byte) {
+		if isHopHeader(string(key)) {
+			return
+		}
+		md.Append(strings.ToLower(string(key)), string(value))
+	})
+	return md, nil
+}
+
+// recordResult feeds the status of the call to the throttler, circuit breaker and
+// host availabler, the same as the status of http response. The calls timed out are
+// not reported to the host availabler, the same as the timed out http requests
+func (c *grpcCaller) recordResult(url string, code codes.Code, cost time.Duration) {
+	hostFailure := false
+	switch code {
+	case codes.Unavailable, codes.Internal, codes.Unknown, codes.DataLoss, codes.DeadlineExceeded:
+		hostFailure = true
+	}
+	if c.caller.breaker != nil {
+		c.caller.breaker.record(hostOfURL(url), hostFailure)
+	}
+	if c.caller.throttler != nil {
+		c.caller.throttler.record(pathOfURL(url), code == codes.ResourceExhausted)
+	}
+	if code != codes.DeadlineExceeded {
+		c.caller.reportResult(url, !hostFailure, cost)
+	}
+}
+
+func (c *grpcCaller) method(path string) string {
+	if c.config.MethodResolver != nil {
+		return c.config.MethodResolver(path)
+	}
+	return path
+}
+
+// acquireConn returns the conn of host for a call, the conn must be released
+// by releaseConn after the call
+func (c *grpcCaller) acquireConn(host string) (*grpcConn, error) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	if conn, exist := c.conns[host]; exist {
+		conn.refs++
+		return conn, nil
+	}
+	secure := c.caller.schema == "https"
+	target := host
+	if _, _, err := net.SplitHostPort(host); err != nil {
+		if secure {
+			target = host + ":443"
+		} else {
+			target = host + ":80"
+		}
+	}
+	transportCredentials := insecure.NewCredentials()
+	if secure {
+		tlsConfig := &tls.Config{}
+		if c.caller.config.TLSConfig != nil {
+			tlsConfig = c.caller.config.TLSConfig.Clone()
+		}
+		transportCredentials = credentials.NewTLS(tlsConfig)
+	}
+	dialOptions := append([]grpc.DialOption{grpc.WithTransportCredentials(transportCredentials)},
+		c.config.DialOptions...)
+	clientConn, err := grpc.Dial(target, dialOptions...)
+	if err != nil {
+		return nil, err
+	}
+	conn := &grpcConn{ClientConn: clientConn, refs: 1}
+	c.conns[host] = conn
+	return conn, nil
+}
+
+// releaseConn finishes a call on conn, and closes the conn if its host is removed
+// and it is the last call
+func (c *grpcCaller) releaseConn(host string, conn *grpcConn) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	conn.refs--
+	if conn.removed && conn.refs == 0 {
+		logs.Info("close grpc conn of removed host:%s", host)
+		_ = conn.Close()
+	}
+}
+
+// closeRemovedHosts closes the conns of the hosts removed from the host config,
+// it is the HostsChangedListener of the host availabler, so that the conns of the
+// hosts out of use are not kept forever. The conns with in-flight calls are closed
+// by releaseConn after the calls finish
+func (c *grpcCaller) closeRemovedHosts(oldHostConfig, newHostConfig map[string][]string) {
+	hosts := make(map[string]bool)
+	for _, pathHosts := range newHostConfig {
+		for _, host := range pathHosts {
+			hosts[host] = true
+		}
+	}
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	for _, pathHosts := range oldHostConfig {
+		for _, host := range pathHosts {
+			conn, exist := c.conns[host]
+			if !exist || hosts[host] {
+				continue
+			}
+			delete(c.conns, host)
+			if conn.refs > 0 {
+				conn.removed = true
+				continue
+			}
+			logs.Info("close grpc conn of removed host:%s", host)
+			_ = conn.Close()
+		}
+	}
+}
+
+func (c *grpcCaller) shutdown() {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	for host, conn := range c.conns {
+		_ = conn.Close()
+		delete(c.conns, host)
+	}
+}
+
+// grpcRawFrame is the already marshaled request, it is sent as is, so that
+// the signed bytes are exactly the bytes sent
+type grpcRawFrame []byte
+
+// grpcRawCodec is the proto codec except that grpcRawFrame is not marshaled again
+type grpcRawCodec struct{}
+
+func (grpcRawCodec) Marshal(v interface{}) ([]byte, error) {
+	switch message := v.(type) {
+	case grpcRawFrame:
+		return message, nil
+	case proto.Message:
+		return proto.Marshal(message)
+	default:
+		return nil, errors.New("grpc message is not proto.Message")
+	}
+}
+
+func (grpcRawCodec) Unmarshal(data []byte, v interface{}) error {
+	message, ok := v.(proto.Message)
+	if !ok {
+		return errors.New("grpc message is not proto.Message")
+	}
+	return proto.Unmarshal(data, message)
+}
+
+func (grpcRawCodec) Name() string {
+	return "proto"
+}
@@ -0,0 +1,124 @@
+package core
+
+import (
+	"context"
+	"errors"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/byteplus-sdk/byteplus-sdk-go-rec-core/coreerr"
+	"github.com/byteplus-sdk/byteplus-sdk-go-rec-core/option"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/connectivity"
+	grpcmetadata "google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/wrapperspb"
+)
+
+func TestGRPCCaller_doPBRequest(t *testing.T) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Skipf("listen fail: %v", err)
+	}
+	server := grpc.NewServer(grpc.UnknownServiceHandler(func(srv interface{}, stream grpc.ServerStream) error {
+		method, _ := grpc.MethodFromServerStream(stream)
+		md, _ := grpcmetadata.FromIncomingContext(stream.Context())
+		request := &wrapperspb.StringValue{}
+		if err := stream.RecvMsg(request); err != nil {
+			return err
+		}
+		if request.Value == "throttle" {
+			return status.Error(codes.ResourceExhausted, "too many requests")
+		}
+		if method != "/rec.Service/Predict" || len(md.Get("request-id")) == 0 || len(md.Get("tenant-signature")) == 0 {
+			return status.Errorf(codes.InvalidArgument, "method:%s metadata:%v", method, md)
+		}
+		if err := stream.SetHeader(grpcmetadata.Pairs("x-region", "sg")); err != nil {
+			return err
+		}
+		return stream.SendMsg(wrapperspb.String("hello " + request.Value))
+	}))
+	go func() { _ = server.Serve(listener) }()
+	defer server.Stop()
+
+	airAuthConfig, _ := fillDefaultAirAuthConfig(nil)
+	caller := newHTTPCaller("project", "tenant", true, "token", airAuthConfig, credential{},
+		&staticHostAvailabler{hosts: []string{listener.Addr().String()}}, &CallerConfig{MaxRetryTimes: 1},
+		"http", false, nil)
+	c := newGRPCCaller(caller, &GRPCConfig{MethodResolver: func(path string) string {
+		return "/rec.Service/Predict"
+	}})
+	defer c.shutdown()
+	url := "http://" + listener.Addr().String() + "/predict"
+
+	response := &wrapperspb.StringValue{}
+	metadata := &option.ResponseMetadata{}
+	reqCtx := newRequestContext("/predict", &option.Options{ResponseMetadata: metadata})
+	if err := c.doPBRequest(reqCtx, url, wrapperspb.String("rec"), response, reqCtx.Options); err != nil {
+		t.Fatalf("doPBRequest() error = %v", err)
+	}
+	if response.Value != "hello rec" {
+		t.Errorf("doPBRequest() response = %s", response.Value)
+	}
+	if metadata.StatusCode != 200 || metadata.Headers.Get("X-Region") != "sg" {
+		t.Errorf("ResponseMetadata = %+v, want status 200 and region header", metadata)
+	}
+
+	err = c.doPBRequest(reqCtx, url, wrapperspb.String("throttle"), response, reqCtx.Options)
+	var attemptErrs AttemptErrors
+	if !errors.As(err, &attemptErrs) || len(attemptErrs) != 2 || !coreerr.IsThrottled(err) {
+		t.Errorf("doPBRequest() error = %v, want 2 throttled attempts", err)
+	}
+
+	// the grpc requests are drained and rejected by the shutdown of caller too
+	if err = caller.shutdown(context.Background()); err != nil {
+		t.Errorf("shutdown() error = %v", err)
+	}
+	err = c.doPBRequest(reqCtx, url, wrapperspb.String("rec"), response, reqCtx.Options)
+	if err != ErrClientShutdown {
+		t.Errorf("doPBRequest() after shutdown error = %v, want ErrClientShutdown", err)
+	}
+}
+
+func TestGRPCCaller_closeRemovedHosts(t *testing.T) {
+	airAuthConfig, _ := fillDefaultAirAuthConfig(nil)
+	caller := newHTTPCaller("project", "tenant", true, "token", airAuthConfig, credential{},
+		&staticHostAvailabler{hosts: []string{"a:80"}}, &CallerConfig{}, "http", false, nil)
+	c := newGRPCCaller(caller, nil)
+	defer c.shutdown()
+	// the conns are dialed lazily, no server is needed
+	removed, _ := c.acquireConn("a:80")
+	c.releaseConn("a:80", removed)
+	kept, _ := c.acquireConn("b:80")
+	c.releaseConn("b:80", kept)
+	inflight, _ := c.acquireConn("c:80")
+	c.closeRemovedHosts(map[string][]string{"*": {"a:80", "b:80", "c:80"}}, map[string][]string{"*": {"b:80"}})
+	if removed.GetState() != connectivity.Shutdown || len(c.conns) != 1 || c.conns["b:80"] != kept {
+		t.Errorf("conns = %v, want the conn of removed host closed", c.conns)
+	}
+	// the conn in use is closed after the in-flight call finishes
+	if inflight.GetState() == connectivity.Shutdown {
+		t.Errorf("conn with in-flight call is closed")
+	}
+	c.releaseConn("c:80", inflight)
+	if inflight.GetState() != connectivity.Shutdown {
+		t.Errorf("conn of removed host is not closed after the in-flight call finishes")
+	}
+}
+
+func TestGRPCCaller_recordResult(t *testing.T) {
+	airAuthConfig, _ := fillDefaultAirAuthConfig(nil)
+	caller := newHTTPCaller("project", "tenant", true, "token", airAuthConfig, credential{},
+		&staticHostAvailabler{hosts: []string{"a:80"}}, &CallerConfig{CircuitBreakerFailureThreshold: 1},
+		"http", false, nil)
+	c := newGRPCCaller(caller, nil)
+	c.recordResult("http://a:80/predict", codes.InvalidArgument, time.Millisecond)
+	c.recordResult("http://b:80/predict", codes.Unavailable, time.Millisecond)
+	caller.breaker.lock.Lock()
+	defer caller.breaker.lock.Unlock()
+	if !caller.breaker.allow("a:80") || caller.breaker.allow("b:80") {
+		t.Errorf("circuits = %v, want only the circuit of unavailable host open", caller.breaker.circuits)
+	}
+}
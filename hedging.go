@@ -0,0 +1,119 @@
+package core
+
+import (
+	"context"
+	"time"
+
+	"github.com/byteplus-sdk/byteplus-sdk-go-rec-core/logs"
+	"github.com/byteplus-sdk/byteplus-sdk-go-rec-core/metrics"
+	"github.com/byteplus-sdk/byteplus-sdk-go-rec-core/option"
+	"github.com/valyala/fasthttp"
+)
+
+// routeHedge sets the url of the duplicate request to the best ranked host other
+// than host, if hedging is enabled by option.WithHedging
+func (h *HTTPClient) routeHedge(reqCtx *RequestContext, host string) {
+	if reqCtx.Options == nil || reqCtx.Options.HedgeDelay <= 0 {
+		return
+	}
+	var hosts []string
+	if reader, ok := h.hostAvailabler.(PathHostsReader); ok {
+		hosts = reader.GetPathHosts(reqCtx.Path)
+	}
+	if len(hosts) == 0 {
+		hosts = h.hostAvailabler.GetHosts()
+	}
+	for _, hedgeHost := range hosts {
+		if hedgeHost != host {
+			reqCtx.hedgeURL = buildURL(h.schema, hedgeHost, reqCtx.Path)
+			return
+		}
+	}
+}
+
+type hedgeResult struct {
+	hedged    bool
+	leg       *RequestContext
+	rspBytes  []byte
+	rspHeader *fasthttp.ResponseHeader
+	err       error
+}
+
+// doHTTPRequestWithHedge sends the request to url, and sends a duplicate one to the
+// hedge url of reqCtx if there is no response after HedgeDelay. The first successful
+// response is returned and the other request is cancelled, the error of the later
+// one is returned if both fail
+func (c *httpCaller) doHTTPRequestWithHedge(reqCtx *RequestContext, url string, headers map[string]string,
+	reqBytes []byte, options *option.Options, rspHeader *fasthttp.ResponseHeader) ([]byte, error) {
+	if options.HedgeDelay <= 0 || reqCtx.hedgeURL == "" {
+		return c.doHTTPRequestWithFailover(reqCtx, url, headers, reqBytes, options, rspHeader)
+	}
+	parent := options.Context
+	if parent == nil {
+		parent = context.Background()
+	}
+	ctx, cancel := context.WithCancel(parent)
+	defer cancel()
+	results := make(chan *hedgeResult, 2)
+	send := func(url string, hedged bool) {
+		hedgeOptions := copyOptions(options)
+		hedgeOptions.Context = ctx
+		result := &hedgeResult{hedged: hedged, leg: reqCtx.hedgeLeg()}
+		if rspHeader != nil {
+			result.rspHeader = &fasthttp.ResponseHeader{}
+		}
+		// the hedged request does not fail over, the original one does
+		if hedged {
+			result.rspBytes, result.err = c.doHTTPRequestWithRetry(result.leg, url,
+				copyHeaders(headers), reqBytes, hedgeOptions, result.rspHeader)
+		} else {
+			result.rspBytes, result.err = c.doHTTPRequestWithFailover(result.leg, url,
+				copyHeaders(headers), reqBytes, hedgeOptions, result.rspHeader)
+		}
+		results <- result
+	}
+	AsyncExecute(func() { send(url, false) })
+
+	timer := time.NewTimer(options.HedgeDelay)
+	defer timer.Stop()
+	pending := 1
+	var result *hedgeResult
+	select {
+	case result = <-results:
+		pending--
+	case <-timer.C:
+		metricsTags := []string{
+			"project_id:" + c.projectID,
+			"url:" + escapeMetricsTagValue(url),
+		}
+		metrics.Counter(metricsKeyRequestHedged, 1, metricsTags...)
+		logs.Debug("send hedged request, request_id:%s url:%s hedge_url:%s",
+			headers["Request-Id"], url, reqCtx.hedgeURL)
+		pending++
+		AsyncExecute(func() { send(reqCtx.hedgeURL, true) })
+	}
+	for result == nil || (result.err != nil && pending > 0) {
+		result = <-results
+		pending--
+	}
+	if result.hedged && result.err == nil {
+		metricsTags := []string{
+			"project_id:" + c.projectID,
+			"url:" + escapeMetricsTagValue(url),
+		}
+		metrics.Counter(metricsKeyRequestHedgeWin, 1, metricsTags...)
+	}
+	reqCtx.copyAttempt(result.leg)
+	if rspHeader != nil && result.rspHeader != nil {
+		result.rspHeader.CopyTo(rspHeader)
+	}
+	return result.rspBytes, result.err
+}
+
+func copyHeaders(headers map[string]string) map[string]string {
+	result := make(map[string]string, len(headers))
+	for k, v := range headers {
+		result[k] = v
+	}
+	return result
+}
@@ -0,0 +1,53 @@
+package core
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/byteplus-sdk/byteplus-sdk-go-rec-core/option"
+	"github.com/valyala/fasthttp"
+)
+
+func TestHTTPCaller_doHTTPRequestWithHedge(t *testing.T) {
+	transport := TransportFunc(func(request *fasthttp.Request, response *fasthttp.Response, deadline time.Time) error {
+		if string(request.Host()) == "slow.byteplus.com" {
+			time.Sleep(200 * time.Millisecond)
+		}
+		response.SetStatusCode(fasthttp.StatusOK)
+		response.SetBody(request.Host())
+		return nil
+	})
+	airAuthConfig, _ := fillDefaultAirAuthConfig(nil)
+	c := newHTTPCaller("project", "tenant", true, "token", airAuthConfig, credential{},
+		&staticHostAvailabler{hosts: []string{"slow.byteplus.com"}}, &CallerConfig{}, "http", false, transport)
+	options := &option.Options{Context: context.Background(), HedgeDelay: 20 * time.Millisecond}
+	reqCtx := newRequestContext("/predict", options)
+	reqCtx.hedgeURL = "http://fast.byteplus.com/predict"
+
+	start := time.Now()
+	rspBytes, err := c.doHTTPRequestWithHedge(reqCtx, "http://slow.byteplus.com/predict",
+		map[string]string{"Request-Id": reqCtx.RequestID}, nil, options, nil)
+	if err != nil {
+		t.Fatalf("doHTTPRequestWithHedge() error = %v", err)
+	}
+	if string(rspBytes) != "fast.byteplus.com" {
+		t.Errorf("doHTTPRequestWithHedge() = %s, want response of hedge host", rspBytes)
+	}
+	if cost := time.Since(start); cost >= 200*time.Millisecond {
+		t.Errorf("doHTTPRequestWithHedge() cost %v, should not wait for the slow host", cost)
+	}
+	// the losing leg running on does not overwrite the host of the winner
+	time.Sleep(300 * time.Millisecond)
+	if reqCtx.Host != "fast.byteplus.com" {
+		t.Errorf("reqCtx.Host = %s, want the host of the winning leg", reqCtx.Host)
+	}
+
+	// the hedged request is not sent if the response arrives before delay
+	options.HedgeDelay = time.Second
+	rspBytes, err = c.doHTTPRequestWithHedge(reqCtx, "http://slow.byteplus.com/predict",
+		map[string]string{"Request-Id": reqCtx.RequestID}, nil, options, nil)
+	if err != nil || string(rspBytes) != "slow.byteplus.com" {
+		t.Errorf("doHTTPRequestWithHedge() = %s, %v, want response of primary host", rspBytes, err)
+	}
+}
@@ -0,0 +1,51 @@
+package core
+
+import (
+	"hash/fnv"
+
+	"github.com/byteplus-sdk/byteplus-sdk-go-rec-core/option"
+)
+
+// AffinityHostReader is implemented by the HostAvailabler which can select the host of
+// path by an affinity key, such as the user id, so that the requests of the same key hit
+// the same backend cache. HTTPClient uses it for the requests with option.WithAffinityKey
+type AffinityHostReader interface {
+	GetAffinityHost(path, affinityKey string) string
+}
+
+// GetAffinityHost selects the host of path by rendezvous hashing of affinityKey over the
+// healthy hosts of the preferred tier, so that only the keys of the removed or recovered
+// host are re-hashed when the hosts change. The top scored host is selected if no host
+// is healthy
+func (a *HostAvailablerBase) GetAffinityHost(path, affinityKey string) string {
+	hosts := a.GetPathHosts(path)
+	if len(hosts) <= 1 {
+		return a.GetHost(path)
+	}
+	var result string
+	var maxWeight uint64
+	for _, host := range a.healthyHosts(hosts) {
+		if weight := affinityWeight(affinityKey, host); result == "" || weight > maxWeight {
+			result, maxWeight = host, weight
+		}
+	}
+	if result == "" {
+		return hosts[0]
+	}
+	return result
+}
+
+func affinityWeight(affinityKey, host string) uint64 {
+	hash := fnv.New64a()
+	_, _ = hash.Write([]byte(affinityKey))
+	_, _ = hash.Write([]byte{0})
+	_, _ = hash.Write([]byte(host))
+	return hash.Sum64()
+}
+
+func affinityKeyOf(options *option.Options) string {
+	if options == nil {
+		return ""
+	}
+	return options.AffinityKey
+}
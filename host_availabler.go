@@ -0,0 +1,96 @@
+package core
+
+import (
+	"context"
+	"fmt"
+	"time"
+)
+
+// HostAvailabler is the only host selection abstraction used by HTTPClient.
+//
+// The built-in implementation is pingHostAvailabler, which embeds
+// HostAvailablerBase and scores hosts by pinging them. To customize host
+// selection, either implement HostAvailabler and pass it to
+// httpClientBuilder.HostAvailabler, or implement HostAvailablerFactory and
+// pass it to httpClientBuilder.HostAvailablerFactory, so that it is also used
+// by the metrics reporter.
+type HostAvailabler interface {
+	// GetHosts returns all the distinct hosts in use
+	GetHosts() []string
+
+	// GetHost returns the preferred host for the path
+	GetHost(path string) string
+
+	// Shutdown stops the background goroutines
+	Shutdown()
+}
+
+// GracefulShutdowner is implemented by the HostAvailabler which can wait for its background
+// goroutines to finish on shutdown, such as HostAvailablerBase. HTTPClient.ShutdownWithContext
+// calls it in place of Shutdown
+type GracefulShutdowner interface {
+	ShutdownWithContext(ctx context.Context) error
+}
+
+// PathHostsReader is implemented by the HostAvailabler which can list all the hosts
+// of a path ordered by availability, LoadBalancer picks host from them
+type PathHostsReader interface {
+	GetPathHosts(path string) []string
+}
+
+// HostsChangedListener is called with the old and new host config of path->hosts ordered
+// by availability when the host config changes, the configs must not be modified
+type HostsChangedListener func(oldHostConfig, newHostConfig map[string][]string)
+
+// HostsChangedNotifier is implemented by the HostAvailabler which notifies the changes
+// of host config, such as HostAvailablerBase
+type HostsChangedNotifier interface {
+	OnHostsChanged(listener HostsChangedListener)
+}
+
+// MainHostReader is implemented by the HostAvailabler which prefers a main host, such as
+// HostAvailablerBase. The requests are sent with the Host header of the main host if
+// CallerConfig.MainHostHeader is enabled
+type MainHostReader interface {
+	MainHost() string
+}
+
+// HostScorer scores hosts for HostAvailablerBase, higher score is preferred
+type HostScorer interface {
+	ScoreHosts(hosts []string) []*HostAvailabilityScore
+}
+
+// PingResultReceiver is implemented by the HostAvailabler which can use
+// ping results observed outside, such as the keep-alive heartbeat of httpCaller
+type PingResultReceiver interface {
+	ReceivePingResult(host string, success bool)
+}
+
+// RequestResultReporter is implemented by the HostAvailabler which scores hosts by the
+// outcomes of the live requests too, such as pingHostAvailabler. httpCaller reports each
+// request sent to the host, which fails on network errors and 5xx. The requests failed by
+// the client, such as timed out, cancelled or rejected by the interceptors, are not reported
+type RequestResultReporter interface {
+	ReportResult(host, path string, success bool, latency time.Duration)
+}
+
+type HostAvailabilityScore struct {
+	Host  string
+	Score float64
+	// the success rate of host if Score is not it, such as scored by latency. The health
+	// checks, such as of the main host, tiers and round robin, apply to it instead of Score
+	availability    float64
+	hasAvailability bool
+}
+
+func (h *HostAvailabilityScore) String() string {
+	return fmt.Sprintf("{Host:%s Score:%v}", h.Host, h.Score)
+}
+
+// availabilityScore returns the success rate of host, which is Score unless it is set
+func (h *HostAvailabilityScore) availabilityScore() float64 {
+	if h.hasAvailability {
+		return h.availability
+	}
+	return h.Score
+}
@@ -4,9 +4,61 @@ type HostAvailablerFactory interface {
 	NewHostAvailabler(projectID string, hosts []string, mainHost string, skipFetchHosts bool) (HostAvailabler, error)
 }
 
+// SchemaHostAvailablerFactory is implemented by the factories which need the schema
+// of client, such as to probe hosts with the same schema as the real requests.
+// HTTPClient prefers it over HostAvailablerFactory if the factory implements it
+type SchemaHostAvailablerFactory interface {
+	NewHostAvailablerWithSchema(projectID string, schema string, hosts []string,
+		mainHost string, skipFetchHosts bool) (HostAvailabler, error)
+}
+
 type HostAvailablerFactoryBase struct {
+	// PingConfig is the config of the created ping host availablers, it is copied
+	// for each availabler, and the schema of client is used if Schema is not set
+	PingConfig *PingHostAvailablerConfig
+	// SelectionStrategy is how the created availablers select the host of path,
+	// it takes place of the SelectionStrategy of PingConfig if it is set
+	SelectionStrategy HostSelectionStrategy
+	// Scoring is how the created availablers score the hosts, it takes place of
+	// the Scoring of PingConfig if it is set
+	Scoring HostScoring
+	// HostScorer scores the hosts of the created availablers, it takes place of
+	// the HostScorer of PingConfig if it is set
+	HostScorer HostScorer
+	// HostResolver discovers the hosts of the created availablers, it takes place of
+	// the HostResolver of PingConfig if it is set
+	HostResolver HostResolver
 }
 
 func (h *HostAvailablerFactoryBase) NewHostAvailabler(projectID string, hosts []string, mainHost string, skipFetchHosts bool) (HostAvailabler, error) {
-	return NewPingHostAvailabler(hosts, projectID, nil, mainHost, skipFetchHosts)
+	return NewPingHostAvailabler(hosts, projectID, h.copyPingConfig(), mainHost, skipFetchHosts)
+}
+
+func (h *HostAvailablerFactoryBase) NewHostAvailablerWithSchema(projectID string, schema string, hosts []string,
+	mainHost string, skipFetchHosts bool) (HostAvailabler, error) {
+	config := h.copyPingConfig()
+	if config.Schema == "" {
+		config.Schema = schema
+	}
+	return NewPingHostAvailabler(hosts, projectID, config, mainHost, skipFetchHosts)
+}
+
+func (h *HostAvailablerFactoryBase) copyPingConfig() *PingHostAvailablerConfig {
+	config := &PingHostAvailablerConfig{}
+	if h.PingConfig != nil {
+		*config = *h.PingConfig
+	}
+	if h.SelectionStrategy != "" {
+		config.SelectionStrategy = h.SelectionStrategy
+	}
+	if h.Scoring != "" {
+		config.Scoring = h.Scoring
+	}
+	if h.HostScorer != nil {
+		config.HostScorer = h.HostScorer
+	}
+	if h.HostResolver != nil {
+		config.HostResolver = h.HostResolver
+	}
+	return config
 }
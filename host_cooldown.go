@@ -0,0 +1,93 @@
+package core
+
+import (
+	"sync"
+	"time"
+
+	"github.com/byteplus-sdk/byteplus-sdk-go-rec-core/logs"
+	"github.com/byteplus-sdk/byteplus-sdk-go-rec-core/metrics"
+)
+
+const (
+	defaultCooldownDuration = 30 * time.Second
+	// the quarantined host is ranked after all the others, even the ones always failing
+	quarantinedHostScore = -1
+)
+
+// hostCooldown quarantines the host failing consecutively, the quarantined host is not
+// pinged and ranked last until the quarantine expires, then it is probed again and
+// quarantined again at once if it still fails
+type hostCooldown struct {
+	projectID string
+	failures  int
+	duration  time.Duration
+	lock      sync.Mutex
+	// the consecutive failures of each host
+	failureMap     map[string]int
+	quarantinedMap map[string]time.Time
+}
+
+// newHostCooldown returns nil if failures is not positive, which disables the cooldown
+func newHostCooldown(projectID string, failures int, duration time.Duration) *hostCooldown {
+	if failures <= 0 {
+		return nil
+	}
+	if duration <= 0 {
+		duration = defaultCooldownDuration
+	}
+	return &hostCooldown{
+		projectID:      projectID,
+		failures:       failures,
+		duration:       duration,
+		failureMap:     make(map[string]int),
+		quarantinedMap: make(map[string]time.Time),
+	}
+}
+
+func (c *hostCooldown) put(host string, success bool) {
+	if c == nil {
+		return
+	}
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	if success {
+		c.failureMap[host] = 0
+		if _, exist := c.quarantinedMap[host]; exist {
+			delete(c.quarantinedMap, host)
+			c.report("released", host)
+		}
+		return
+	}
+	c.failureMap[host]++
+	if c.failureMap[host] < c.failures {
+		return
+	}
+	now := time.Now()
+	if until, exist := c.quarantinedMap[host]; exist && now.Before(until) {
+		return
+	}
+	c.quarantinedMap[host] = now.Add(c.duration)
+	c.report("quarantined", host)
+}
+
+func (c *hostCooldown) isQuarantined(host string) bool {
+	if c == nil {
+		return false
+	}
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	until, exist := c.quarantinedMap[host]
+	return exist && time.Now().Before(until)
+}
+
+// report should be called with lock held
+func (c *hostCooldown) report(event, host string) {
+	metricsTags := []string{
+		"type:" + event,
+		"project_id:" + c.projectID,
+		"host:" + escapeMetricsTagValue(host),
+	}
+	metrics.Counter(metricsKeyHostQuarantine, 1, metricsTags...)
+	logs.Warn("host is %s, project_id:%s host:%s consecutive_failures:%d",
+		event, c.projectID, host, c.failureMap[host])
+}
@@ -0,0 +1,56 @@
+package core
+
+import (
+	"testing"
+	"time"
+)
+
+func TestHostCooldown(t *testing.T) {
+	if c := newHostCooldown("1", 0, time.Second); c != nil || c.isQuarantined("a") {
+		t.Fatalf("newHostCooldown() without failures = %v, want disabled", c)
+	}
+	c := newHostCooldown("1", 3, 50*time.Millisecond)
+	c.put("a", false)
+	c.put("a", false)
+	c.put("a", true)
+	c.put("a", false)
+	c.put("a", false)
+	if c.isQuarantined("a") {
+		t.Fatalf("host is quarantined without consecutive failures")
+	}
+	c.put("a", false)
+	if !c.isQuarantined("a") || c.isQuarantined("b") {
+		t.Fatalf("host is not quarantined after consecutive failures")
+	}
+	time.Sleep(60 * time.Millisecond)
+	if c.isQuarantined("a") {
+		t.Fatalf("host is still quarantined after the cooldown")
+	}
+	// the probe after the cooldown fails, then the host is quarantined again at once
+	c.put("a", false)
+	if !c.isQuarantined("a") {
+		t.Fatalf("host is not quarantined again after failing the probe")
+	}
+	c.put("a", true)
+	if c.isQuarantined("a") {
+		t.Errorf("host is quarantined after succeeding")
+	}
+}
+
+func TestPingHostAvailabler_cooldown(t *testing.T) {
+	availabler := &pingHostAvailabler{
+		HostAvailablerBase:  &HostAvailablerBase{projectID: "1"},
+		config:              fillDefaultConfig(&PingHostAvailablerConfig{CooldownFailures: 1}),
+		hostWindowMap:       make(map[string]*window),
+		hostLatencyMap:      make(map[string]*ewmaLatency),
+		receivedPingTimeMap: make(map[string]time.Time),
+		cooldown:            newHostCooldown("1", 1, time.Minute),
+	}
+	availabler.ReceivePingResult("a", false)
+	availabler.ReceivePingResult("b", true)
+	// the received results are not pinged again, and the quarantined host is not pinged
+	scores := availabler.ScoreHosts([]string{"a", "b"})
+	if scores[0].Score != quarantinedHostScore || scores[1].Score != 1 {
+		t.Errorf("ScoreHosts() = %v, want a quarantined", scores)
+	}
+}
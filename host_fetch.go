@@ -0,0 +1,95 @@
+package core
+
+import (
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/byteplus-sdk/byteplus-sdk-go-rec-core/logs"
+	"github.com/valyala/fasthttp"
+)
+
+const (
+	defaultFetchHostsSchema  = "http"
+	defaultFetchHostsPath    = "/data/api/sdk/host"
+	defaultFetchHostsTimeout = 5 * time.Second
+)
+
+// FetchRequestSigner signs the request fetching the host config from the host server,
+// such as with the credentials of the client
+type FetchRequestSigner func(request *fasthttp.Request) error
+
+// doFetchRequest sends the request fetching the host config signed by fetchHostsSigner,
+// if the host server rejects the signed request, such as the server not supporting
+// auth yet, the request is sent again anonymously for backward compatibility
+func (a *HostAvailablerBase) doFetchRequest(request *fasthttp.Request, response *fasthttp.Response,
+	reqID, url string) error {
+	a.buildFetchRequest(request, reqID, url)
+	if !a.signFetchRequest(request) {
+		return a.fetchHostsHTTPClient.DoTimeout(request, response, defaultFetchHostsTimeout)
+	}
+	err := a.fetchHostsHTTPClient.DoTimeout(request, response, defaultFetchHostsTimeout)
+	if err != nil || !isAuthFailureStatus(response.StatusCode()) {
+		return err
+	}
+	logs.Warn("signed fetching host is rejected, fetch anonymously, url:%s status:%d", url, response.StatusCode())
+	request.Reset()
+	response.Reset()
+	a.buildFetchRequest(request, reqID, url)
+	return a.fetchHostsHTTPClient.DoTimeout(request, response, defaultFetchHostsTimeout)
+}
+
+func (a *HostAvailablerBase) buildFetchRequest(request *fasthttp.Request, reqID, url string) {
+	request.SetRequestURI(url)
+	request.Header.SetMethod(fasthttp.MethodGet)
+	request.Header.Set("Request-Id", reqID)
+}
+
+// signFetchRequest reports whether the request is signed, it is sent anonymously if signing fails
+func (a *HostAvailablerBase) signFetchRequest(request *fasthttp.Request) bool {
+	if a.fetchHostsSigner == nil {
+		return false
+	}
+	if err := a.fetchHostsSigner(request); err != nil {
+		logs.Warn("sign fetching host fail, fetch anonymously, project_id:%s err:%v", a.projectID, err)
+		return false
+	}
+	return true
+}
+
+func (a *HostAvailablerBase) fetchHostsSchema() string {
+	if a.fetchSchema == "" {
+		return defaultFetchHostsSchema
+	}
+	return a.fetchSchema
+}
+
+// fetchHostsURL returns the url of the attempt of fetching hosts, the attempts rotate over
+// the default hosts unless the fetch host is specified, so that a down host is skipped
+func (a *HostAvailablerBase) fetchHostsURL(attempt int) string {
+	host := a.fetchHost
+	if host == "" {
+		defaultHosts := a.loadDefaultHosts()
+		host = defaultHosts[attempt%len(defaultHosts)]
+	}
+	path := a.fetchPath
+	if path == "" {
+		path = defaultFetchHostsPath
+	} else if !strings.HasPrefix(path, "/") {
+		path = "/" + path
+	}
+	args := fasthttp.AcquireArgs()
+	defer fasthttp.ReleaseArgs(args)
+	args.Add("project_id", a.projectID)
+	keys := make([]string, 0, len(a.fetchQueries))
+	for key := range a.fetchQueries {
+		if key != "project_id" {
+			keys = append(keys, key)
+		}
+	}
+	sort.Strings(keys)
+	for _, key := range keys {
+		args.Add(key, a.fetchQueries[key])
+	}
+	return a.fetchHostsSchema() + "://" + host + path + "?" + args.String()
+}
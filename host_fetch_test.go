@@ -0,0 +1,159 @@
+package core
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/valyala/fasthttp"
+)
+
+func TestHostAvailablerBase_signedFetch(t *testing.T) {
+	tests := []struct {
+		name           string
+		acceptSigned   bool
+		wantAnonymous  bool
+		wantHostConfig map[string][]string
+	}{
+		{"signed", true, false, map[string][]string{"*": {"signed-host"}}},
+		{"fallback_to_anonymous", false, true, map[string][]string{"*": {"anonymous-host"}}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			anonymous := false
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				if r.Header.Get("Authorization") == "" {
+					anonymous = true
+					_, _ = w.Write([]byte(`{"*":["anonymous-host"]}`))
+					return
+				}
+				if !tt.acceptSigned {
+					w.WriteHeader(http.StatusUnauthorized)
+					return
+				}
+				_, _ = w.Write([]byte(`{"*":["signed-host"]}`))
+			}))
+			defer server.Close()
+			a := &HostAvailablerBase{projectID: "1", hostScorer: reversingHostScorer{},
+				fetchHostsHTTPClient: &fasthttp.Client{},
+				fetchHostsSigner: func(request *fasthttp.Request) error {
+					request.Header.Set("Authorization", "signature")
+					return nil
+				}}
+			a.setHosts([]string{strings.TrimPrefix(server.URL, "http://")})
+			a.fetchHostsFromServer()
+			if got := a.GetSnapshot(); !reflect.DeepEqual(got, tt.wantHostConfig) || anonymous != tt.wantAnonymous {
+				t.Errorf("fetched %v anonymous:%v, want %v anonymous:%v", got, anonymous, tt.wantHostConfig, tt.wantAnonymous)
+			}
+		})
+	}
+}
+
+func TestHTTPClientBuilder_withFetchHostsSigner(t *testing.T) {
+	builder := &httpClientBuilder{projectID: "1", tenantID: "1", authAK: "fetch-ak", authSK: "sk",
+		authService: "air", region: &RegionConfig{Hosts: []string{"host"}, AuthRegion: "cn"},
+		hostAvailablerFactory: &HostAvailablerFactoryBase{}}
+	builder.withFetchHostsSigner()
+	signer := builder.hostAvailablerFactory.(*HostAvailablerFactoryBase).PingConfig.FetchRequestSigner
+	request := fasthttp.AcquireRequest()
+	defer fasthttp.ReleaseRequest(request)
+	request.SetRequestURI("http://host/data/api/sdk/host?project_id=1")
+	if err := signer(request); err != nil {
+		t.Fatalf("sign error = %v", err)
+	}
+	if auth := string(request.Header.Peek("Authorization")); !strings.Contains(auth, "Credential=fetch-ak/") {
+		t.Errorf("Authorization = %q, want signed by fetch-ak", auth)
+	}
+	// once the client is built, the requests are signed by its caller, so that
+	// the auth updated by the client takes effect
+	airAuthConfig, _ := fillDefaultAirAuthConfig(nil)
+	cli := newHTTPCaller("1", "1", true, "token", airAuthConfig, credential{},
+		&staticHostAvailabler{hosts: []string{"host"}}, &CallerConfig{}, "http", false, nil)
+	builder.fetchHostsSigner.Store(cli)
+	client := &HTTPClient{cli: cli}
+	if err := client.SetAirAuthToken("new-token"); err != nil {
+		t.Fatalf("SetAirAuthToken() error = %v", err)
+	}
+	request.Header.Del("Authorization")
+	if err := signer(request); err != nil {
+		t.Fatalf("sign error = %v", err)
+	}
+	ts, nonce := string(request.Header.Peek("Tenant-Ts")), string(request.Header.Peek("Tenant-Nonce"))
+	want := CalAirAuthSignature("new-token", "1", request.Body(), ts, nonce, "")
+	if got := string(request.Header.Peek("Tenant-Signature")); got != want {
+		t.Errorf("Tenant-Signature = %s, want signed by the updated token", got)
+	}
+}
+
+func TestHostAvailablerBase_fetchHostsEndpoint(t *testing.T) {
+	var requestURI string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requestURI = r.URL.RequestURI()
+		_, _ = w.Write([]byte(`{"*":["fetched-host"]}`))
+	}))
+	defer server.Close()
+	a := &HostAvailablerBase{projectID: "1", hostScorer: reversingHostScorer{},
+		fetchHostsHTTPClient: &fasthttp.Client{}, fetchPath: "custom/host",
+		fetchQueries: map[string]string{"env": "sandbox", "cluster": "a b", "project_id": "ignored"}}
+	// the down host is skipped by the next attempt
+	a.setHosts([]string{"127.0.0.1:1", strings.TrimPrefix(server.URL, "http://")})
+	a.fetchHostsFromServer()
+	if want := "/custom/host?project_id=1&cluster=a+b&env=sandbox"; requestURI != want {
+		t.Errorf("request uri = %s, want %s", requestURI, want)
+	}
+	if got := a.GetSnapshot(); !reflect.DeepEqual(got, map[string][]string{"*": {"fetched-host"}}) {
+		t.Errorf("GetSnapshot() = %v, want fetched-host", got)
+	}
+	a.fetchHost = "fetch.byteplus.com"
+	if got := a.fetchHostsURL(1); !strings.HasPrefix(got, "http://fetch.byteplus.com/custom/host?") {
+		t.Errorf("fetchHostsURL() = %s, want the specified host", got)
+	}
+}
+
+func TestHostAvailablerBase_restoreFetchedHosts(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write([]byte(`{"*":["fetched-host"]}`))
+	}))
+	defer server.Close()
+	dir := t.TempDir()
+	a := &HostAvailablerBase{projectID: "1", hostScorer: reversingHostScorer{},
+		fetchHostsHTTPClient: &fasthttp.Client{}, stateStore: &FileStateStore{Dir: dir}}
+	a.setHosts([]string{strings.TrimPrefix(server.URL, "http://")})
+	a.fetchHostsFromServer()
+
+	// the host server is broken on the cold start
+	server.Close()
+	for _, projectID := range []string{"1", "2"} {
+		// the store is shared by mistake, such as keyed without project
+		store := &projectStateStore{FileStateStore{Dir: dir}}
+		restarted := &HostAvailablerBase{projectID: projectID, hostScorer: reversingHostScorer{},
+			stateStore: store}
+		if err := restarted.Init([]string{"default-host"}, time.Hour, time.Hour); err != nil {
+			t.Fatalf("Init() error = %v", err)
+		}
+		restarted.Shutdown()
+		want := map[string][]string{"*": {"fetched-host"}}
+		if projectID != "1" {
+			want = map[string][]string{"*": {"default-host"}}
+		}
+		if got := restarted.GetSnapshot(); !reflect.DeepEqual(got, want) {
+			t.Errorf("project %s starts with %v, want %v", projectID, got, want)
+		}
+	}
+}
+
+// projectStateStore loads the state of project 1 for any project, and saves nothing
+type projectStateStore struct {
+	FileStateStore
+}
+
+func (s *projectStateStore) Load(projectID string) (*AvailablerState, error) {
+	return s.FileStateStore.Load("1")
+}
+
+func (s *projectStateStore) Save(projectID string, state *AvailablerState) error {
+	return nil
+}
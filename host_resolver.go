@@ -0,0 +1,87 @@
+package core
+
+import (
+	"context"
+	"errors"
+	"net"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/byteplus-sdk/byteplus-sdk-go-rec-core/logs"
+	"github.com/byteplus-sdk/byteplus-sdk-go-rec-core/metrics"
+)
+
+const defaultResolveTimeout = 5 * time.Second
+
+// HostResolver discovers the hosts serving the project in place of fetching them from
+// the host server, such as in the private-link deployments where the host server is not
+// reachable. It is called on the fetch host interval
+type HostResolver interface {
+	ResolveHosts() ([]string, error)
+}
+
+// SRVHostResolver discovers the hosts by the DNS SRV records of
+// _Service._Proto.Name, the host is "target:port" ordered by priority and weight
+type SRVHostResolver struct {
+	// Service such as "byteplus-rec", it can be empty to look up Name directly
+	Service string
+	// Proto such as "tcp"
+	Proto string
+	Name  string
+	// Resolver is the DNS resolver, default is net.DefaultResolver
+	Resolver *net.Resolver
+	// Timeout of each lookup, default is 5s
+	Timeout time.Duration
+}
+
+func (r *SRVHostResolver) ResolveHosts() ([]string, error) {
+	resolver := r.Resolver
+	if resolver == nil {
+		resolver = net.DefaultResolver
+	}
+	timeout := r.Timeout
+	if timeout <= 0 {
+		timeout = defaultResolveTimeout
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+	// the records are sorted by priority and randomized by weight
+	_, records, err := resolver.LookupSRV(ctx, r.Service, r.Proto, r.Name)
+	if err != nil {
+		return nil, err
+	}
+	hosts := make([]string, 0, len(records))
+	for _, record := range records {
+		target := strings.TrimSuffix(record.Target, ".")
+		if target == "" {
+			continue
+		}
+		hosts = append(hosts, net.JoinHostPort(target, strconv.Itoa(int(record.Port))))
+	}
+	if len(hosts) == 0 {
+		return nil, errors.New("no srv record is found")
+	}
+	return hosts, nil
+}
+
+// resolveHosts updates the host config by the hosts of hostResolver, the resolved
+// hosts serve all the paths
+func (a *HostAvailablerBase) resolveHosts() {
+	hosts, err := a.hostResolver.ResolveHosts()
+	if err != nil || len(hosts) == 0 {
+		metricsTags := []string{
+			"type:resolve_host_fail",
+			"project_id:" + a.projectID,
+		}
+		metrics.Counter(metricsKeyCommonError, 1, metricsTags...)
+		logs.Warn("resolve hosts fail, project_id:%s hosts:%v err:%v", a.projectID, hosts, err)
+		return
+	}
+	hostConfig := map[string][]string{"*": hosts}
+	if a.isServerHostsNotUpdated(hostConfig) {
+		logs.Debug("resolved hosts are not changed, hosts: %v", hosts)
+		return
+	}
+	a.doScoreAndUpdateHosts(hostConfig)
+}
@@ -0,0 +1,43 @@
+package core
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+)
+
+type staticHostResolver struct {
+	hosts []string
+	err   error
+}
+
+func (r *staticHostResolver) ResolveHosts() ([]string, error) {
+	return r.hosts, r.err
+}
+
+func TestHostAvailablerBase_hostResolver(t *testing.T) {
+	resolver := &staticHostResolver{hosts: []string{"c:443", "d:443"}}
+	a := &HostAvailablerBase{projectID: "1", hostScorer: staticHostScorer{"c:443": 0.5, "d:443": 1},
+		hostResolver: resolver}
+	if err := a.Init([]string{"a", "b"}, defaultFetchHostInterval, defaultPingInterval); err != nil {
+		t.Fatalf("Init() error = %v", err)
+	}
+	defer a.Shutdown()
+	want := map[string][]string{"*": {"d:443", "c:443"}}
+	if got := a.GetSnapshot(); !reflect.DeepEqual(got, want) {
+		t.Fatalf("GetSnapshot() = %v, want %v", got, want)
+	}
+	// the last resolved hosts are kept if resolving fails
+	resolver.err = errors.New("lookup fail")
+	a.fetchHostsFromServer()
+	if got := a.GetSnapshot(); !reflect.DeepEqual(got, want) {
+		t.Errorf("GetSnapshot() after resolving fail = %v, want %v", got, want)
+	}
+}
+
+func TestSRVHostResolver_notFound(t *testing.T) {
+	resolver := &SRVHostResolver{Service: "rec", Proto: "tcp", Name: "byteplus.invalid"}
+	if hosts, err := resolver.ResolveHosts(); err == nil {
+		t.Errorf("ResolveHosts() = %v, want error of the invalid domain", hosts)
+	}
+}
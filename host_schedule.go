@@ -0,0 +1,59 @@
+package core
+
+import (
+	"math/rand"
+	"sync/atomic"
+	"time"
+)
+
+const (
+	// the default ratio of the random jitter of the fetch and score intervals
+	defaultIntervalJitter = 0.1
+	// the interval is at least half of the configured one, so that it is not close to 0
+	maxIntervalJitter = 0.5
+)
+
+// scheduleWithJitter runs task every interval randomized by intervalJitter until stop is
+// closed, so that the clients started together do not ping and fetch at the same time. The
+// interval is loaded every round, so that it can be adjusted at runtime
+func (a *HostAvailablerBase) scheduleWithJitter(stop chan bool, interval *atomic.Value, task func()) {
+	a.asyncExecute(func() {
+		for {
+			timer := time.NewTimer(jitterDuration(interval.Load().(time.Duration), a.intervalJitter))
+			select {
+			case <-stop:
+				timer.Stop()
+				return
+			case <-timer.C:
+				task()
+			}
+		}
+	})
+}
+
+// jitterDuration returns a random duration in [d*(1-jitter), d*(1+jitter)], the jitter
+// is at most maxIntervalJitter
+func jitterDuration(d time.Duration, jitter float64) time.Duration {
+	if jitter <= 0 || d <= 0 {
+		return d
+	}
+	if jitter > maxIntervalJitter {
+		jitter = maxIntervalJitter
+	}
+	return time.Duration(float64(d) * (1 + jitter*(2*rand.Float64()-1)))
+}
+
+// SetFetchHostInterval adjusts the interval of fetching hosts from server, it takes
+// effect from the next round
+func (a *HostAvailablerBase) SetFetchHostInterval(interval time.Duration) {
+	if interval > 0 {
+		a.fetchInterval.Store(interval)
+	}
+}
+
+// SetScoreHostInterval adjusts the interval of scoring hosts, it takes effect from the next round
+func (a *HostAvailablerBase) SetScoreHostInterval(interval time.Duration) {
+	if interval > 0 {
+		a.scoreInterval.Store(interval)
+	}
+}
@@ -0,0 +1,50 @@
+package core
+
+import (
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestJitterDuration(t *testing.T) {
+	tests := []struct {
+		name     string
+		interval time.Duration
+		jitter   float64
+		min, max time.Duration
+	}{
+		{"no_jitter", time.Second, 0, time.Second, time.Second},
+		{"negative", time.Second, -1, time.Second, time.Second},
+		{"ratio", time.Second, 0.1, 900 * time.Millisecond, 1100 * time.Millisecond},
+		{"over_half", time.Second, 1, 500 * time.Millisecond, 1500 * time.Millisecond},
+		{"sub_millisecond", 500 * time.Microsecond, 1, 250 * time.Microsecond, 750 * time.Microsecond},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			for i := 0; i < 100; i++ {
+				if got := jitterDuration(tt.interval, tt.jitter); got < tt.min || got > tt.max {
+					t.Fatalf("jitterDuration() = %v, want in [%v, %v]", got, tt.min, tt.max)
+				}
+			}
+		})
+	}
+}
+
+func TestHostAvailablerBase_scheduleWithJitter(t *testing.T) {
+	a := &HostAvailablerBase{stop: make(chan bool), intervalJitter: defaultIntervalJitter}
+	a.fetchInterval.Store(time.Hour)
+	a.SetFetchHostInterval(0)
+	if got := a.fetchInterval.Load().(time.Duration); got != time.Hour {
+		t.Fatalf("interval after setting 0 = %v, want unchanged", got)
+	}
+	a.SetFetchHostInterval(5 * time.Millisecond)
+	var runs int32
+	a.scheduleWithJitter(a.stop, &a.fetchInterval, func() {
+		atomic.AddInt32(&runs, 1)
+	})
+	time.Sleep(100 * time.Millisecond)
+	close(a.stop)
+	if atomic.LoadInt32(&runs) < 2 {
+		t.Errorf("task runs %d times in 100ms of 5ms interval", runs)
+	}
+}
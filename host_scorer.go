@@ -0,0 +1,46 @@
+package core
+
+import "time"
+
+// HostScoring is how pingHostAvailabler scores the hosts by the ping results
+type HostScoring string
+
+const (
+	// HostScoringAvailability scores hosts by the success rate of pings, it is the default
+	HostScoringAvailability HostScoring = "availability"
+	// HostScoringLatency scores hosts by the EWMA latency of pings, the failed pings are
+	// counted as the ping timeout, so that a slow but successful host loses rank. The
+	// latency only orders the hosts, the health checks still apply to the success rate
+	HostScoringLatency HostScoring = "latency"
+	// HostScoringAvailabilityLatency scores hosts by the product of both scores
+	HostScoringAvailabilityLatency HostScoring = "availability_latency"
+)
+
+// the weight of the latest latency in EWMA
+const defaultLatencyDecay = 0.3
+
+// ewmaLatency is the exponentially weighted moving average of latency
+type ewmaLatency struct {
+	value       float64
+	initialized bool
+}
+
+func (e *ewmaLatency) put(latency time.Duration, decay float64) {
+	if !e.initialized {
+		e.value, e.initialized = float64(latency), true
+		return
+	}
+	e.value = decay*float64(latency) + (1-decay)*e.value
+}
+
+// score is in [0, 1], 1 for no latency and 0 for the latency of timeout
+func (e *ewmaLatency) score(timeout time.Duration) float64 {
+	if !e.initialized {
+		return 1
+	}
+	score := 1 - e.value/float64(timeout)
+	if score < 0 {
+		return 0
+	}
+	return score
+}
@@ -0,0 +1,82 @@
+package core
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/valyala/fasthttp"
+)
+
+func TestPingHostAvailabler_scoring(t *testing.T) {
+	newServer := func(delay time.Duration) string {
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			time.Sleep(delay)
+			_, _ = w.Write([]byte("pong"))
+		}))
+		t.Cleanup(server.Close)
+		return strings.TrimPrefix(server.URL, "http://")
+	}
+	fast, slow := newServer(0), newServer(150*time.Millisecond)
+	tests := []struct {
+		scoring  HostScoring
+		wantSlow bool
+	}{
+		{HostScoringAvailability, false},
+		{HostScoringLatency, true},
+		{HostScoringAvailabilityLatency, true},
+	}
+	for _, tt := range tests {
+		t.Run(string(tt.scoring), func(t *testing.T) {
+			availabler := &pingHostAvailabler{
+				HostAvailablerBase: &HostAvailablerBase{projectID: "1"},
+				config: fillDefaultConfig(&PingHostAvailablerConfig{Scoring: tt.scoring,
+					PingTimeout: 300 * time.Millisecond}),
+				httpCli:             &fasthttp.Client{},
+				hostWindowMap:       make(map[string]*window),
+				hostLatencyMap:      make(map[string]*ewmaLatency),
+				receivedPingTimeMap: make(map[string]time.Time),
+			}
+			scores := availabler.ScoreHosts([]string{fast, slow})
+			if scores[0].Score <= 0 || scores[1].Score <= 0 {
+				t.Fatalf("ScoreHosts() = %v, want positive scores of successful hosts", scores)
+			}
+			if slower := scores[1].Score < scores[0].Score; slower != tt.wantSlow {
+				t.Errorf("ScoreHosts() = %v, slow host loses rank = %v, want %v", scores, slower, tt.wantSlow)
+			}
+		})
+	}
+}
+
+func TestEWMALatency(t *testing.T) {
+	e := &ewmaLatency{}
+	if e.score(time.Second) != 1 {
+		t.Errorf("score() without latency = %v, want 1", e.score(time.Second))
+	}
+	e.put(100*time.Millisecond, 0.5)
+	e.put(300*time.Millisecond, 0.5)
+	if got := time.Duration(e.value); got != 200*time.Millisecond {
+		t.Errorf("ewma = %v, want 200ms", got)
+	}
+	e.put(5*time.Second, 1)
+	if e.score(time.Second) != 0 {
+		t.Errorf("score() over timeout = %v, want 0", e.score(time.Second))
+	}
+}
+
+func TestHostAvailablerFactoryBase_hostScorer(t *testing.T) {
+	scorer := staticHostScorer{"a": 0.1, "b": 0.8}
+	builder := &httpClientBuilder{hostScorer: scorer,
+		hostAvailablerFactory: &HostAvailablerFactoryBase{PingConfig: &PingHostAvailablerConfig{}}}
+	builder.withHostScorer()
+	availabler, err := builder.hostAvailablerFactory.NewHostAvailabler("1", []string{"a", "b"}, "", true)
+	if err != nil {
+		t.Fatalf("NewHostAvailabler() error = %v", err)
+	}
+	defer availabler.Shutdown()
+	if host := availabler.GetHost("Predict"); host != "b" {
+		t.Errorf("GetHost() = %s, want b scored by the injected scorer", host)
+	}
+}
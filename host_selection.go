@@ -0,0 +1,106 @@
+package core
+
+import (
+	"math/rand"
+	"sync/atomic"
+)
+
+// HostSelectionStrategy is how HostAvailablerBase.GetHost selects the host of path
+// from the hosts ordered by score
+type HostSelectionStrategy string
+
+const (
+	// HostSelectionTopOne always selects the top scored host, it is the default
+	HostSelectionTopOne HostSelectionStrategy = "top_one"
+	// HostSelectionRoundRobin selects the healthy hosts in turn
+	HostSelectionRoundRobin HostSelectionStrategy = "round_robin"
+	// HostSelectionWeighted selects the hosts randomly, weighted by their scores
+	HostSelectionWeighted HostSelectionStrategy = "weighted"
+)
+
+// the host whose success rate is not less than healthyHostScore is selected by round robin
+const healthyHostScore = 0.9
+
+// selectHost selects one of the non-empty hosts ordered by score, the top scored
+// host is selected if no host is healthy
+func (a *HostAvailablerBase) selectHost(hosts []string) string {
+	if len(hosts) == 1 {
+		return hosts[0]
+	}
+	switch a.selectionStrategy {
+	case HostSelectionRoundRobin:
+		healthy := a.healthyHosts(hosts)
+		if len(healthy) == 0 {
+			return hosts[0]
+		}
+		next := atomic.AddUint32(&a.nextHost, 1) - 1
+		return healthy[int(next%uint32(len(healthy)))]
+	case HostSelectionWeighted:
+		scores := a.loadHostScores()
+		total := 0.0
+		for _, host := range hosts {
+			if score := scores[host]; score > 0 {
+				total += score
+			}
+		}
+		if total <= 0 {
+			return hosts[0]
+		}
+		r := rand.Float64() * total
+		for _, host := range hosts {
+			if score := scores[host]; score > 0 {
+				if r < score {
+					return host
+				}
+				r -= score
+			}
+		}
+		return hosts[0]
+	default:
+		return hosts[0]
+	}
+}
+
+// healthyHosts returns the healthy hosts of the preferred tier in order. They are filtered
+// by the success rates instead of taking the leading ones, as the hosts of the preferred
+// tier are ahead of the others regardless of their scores, see preferTier
+func (a *HostAvailablerBase) healthyHosts(hosts []string) []string {
+	availabilities := a.loadHostAvailabilities()
+	var result []string
+	tier, found := 0, false
+	for _, host := range hosts {
+		if availabilities[host] < healthyHostScore {
+			continue
+		}
+		if !found {
+			tier, found = a.hostTiers[host], true
+		}
+		if a.hostTiers[host] == tier {
+			result = append(result, host)
+		}
+	}
+	return result
+}
+
+// loadHostScores returns the scores of the latest scoring, which must not be modified
+func (a *HostAvailablerBase) loadHostScores() map[string]float64 {
+	scores, _ := a.hostScores.Load().(map[string]float64)
+	return scores
+}
+
+// loadHostAvailabilities returns the success rates of the latest scoring, which must not be modified
+func (a *HostAvailablerBase) loadHostAvailabilities() map[string]float64 {
+	availabilities, _ := a.hostAvailabilities.Load().(map[string]float64)
+	return availabilities
+}
+
+func (a *HostAvailablerBase) storeHostScores(hostScores []*HostAvailabilityScore) {
+	scores := make(map[string]float64, len(hostScores))
+	availabilities := make(map[string]float64, len(hostScores))
+	for _, hostScore := range hostScores {
+		scores[hostScore.Host] = hostScore.Score
+		availabilities[hostScore.Host] = hostScore.availabilityScore()
+	}
+	a.hostScores.Store(scores)
+	a.hostAvailabilities.Store(availabilities)
+}
@@ -0,0 +1,72 @@
+package core
+
+import (
+	"sort"
+	"time"
+)
+
+// HostState is the routing health of a host observed by the host availabler
+type HostState struct {
+	Host string
+	// The score of the latest scoring, higher is preferred
+	Score float64
+	// The failure rate of the pings in window
+	FailureRate float64
+	// The latency of the latest ping, zero if it has not been pinged
+	LastLatency time.Duration
+	// The error of the latest ping, empty if it succeeded
+	LastError string
+	// The time of the latest ping
+	LastPingTime time.Time
+	// Whether the host is quarantined for failing consecutively
+	Quarantined bool
+}
+
+// HostStatesReader is implemented by the HostAvailabler which can report the health of
+// hosts, such as the ping host availabler, so that it can be surfaced on the dashboards
+type HostStatesReader interface {
+	GetHostStates() []*HostState
+}
+
+// hostPingState is the latest ping of a host
+type hostPingState struct {
+	latency time.Duration
+	err     error
+	time    time.Time
+}
+
+func (receiver *pingHostAvailabler) putPingState(host string, latency time.Duration, err error) {
+	receiver.lock.Lock()
+	defer receiver.lock.Unlock()
+	if receiver.hostPingStateMap == nil {
+		receiver.hostPingStateMap = make(map[string]*hostPingState)
+	}
+	receiver.hostPingStateMap[host] = &hostPingState{latency: latency, err: err, time: time.Now()}
+}
+
+// GetHostStates returns the states of the hosts in use ordered by host
+func (receiver *pingHostAvailabler) GetHostStates() []*HostState {
+	hosts := receiver.GetHosts()
+	sort.Strings(hosts)
+	scores := receiver.loadHostScores()
+	result := make([]*HostState, 0, len(hosts))
+	receiver.lock.Lock()
+	defer receiver.lock.Unlock()
+	for _, host := range hosts {
+		state := &HostState{
+			Host:        host,
+			Score:       scores[host],
+			FailureRate: receiver.getOrCreateWindow(host).failureRate(),
+			Quarantined: receiver.cooldown.isQuarantined(host),
+		}
+		if pingState, exist := receiver.hostPingStateMap[host]; exist {
+			state.LastLatency = pingState.latency
+			state.LastPingTime = pingState.time
+			if pingState.err != nil {
+				state.LastError = pingState.err.Error()
+			}
+		}
+		result = append(result, state)
+	}
+	return result
+}
@@ -0,0 +1,52 @@
+package core
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"sort"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestPingHostAvailabler_GetHostStates(t *testing.T) {
+	newServer := func(status int) string {
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.WriteHeader(status)
+			_, _ = w.Write([]byte("pong"))
+		}))
+		t.Cleanup(server.Close)
+		return strings.TrimPrefix(server.URL, "http://")
+	}
+	hosts := []string{newServer(http.StatusOK), newServer(http.StatusServiceUnavailable)}
+	availabler, err := NewPingHostAvailabler(hosts, "1", &PingHostAvailablerConfig{WindowSize: 2,
+		PingInterval: time.Hour}, "", true)
+	if err != nil {
+		t.Fatalf("NewPingHostAvailabler() error = %v", err)
+	}
+	defer availabler.Shutdown()
+	client := &HTTPClient{hostAvailabler: availabler}
+	states := client.GetHostStates()
+	if len(states) != 2 {
+		t.Fatalf("GetHostStates() = %v, want 2 hosts", states)
+	}
+	stateOf := make(map[string]*HostState)
+	for _, state := range states {
+		stateOf[state.Host] = state
+	}
+	healthy, down := stateOf[hosts[0]], stateOf[hosts[1]]
+	if healthy.Score != 1 || healthy.FailureRate != 0 || healthy.LastError != "" || healthy.LastPingTime.IsZero() {
+		t.Errorf("state of healthy host = %+v", healthy)
+	}
+	if down.Score != 0.5 || down.FailureRate != 0.5 || !strings.Contains(down.LastError, "503") {
+		t.Errorf("state of down host = %+v", down)
+	}
+	if !sort.SliceIsSorted(states, func(i, j int) bool { return states[i].Host < states[j].Host }) {
+		t.Errorf("GetHostStates() is not ordered by host")
+	}
+
+	client = &HTTPClient{hostAvailabler: &staticHostAvailabler{hosts: hosts}}
+	if states = client.GetHostStates(); states != nil {
+		t.Errorf("GetHostStates() of static host availabler = %v, want nil", states)
+	}
+}
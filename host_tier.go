@@ -0,0 +1,31 @@
+package core
+
+import "sort"
+
+// the preferred tier fails over if the failure rate of its best host exceeds it
+const defaultTierFailoverThreshold = 1 - healthyHostScore
+
+// preferTier moves the hosts of the preferred tier ahead of the others, keeping the order
+// by score within them. The preferred tier is the lowest tier whose best host fails no
+// more than tierThreshold, the failure rate of host is 1 - success rate. The hosts are
+// ordered by score only if no tier is healthy
+func (a *HostAvailablerBase) preferTier(hosts []string, availabilityIndex map[string]float64) {
+	if len(a.hostTiers) == 0 {
+		return
+	}
+	preferred, found := 0, false
+	for _, host := range hosts {
+		if 1-availabilityIndex[host] > a.tierThreshold {
+			continue
+		}
+		if tier := a.hostTiers[host]; !found || tier < preferred {
+			preferred, found = tier, true
+		}
+	}
+	if !found {
+		return
+	}
+	sort.SliceStable(hosts, func(i, j int) bool {
+		return a.hostTiers[hosts[i]] == preferred && a.hostTiers[hosts[j]] != preferred
+	})
+}
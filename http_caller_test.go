@@ -1,9 +1,16 @@
 package core
 
 import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"net/textproto"
+	"strings"
 	"testing"
+	"time"
 
 	"github.com/byteplus-sdk/byteplus-sdk-go-rec-core/option"
+	"github.com/valyala/fasthttp"
 )
 
 func TestHTTPCaller_withOptionQueries(t *testing.T) {
@@ -90,3 +97,199 @@ func TestHTTPCaller_withOptionQueries(t *testing.T) {
 		})
 	}
 }
+
+func TestHTTPCaller_withOptionHeaders(t *testing.T) {
+	tests := []struct {
+		name    string
+		options *option.Options
+		want    map[string]string
+	}{
+		{
+			name:    "lower_case_overrides_sdk_header",
+			options: option.Conv2Options(option.WithRequestID("req"), option.WithHTTPHeader("request-id", "user")),
+			want:    map[string]string{"Request-Id": "user"},
+		},
+		{
+			name: "raw_headers_canonicalized",
+			options: &option.Options{
+				RequestID: "req",
+				Headers:   map[string]string{"x-custom": "a", "timeout-millis": "100"},
+			},
+			want: map[string]string{"Request-Id": "req", "X-Custom": "a", "Timeout-Millis": "100"},
+		},
+		{
+			name:    "same_key_in_different_case",
+			options: option.Conv2Options(option.WithHTTPHeader("x-custom", "a"), option.WithHTTPHeader("X-CUSTOM", "b")),
+			want:    map[string]string{"X-Custom": "b"},
+		},
+	}
+	c := &httpCaller{}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			headers := make(map[string]string)
+			c.withOptionHeaders(headers, tt.options)
+			for k, v := range tt.want {
+				if headers[k] != v {
+					t.Errorf("headers[%q] = %q, want %q", k, headers[k], v)
+				}
+			}
+			for k := range headers {
+				if k != textproto.CanonicalMIMEHeaderKey(k) {
+					t.Errorf("header key %q is not canonical", k)
+				}
+			}
+		})
+	}
+}
+
+func TestHTTPCaller_withOptionQueriesEscaped(t *testing.T) {
+	tests := []struct {
+		name    string
+		options *option.Options
+		want    string
+	}{
+		{
+			name:    "special_chars",
+			options: option.Conv2Options(option.WithHTTPQuery("filter", "a=1&b c")),
+			want:    "http://host/path?filter=a%3D1%26b+c",
+		},
+		{
+			name:    "unicode",
+			options: option.Conv2Options(option.WithHTTPQuery("name", "数据")),
+			want:    "http://host/path?name=%E6%95%B0%E6%8D%AE",
+		},
+		{
+			name: "sorted_with_multi_values",
+			options: option.Conv2Options(option.WithHTTPQuery("stage", "pre"), option.WithHTTPQuery("a", "1"),
+				option.WithHTTPQueryAdd("id", "x"), option.WithHTTPQueryAdd("id", "y")),
+			want: "http://host/path?a=1&stage=pre&id=x&id=y",
+		},
+	}
+	c := &httpCaller{}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := c.withOptionQueries(tt.options, "http://host/path")
+			if got != tt.want {
+				t.Errorf("withOptionQueries() = %v, want %v", got, tt.want)
+			}
+			uri := fasthttp.AcquireURI()
+			defer fasthttp.ReleaseURI(uri)
+			if err := uri.Parse(nil, []byte(got)); err != nil {
+				t.Fatalf("Parse() error = %v", err)
+			}
+			for name, value := range tt.options.Queries {
+				if string(uri.QueryArgs().Peek(name)) != value {
+					t.Errorf("query %s = %q, want %q", name, uri.QueryArgs().Peek(name), value)
+				}
+			}
+		})
+	}
+}
+
+func TestHTTPCaller_reportResult(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/fail":
+			w.WriteHeader(http.StatusBadGateway)
+		case "/slow":
+			time.Sleep(100 * time.Millisecond)
+		}
+	}))
+	defer server.Close()
+	host := strings.TrimPrefix(server.URL, "http://")
+	availabler := &pingHostAvailabler{
+		HostAvailablerBase:  &HostAvailablerBase{projectID: "1"},
+		config:              fillDefaultConfig(&PingHostAvailablerConfig{WindowSize: 2}),
+		hostWindowMap:       map[string]*window{host: newWindow(2)},
+		hostLatencyMap:      make(map[string]*ewmaLatency),
+		receivedPingTimeMap: make(map[string]time.Time),
+	}
+	airAuthConfig, _ := fillDefaultAirAuthConfig(nil)
+	c := newHTTPCaller("project", "tenant", true, "token", airAuthConfig, credential{},
+		availabler, &CallerConfig{}, "http", false, nil)
+	for i := 0; i < minPassiveRequests/2; i++ {
+		for _, path := range []string{"/ok", "/fail"} {
+			_, _ = c.doHTTPRequest("req", server.URL+path, map[string]string{}, []byte("{}"),
+				&option.Options{}, nil)
+		}
+	}
+	// the requests failed by the client are not the failures of host
+	_, _ = c.doHTTPRequest("req", server.URL+"/slow", map[string]string{}, []byte("{}"),
+		&option.Options{Timeout: 10 * time.Millisecond}, nil)
+	c.interceptors = []Interceptor{func(next Invoker) Invoker {
+		return func(request *fasthttp.Request, response *fasthttp.Response) error {
+			return errors.New("rejected by interceptor")
+		}
+	}}
+	_, _ = c.doHTTPRequest("req", server.URL+"/ok", map[string]string{}, []byte("{}"), &option.Options{}, nil)
+
+	availabler.lock.Lock()
+	defer availabler.lock.Unlock()
+	if got, count := availabler.hostPassiveMap[host].failureRate(time.Now()); got != 0.5 || count != minPassiveRequests {
+		t.Errorf("passive failure rate = %v of %d requests, want 0.5 of %d", got, count, minPassiveRequests)
+	}
+	if got := availabler.hostWindowMap[host].failureRate(); got != 0 {
+		t.Errorf("ping failure rate = %v, want the live requests not in the ping window", got)
+	}
+	if _, availability := availabler.score(host); availability != 0.5 {
+		t.Errorf("availability = %v, want lowered by the live requests", availability)
+	}
+	if availabler.hostLatencyMap[host] == nil {
+		t.Errorf("the failed request should count as ping timeout")
+	}
+}
+
+func TestPassiveWindow(t *testing.T) {
+	w := newPassiveWindow(10 * time.Second)
+	now := time.Now()
+	w.put(false, now)
+	w.put(true, now.Add(5*time.Second))
+	if got, count := w.failureRate(now.Add(5 * time.Second)); got != 0.5 || count != 2 {
+		t.Errorf("failureRate() = %v of %d, want 0.5 of 2", got, count)
+	}
+	// the outcomes out of the window expire
+	if got, count := w.failureRate(now.Add(12 * time.Second)); got != 0 || count != 1 {
+		t.Errorf("failureRate() after expiring = %v of %d, want 0 of 1", got, count)
+	}
+}
+
+func TestRequestKey(t *testing.T) {
+	cred := func(sk string) *option.Options {
+		return &option.Options{Credential: &option.Credential{AccessKeyID: "ak", SecretAccessKey: sk}}
+	}
+	if requestKey(cred("sk1"), "url", nil) == requestKey(cred("sk2"), "url", nil) {
+		t.Errorf("the requests of different credentials share the same key")
+	}
+	if isIdempotentRead(&option.Options{}) {
+		t.Errorf("POST request without WithCacheable is not an idempotent read")
+	}
+	if !isIdempotentRead(&option.Options{Method: "get"}) || !isIdempotentRead(&option.Options{Cacheable: true}) {
+		t.Errorf("GET and cacheable requests are idempotent reads")
+	}
+}
+
+func TestHTTPCaller_retry(t *testing.T) {
+	c := &httpCaller{config: &CallerConfig{MaxRetryTimes: 2}, projectID: "project"}
+	tests := []struct {
+		name string
+		err  error
+		want int
+	}{
+		{"retryable", newRequestError("req", "http://host/path", 503, nil, nil), 3},
+		{"not retryable", newRequestError("req", "http://host/path", 400, nil, nil), 1},
+		{"client throttled", ErrClientThrottled, 1},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			attempts := 0
+			err := c.retry(&RequestContext{}, "req", "http://host/path", &option.Options{}, func() error {
+				attempts++
+				return tt.err
+			})
+			var attemptErrs AttemptErrors
+			if attempts != tt.want || !errors.As(err, &attemptErrs) || len(attemptErrs) != tt.want {
+				t.Errorf("attempts = %d err = %v, want %d attempts", attempts, err, tt.want)
+			}
+		})
+	}
+}
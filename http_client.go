@@ -1,37 +1,195 @@
 package core
 
 import (
+	"context"
 	"errors"
+	"fmt"
+	"net/http"
 	"sync"
+	"sync/atomic"
+	"time"
 
+	"github.com/byteplus-sdk/byteplus-sdk-go-rec-core/logs"
 	"github.com/byteplus-sdk/byteplus-sdk-go-rec-core/metrics"
 
 	"github.com/byteplus-sdk/byteplus-sdk-go-rec-core/option"
+	"github.com/valyala/fasthttp"
 	"google.golang.org/protobuf/proto"
 )
 
 type HTTPClient struct {
-	cli            *httpCaller
-	hostAvailabler HostAvailabler
-	schema         string
-	projectID      string
+	// the generation of metrics.Collector owned by the client, 0 if it is not owned, it is
+	// swapped to 0 on shutdown, see acquireMetrics. It is the first field, so that it is
+	// 64-bit aligned for the atomic operations on 32-bit platforms
+	metricsGeneration int64
+	cli               *httpCaller
+	grpcCli           *grpcCaller
+	hostAvailabler    HostAvailabler
+	schema            string
+	projectID         string
+	routeInterceptors []RouteInterceptor
+	requestHooks      []RequestHook
+	loadBalancer      LoadBalancer
+	strictRequestID   bool
 }
 
 func (h *HTTPClient) DoJSONRequest(path string, request interface{},
 	response proto.Message, options *option.Options) error {
-	url := buildURL(h.schema, h.hostAvailabler.GetHost(path), path)
-	return h.cli.doJSONRequest(url, request, response, options)
+	reqCtx, err := h.newRequestContext(path, options)
+	if err != nil {
+		return err
+	}
+	url, options := h.route(reqCtx)
+	err = h.cli.doJSONRequest(reqCtx, url, request, response, options)
+	h.callRequestHooks(reqCtx, err)
+	return err
 }
 
 func (h *HTTPClient) DoPBRequest(path string, request proto.Message,
 	response proto.Message, options *option.Options) error {
-	url := buildURL(h.schema, h.hostAvailabler.GetHost(path), path)
-	return h.cli.doPBRequest(url, request, response, options)
+	reqCtx, err := h.newRequestContext(path, options)
+	if err != nil {
+		return err
+	}
+	url, options := h.route(reqCtx)
+	if h.grpcCli != nil {
+		err = h.grpcCli.doPBRequest(reqCtx, url, request, response, options)
+	} else {
+		err = h.cli.doPBRequest(reqCtx, url, request, response, options)
+	}
+	h.callRequestHooks(reqCtx, err)
+	return err
+}
+
+// DoRawRequest sends body as is with contentType, such as the csv or ndjson bodies,
+// and returns the response body. It is signed, routed, compressed and retried the
+// same as DoPBRequest, except that it is always sent by http
+func (h *HTTPClient) DoRawRequest(path, contentType string, body []byte,
+	options *option.Options) ([]byte, error) {
+	reqCtx, err := h.newRequestContext(path, options)
+	if err != nil {
+		return nil, err
+	}
+	url, options := h.route(reqCtx)
+	rspBytes, err := h.cli.doRawRequest(reqCtx, url, contentType, body, options)
+	h.callRequestHooks(reqCtx, err)
+	return rspBytes, err
 }
 
+// newRequestContext creates the context of a call, the request id is required in strict mode,
+// and the stage of option.WithStage should be supported
+func (h *HTTPClient) newRequestContext(path string, options *option.Options) (*RequestContext, error) {
+	if h.strictRequestID && !hasRequestID(options) {
+		metricsTags := []string{
+			"type:missing_request_id",
+			"project_id:" + h.projectID,
+		}
+		metrics.Counter(metricsKeyCommonError, 1, metricsTags...)
+		logs.Error("request id is required in strict mode, path:%s", path)
+		return nil, ErrRequestIDRequired
+	}
+	if options != nil && options.Stage != "" && !options.Stage.Valid() {
+		metricsTags := []string{
+			"type:invalid_stage",
+			"project_id:" + h.projectID,
+		}
+		metrics.Counter(metricsKeyCommonError, 1, metricsTags...)
+		logs.Error("stage is not supported, stage:%s path:%s", options.Stage, path)
+		return nil, ErrInvalidStage
+	}
+	return newRequestContext(path, options), nil
+}
+
+func (h *HTTPClient) callRequestHooks(reqCtx *RequestContext, err error) {
+	for _, hook := range h.requestHooks {
+		hook(reqCtx, err)
+	}
+}
+
+// SetAirAuthToken rotates the air auth token of the client, the requests signed after it
+// use the new token, and the keep-alive connections are not dropped
+func (h *HTTPClient) SetAirAuthToken(token string) error {
+	if !h.cli.useAirAuth {
+		return errors.New("client does not use air auth")
+	}
+	if token == "" {
+		return errors.New("token cannot be null")
+	}
+	if h.cli.fipsMode {
+		if err := checkFIPSAirAuthToken(token); err != nil {
+			return err
+		}
+	}
+	h.cli.airAuthToken.Store(token)
+	logs.Info("air auth token is rotated, project_id:%s", h.projectID)
+	return nil
+}
+
+// OnHostsChanged adds the listener called when the host config of the client changes,
+// it returns error if the host availabler does not implement HostsChangedNotifier
+func (h *HTTPClient) OnHostsChanged(listener HostsChangedListener) error {
+	notifier, ok := h.hostAvailabler.(HostsChangedNotifier)
+	if !ok {
+		return errors.New("host availabler does not notify hosts changes")
+	}
+	notifier.OnHostsChanged(listener)
+	return nil
+}
+
+// GetHostStates returns the routing health of hosts, or nil if the host availabler
+// does not implement HostStatesReader
+func (h *HTTPClient) GetHostStates() []*HostState {
+	reader, ok := h.hostAvailabler.(HostStatesReader)
+	if !ok {
+		return nil
+	}
+	return reader.GetHostStates()
+}
+
+// Stats returns the connection and request counters of the client, the connection
+// counters are unknown if the transport is injected, see CallerStats.ConnsUnknown
+func (h *HTTPClient) Stats() CallerStats {
+	return h.cli.stats.snapshot()
+}
+
+// Shutdown is ShutdownWithContext bounded by 30s, so that a hung request does not block
+// the exit of process, it can be called more than once
 func (h *HTTPClient) Shutdown() {
-	h.hostAvailabler.Shutdown()
-	h.cli.shutdown()
+	_ = h.ShutdownWithTimeout(defaultShutdownTimeout)
+}
+
+// ShutdownWithTimeout is ShutdownWithContext bounded by timeout
+func (h *HTTPClient) ShutdownWithTimeout(timeout time.Duration) error {
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+	return h.ShutdownWithContext(ctx)
+}
+
+// ShutdownWithContext rejects the new requests by ErrClientShutdown, drains the in-flight
+// requests including their retries, flushes the metrics, stops the background goroutines
+// of the client and host availabler, and closes the connections.
+// It returns the error of ctx if the requests or goroutines are not finished before ctx
+// is done, the connections are closed anyway.
+// metrics.Collector is shared by the clients, it is shut down by the last client of the
+// ones sharing it if it is initialed by a client, otherwise it is only flushed
+func (h *HTTPClient) ShutdownWithContext(ctx context.Context) error {
+	err := h.cli.shutdown(ctx)
+	// after the requests are drained, so that their metrics are flushed too, and before
+	// the host availabler is shut down, which provides the domains to report to
+	if metricsErr := releaseMetrics(ctx, atomic.SwapInt64(&h.metricsGeneration, 0)); err == nil {
+		err = metricsErr
+	}
+	if shutdowner, ok := h.hostAvailabler.(GracefulShutdowner); ok {
+		if shutdownErr := shutdowner.ShutdownWithContext(ctx); err == nil {
+			err = shutdownErr
+		}
+	} else {
+		h.hostAvailabler.Shutdown()
+	}
+	if h.grpcCli != nil {
+		h.grpcCli.shutdown()
+	}
+	return err
 }
 
 type httpClientBuilder struct {
@@ -39,18 +197,40 @@ type httpClientBuilder struct {
 	projectID             string
 	useAirAuth            bool
 	airAuthToken          string
+	airAuthConfig         *AirAuthConfig
 	authAK                string
 	authSK                string
+	credentialsProvider   CredentialsProvider
+	tokenSource           TokenSource
 	authService           string
 	schema                string
 	mainHost              string
 	hosts                 []string
 	region                IRegion
+	regionName            string
+	environment           string
 	keepAlive             bool
 	hostAvailablerFactory HostAvailablerFactory
+	hostScorer            HostScorer
 	callerConfig          *CallerConfig
 	hostAvailabler        HostAvailabler
 	metricsCfg            *metrics.Config
+	transport             Transport
+	routeInterceptors     []RouteInterceptor
+	requestHooks          []RequestHook
+	quotaCoordinator      QuotaCoordinator
+	loadBalancer          LoadBalancer
+	strictRequestID       bool
+	auditSink             AuditSink
+	fipsMode              bool
+	protocol              Protocol
+	interceptors          []Interceptor
+	contextInterceptors   []ContextInterceptor
+	grpcConfig            *GRPCConfig
+	// shared by the httpCaller of client and the signer of fetching hosts
+	credentialsCache *credentialsCache
+	// holds the *httpCaller signing the requests fetching hosts
+	fetchHostsSigner *atomic.Value
 }
 
 func NewHTTPClientBuilder() *httpClientBuilder {
@@ -72,6 +252,11 @@ func (receiver *httpClientBuilder) AirAuthToken(airAuthToken string) *httpClient
 	return receiver
 }
 
+func (receiver *httpClientBuilder) AirAuthConfig(airAuthConfig *AirAuthConfig) *httpClientBuilder {
+	receiver.airAuthConfig = airAuthConfig
+	return receiver
+}
+
 func (receiver *httpClientBuilder) AuthAK(authAK string) *httpClientBuilder {
 	receiver.authAK = authAK
 	return receiver
@@ -82,6 +267,25 @@ func (receiver *httpClientBuilder) AuthSK(authSK string) *httpClientBuilder {
 	return receiver
 }
 
+// CredentialsProvider resolves the ak and sk per request instead of AuthAK and AuthSK,
+// such as DefaultCredentialsProvider, so that the rotated credentials take effect
+func (receiver *httpClientBuilder) CredentialsProvider(provider CredentialsProvider) *httpClientBuilder {
+	receiver.credentialsProvider = provider
+	return receiver
+}
+
+// TokenSource signs the requests by the bearer token of source instead of air auth
+// and ak/sk, the token is cached until it is about to expire
+func (receiver *httpClientBuilder) TokenSource(source TokenSource) *httpClientBuilder {
+	receiver.tokenSource = source
+	return receiver
+}
+
+// BearerToken signs the requests by the static bearer token, see TokenSource
+func (receiver *httpClientBuilder) BearerToken(token string) *httpClientBuilder {
+	return receiver.TokenSource(StaticTokenSource(token))
+}
+
 func (receiver *httpClientBuilder) AuthService(authService string) *httpClientBuilder {
 	receiver.authService = authService
 	return receiver
@@ -112,12 +316,32 @@ func (receiver *httpClientBuilder) Region(region IRegion) *httpClientBuilder {
 	return receiver
 }
 
+// RegionName selects the region registered by RegisterRegion, it takes place of Region
+func (receiver *httpClientBuilder) RegionName(name string) *httpClientBuilder {
+	receiver.regionName = name
+	return receiver
+}
+
+// Environment selects the hosts of environment configured in RegionConfig.Environments,
+// such as "sandbox", default is the hosts of the region
+func (receiver *httpClientBuilder) Environment(environment string) *httpClientBuilder {
+	receiver.environment = environment
+	return receiver
+}
+
 func (receiver *httpClientBuilder) HostAvailablerFactory(
 	hostAvailablerFactory HostAvailablerFactory) *httpClientBuilder {
 	receiver.hostAvailablerFactory = hostAvailablerFactory
 	return receiver
 }
 
+// HostScorer scores the hosts of the default host availabler in place of pinging them,
+// such as by the health data of the service mesh. It takes effect with HostAvailablerFactoryBase
+func (receiver *httpClientBuilder) HostScorer(scorer HostScorer) *httpClientBuilder {
+	receiver.hostScorer = scorer
+	return receiver
+}
+
 func (receiver *httpClientBuilder) KeepAlive(keepAlive bool) *httpClientBuilder {
 	receiver.keepAlive = keepAlive
 	return receiver
@@ -133,6 +357,105 @@ func (receiver *httpClientBuilder) HostAvailabler(hostAvailabler HostAvailabler)
 	return receiver
 }
 
+// FastHTTPClient use the pre-configured client to send requests, so that it can
+// be shared by multiple HTTPClient. The connection related fields of CallerConfig
+// are ignored, and the connection counters of HTTPClient.Stats are unknown.
+func (receiver *httpClientBuilder) FastHTTPClient(client *fasthttp.Client) *httpClientBuilder {
+	if client != nil {
+		receiver.transport = client
+	}
+	return receiver
+}
+
+// Transport use the transport to send requests instead of the default fasthttp client,
+// the same as FastHTTPClient, the connection related fields of CallerConfig are ignored
+func (receiver *httpClientBuilder) Transport(transport Transport) *httpClientBuilder {
+	receiver.transport = transport
+	return receiver
+}
+
+// NetHTTPClient use the net/http client to send requests instead of fasthttp,
+// http.DefaultClient is used if client is nil, see NetHTTPTransport
+func (receiver *httpClientBuilder) NetHTTPClient(client *http.Client) *httpClientBuilder {
+	receiver.transport = NewNetHTTPTransport(client)
+	return receiver
+}
+
+// Protocol the protocol of sending the pb requests, ProtocolHTTP by default
+func (receiver *httpClientBuilder) Protocol(protocol Protocol) *httpClientBuilder {
+	receiver.protocol = protocol
+	return receiver
+}
+
+// GRPCConfig the config of ProtocolGRPC
+func (receiver *httpClientBuilder) GRPCConfig(config *GRPCConfig) *httpClientBuilder {
+	receiver.grpcConfig = config
+	return receiver
+}
+
+// Interceptors the interceptors wrap every request and response of http,
+// they are called in the order of registration, see Interceptor
+func (receiver *httpClientBuilder) Interceptors(interceptors ...Interceptor) *httpClientBuilder {
+	receiver.interceptors = append(receiver.interceptors, interceptors...)
+	return receiver
+}
+
+// ContextInterceptors the interceptors wrap every request and response of http with
+// the context of the request, they wrap the Interceptors, see ContextInterceptor
+func (receiver *httpClientBuilder) ContextInterceptors(interceptors ...ContextInterceptor) *httpClientBuilder {
+	receiver.contextInterceptors = append(receiver.contextInterceptors, interceptors...)
+	return receiver
+}
+
+// RouteInterceptors the interceptors can change the host, path and options of
+// each request before it is signed, they are called in the order of registration
+func (receiver *httpClientBuilder) RouteInterceptors(interceptors ...RouteInterceptor) *httpClientBuilder {
+	receiver.routeInterceptors = append(receiver.routeInterceptors, interceptors...)
+	return receiver
+}
+
+// RequestHooks the hooks are called after each request is finished, in the order of registration
+func (receiver *httpClientBuilder) RequestHooks(hooks ...RequestHook) *httpClientBuilder {
+	receiver.requestHooks = append(receiver.requestHooks, hooks...)
+	return receiver
+}
+
+// QuotaCoordinator consults the coordinator before each request is sent,
+// to share the tenant quota among the SDK instances
+func (receiver *httpClientBuilder) QuotaCoordinator(coordinator QuotaCoordinator) *httpClientBuilder {
+	receiver.quotaCoordinator = coordinator
+	return receiver
+}
+
+// LoadBalancer picks the host of each request from the hosts of the path,
+// default is to pick the most available host
+func (receiver *httpClientBuilder) LoadBalancer(loadBalancer LoadBalancer) *httpClientBuilder {
+	receiver.loadBalancer = loadBalancer
+	return receiver
+}
+
+// StrictRequestID rejects the requests without request id specified by
+// option.WithRequestID, instead of generating one for them
+func (receiver *httpClientBuilder) StrictRequestID(strict bool) *httpClientBuilder {
+	receiver.strictRequestID = strict
+	return receiver
+}
+
+// AuditSink records every attempt of requests to the sink in a hash-chained format,
+// so that the data sent to server can be audited
+func (receiver *httpClientBuilder) AuditSink(sink AuditSink) *httpClientBuilder {
+	receiver.auditSink = sink
+	return receiver
+}
+
+// FIPSMode requires go crypto running in FIPS 140 mode, so that signing uses
+// the FIPS-validated hash and HMAC, and rejects the options not approved
+// by FIPS, such as air auth v1. Build fails if the requirements are not met
+func (receiver *httpClientBuilder) FIPSMode(enabled bool) *httpClientBuilder {
+	receiver.fipsMode = enabled
+	return receiver
+}
+
 func (receiver *httpClientBuilder) MetricsCfg(metricsConfig *metrics.Config) *httpClientBuilder {
 	receiver.metricsCfg = metricsConfig
 	return receiver
@@ -154,35 +477,138 @@ func (receiver *httpClientBuilder) Build() (*HTTPClient, error) {
 			receiver.initGlobalHostAvailabler()
 		}
 	}
-	metrics.Collector.Init(receiver.metricsCfg, globalHostAvailabler)
+	metricsGeneration := acquireMetrics(receiver.metricsCfg, globalHostAvailabler)
+	// the collector may be initialed before any availabler is created, such as
+	// by metrics.Collector.InitWithOptions, follow the hosts of this client then
+	if globalHostAvailabler != nil {
+		metrics.Collector.SetHostReader(globalHostAvailabler)
+	} else {
+		metrics.Collector.SetHostReader(receiver.hostAvailabler)
+	}
+	cli := receiver.newHTTPCaller()
+	if receiver.fetchHostsSigner != nil {
+		receiver.fetchHostsSigner.Store(cli)
+	}
+	var grpcCli *grpcCaller
+	if receiver.protocol == ProtocolGRPC {
+		grpcCli = newGRPCCaller(cli, receiver.grpcConfig)
+		if notifier, ok := receiver.hostAvailabler.(HostsChangedNotifier); ok {
+			notifier.OnHostsChanged(grpcCli.closeRemovedHosts)
+		}
+	}
+	receiver.followRegionSelector()
 	return &HTTPClient{
-		cli:            receiver.newHTTPCaller(),
-		hostAvailabler: receiver.hostAvailabler,
-		schema:         receiver.schema,
-		projectID:      receiver.projectID,
+		cli:               cli,
+		grpcCli:           grpcCli,
+		hostAvailabler:    receiver.hostAvailabler,
+		schema:            receiver.schema,
+		projectID:         receiver.projectID,
+		routeInterceptors: receiver.routeInterceptors,
+		requestHooks:      receiver.requestHooks,
+		loadBalancer:      receiver.loadBalancer,
+		strictRequestID:   receiver.strictRequestID,
+		metricsGeneration: metricsGeneration,
 	}, nil
 }
 
+// hostsSwitcher is implemented by the host availablers embedding HostAvailablerBase
+type hostsSwitcher interface {
+	switchHosts(hosts []string)
+}
+
+// followRegionSelector switches the hosts of client to the ones of the region selected
+// by RegionSelector, unless the hosts are specified by user
+func (receiver *httpClientBuilder) followRegionSelector() {
+	selector, ok := receiver.region.(*RegionSelector)
+	if !ok || len(receiver.hosts) > 0 {
+		return
+	}
+	switcher, ok := receiver.hostAvailabler.(hostsSwitcher)
+	if !ok {
+		logs.Warn("the hosts of custom host availabler do not follow the selected region")
+		return
+	}
+	selector.onSelected(func(config *RegionConfig) {
+		switcher.switchHosts(config.Hosts)
+	})
+}
+
 func (receiver *httpClientBuilder) checkRequiredField() error {
 	if receiver.tenantID == "" {
 		return errors.New("tenant id is null")
 	}
+	if err := receiver.checkFIPSMode(); err != nil {
+		return err
+	}
 	if err := receiver.checkAuthRequiredField(); err != nil {
 		return err
 	}
+	if err := receiver.checkProxy(); err != nil {
+		return err
+	}
+	if receiver.regionName != "" {
+		config, exist := GetRegionConfig(receiver.regionName)
+		if !exist {
+			return fmt.Errorf("region %s is not registered", receiver.regionName)
+		}
+		receiver.region = config
+	}
 	if receiver.region == nil {
 		return errors.New("region is null")
 	}
+	if receiver.environment != "" && receiver.environment != DefaultEnvironment {
+		config, ok := receiver.region.(*RegionConfig)
+		if !ok {
+			return fmt.Errorf("environment %s is only supported by RegionConfig", receiver.environment)
+		}
+		environmentConfig, err := config.ForEnvironment(receiver.environment)
+		if err != nil {
+			return err
+		}
+		receiver.region = environmentConfig
+	}
+	return nil
+}
+
+// checkProxy fails on the invalid proxies of CallerConfig, instead of connecting
+// the hosts directly or failing every request
+func (receiver *httpClientBuilder) checkProxy() error {
+	config := receiver.callerConfig
+	if config == nil || config.SidecarAddress != "" {
+		return nil
+	}
+	if _, err := newConfigProxyDial(config, fasthttp.Dial); err != nil {
+		return fmt.Errorf("invalid proxy: %w", err)
+	}
+	for host, proxy := range config.HostProxies {
+		if proxy == "" {
+			continue
+		}
+		proxyURL, err := parseProxyURL(proxy)
+		if err == nil {
+			_, err = newProxyDial(proxyURL)
+		}
+		if err != nil {
+			return fmt.Errorf("invalid proxy of host %s: %w", host, err)
+		}
+	}
 	return nil
 }
 
 func (receiver *httpClientBuilder) checkAuthRequiredField() error {
-	if receiver.useAirAuth && receiver.airAuthToken == "" {
+	// the bearer token takes place of air auth and ak/sk
+	if receiver.tokenSource == nil && receiver.useAirAuth && receiver.airAuthToken == "" {
 		return errors.New("token cannot be null")
 	}
-	if !receiver.useAirAuth && (receiver.authAK == "" || receiver.authSK == "") {
+	if receiver.tokenSource == nil && !receiver.useAirAuth && receiver.credentialsProvider == nil &&
+		(receiver.authAK == "" || receiver.authSK == "") {
 		return errors.New("ak and sk cannot be null")
 	}
+	airAuthConfig, err := fillDefaultAirAuthConfig(receiver.airAuthConfig)
+	if err != nil {
+		return err
+	}
+	receiver.airAuthConfig = airAuthConfig
 	return nil
 }
 
@@ -190,10 +616,17 @@ func (receiver *httpClientBuilder) fillDefault() {
 	if receiver.schema == "" {
 		receiver.schema = "https"
 	}
+	// the sidecar terminates TLS
+	if receiver.callerConfig != nil && receiver.callerConfig.SidecarAddress != "" {
+		receiver.schema = "http"
+	}
 	// fill hostAvailabler.
 	if receiver.hostAvailablerFactory == nil {
 		receiver.hostAvailablerFactory = &HostAvailablerFactoryBase{}
 	}
+	receiver.withHostScorer()
+	receiver.withFetchHostsSigner()
+	receiver.withConnConfig()
 	receiver.hostAvailabler, _ = receiver.newHostAvailabler()
 
 	// fill default caller config.
@@ -202,7 +635,143 @@ func (receiver *httpClientBuilder) fillDefault() {
 	}
 }
 
+// withHostScorer makes the host availablers of HostAvailablerFactoryBase score hosts by hostScorer
+func (receiver *httpClientBuilder) withHostScorer() {
+	if receiver.hostScorer == nil {
+		return
+	}
+	factory, ok := receiver.hostAvailablerFactory.(*HostAvailablerFactoryBase)
+	if !ok {
+		logs.Warn("host scorer is ignored by the custom host availabler factory")
+		return
+	}
+	scorerFactory := *factory
+	scorerFactory.HostScorer = receiver.hostScorer
+	receiver.hostAvailablerFactory = &scorerFactory
+}
+
+// withFetchHostsSigner makes the host availablers of HostAvailablerFactoryBase sign the
+// requests fetching hosts with the auth of client, unless they have their own signer.
+// They are signed by the httpCaller of client once it is built, so that the updates
+// of auth, such as SetAirAuthToken, take effect on them too
+func (receiver *httpClientBuilder) withFetchHostsSigner() {
+	factory, ok := receiver.hostAvailablerFactory.(*HostAvailablerFactoryBase)
+	if !ok || (factory.PingConfig != nil && factory.PingConfig.FetchRequestSigner != nil) {
+		return
+	}
+	signer := &atomic.Value{}
+	signer.Store(receiver.newAuthSigner())
+	receiver.fetchHostsSigner = signer
+	pingConfig := factory.copyPingConfig()
+	pingConfig.FetchRequestSigner = func(request *fasthttp.Request) error {
+		return signer.Load().(*httpCaller).withAuthHeaders(request, request.Body(), nil)
+	}
+	receiver.hostAvailablerFactory = &HostAvailablerFactoryBase{PingConfig: pingConfig}
+}
+
+// newAuthSigner returns the caller only signing requests with the auth of client,
+// which signs the requests fetching hosts before the httpCaller of client is built
+func (receiver *httpClientBuilder) newAuthSigner() *httpCaller {
+	signer := &httpCaller{
+		projectID:     receiver.projectID,
+		tenantID:      receiver.tenantID,
+		useAirAuth:    receiver.useAirAuth,
+		airAuthConfig: receiver.airAuthConfig,
+		credentials: credential{
+			accessKeyID:     receiver.authAK,
+			secretAccessKey: receiver.authSK,
+			service:         receiver.authService,
+			region:          receiver.region.GetAuthRegion(),
+		},
+	}
+	signer.airAuthToken.Store(receiver.airAuthToken)
+	signer.region = receiver.region
+	if receiver.tokenSource != nil {
+		signer.tokenSource = ReuseTokenSource(receiver.tokenSource)
+	}
+	signer.credentialsCache = receiver.getCredentialsCache()
+	return signer
+}
+
+// getCredentialsCache returns the credentials cache of credentialsProvider, which is
+// shared by the callers of client, so that the credentials are retrieved once
+func (receiver *httpClientBuilder) getCredentialsCache() *credentialsCache {
+	if receiver.credentialsProvider == nil {
+		return nil
+	}
+	if receiver.credentialsCache == nil {
+		receiver.credentialsCache = newCredentialsCache(receiver.credentialsProvider)
+	}
+	return receiver.credentialsCache
+}
+
+// credentialsTransport returns the transport of the credentials providers fetching the
+// credentials by http, which connects through the proxy and with the tls config of config.
+// It is nil through sidecar, which only forwards the requests to the hosts of client
+func (receiver *httpClientBuilder) credentialsTransport(config *CallerConfig) Transport {
+	if receiver.transport != nil {
+		return receiver.transport
+	}
+	if config.SidecarAddress != "" {
+		return nil
+	}
+	return newFastHTTPClient(config, newDial(config))
+}
+
+// withConnConfig makes the ping of the default host availabler and the metrics reporter
+// connect through the proxy and with the tls config of CallerConfig, unless they have their own.
+// The pings are sent with the Host header of main host too if CallerConfig.MainHostHeader is enabled
+func (receiver *httpClientBuilder) withConnConfig() {
+	if receiver.callerConfig == nil || receiver.callerConfig.SidecarAddress != "" {
+		return
+	}
+	if factory, ok := receiver.hostAvailablerFactory.(*HostAvailablerFactoryBase); ok &&
+		receiver.callerConfig.MainHostHeader {
+		pingConfig := factory.copyPingConfig()
+		pingConfig.MainHostHeader = true
+		receiver.hostAvailablerFactory = &HostAvailablerFactoryBase{PingConfig: pingConfig}
+	}
+	proxyDial, err := newConfigProxyDial(receiver.callerConfig, fasthttp.Dial)
+	if err != nil {
+		logs.Error("invalid proxy:%s, err:%v", redactProxyURL(receiver.callerConfig.ProxyURL), err)
+		proxyDial = nil
+	}
+	tlsConfig := receiver.callerConfig.TLSConfig
+	if proxyDial == nil && tlsConfig == nil {
+		return
+	}
+	if factory, ok := receiver.hostAvailablerFactory.(*HostAvailablerFactoryBase); ok {
+		pingConfig := factory.copyPingConfig()
+		if pingConfig.Dial == nil {
+			pingConfig.Dial = proxyDial
+		}
+		if pingConfig.TLSConfig == nil {
+			pingConfig.TLSConfig = tlsConfig
+		}
+		receiver.hostAvailablerFactory = &HostAvailablerFactoryBase{PingConfig: pingConfig}
+	}
+	if receiver.metricsCfg != nil {
+		metricsCfg := *receiver.metricsCfg
+		if metricsCfg.Dial == nil {
+			metricsCfg.Dial = proxyDial
+		}
+		if metricsCfg.TLSConfig == nil {
+			metricsCfg.TLSConfig = tlsConfig
+		}
+		receiver.metricsCfg = &metricsCfg
+	}
+}
+
 func (receiver *httpClientBuilder) newHostAvailabler() (HostAvailabler, error) {
+	if factory, ok := receiver.hostAvailablerFactory.(SchemaHostAvailablerFactory); ok {
+		// if '.hosts' is set, then skip fetch hosts from server
+		if len(receiver.hosts) > 0 {
+			return factory.NewHostAvailablerWithSchema(receiver.projectID, receiver.schema,
+				receiver.hosts, receiver.mainHost, true)
+		}
+		return factory.NewHostAvailablerWithSchema(receiver.projectID, receiver.schema,
+			receiver.region.GetHosts(), receiver.mainHost, false)
+	}
 	// if '.hosts' is set, then skip fetch hosts from server
 	if len(receiver.hosts) > 0 {
 		return receiver.hostAvailablerFactory.NewHostAvailabler(receiver.projectID, receiver.hosts, receiver.mainHost, true)
@@ -232,11 +801,31 @@ func (receiver *httpClientBuilder) newHTTPCaller() *httpCaller {
 		receiver.tenantID,
 		receiver.useAirAuth,
 		receiver.airAuthToken,
+		receiver.airAuthConfig,
 		cred,
 		receiver.hostAvailabler,
 		receiver.callerConfig,
 		receiver.schema,
 		receiver.keepAlive,
+		receiver.transport,
 	)
+	if receiver.tokenSource != nil {
+		mHTTPCaller.tokenSource = ReuseTokenSource(receiver.tokenSource)
+	}
+	if receiver.credentialsProvider != nil {
+		mHTTPCaller.credentialsCache = receiver.getCredentialsCache()
+		if setter, ok := receiver.credentialsProvider.(credentialsTransportSetter); ok {
+			setter.setTransport(receiver.credentialsTransport(mHTTPCaller.config))
+		}
+	}
+	mHTTPCaller.region = receiver.region
+	mHTTPCaller.fipsMode = receiver.fipsMode
+	mHTTPCaller.quota = receiver.quotaCoordinator
+	mHTTPCaller.interceptors = receiver.interceptors
+	mHTTPCaller.contextInterceptors = receiver.contextInterceptors
+	mHTTPCaller.requestTracker, _ = receiver.loadBalancer.(RequestTracker)
+	if receiver.auditSink != nil {
+		mHTTPCaller.audit = newAuditLogger(receiver.auditSink)
+	}
 	return mHTTPCaller
 }
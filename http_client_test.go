@@ -0,0 +1,132 @@
+package core
+
+import (
+	"testing"
+	"time"
+
+	"github.com/byteplus-sdk/byteplus-sdk-go-rec-core/option"
+	"github.com/valyala/fasthttp"
+)
+
+func TestHTTPClient_DoRawRequest(t *testing.T) {
+	var contentType, body, uri string
+	transport := TransportFunc(func(request *fasthttp.Request, response *fasthttp.Response, deadline time.Time) error {
+		contentType = string(request.Header.ContentType())
+		body = string(request.Body())
+		uri = request.URI().String()
+		response.SetStatusCode(fasthttp.StatusOK)
+		response.SetBodyString("imported")
+		return nil
+	})
+	airAuthConfig, _ := fillDefaultAirAuthConfig(nil)
+	availabler := &staticHostAvailabler{hosts: []string{"host"}}
+	h := &HTTPClient{
+		cli: newHTTPCaller("project", "tenant", true, "token", airAuthConfig, credential{},
+			availabler, &CallerConfig{DisableCompression: true}, "http", false, transport),
+		hostAvailabler: availabler,
+		schema:         "http",
+		projectID:      "project",
+	}
+	options := option.Conv2Options(option.WithHTTPQuery("format", "csv"))
+	rspBytes, err := h.DoRawRequest("/import", "text/csv", []byte("id,name\n1,a\n"), options)
+	if err != nil {
+		t.Fatalf("DoRawRequest() error = %v", err)
+	}
+	if string(rspBytes) != "imported" {
+		t.Errorf("DoRawRequest() = %s, want imported", rspBytes)
+	}
+	if contentType != "text/csv" || body != "id,name\n1,a\n" || uri != "http://host/import?format=csv" {
+		t.Errorf("request content type:%s body:%q uri:%s", contentType, body, uri)
+	}
+}
+
+func TestHTTPClient_DoRawRequestMethod(t *testing.T) {
+	tests := []struct {
+		name   string
+		method string
+		body   []byte
+	}{
+		{"get", fasthttp.MethodGet, nil},
+		{"delete", fasthttp.MethodDelete, nil},
+		{"put", fasthttp.MethodPut, []byte(`{"id":"1"}`)},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var method, contentType, contentEncoding, signature, ts, nonce string
+			var body []byte
+			transport := TransportFunc(func(request *fasthttp.Request, response *fasthttp.Response, deadline time.Time) error {
+				method = string(request.Header.Method())
+				contentType = string(request.Header.Peek("Content-Type"))
+				contentEncoding = string(request.Header.Peek("Content-Encoding"))
+				signature = string(request.Header.Peek("Tenant-Signature"))
+				ts = string(request.Header.Peek("Tenant-Ts"))
+				nonce = string(request.Header.Peek("Tenant-Nonce"))
+				body = append([]byte(nil), request.Body()...)
+				response.SetStatusCode(fasthttp.StatusOK)
+				return nil
+			})
+			airAuthConfig, _ := fillDefaultAirAuthConfig(nil)
+			availabler := &staticHostAvailabler{hosts: []string{"host"}}
+			h := &HTTPClient{
+				cli: newHTTPCaller("project", "tenant", true, "token", airAuthConfig, credential{},
+					availabler, &CallerConfig{CompressMinBytes: 1024}, "http", false, transport),
+				hostAvailabler: availabler,
+				schema:         "http",
+				projectID:      "project",
+			}
+			options := option.Conv2Options(option.WithHTTPMethod(tt.method))
+			if _, err := h.DoRawRequest("/items/1", "application/json", tt.body, options); err != nil {
+				t.Fatalf("DoRawRequest() error = %v", err)
+			}
+			if method != tt.method || string(body) != string(tt.body) || contentEncoding != "" {
+				t.Errorf("method:%s body:%s content encoding:%s", method, body, contentEncoding)
+			}
+			if (contentType == "") != (len(tt.body) == 0) {
+				t.Errorf("content type:%q of body:%q", contentType, tt.body)
+			}
+			if want := CalAirAuthSignature("token", "tenant", tt.body, ts, nonce, ""); signature != want {
+				t.Errorf("signature = %s, want %s", signature, want)
+			}
+		})
+	}
+}
+
+func TestHTTPClient_SetAirAuthToken(t *testing.T) {
+	var signature, ts, nonce string
+	transport := TransportFunc(func(request *fasthttp.Request, response *fasthttp.Response, deadline time.Time) error {
+		signature = string(request.Header.Peek("Tenant-Signature"))
+		ts = string(request.Header.Peek("Tenant-Ts"))
+		nonce = string(request.Header.Peek("Tenant-Nonce"))
+		response.SetStatusCode(fasthttp.StatusOK)
+		return nil
+	})
+	airAuthConfig, _ := fillDefaultAirAuthConfig(nil)
+	availabler := &staticHostAvailabler{hosts: []string{"host"}}
+	h := &HTTPClient{
+		cli: newHTTPCaller("project", "tenant", true, "old-token", airAuthConfig, credential{},
+			availabler, &CallerConfig{DisableCompression: true}, "http", false, transport),
+		hostAvailabler: availabler,
+		schema:         "http",
+		projectID:      "project",
+	}
+	if err := h.SetAirAuthToken(""); err == nil {
+		t.Errorf("SetAirAuthToken() of empty token error = nil")
+	}
+	if err := h.SetAirAuthToken("new-token"); err != nil {
+		t.Fatalf("SetAirAuthToken() error = %v", err)
+	}
+	if _, err := h.DoRawRequest("/import", "application/json", []byte("{}"), &option.Options{}); err != nil {
+		t.Fatalf("DoRawRequest() error = %v", err)
+	}
+	if want := CalAirAuthSignature("new-token", "tenant", []byte("{}"), ts, nonce, ""); signature != want {
+		t.Errorf("signature = %s, want signed by new token %s", signature, want)
+	}
+	// the rotated token is checked the same as the token of Build in fips mode
+	h.cli.fipsMode = true
+	if err := h.SetAirAuthToken("short-token"); err == nil {
+		t.Errorf("SetAirAuthToken() of token shorter than 112 bits in fips mode error = nil")
+	}
+	if err := h.SetAirAuthToken("token-of-112-bits"); err != nil {
+		t.Errorf("SetAirAuthToken() in fips mode error = %v", err)
+	}
+}
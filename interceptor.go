@@ -0,0 +1,56 @@
+package core
+
+import (
+	"context"
+
+	"github.com/valyala/fasthttp"
+)
+
+// Invoker sends request and fills response
+type Invoker func(request *fasthttp.Request, response *fasthttp.Response) error
+
+// Interceptor wraps every request sent by HTTPClient, including each retry. It can
+// change the request before calling next, such as adding headers, and inspect the
+// response after next returns, such as for audit logging or custom metrics.
+// next signs and sends the request, so the changes to the request are signed too.
+// The request and response passed to next must be the ones the interceptor receives
+type Interceptor func(next Invoker) Invoker
+
+// chainInterceptors wraps invoker by interceptors, the first one is the outermost
+func chainInterceptors(interceptors []Interceptor, invoker Invoker) Invoker {
+	for i := len(interceptors) - 1; i >= 0; i-- {
+		invoker = interceptors[i](invoker)
+	}
+	return invoker
+}
+
+// ContextInvoker is Invoker with the context of the request, which is the one of
+// option.WithContext, or context.Background if it is not set
+type ContextInvoker func(ctx context.Context, request *fasthttp.Request, response *fasthttp.Response) error
+
+// ContextInterceptor is Interceptor receiving the context of the request, such as to trace
+// the request as a child of the span in the context. The context passed to next is only
+// seen by the inner context interceptors, the request is still cancelled by the context of
+// option.WithContext. The context interceptors wrap the interceptors
+type ContextInterceptor func(next ContextInvoker) ContextInvoker
+
+// intercept wraps invoker by the context interceptors and the interceptors of caller,
+// the context interceptors are called with ctx
+func (c *httpCaller) intercept(ctx context.Context, invoker Invoker) Invoker {
+	invoker = chainInterceptors(c.interceptors, invoker)
+	if len(c.contextInterceptors) == 0 {
+		return invoker
+	}
+	if ctx == nil {
+		ctx = context.Background()
+	}
+	next := func(_ context.Context, request *fasthttp.Request, response *fasthttp.Response) error {
+		return invoker(request, response)
+	}
+	for i := len(c.contextInterceptors) - 1; i >= 0; i-- {
+		next = c.contextInterceptors[i](next)
+	}
+	return func(request *fasthttp.Request, response *fasthttp.Response) error {
+		return next(ctx, request, response)
+	}
+}
@@ -0,0 +1,85 @@
+package core
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/byteplus-sdk/byteplus-sdk-go-rec-core/option"
+	"github.com/valyala/fasthttp"
+)
+
+func TestHTTPCaller_interceptors(t *testing.T) {
+	var order []string
+	var headers fasthttp.RequestHeader
+	transport := TransportFunc(func(request *fasthttp.Request, response *fasthttp.Response, deadline time.Time) error {
+		request.Header.CopyTo(&headers)
+		response.SetStatusCode(fasthttp.StatusOK)
+		return nil
+	})
+	airAuthConfig, _ := fillDefaultAirAuthConfig(nil)
+	c := newHTTPCaller("project", "tenant", true, "token", airAuthConfig, credential{},
+		&staticHostAvailabler{hosts: []string{"host"}}, &CallerConfig{}, "http", false, transport)
+	newInterceptor := func(name string) Interceptor {
+		return func(next Invoker) Invoker {
+			return func(request *fasthttp.Request, response *fasthttp.Response) error {
+				order = append(order, "before "+name)
+				request.Header.Set("X-"+name, "1")
+				err := next(request, response)
+				order = append(order, "after "+name+" "+string(rune('0'+response.StatusCode()/100)))
+				return err
+			}
+		}
+	}
+	c.interceptors = []Interceptor{newInterceptor("A"), newInterceptor("B")}
+	_, err := c.doHTTPRequest("req", "http://host/path", map[string]string{"Request-Id": "req"},
+		nil, &option.Options{}, nil)
+	if err != nil {
+		t.Fatalf("doHTTPRequest() error = %v", err)
+	}
+	want := []string{"before A", "before B", "after B 2", "after A 2"}
+	if len(order) != len(want) {
+		t.Fatalf("order = %v, want %v", order, want)
+	}
+	for i := range want {
+		if order[i] != want[i] {
+			t.Errorf("order = %v, want %v", order, want)
+		}
+	}
+	if len(headers.Peek("X-A")) == 0 || len(headers.Peek("X-B")) == 0 || len(headers.Peek("Tenant-Signature")) == 0 {
+		t.Errorf("headers of interceptors should be sent and signed:\n%s", &headers)
+	}
+}
+
+func TestHTTPCaller_contextInterceptors(t *testing.T) {
+	transport := TransportFunc(func(request *fasthttp.Request, response *fasthttp.Response, deadline time.Time) error {
+		response.SetStatusCode(fasthttp.StatusOK)
+		return nil
+	})
+	airAuthConfig, _ := fillDefaultAirAuthConfig(nil)
+	c := newHTTPCaller("project", "tenant", true, "token", airAuthConfig, credential{},
+		&staticHostAvailabler{hosts: []string{"host"}}, &CallerConfig{}, "http", false, transport)
+	type ctxKey struct{}
+	var order []string
+	c.interceptors = []Interceptor{func(next Invoker) Invoker {
+		return func(request *fasthttp.Request, response *fasthttp.Response) error {
+			order = append(order, "interceptor")
+			return next(request, response)
+		}
+	}}
+	c.contextInterceptors = []ContextInterceptor{func(next ContextInvoker) ContextInvoker {
+		return func(ctx context.Context, request *fasthttp.Request, response *fasthttp.Response) error {
+			order = append(order, "context "+ctx.Value(ctxKey{}).(string))
+			return next(ctx, request, response)
+		}
+	}}
+	ctx := context.WithValue(context.Background(), ctxKey{}, "value")
+	_, err := c.doHTTPRequest("req", "http://host/path", map[string]string{"Request-Id": "req"},
+		nil, &option.Options{Context: ctx}, nil)
+	if err != nil {
+		t.Fatalf("doHTTPRequest() error = %v", err)
+	}
+	if len(order) != 2 || order[0] != "context value" || order[1] != "interceptor" {
+		t.Errorf("order = %v, want the context interceptor with ctx wrapping the interceptor", order)
+	}
+}
@@ -0,0 +1,151 @@
+package core
+
+import (
+	"math/rand"
+	"sync"
+	"sync/atomic"
+
+	"github.com/byteplus-sdk/byteplus-sdk-go-rec-core/metrics"
+)
+
+// LoadBalancer picks the host to send a request to, from the hosts of the path
+// ordered by availability. Without LoadBalancer, the first ranked host is used
+type LoadBalancer interface {
+	// Name is the strategy name used in metrics tags
+	Name() string
+	// Pick returns one of hosts, hosts is not empty
+	Pick(path string, hosts []string) string
+}
+
+// RequestTracker is implemented by the LoadBalancer which needs to know the
+// in-flight requests of each host, it is called around each attempt
+type RequestTracker interface {
+	RequestStarted(host string)
+	RequestFinished(host string)
+}
+
+// FirstRankedLoadBalancer always picks the most available host
+type FirstRankedLoadBalancer struct {
+}
+
+func (b *FirstRankedLoadBalancer) Name() string {
+	return "first_ranked"
+}
+
+func (b *FirstRankedLoadBalancer) Pick(path string, hosts []string) string {
+	return hosts[0]
+}
+
+// RoundRobinLoadBalancer picks the hosts in turn
+type RoundRobinLoadBalancer struct {
+	next uint64
+}
+
+func (b *RoundRobinLoadBalancer) Name() string {
+	return "round_robin"
+}
+
+func (b *RoundRobinLoadBalancer) Pick(path string, hosts []string) string {
+	next := atomic.AddUint64(&b.next, 1) - 1
+	return hosts[next%uint64(len(hosts))]
+}
+
+// RandomLoadBalancer picks a host randomly
+type RandomLoadBalancer struct {
+}
+
+func (b *RandomLoadBalancer) Name() string {
+	return "random"
+}
+
+func (b *RandomLoadBalancer) Pick(path string, hosts []string) string {
+	return hosts[rand.Intn(len(hosts))]
+}
+
+// LeastPendingLoadBalancer picks the host with the least in-flight requests,
+// the more available one is picked if they are equal
+type LeastPendingLoadBalancer struct {
+	lock    sync.RWMutex
+	pending map[string]*int64
+}
+
+func (b *LeastPendingLoadBalancer) Name() string {
+	return "least_pending"
+}
+
+func (b *LeastPendingLoadBalancer) Pick(path string, hosts []string) string {
+	result := hosts[0]
+	var minPending int64 = -1
+	for _, host := range hosts {
+		pending := atomic.LoadInt64(b.counter(host))
+		if minPending < 0 || pending < minPending {
+			result, minPending = host, pending
+		}
+	}
+	return result
+}
+
+func (b *LeastPendingLoadBalancer) RequestStarted(host string) {
+	atomic.AddInt64(b.counter(host), 1)
+}
+
+func (b *LeastPendingLoadBalancer) RequestFinished(host string) {
+	atomic.AddInt64(b.counter(host), -1)
+}
+
+func (b *LeastPendingLoadBalancer) counter(host string) *int64 {
+	b.lock.RLock()
+	counter, exist := b.pending[host]
+	b.lock.RUnlock()
+	if exist {
+		return counter
+	}
+	b.lock.Lock()
+	defer b.lock.Unlock()
+	if b.pending == nil {
+		b.pending = make(map[string]*int64)
+	}
+	if counter, exist = b.pending[host]; !exist {
+		counter = new(int64)
+		b.pending[host] = counter
+	}
+	return counter
+}
+
+// pickHost picks the host of path by loadBalancer, or by affinityKey if it is set, if the host
+// availabler can list the hosts of path, the host whose circuit is open is skipped
+func (h *HTTPClient) pickHost(path, affinityKey string) string {
+	reader, ok := h.hostAvailabler.(PathHostsReader)
+	if affinityReader, isAffinity := h.hostAvailabler.(AffinityHostReader); isAffinity && affinityKey != "" {
+		return h.skipOpenCircuit(affinityReader.GetAffinityHost(path, affinityKey), path, reader)
+	}
+	if h.loadBalancer == nil || !ok {
+		return h.skipOpenCircuit(h.hostAvailabler.GetHost(path), path, reader)
+	}
+	hosts := reader.GetPathHosts(path)
+	if len(hosts) == 0 {
+		return h.skipOpenCircuit(h.hostAvailabler.GetHost(path), path, reader)
+	}
+	host := h.loadBalancer.Pick(path, hosts)
+	metricsTags := []string{
+		"strategy:" + h.loadBalancer.Name(),
+		"project_id:" + h.projectID,
+		"host:" + escapeMetricsTagValue(host),
+	}
+	metrics.Counter(metricsKeyLoadBalancerPick, 1, metricsTags...)
+	return h.skipOpenCircuit(host, path, reader)
+}
+
+func (h *HTTPClient) skipOpenCircuit(host, path string, reader PathHostsReader) string {
+	if h.cli == nil || h.cli.breaker == nil {
+		return host
+	}
+	var candidates []string
+	if reader != nil {
+		candidates = reader.GetPathHosts(path)
+	}
+	if len(candidates) == 0 {
+		candidates = h.hostAvailabler.GetHosts()
+	}
+	return h.cli.breaker.pick(host, candidates)
+}
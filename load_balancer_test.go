@@ -0,0 +1,26 @@
+package core
+
+import "testing"
+
+func TestLoadBalancers(t *testing.T) {
+	hosts := []string{"a.byteplus.com", "b.byteplus.com", "c.byteplus.com"}
+	if got := (&FirstRankedLoadBalancer{}).Pick("/", hosts); got != hosts[0] {
+		t.Errorf("first ranked Pick() = %v, want %v", got, hosts[0])
+	}
+	roundRobin := &RoundRobinLoadBalancer{}
+	for i := 0; i < 2*len(hosts); i++ {
+		if got := roundRobin.Pick("/", hosts); got != hosts[i%len(hosts)] {
+			t.Errorf("round robin Pick() = %v, want %v", got, hosts[i%len(hosts)])
+		}
+	}
+	leastPending := &LeastPendingLoadBalancer{}
+	leastPending.RequestStarted(hosts[0])
+	leastPending.RequestStarted(hosts[1])
+	if got := leastPending.Pick("/", hosts); got != hosts[2] {
+		t.Errorf("least pending Pick() = %v, want %v", got, hosts[2])
+	}
+	leastPending.RequestFinished(hosts[0])
+	if got := leastPending.Pick("/", hosts); got != hosts[0] {
+		t.Errorf("least pending Pick() = %v, want %v", got, hosts[0])
+	}
+}
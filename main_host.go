@@ -0,0 +1,133 @@
+package core
+
+import (
+	"crypto/tls"
+	"net"
+	"sync"
+	"time"
+
+	"github.com/valyala/fasthttp"
+)
+
+// mainHostTransport sends the requests to the host of url with the Host header of mainHost,
+// so that the deployments fronted by a shared gateway routing on Host work with any of the
+// hosts. The requests to the other hosts are sent by the HostClient connecting to the host,
+// which is built from the fasthttp client
+type mainHostTransport struct {
+	mainHost string
+	client   *fasthttp.Client
+	lock     sync.RWMutex
+	// "schema://host"->client connecting to the host
+	hostClients map[string]*fasthttp.HostClient
+}
+
+// newMainHostTransport returns transport unchanged if it is not a fasthttp client,
+// which can not connect to a host other than the Host header
+func newMainHostTransport(mainHost string, transport Transport) (Transport, bool) {
+	client, ok := transport.(*fasthttp.Client)
+	if mainHost == "" || !ok {
+		return transport, false
+	}
+	return &mainHostTransport{
+		mainHost:    mainHost,
+		client:      client,
+		hostClients: make(map[string]*fasthttp.HostClient),
+	}, true
+}
+
+func (t *mainHostTransport) DoDeadline(request *fasthttp.Request,
+	response *fasthttp.Response, deadline time.Time) error {
+	return t.do(request, func(client fastHTTPDoDeadliner) error {
+		return client.DoDeadline(request, response, deadline)
+	})
+}
+
+// Do implements fastHTTPDoer, so that the requests are cancelled by closing their connections
+func (t *mainHostTransport) Do(request *fasthttp.Request, response *fasthttp.Response) error {
+	return t.do(request, func(client fastHTTPDoDeadliner) error {
+		return client.Do(request, response)
+	})
+}
+
+type fastHTTPDoDeadliner interface {
+	fastHTTPDoer
+	DoDeadline(request *fasthttp.Request, response *fasthttp.Response, deadline time.Time) error
+}
+
+func (t *mainHostTransport) do(request *fasthttp.Request, send func(client fastHTTPDoDeadliner) error) error {
+	uri := request.URI()
+	host := string(uri.Host())
+	if host == t.mainHost {
+		return send(t.client)
+	}
+	hostClient := t.hostClient(string(uri.Scheme()), host)
+	uri.SetHost(t.mainHost)
+	// the host of url is kept for the retries and logs
+	defer uri.SetHost(host)
+	return send(hostClient)
+}
+
+func (t *mainHostTransport) CloseIdleConnections() {
+	t.client.CloseIdleConnections()
+	t.lock.RLock()
+	defer t.lock.RUnlock()
+	for _, hostClient := range t.hostClients {
+		hostClient.CloseIdleConnections()
+	}
+}
+
+func (t *mainHostTransport) hostClient(schema, host string) *fasthttp.HostClient {
+	key := schema + "://" + host
+	t.lock.RLock()
+	hostClient, exist := t.hostClients[key]
+	t.lock.RUnlock()
+	if exist {
+		return hostClient
+	}
+	t.lock.Lock()
+	defer t.lock.Unlock()
+	if hostClient, exist = t.hostClients[key]; exist {
+		return hostClient
+	}
+	isTLS := schema == "https"
+	hostClient = &fasthttp.HostClient{
+		Addr:                          addMissingPort(host, isTLS),
+		IsTLS:                         isTLS,
+		TLSConfig:                     t.tlsConfig(isTLS),
+		Dial:                          t.client.Dial,
+		MaxConns:                      t.client.MaxConnsPerHost,
+		MaxIdleConnDuration:           t.client.MaxIdleConnDuration,
+		MaxConnWaitTimeout:            t.client.MaxConnWaitTimeout,
+		ReadTimeout:                   t.client.ReadTimeout,
+		WriteTimeout:                  t.client.WriteTimeout,
+		MaxIdemponentCallAttempts:     t.client.MaxIdemponentCallAttempts,
+		DisableHeaderNamesNormalizing: t.client.DisableHeaderNamesNormalizing,
+	}
+	t.hostClients[key] = hostClient
+	return hostClient
+}
+
+// tlsConfig verifies the certificate of mainHost, as the gateway serves mainHost
+func (t *mainHostTransport) tlsConfig(isTLS bool) *tls.Config {
+	if !isTLS {
+		return nil
+	}
+	config := &tls.Config{}
+	if t.client.TLSConfig != nil {
+		config = t.client.TLSConfig.Clone()
+	}
+	if config.ServerName == "" {
+		config.ServerName, _, _ = net.SplitHostPort(addMissingPort(t.mainHost, true))
+	}
+	return config
+}
+
+func addMissingPort(host string, isTLS bool) string {
+	if _, _, err := net.SplitHostPort(host); err == nil {
+		return host
+	}
+	if isTLS {
+		return net.JoinHostPort(host, "443")
+	}
+	return net.JoinHostPort(host, "80")
+}
@@ -0,0 +1,81 @@
+package core
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/byteplus-sdk/byteplus-sdk-go-rec-core/option"
+)
+
+type mainHostAvailabler struct {
+	staticHostAvailabler
+	mainHost string
+}
+
+func (a *mainHostAvailabler) MainHost() string {
+	return a.mainHost
+}
+
+func TestHTTPCaller_mainHost(t *testing.T) {
+	var gotHost, gotPingHost string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if strings.HasSuffix(r.URL.Path, "/ping") {
+			gotPingHost = r.Host
+			_, _ = w.Write([]byte("pong"))
+			return
+		}
+		gotHost = r.Host
+	}))
+	defer server.Close()
+	host := strings.TrimPrefix(server.URL, "http://")
+	availabler := &mainHostAvailabler{staticHostAvailabler{hosts: []string{host}}, "gateway.byteplus.com"}
+	airAuthConfig, _ := fillDefaultAirAuthConfig(nil)
+	c := newHTTPCaller("project", "tenant", false, "", airAuthConfig, credential{accessKeyID: "ak",
+		secretAccessKey: "sk", region: "cn", service: "air"}, availabler,
+		&CallerConfig{MainHostHeader: true}, "http", false, nil)
+	request := c.acquireRequest("POST", server.URL+"/predict", nil, []byte("{}"))
+	if err := c.withAuthHeaders(request, request.Body(), &option.Options{}); err != nil {
+		t.Fatalf("withAuthHeaders() error = %v", err)
+	}
+	// the request is signed with the Host header it is sent with
+	ok, explanation := VerifyRequest(request, SigningCredential{AccessKeyID: "ak", SecretAccessKey: "sk",
+		Region: "cn", Service: "air"})
+	if !ok || !strings.Contains(explanation.CanonicalRequest, "host:gateway.byteplus.com") {
+		t.Errorf("canonical request = %s, want signed with the main host", explanation.CanonicalRequest)
+	}
+	if _, err := c.doHTTPRequest("req", server.URL+"/predict", map[string]string{}, []byte("{}"),
+		&option.Options{}, nil); err != nil {
+		t.Fatalf("doHTTPRequest() error = %v", err)
+	}
+	if gotHost != "gateway.byteplus.com" {
+		t.Errorf("Host = %s, want gateway.byteplus.com", gotHost)
+	}
+	if !Ping("project", c.transport, defaultPingURLFormat, "http", host, time.Second) ||
+		gotPingHost != "gateway.byteplus.com" {
+		t.Errorf("Host of ping = %s, want gateway.byteplus.com", gotPingHost)
+	}
+}
+
+func TestHTTPCaller_mainHostHeaderDisabled(t *testing.T) {
+	var gotHost string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		gotHost = r.Host
+	}))
+	defer server.Close()
+	host := strings.TrimPrefix(server.URL, "http://")
+	availabler := &mainHostAvailabler{staticHostAvailabler{hosts: []string{host}}, "gateway.byteplus.com"}
+	airAuthConfig, _ := fillDefaultAirAuthConfig(nil)
+	c := newHTTPCaller("project", "tenant", true, "token", airAuthConfig, credential{},
+		availabler, &CallerConfig{}, "http", false, nil)
+	if _, err := c.doHTTPRequest("req", server.URL+"/predict", map[string]string{}, []byte("{}"),
+		&option.Options{}, nil); err != nil {
+		t.Fatalf("doHTTPRequest() error = %v", err)
+	}
+	// the failover hosts are not sent with the Host header of main host by default
+	if gotHost != host {
+		t.Errorf("Host = %s, want %s", gotHost, host)
+	}
+}
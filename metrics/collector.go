@@ -1,10 +1,13 @@
 package metrics
 
 import (
+	"context"
+	"crypto/tls"
 	"fmt"
 	"runtime/debug"
 	"strings"
 	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/byteplus-sdk/byteplus-sdk-go-rec-core/logs"
@@ -16,8 +19,18 @@ type HostReader interface {
 	GetHost(path string) string
 }
 
+// PathHostsReader is implemented by the HostReader which can list all the hosts
+// of a path ordered by availability, the collector fails over to the next host
+// when reporting to the first one fails
+type PathHostsReader interface {
+	GetPathHosts(path string) []string
+}
+
 var (
 	Collector = &collector{}
+	// the initial backoff of restarting the report loop after it panics,
+	// doubled for each successive panic
+	reportRestartBackoff = time.Second
 )
 
 type Config struct {
@@ -35,6 +48,24 @@ type Config struct {
 	ReportInterval time.Duration
 	// Timeout for request reporting.
 	HTTPTimeout time.Duration
+	// PrivacyMode hashes or omits the tenant id, project id, hosts, urls and request ids
+	// in metrics and metric logs before they are reported, default is off.
+	PrivacyMode PrivacyMode
+	// PrivacyHashSalt is mixed into the hashes of PrivacyModeHash, so that the
+	// identifiers can not be recovered by enumerating
+	PrivacyHashSalt string
+	// Dial the dial of reporting, such as the proxy dial, default is to dial directly
+	Dial fasthttp.DialFunc
+	// TLSConfig the tls config of reporting, such as with the client certificates of mTLS
+	TLSConfig *tls.Config
+	// StatsDAddress is the "host:port" of the local statsd agent, the metrics are sent to it
+	// over UDP in place of the byteplus server if it is set, the metrics logs are not affected
+	StatsDAddress string
+	// StatsDFormat is the line format sent to the statsd agent, default is StatsDFormatDogStatsD
+	StatsDFormat StatsDFormat
+	// Reporter takes place of reporting to the byteplus server or the statsd agent, such as
+	// routing the metrics to Kafka, or NoopReporter discarding them in tests
+	Reporter Reporter
 }
 
 func NewConfig() *Config {
@@ -67,39 +98,57 @@ func fillDefaultCfg(cfg *Config) {
 	}
 }
 
+// emitConfig is what the emitting goroutines need, it is published by doInit as a whole,
+// so that they never see the config of one init with the collectors of another
+type emitConfig struct {
+	cfg                 *Config
+	scrubber            *scrubber
+	metricsCollector    chan *protocol.Metric
+	metricsLogCollector chan *protocol.MetricLog
+}
+
 type collector struct {
-	cfg                         *Config
-	reporter                    *reporter
-	metricsCollector            chan *protocol.Metric
-	metricsLogCollector         chan *protocol.MetricLog
-	cleaningMetricsCollector    bool
-	cleaningMetricsLogCollector bool
+	cfg            *Config
+	reporter       Reporter
+	statsDReporter *statsDReporter
+	// *emitConfig, it is loaded without lock by the emitting goroutines
+	emitting atomic.Value
+	// 1 while draining the collectors, read and written atomically,
+	// as the emitting goroutines spin on them
+	cleaningMetricsCollector    int32
+	cleaningMetricsLogCollector int32
 	initialed                   bool
 	hostReader                  HostReader
-	lock                        *sync.Mutex
+	meters                      meterRegistry
+	// guards initialed, hostReader and the report loop
+	lock sync.Mutex
+	// serializes the reports of the report loop and Flush
+	reportLock sync.Mutex
+	// closed to stop the report loop
+	stop chan struct{}
+	// closed when the report loop exits
+	stopped chan struct{}
 }
 
 func (c *collector) Init(cfg *Config, hostReader HostReader) {
-	if c.initialed {
+	if c.IsInitialed() {
 		return
 	}
 	if cfg == nil {
 		cfg = NewConfig()
 	}
 	fillDefaultCfg(cfg)
-	c.lock = &sync.Mutex{}
 	c.doInit(cfg, hostReader)
 }
 
 func (c *collector) InitWithOptions(opts ...Option) {
-	if c.initialed {
+	if c.IsInitialed() {
 		return
 	}
 	cfg := NewConfig()
 	for _, opt := range opts {
 		opt(cfg)
 	}
-	c.lock = &sync.Mutex{}
 	c.doInit(cfg, nil)
 }
 
@@ -114,14 +163,33 @@ func (c *collector) doInit(cfg *Config, hostReader HostReader) {
 	// initialize metrics reporter
 	c.reporter = &reporter{
 		httpCli: &fasthttp.Client{
+			Dial:                cfg.Dial,
+			TLSConfig:           cfg.TLSConfig,
 			MaxIdleConnDuration: 60 * time.Second,
 		},
 		metricsCfg: c.cfg,
+		domains:    c.getDomains,
+	}
+	c.statsDReporter = nil
+	if cfg.Reporter != nil {
+		c.reporter = cfg.Reporter
+	} else if cfg.StatsDAddress != "" {
+		statsDReporter, err := newStatsDReporter(cfg.StatsDAddress, cfg.StatsDFormat, c.reporter)
+		if err != nil {
+			logs.Error("[Metrics] init statsd reporter fail, report to server instead, err:%v addr:%s",
+				err, cfg.StatsDAddress)
+		} else {
+			c.statsDReporter, c.reporter = statsDReporter, statsDReporter
+		}
 	}
 	// initialize metrics collector
-	c.metricsCollector = make(chan *protocol.Metric, maxMetricsSize)
-	c.metricsLogCollector = make(chan *protocol.MetricLog, maxMetricsLogSize)
-	if !c.isEnableMetrics() && !c.isEnableMetricsLog() {
+	c.emitting.Store(&emitConfig{
+		cfg:                 cfg,
+		scrubber:            &scrubber{mode: cfg.PrivacyMode, salt: cfg.PrivacyHashSalt},
+		metricsCollector:    make(chan *protocol.Metric, maxMetricsSize),
+		metricsLogCollector: make(chan *protocol.MetricLog, maxMetricsLogSize),
+	})
+	if !cfg.EnableMetrics && !cfg.EnableMetricsLog {
 		c.initialed = true
 		return
 	}
@@ -129,31 +197,41 @@ func (c *collector) doInit(cfg *Config, hostReader HostReader) {
 	c.initialed = true
 }
 
-func (c *collector) IsInitialed() bool {
-	return c.initialed
+// SetHostReader makes the collector report to the host of hostReader, so that the
+// reporting domain follows the host updates of hostReader.
+// It is ignored if the collector is not initialed or already has a host reader
+func (c *collector) SetHostReader(hostReader HostReader) {
+	if hostReader == nil {
+		return
+	}
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	if !c.initialed || c.hostReader != nil {
+		return
+	}
+	c.hostReader = hostReader
 }
 
-func (c *collector) isEnableMetrics() bool {
-	if c.cfg == nil {
-		return false
-	}
-	return c.cfg.EnableMetrics
+func (c *collector) IsInitialed() bool {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	return c.initialed
 }
 
-func (c *collector) isEnableMetricsLog() bool {
-	if c.cfg == nil {
-		return false
-	}
-	return c.cfg.EnableMetricsLog
+// loadEmitConfig returns the emit config published by doInit, nil if it is not initialed
+func (c *collector) loadEmitConfig() *emitConfig {
+	emitting, _ := c.emitting.Load().(*emitConfig)
+	return emitting
 }
 
 func (c *collector) EmitMetric(metricsType, name string, value int64, tagKvs ...string) {
-	if !c.isEnableMetrics() {
+	emitting := c.loadEmitConfig()
+	if emitting == nil || !emitting.cfg.EnableMetrics {
 		return
 	}
 	// spin when cleaning collector
 	tryTimes := 0
-	for c.cleaningMetricsCollector {
+	for atomic.LoadInt32(&c.cleaningMetricsCollector) == 1 {
 		if tryTimes >= maxSpinTimes {
 			return
 		}
@@ -161,8 +239,8 @@ func (c *collector) EmitMetric(metricsType, name string, value int64, tagKvs ...
 		tryTimes += 1
 	}
 	metricsName := name
-	if len(c.cfg.Prefix) > 0 {
-		metricsName = fmt.Sprintf("%s.%s", c.cfg.Prefix, metricsName)
+	if len(emitting.cfg.Prefix) > 0 {
+		metricsName = fmt.Sprintf("%s.%s", emitting.cfg.Prefix, metricsName)
 	}
 	metric := &protocol.Metric{
 		Name:      metricsName,
@@ -171,20 +249,41 @@ func (c *collector) EmitMetric(metricsType, name string, value int64, tagKvs ...
 		Timestamp: currentTimeMillis(),
 		Tags:      recoverTags(tagKvs...),
 	}
+	if emitting.scrubber.enabled() {
+		emitting.scrubber.scrubTags(metric.Tags)
+	}
 	select {
-	case c.metricsCollector <- metric:
+	case emitting.metricsCollector <- metric:
 	default:
 		logs.Debug("[Metrics]: The number of metrics exceeds the limit, the metrics write is rejected")
 	}
 }
 
+// EmitRate marks value events of the meter, whose moving rates are reported as gauges
+func (c *collector) EmitRate(name string, value int64, tagKvs ...string) {
+	emitting := c.loadEmitConfig()
+	if emitting == nil || !emitting.cfg.EnableMetrics {
+		return
+	}
+	metricsName := name
+	if len(emitting.cfg.Prefix) > 0 {
+		metricsName = fmt.Sprintf("%s.%s", emitting.cfg.Prefix, metricsName)
+	}
+	tags := recoverTags(tagKvs...)
+	if emitting.scrubber.enabled() {
+		emitting.scrubber.scrubTags(tags)
+	}
+	c.meters.mark(metricsName, value, tags)
+}
+
 func (c *collector) EmitLog(logID, message, logLevel string, timestamp int64) {
-	if !c.isEnableMetricsLog() {
+	emitting := c.loadEmitConfig()
+	if emitting == nil || !emitting.cfg.EnableMetricsLog {
 		return
 	}
 	// spin when cleaning collector
 	tryTimes := 0
-	for c.cleaningMetricsLogCollector {
+	for atomic.LoadInt32(&c.cleaningMetricsLogCollector) == 1 {
 		if tryTimes >= maxSpinTimes {
 			return
 		}
@@ -197,92 +296,236 @@ func (c *collector) EmitLog(logID, message, logLevel string, timestamp int64) {
 		Level:     logLevel,
 		Timestamp: currentTimeMillis(),
 	}
+	if emitting.scrubber.enabled() {
+		metricLog.Id = emitting.scrubber.scrub(logID)
+		metricLog.Message = emitting.scrubber.scrubMessage(message)
+	}
 	select {
-	case c.metricsLogCollector <- metricLog:
+	case emitting.metricsLogCollector <- metricLog:
 	default:
 		logs.Debug("[Metrics]: The number of metrics logs exceeds the limit, the metrics write is rejected")
 	}
 }
 
 func (c *collector) startReport() {
+	stop := make(chan struct{})
+	stopped := make(chan struct{})
+	c.stop = stop
+	c.stopped = stopped
 	go func() {
-		defer func() {
-			if err := recover(); err != nil {
-				logs.Error("metrics report encounter panic:%+v, stack:%s", err, string(debug.Stack()))
+		defer close(stopped)
+		backoff := reportRestartBackoff
+		for {
+			start := time.Now()
+			if c.runReportLoop(stop) {
+				return
+			}
+			// the loop panicked, restart it after backoff so that the
+			// metrics are not lost for the remaining process lifetime
+			c.EmitMetric(metricsTypeCounter, metricsKeyReportPanic, 1)
+			if time.Since(start) > maxReportRestartBackoff {
+				backoff = reportRestartBackoff
+			}
+			select {
+			case <-stop:
+				return
+			case <-time.After(backoff):
+			}
+			backoff *= 2
+			if backoff > maxReportRestartBackoff {
+				backoff = maxReportRestartBackoff
 			}
-		}()
-		ticker := time.NewTicker(c.cfg.ReportInterval)
-		for range ticker.C {
-			c.report()
 		}
 	}()
 }
 
-func (c *collector) report() {
-	if c.isEnableMetrics() {
-		c.reportMetrics()
+// runReportLoop reports periodically until stop is closed or it panics,
+// returns true if it exits because of stop
+func (c *collector) runReportLoop(stop chan struct{}) (stopped bool) {
+	defer func() {
+		if err := recover(); err != nil {
+			logs.Error("metrics report encounter panic:%+v, stack:%s", err, string(debug.Stack()))
+			// the panic may happen while cleaning collector
+			atomic.StoreInt32(&c.cleaningMetricsCollector, 0)
+			atomic.StoreInt32(&c.cleaningMetricsLogCollector, 0)
+			stopped = false
+		}
+	}()
+	ticker := time.NewTicker(c.cfg.ReportInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-stop:
+			return true
+		case <-ticker.C:
+			c.report()
+		}
+	}
+}
+
+// Flush reports the buffered metrics and metrics logs synchronously, so that they are
+// not lost when the process exits before the next report interval
+func (c *collector) Flush() {
+	if !c.IsInitialed() {
+		return
 	}
-	if c.isEnableMetricsLog() {
-		c.reportMetricsLog()
+	c.report()
+}
+
+// FlushWithContext is Flush returning the error of ctx if ctx is done before the metrics
+// are reported, the report goes on in background then
+func (c *collector) FlushWithContext(ctx context.Context) error {
+	return runWithContext(ctx, c.Flush)
+}
+
+// Shutdown stops the report loop and waits for it to exit, then flushes the metrics not
+// reported yet. The collector can be initialed again after Shutdown, so that
+// the processes rebuilding clients can restart it with new config
+func (c *collector) Shutdown() {
+	c.lock.Lock()
+	if !c.initialed {
+		c.lock.Unlock()
+		return
+	}
+	stop, stopped := c.stop, c.stopped
+	c.stop, c.stopped = nil, nil
+	c.lock.Unlock()
+	// wait without lock, the running report needs the lock to read hostReader
+	if stop != nil {
+		close(stop)
+		<-stopped
+	}
+	// flush before clearing hostReader, which provides the domains to report to
+	c.report()
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	if c.statsDReporter != nil {
+		c.statsDReporter.close()
+		c.statsDReporter = nil
+	}
+	c.hostReader = nil
+	c.initialed = false
+}
+
+// ShutdownWithContext is Shutdown returning the error of ctx if ctx is done before the
+// report loop exits and the metrics are flushed, the shutdown goes on in background then
+func (c *collector) ShutdownWithContext(ctx context.Context) error {
+	return runWithContext(ctx, c.Shutdown)
+}
+
+// runWithContext runs f in a goroutine and waits for it until ctx is done
+func runWithContext(ctx context.Context, f func()) error {
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		f()
+	}()
+	select {
+	case <-done:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
 	}
 }
 
-func (c *collector) reportMetrics() {
-	metricsLen := len(c.metricsCollector)
-	if metricsLen == 0 {
+func (c *collector) report() {
+	c.reportLock.Lock()
+	defer c.reportLock.Unlock()
+	emitting := c.loadEmitConfig()
+	if emitting == nil {
 		return
 	}
+	if emitting.cfg.EnableMetrics {
+		c.reportMetrics(emitting.metricsCollector)
+	}
+	if emitting.cfg.EnableMetricsLog {
+		c.reportMetricsLog(emitting.metricsLogCollector)
+	}
+}
+
+func (c *collector) reportMetrics(metricsCollector chan *protocol.Metric) {
+	metricsLen := len(metricsCollector)
 	metrics := make([]*protocol.Metric, 0, metricsLen)
-	c.cleaningMetricsCollector = true
+	atomic.StoreInt32(&c.cleaningMetricsCollector, 1)
 	for i := 0; i < metricsLen; i++ {
-		metric := <-c.metricsCollector
+		metric := <-metricsCollector
 		metrics = append(metrics, metric)
 	}
-	c.cleaningMetricsCollector = false
+	atomic.StoreInt32(&c.cleaningMetricsCollector, 0)
+	metrics = append(lastGauges(metrics), c.meters.metrics()...)
+	if len(metrics) == 0 {
+		return
+	}
 	c.doReportMetrics(metrics)
 }
 
-func (c *collector) getDomain(path string) string {
-	if c.hostReader == nil {
-		return c.cfg.Domain
+// lastGauges keeps the last gauge of each name and tags, as only the last value of
+// gauge in a report interval is meaningful, the other metrics are kept as is
+func lastGauges(metrics []*protocol.Metric) []*protocol.Metric {
+	lastIndexes := make(map[string]int)
+	for i, metric := range metrics {
+		if metric.Type == metricsTypeGauge {
+			lastIndexes[metricKey(metric.Name, metric.Tags)] = i
+		}
+	}
+	if len(lastIndexes) == 0 {
+		return metrics
+	}
+	result := make([]*protocol.Metric, 0, len(metrics))
+	for i, metric := range metrics {
+		if metric.Type == metricsTypeGauge && lastIndexes[metricKey(metric.Name, metric.Tags)] != i {
+			continue
+		}
+		result = append(result, metric)
 	}
-	return c.hostReader.GetHost(path)
+	return result
 }
 
-func (c *collector) doReportMetrics(metrics []*protocol.Metric) {
-	url := fmt.Sprintf(metricsURLFormat, c.cfg.HTTPSchema, c.getDomain(metricsPath))
-	metricMessage := &protocol.MetricMessage{
-		Metrics: metrics,
+func (c *collector) getHostReader() HostReader {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	return c.hostReader
+}
+
+// getDomains returns the domains to report to in order, the later ones are used
+// when reporting to the former ones fails
+func (c *collector) getDomains(path string) []string {
+	hostReader := c.getHostReader()
+	if hostReader == nil {
+		return []string{c.cfg.Domain}
+	}
+	if pathHostsReader, ok := hostReader.(PathHostsReader); ok {
+		if hosts := pathHostsReader.GetPathHosts(path); len(hosts) > 0 {
+			return hosts
+		}
 	}
-	err := c.reporter.reportMetrics(metricMessage, url)
-	if err != nil {
-		logs.Error("[Metrics] report metrics fail, err:%v, url:%s", err, url)
+	return []string{hostReader.GetHost(path)}
+}
+
+func (c *collector) doReportMetrics(metrics []*protocol.Metric) {
+	if err := c.reporter.Report(metrics); err != nil {
+		logs.Error("[Metrics] report %d metrics fail, err:%v", len(metrics), err)
 	}
 }
 
-func (c *collector) reportMetricsLog() {
-	metricsLogLen := len(c.metricsLogCollector)
+func (c *collector) reportMetricsLog(metricsLogCollector chan *protocol.MetricLog) {
+	metricsLogLen := len(metricsLogCollector)
 	if metricsLogLen == 0 {
 		return
 	}
 	metricLogs := make([]*protocol.MetricLog, 0, metricsLogLen)
-	c.cleaningMetricsLogCollector = true
+	atomic.StoreInt32(&c.cleaningMetricsLogCollector, 1)
 	for i := 0; i < metricsLogLen; i++ {
-		metricLog := <-c.metricsLogCollector
+		metricLog := <-metricsLogCollector
 		metricLogs = append(metricLogs, metricLog)
 	}
-	c.cleaningMetricsLogCollector = false
+	atomic.StoreInt32(&c.cleaningMetricsLogCollector, 0)
 	c.doReportMetricsLogs(metricLogs)
 }
 
 func (c *collector) doReportMetricsLogs(metricLogs []*protocol.MetricLog) {
-	url := fmt.Sprintf(metricsLogURLFormat, c.cfg.HTTPSchema, c.getDomain(metricsLogPath))
-	metricLogMessage := &protocol.MetricLogMessage{
-		MetricLogs: metricLogs,
-	}
-	err := c.reporter.reportMetricsLog(metricLogMessage, url)
-	if err != nil {
-		logs.Error("[Metrics] report metrics log fail, err:%v, url:%s", err, url)
+	if err := c.reporter.ReportLogs(metricLogs); err != nil {
+		logs.Error("[Metrics] report %d metrics logs fail, err:%v", len(metricLogs), err)
 	}
 }
 
@@ -0,0 +1,237 @@
+package metrics
+
+import (
+	"context"
+	"reflect"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/byteplus-sdk/byteplus-sdk-go-rec-core/metrics/protocol"
+)
+
+type fakeHostReader struct {
+	hosts []string
+}
+
+func (f *fakeHostReader) GetHost(path string) string {
+	return f.hosts[0]
+}
+
+func (f *fakeHostReader) GetPathHosts(path string) []string {
+	return f.hosts
+}
+
+func TestCollectorGetDomains(t *testing.T) {
+	c := &collector{
+		cfg:       &Config{Domain: "default.byteplus.com"},
+		initialed: true,
+	}
+	if got := c.getDomains(metricsPath); !reflect.DeepEqual(got, []string{"default.byteplus.com"}) {
+		t.Errorf("getDomains() without host reader = %v", got)
+	}
+	hostReader := &fakeHostReader{hosts: []string{"a.byteplus.com", "b.byteplus.com"}}
+	c.SetHostReader(hostReader)
+	if got := c.getDomains(metricsPath); !reflect.DeepEqual(got, hostReader.hosts) {
+		t.Errorf("getDomains() = %v, want %v", got, hostReader.hosts)
+	}
+	// the first host reader is kept
+	c.SetHostReader(&fakeHostReader{hosts: []string{"c.byteplus.com"}})
+	if got := c.getDomains(metricsPath); !reflect.DeepEqual(got, hostReader.hosts) {
+		t.Errorf("getDomains() = %v, want %v", got, hostReader.hosts)
+	}
+}
+
+func TestCollectorShutdown(t *testing.T) {
+	c := &collector{}
+	cfg := &Config{EnableMetrics: true, ReportInterval: time.Millisecond}
+	c.Init(cfg, nil)
+	if !c.IsInitialed() {
+		t.Fatalf("collector should be initialed")
+	}
+	stopped := c.stopped
+	c.Shutdown()
+	select {
+	case <-stopped:
+	default:
+		t.Errorf("report loop should exit after Shutdown")
+	}
+	if c.IsInitialed() {
+		t.Errorf("collector should not be initialed after Shutdown")
+	}
+	// restart
+	c.Init(cfg, nil)
+	if !c.IsInitialed() || c.stopped == nil {
+		t.Errorf("collector should be restarted")
+	}
+	c.Shutdown()
+}
+
+type panicHostReader struct {
+	panicTimes int32
+}
+
+func (p *panicHostReader) GetHost(path string) string {
+	if atomic.AddInt32(&p.panicTimes, -1) >= 0 {
+		panic("get host panic")
+	}
+	return "127.0.0.1:0"
+}
+
+func TestCollectorRestartAfterPanic(t *testing.T) {
+	originBackoff := reportRestartBackoff
+	reportRestartBackoff = time.Millisecond
+	defer func() {
+		reportRestartBackoff = originBackoff
+	}()
+	c := &collector{}
+	hostReader := &panicHostReader{panicTimes: 2}
+	c.Init(&Config{EnableMetrics: true, ReportInterval: time.Millisecond, HTTPTimeout: time.Millisecond}, hostReader)
+	defer c.Shutdown()
+	c.EmitMetric(metricsTypeCounter, "test", 1)
+	deadline := time.Now().Add(3 * time.Second)
+	for atomic.LoadInt32(&hostReader.panicTimes) >= 0 && time.Now().Before(deadline) {
+		// the panic counter is reported, and get host is called again after restart
+		time.Sleep(time.Millisecond)
+	}
+	if atomic.LoadInt32(&hostReader.panicTimes) >= 0 {
+		t.Errorf("report loop should be restarted after panic")
+	}
+}
+
+type recordingReporter struct {
+	lock       sync.Mutex
+	metrics    []*protocol.Metric
+	metricLogs []*protocol.MetricLog
+}
+
+func (r *recordingReporter) Report(metrics []*protocol.Metric) error {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	r.metrics = append(r.metrics, metrics...)
+	return nil
+}
+
+func (r *recordingReporter) ReportLogs(metricLogs []*protocol.MetricLog) error {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	r.metricLogs = append(r.metricLogs, metricLogs...)
+	return nil
+}
+
+func TestCollectorReporter(t *testing.T) {
+	reporter := &recordingReporter{}
+	c := &collector{}
+	c.Init(&Config{EnableMetrics: true, EnableMetricsLog: true, ReportInterval: time.Hour,
+		Reporter: reporter}, nil)
+	defer c.Shutdown()
+	c.EmitMetric(metricsTypeCounter, "request.count", 1, "project_id:1")
+	c.EmitLog("log_id", "message", logLevelInfo, currentTimeMillis())
+	c.report()
+	reporter.lock.Lock()
+	defer reporter.lock.Unlock()
+	if len(reporter.metrics) != 1 || reporter.metrics[0].Name != defaultMetricsPrefix+".request.count" {
+		t.Errorf("reported metrics = %v", reporter.metrics)
+	}
+	if len(reporter.metricLogs) != 1 || reporter.metricLogs[0].Id != "log_id" {
+		t.Errorf("reported metrics logs = %v", reporter.metricLogs)
+	}
+}
+
+func TestCollectorGauge(t *testing.T) {
+	reporter := &recordingReporter{}
+	c := &collector{}
+	c.Init(&Config{EnableMetrics: true, ReportInterval: time.Hour, Reporter: reporter}, nil)
+	defer c.Shutdown()
+	c.EmitMetric(metricsTypeGauge, "queue.size", 3, "project_id:1", "queue:write")
+	c.EmitMetric(metricsTypeGauge, "queue.size", 7, "queue:write", "project_id:1")
+	c.EmitMetric(metricsTypeGauge, "queue.size", 5, "project_id:2", "queue:write")
+	c.EmitMetric(metricsTypeCounter, "request.count", 1, "project_id:1")
+	c.EmitMetric(metricsTypeCounter, "request.count", 1, "project_id:1")
+	c.report()
+	reporter.lock.Lock()
+	defer reporter.lock.Unlock()
+	gauges := make(map[string]float64)
+	counters := 0
+	for _, metric := range reporter.metrics {
+		switch metric.Type {
+		case metricsTypeGauge:
+			gauges[metric.Tags["project_id"]] = metric.Value
+		case metricsTypeCounter:
+			counters++
+		}
+	}
+	if len(reporter.metrics) != 4 || gauges["1"] != 7 || gauges["2"] != 5 || counters != 2 {
+		t.Errorf("reported metrics = %v", reporter.metrics)
+	}
+}
+
+func TestCollectorFlush(t *testing.T) {
+	reporter := &recordingReporter{}
+	c := &collector{}
+	c.Init(&Config{EnableMetrics: true, EnableMetricsLog: true, ReportInterval: time.Hour,
+		Reporter: reporter}, nil)
+	c.EmitMetric(metricsTypeCounter, "request.count", 1, "project_id:1")
+	c.EmitLog("log_id", "message", logLevelInfo, currentTimeMillis())
+	c.Flush()
+	reporter.lock.Lock()
+	if len(reporter.metrics) != 1 || len(reporter.metricLogs) != 1 {
+		t.Errorf("flushed metrics = %v, metrics logs = %v", reporter.metrics, reporter.metricLogs)
+	}
+	reporter.lock.Unlock()
+	// the metrics not reported yet are flushed on Shutdown
+	c.EmitMetric(metricsTypeCounter, "request.count", 1, "project_id:1")
+	c.Shutdown()
+	reporter.lock.Lock()
+	defer reporter.lock.Unlock()
+	if len(reporter.metrics) != 2 {
+		t.Errorf("metrics after Shutdown = %v", reporter.metrics)
+	}
+	if len(c.loadEmitConfig().metricsCollector) != 0 {
+		t.Errorf("metrics collector should be drained after Shutdown")
+	}
+}
+
+func TestCollectorFlushWithContext(t *testing.T) {
+	reporter := &recordingReporter{}
+	c := &collector{}
+	c.Init(&Config{EnableMetrics: true, ReportInterval: time.Hour, Reporter: reporter}, nil)
+	c.EmitMetric(metricsTypeCounter, "request.count", 1, "project_id:1")
+	// the report is blocked by the lock of reporter
+	reporter.lock.Lock()
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	if err := c.FlushWithContext(ctx); err != context.DeadlineExceeded {
+		t.Errorf("FlushWithContext() error = %v, want deadline exceeded", err)
+	}
+	reporter.lock.Unlock()
+	if err := c.ShutdownWithContext(context.Background()); err != nil {
+		t.Errorf("ShutdownWithContext() error = %v", err)
+	}
+	reporter.lock.Lock()
+	defer reporter.lock.Unlock()
+	if len(reporter.metrics) != 1 {
+		t.Errorf("reported metrics = %v, want the flushed one", reporter.metrics)
+	}
+}
+
+func TestCollectorEmitWhileInit(t *testing.T) {
+	c := &collector{}
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		for i := 0; i < 100; i++ {
+			c.EmitMetric(metricsTypeCounter, "request.count", 1, "project_id:1")
+			c.EmitRate("request.count", 1, "project_id:1")
+			c.EmitLog("id", "message", "info", 0)
+		}
+	}()
+	// the emitting goroutine reads the config published by Init without lock
+	for i := 0; i < 10; i++ {
+		c.Init(&Config{EnableMetrics: true, EnableMetricsLog: true, ReportInterval: time.Hour,
+			Reporter: &recordingReporter{}}, nil)
+		c.Shutdown()
+	}
+	<-done
+}
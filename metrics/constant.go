@@ -25,6 +25,12 @@ const (
 	maxMetricsSize        = 10000
 	maxMetricsLogSize     = 5000
 
+	// the max backoff of restarting the report loop after it panics
+	maxReportRestartBackoff = time.Minute
+
+	// self monitoring metrics key
+	metricsKeyReportPanic = "metrics.report.panic"
+
 	// metrics log level
 	logLevelTrace  = "trace"
 	logLevelDebug  = "debug"
@@ -37,6 +43,7 @@ const (
 	// metrics type
 	metricsTypeCounter     = "counter"
 	metricsTypeStore       = "store"
+	metricsTypeGauge       = "gauge"
 	metricsTypeTimer       = "timer"
 	metricsTypeRateCounter = "rate_counter"
 	metricsTypeMeter       = "meter"
@@ -0,0 +1,198 @@
+package metrics
+
+import (
+	"math"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/byteplus-sdk/byteplus-sdk-go-rec-core/metrics/protocol"
+)
+
+const (
+	// the interval of decaying the moving rates
+	meterTickInterval = 5 * time.Second
+	// the moving rates are almost 0 after idle this long, then the meter is removed
+	meterIdleTimeout = time.Hour
+	// rates are reset instead of decayed tick by tick after idle this long
+	maxMeterTicks = int(meterIdleTimeout / meterTickInterval)
+)
+
+// the windows of the moving rates and the suffixes of the reported metrics
+var meterWindows = []struct {
+	suffix string
+	window time.Duration
+}{
+	{"m1_rate", time.Minute},
+	{"m5_rate", 5 * time.Minute},
+	{"m15_rate", 15 * time.Minute},
+}
+
+// ewmaRate is the exponentially weighted moving rate of events per second
+type ewmaRate struct {
+	alpha       float64
+	rate        float64
+	initialized bool
+}
+
+func newEWMARate(window time.Duration) ewmaRate {
+	return ewmaRate{alpha: 1 - math.Exp(-float64(meterTickInterval)/float64(window))}
+}
+
+func (e *ewmaRate) tick(count int64) {
+	instantRate := float64(count) / meterTickInterval.Seconds()
+	if !e.initialized {
+		e.rate, e.initialized = instantRate, true
+		return
+	}
+	e.rate += e.alpha * (instantRate - e.rate)
+}
+
+// meter tracks the 1/5/15-minute moving rates of events, it is ticked lazily
+// when marked or read, so that no goroutine is needed for each meter
+type meter struct {
+	name      string
+	tags      map[string]string
+	lock      sync.Mutex
+	uncounted int64
+	lastTick  time.Time
+	lastMark  time.Time
+	rates     []ewmaRate
+}
+
+func newMeter(name string, tags map[string]string, now time.Time) *meter {
+	rates := make([]ewmaRate, 0, len(meterWindows))
+	for _, meterWindow := range meterWindows {
+		rates = append(rates, newEWMARate(meterWindow.window))
+	}
+	return &meter{
+		name:     name,
+		tags:     tags,
+		lastTick: now,
+		lastMark: now,
+		rates:    rates,
+	}
+}
+
+func (m *meter) mark(value int64, now time.Time) {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+	m.tickIfNeeded(now)
+	m.uncounted += value
+	m.lastMark = now
+}
+
+// snapshot returns the moving rates in the order of meterWindows
+func (m *meter) snapshot(now time.Time) []float64 {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+	m.tickIfNeeded(now)
+	rates := make([]float64, 0, len(m.rates))
+	for i := range m.rates {
+		rates = append(rates, m.rates[i].rate)
+	}
+	return rates
+}
+
+func (m *meter) isIdle(now time.Time) bool {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+	return now.Sub(m.lastMark) > meterIdleTimeout
+}
+
+// tickIfNeeded should be called with lock held
+func (m *meter) tickIfNeeded(now time.Time) {
+	ticks := int(now.Sub(m.lastTick) / meterTickInterval)
+	if ticks <= 0 {
+		return
+	}
+	lastTick := m.lastTick
+	m.lastTick = m.lastTick.Add(time.Duration(ticks) * meterTickInterval)
+	if ticks > maxMeterTicks {
+		for i := range m.rates {
+			m.rates[i].rate = 0
+		}
+		m.uncounted = 0
+		return
+	}
+	// the uncounted events are marked since the last tick until lastMark, they are
+	// credited to the tick of lastMark, the ticks before and after it decay the rates only
+	markTick := int(m.lastMark.Sub(lastTick) / meterTickInterval)
+	if markTick < 0 {
+		markTick = 0
+	} else if markTick >= ticks {
+		markTick = ticks - 1
+	}
+	for i := range m.rates {
+		for j := 0; j < ticks; j++ {
+			if j == markTick {
+				m.rates[i].tick(m.uncounted)
+			} else {
+				m.rates[i].tick(0)
+			}
+		}
+	}
+	m.uncounted = 0
+}
+
+// meterRegistry holds the meters of each name and tags
+type meterRegistry struct {
+	lock   sync.Mutex
+	meters map[string]*meter
+}
+
+func (r *meterRegistry) mark(name string, value int64, tags map[string]string) {
+	key := metricKey(name, tags)
+	now := time.Now()
+	r.lock.Lock()
+	if r.meters == nil {
+		r.meters = make(map[string]*meter)
+	}
+	m, exist := r.meters[key]
+	if !exist {
+		m = newMeter(name, tags, now)
+		r.meters[key] = m
+	}
+	r.lock.Unlock()
+	m.mark(value, now)
+}
+
+// metrics returns the moving rates of the meters as gauges, such as
+// "request.count.m1_rate", and removes the idle meters
+func (r *meterRegistry) metrics() []*protocol.Metric {
+	now := time.Now()
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	var result []*protocol.Metric
+	for key, m := range r.meters {
+		if m.isIdle(now) {
+			delete(r.meters, key)
+			continue
+		}
+		for i, rate := range m.snapshot(now) {
+			tags := make(map[string]string, len(m.tags))
+			for k, v := range m.tags {
+				tags[k] = v
+			}
+			result = append(result, &protocol.Metric{
+				Name:      m.name + "." + meterWindows[i].suffix,
+				Value:     rate,
+				Type:      metricsTypeGauge,
+				Timestamp: now.UnixNano() / int64(time.Millisecond),
+				Tags:      tags,
+			})
+		}
+	}
+	return result
+}
+
+// metricKey identifies the metric by name and tags regardless of the order of tags
+func metricKey(name string, tags map[string]string) string {
+	tagKvs := make([]string, 0, len(tags))
+	for key, value := range tags {
+		tagKvs = append(tagKvs, key+":"+value)
+	}
+	sort.Strings(tagKvs)
+	return name + "|" + strings.Join(tagKvs, ",")
+}
@@ -0,0 +1,77 @@
+package metrics
+
+import (
+	"math"
+	"testing"
+	"time"
+)
+
+func TestMeter(t *testing.T) {
+	now := time.Now()
+	m := newMeter("request.count", nil, now)
+	m.mark(50, now)
+	// 50 events in the first tick are 10 events per second
+	rates := m.snapshot(now.Add(meterTickInterval))
+	for i, rate := range rates {
+		if rate != 10 {
+			t.Errorf("%s = %v, want 10", meterWindows[i].suffix, rate)
+		}
+	}
+	// idle for one minute, the 1-minute rate decays faster than the others
+	rates = m.snapshot(now.Add(meterTickInterval + time.Minute))
+	if want := 10 * math.Exp(-1); math.Abs(rates[0]-want) > 1e-9 {
+		t.Errorf("m1_rate = %v, want %v", rates[0], want)
+	}
+	if !(rates[0] < rates[1] && rates[1] < rates[2] && rates[2] < 10) {
+		t.Errorf("rates = %v", rates)
+	}
+	// the rates are reset after idle too long
+	rates = m.snapshot(now.Add(2 * meterIdleTimeout))
+	for i, rate := range rates {
+		if rate != 0 {
+			t.Errorf("%s = %v, want 0", meterWindows[i].suffix, rate)
+		}
+	}
+	if !m.isIdle(now.Add(2 * meterIdleTimeout)) {
+		t.Errorf("meter should be idle")
+	}
+}
+
+func TestMeter_elapsedTicks(t *testing.T) {
+	now := time.Now()
+	m := newMeter("request.count", nil, now)
+	m.mark(50, now)
+	m.mark(100, now.Add(meterTickInterval+time.Second))
+	// the events are credited to the ticks they are marked in, the last tick is idle
+	rates := m.snapshot(now.Add(3 * meterTickInterval))
+	for i, rate := range rates {
+		alpha := m.rates[i].alpha
+		if want := (10 + alpha*(20-10)) * (1 - alpha); math.Abs(rate-want) > 1e-9 {
+			t.Errorf("%s = %v, want %v", meterWindows[i].suffix, rate, want)
+		}
+	}
+}
+
+func TestCollectorRate(t *testing.T) {
+	reporter := &recordingReporter{}
+	c := &collector{}
+	c.Init(&Config{EnableMetrics: true, ReportInterval: time.Hour, Reporter: reporter}, nil)
+	defer c.Shutdown()
+	c.EmitRate("request.count", 1, "project_id:1")
+	c.EmitRate("request.count", 1, "project_id:1")
+	c.report()
+	reporter.lock.Lock()
+	defer reporter.lock.Unlock()
+	if len(reporter.metrics) != len(meterWindows) {
+		t.Fatalf("reported metrics = %v", reporter.metrics)
+	}
+	for i, metric := range reporter.metrics {
+		if metric.Type != metricsTypeGauge || metric.Tags["project_id"] != "1" {
+			t.Errorf("reported metric %d = %v", i, metric)
+		}
+	}
+	if name := defaultMetricsPrefix + ".request.count.m1_rate"; reporter.metrics[0].Name != name &&
+		reporter.metrics[1].Name != name && reporter.metrics[2].Name != name {
+		t.Errorf("reported metrics = %v, want %s", reporter.metrics, name)
+	}
+}
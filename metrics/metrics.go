@@ -6,6 +6,13 @@ func Store(key string, value int64, tagKvs ...string) {
 	Collector.EmitMetric(metricsTypeStore, key, value, tagKvs...)
 }
 
+// Gauge reports the last value of key in a report interval, such as the queue depth
+// and connection count. tagKvs should be formatted as "key:value"
+// example: Gauge("async_write.queue_size", 100, "project_id:1")
+func Gauge(key string, value int64, tagKvs ...string) {
+	Collector.EmitMetric(metricsTypeGauge, key, value, tagKvs...)
+}
+
 // Counter description: Store tagKvs should be formatted as "key:value"
 // example: counter("request.count", 1, "method:user", "type:upload")
 func Counter(key string, value int64, tagKvs ...string) {
@@ -32,6 +39,13 @@ func RateCounter(key string, value int64, tagKvs ...string) {
 	Collector.EmitMetric(metricsTypeRateCounter, key, value, tagKvs...)
 }
 
+// Rate tracks the events per second of key with the 1/5/15-minute moving rates,
+// which are reported as the gauges of key.m1_rate, key.m5_rate and key.m15_rate
+// example: Rate("request.count", 1, "project_id:1")
+func Rate(key string, value int64, tagKvs ...string) {
+	Collector.EmitRate(key, value, tagKvs...)
+}
+
 // Meter description:
 //  - meter(xx) = counter(xx) + rateCounter(xx.rate)
 //  - Store tagKvs should be formatted as "key:value"
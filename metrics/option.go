@@ -1,7 +1,10 @@
 package metrics
 
 import (
+	"crypto/tls"
 	"time"
+
+	"github.com/valyala/fasthttp"
 )
 
 type Option func(config *Config)
@@ -59,3 +62,42 @@ func WithMetricsTimeout(timeout time.Duration) Option {
 		config.HTTPTimeout = timeout
 	}
 }
+
+// WithPrivacyMode hashes or omits the identifiers in metrics and metric logs before reported
+func WithPrivacyMode(mode PrivacyMode, hashSalt string) Option {
+	return func(config *Config) {
+		config.PrivacyMode = mode
+		config.PrivacyHashSalt = hashSalt
+	}
+}
+
+// WithDial set the dial of reporting, such as the proxy dial of core.NewProxyDial
+func WithDial(dial fasthttp.DialFunc) Option {
+	return func(config *Config) {
+		config.Dial = dial
+	}
+}
+
+// WithStatsD send the metrics to the statsd agent of address over UDP in the format,
+// in place of reporting them to the byteplus server
+func WithStatsD(address string, format StatsDFormat) Option {
+	return func(config *Config) {
+		config.StatsDAddress = address
+		config.StatsDFormat = format
+	}
+}
+
+// WithReporter set the reporter taking place of reporting to the byteplus server,
+// such as NoopReporter discarding the metrics in tests
+func WithReporter(reporter Reporter) Option {
+	return func(config *Config) {
+		config.Reporter = reporter
+	}
+}
+
+// WithTLSConfig set the tls config of reporting, such as with the client certificates of mTLS
+func WithTLSConfig(tlsConfig *tls.Config) Option {
+	return func(config *Config) {
+		config.TLSConfig = tlsConfig
+	}
+}
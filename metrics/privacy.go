@@ -0,0 +1,76 @@
+package metrics
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"regexp"
+)
+
+// PrivacyMode decides how the identifiers in metrics and metric logs are scrubbed
+// before they leave the process, the identifiers are tenant id, project id, host,
+// url and request id
+type PrivacyMode string
+
+const (
+	// PrivacyModeOff sends the identifiers as is
+	PrivacyModeOff PrivacyMode = ""
+	// PrivacyModeHash replaces the identifiers with their salted hash, so that
+	// the metrics of the same identifier can still be grouped
+	PrivacyModeHash PrivacyMode = "hash"
+	// PrivacyModeOmit removes the identifiers
+	PrivacyModeOmit PrivacyMode = "omit"
+)
+
+const omittedIdentifier = "-"
+
+// the tag keys whose values are identifiers
+var sensitiveTagKeys = map[string]bool{
+	"tenant_id":  true,
+	"project_id": true,
+	"host":       true,
+	"url":        true,
+	"request_id": true,
+}
+
+var (
+	// such as "project_id:1234" and "url: https://host/path" in messages
+	sensitiveKVPattern = regexp.MustCompile(`\b(tenant_id|project_id|request_id|host|url)(\s*[:=]\s*)([^,\s]+)`)
+	urlPattern         = regexp.MustCompile(`\b[a-zA-Z][a-zA-Z0-9+.-]*://[^\s,]+`)
+	hostPattern        = regexp.MustCompile(
+		`\b(?:\d{1,3}(?:\.\d{1,3}){3}|(?:[a-zA-Z0-9-]+\.)+[a-zA-Z][a-zA-Z0-9-]*)(?::\d+)?\b`)
+)
+
+type scrubber struct {
+	mode PrivacyMode
+	salt string
+}
+
+func (s *scrubber) enabled() bool {
+	return s.mode == PrivacyModeHash || s.mode == PrivacyModeOmit
+}
+
+func (s *scrubber) scrub(value string) string {
+	if value == "" || s.mode == PrivacyModeOmit {
+		return omittedIdentifier
+	}
+	sum := sha256.Sum256([]byte(s.salt + value))
+	return "h_" + hex.EncodeToString(sum[:8])
+}
+
+func (s *scrubber) scrubTags(tags map[string]string) {
+	for key, value := range tags {
+		if sensitiveTagKeys[key] {
+			tags[key] = s.scrub(value)
+		}
+	}
+}
+
+// scrubMessage scrubs the identifiers in key:value form, the urls and the hosts of message
+func (s *scrubber) scrubMessage(message string) string {
+	message = sensitiveKVPattern.ReplaceAllStringFunc(message, func(kv string) string {
+		parts := sensitiveKVPattern.FindStringSubmatch(kv)
+		return parts[1] + parts[2] + s.scrub(parts[3])
+	})
+	message = urlPattern.ReplaceAllStringFunc(message, s.scrub)
+	return hostPattern.ReplaceAllStringFunc(message, s.scrub)
+}
@@ -0,0 +1,30 @@
+package metrics
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestScrubber(t *testing.T) {
+	s := &scrubber{mode: PrivacyModeHash, salt: "salt"}
+	tags := map[string]string{"project_id": "1234", "type": "request_timeout"}
+	s.scrubTags(tags)
+	if tags["project_id"] != s.scrub("1234") || tags["type"] != "request_timeout" {
+		t.Errorf("unexpected tags: %v", tags)
+	}
+	message := "[ByteplusSDK] do http request timeout, project_id:1234, " +
+		"url:https://rec.byteplus.com/predict, err:dial tcp4 10.0.0.1:443 timeout, score:0.95"
+	scrubbed := s.scrubMessage(message)
+	for _, identifier := range []string{"1234", "rec.byteplus.com", "10.0.0.1"} {
+		if strings.Contains(scrubbed, identifier) {
+			t.Errorf("identifier %s is not scrubbed: %s", identifier, scrubbed)
+		}
+	}
+	if !strings.Contains(scrubbed, "score:0.95") || !strings.Contains(scrubbed, "do http request timeout") {
+		t.Errorf("non-identifiers should be kept: %s", scrubbed)
+	}
+	omit := &scrubber{mode: PrivacyModeOmit}
+	if got := omit.scrub("1234"); got != omittedIdentifier {
+		t.Errorf("omit scrub() = %v", got)
+	}
+}
@@ -4,14 +4,83 @@ import (
 	"fmt"
 	"strings"
 
+	"github.com/byteplus-sdk/byteplus-sdk-go-rec-core/events"
 	"github.com/byteplus-sdk/byteplus-sdk-go-rec-core/metrics/protocol"
 	"github.com/valyala/fasthttp"
 	"google.golang.org/protobuf/proto"
 )
 
+// Reporter sends the metrics and metrics logs collected in a ReportInterval, such as to
+// Kafka or a log pipeline, it is called in the report goroutine. The default reports
+// them to the byteplus server
+type Reporter interface {
+	Report(metrics []*protocol.Metric) error
+	ReportLogs(logs []*protocol.MetricLog) error
+}
+
+// NoopReporter discards the metrics and metrics logs, such as in tests
+type NoopReporter struct {
+}
+
+func (r NoopReporter) Report(metrics []*protocol.Metric) error {
+	return nil
+}
+
+func (r NoopReporter) ReportLogs(logs []*protocol.MetricLog) error {
+	return nil
+}
+
+// reporter reports to the byteplus server, it fails over to the next domain
+// when reporting to the former one fails
 type reporter struct {
 	httpCli    *fasthttp.Client
 	metricsCfg *Config
+	// the domains of the path in order
+	domains func(path string) []string
+}
+
+func (r *reporter) Report(metrics []*protocol.Metric) error {
+	metricMessage := &protocol.MetricMessage{
+		Metrics: metrics,
+	}
+	var err error
+	for _, domain := range r.domains(metricsPath) {
+		url := fmt.Sprintf(metricsURLFormat, r.metricsCfg.HTTPSchema, domain)
+		if err = r.reportMetrics(metricMessage, url); err == nil {
+			return nil
+		}
+		events.Publish(&events.Event{
+			Type: events.MetricsReportFailed,
+			URL:  url,
+			Host: domain,
+			Err:  err,
+		})
+		// the failure is logged by the collector once all the domains fail
+		err = fmt.Errorf("%v, url:%s", err, url)
+	}
+	return err
+}
+
+func (r *reporter) ReportLogs(metricLogs []*protocol.MetricLog) error {
+	metricLogMessage := &protocol.MetricLogMessage{
+		MetricLogs: metricLogs,
+	}
+	var err error
+	for _, domain := range r.domains(metricsLogPath) {
+		url := fmt.Sprintf(metricsLogURLFormat, r.metricsCfg.HTTPSchema, domain)
+		if err = r.reportMetricsLog(metricLogMessage, url); err == nil {
+			return nil
+		}
+		events.Publish(&events.Event{
+			Type: events.MetricsReportFailed,
+			URL:  url,
+			Host: domain,
+			Err:  err,
+		})
+		// the failure is logged by the collector once all the domains fail
+		err = fmt.Errorf("%v, url:%s", err, url)
+	}
+	return err
 }
 
 func (r *reporter) reportMetrics(metricMessage *protocol.MetricMessage, url string) error {
@@ -0,0 +1,133 @@
+package metrics
+
+import (
+	"fmt"
+	"net"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/byteplus-sdk/byteplus-sdk-go-rec-core/events"
+	"github.com/byteplus-sdk/byteplus-sdk-go-rec-core/metrics/protocol"
+)
+
+// StatsDFormat is the line format of the metrics sent to the statsd agent
+type StatsDFormat string
+
+const (
+	// StatsDFormatDogStatsD sends the tags in the dogstatsd extension, such as
+	// "request.count:1|c|#project_id:1", it is the default
+	StatsDFormatDogStatsD StatsDFormat = "dogstatsd"
+	// StatsDFormatStatsD sends the tags in the name by the graphite tag syntax, such as
+	// "request.count;project_id=1:1|c", as the plain statsd has no tags
+	StatsDFormatStatsD StatsDFormat = "statsd"
+)
+
+// the max size of a udp packet not fragmented over the common networks
+const maxStatsDPacketSize = 1432
+
+// statsDReporter sends the metrics to the local statsd agent over UDP, in place of
+// reporting them to the byteplus server. The metrics logs are reported by logsReporter
+type statsDReporter struct {
+	conn         net.Conn
+	format       StatsDFormat
+	logsReporter Reporter
+}
+
+func newStatsDReporter(address string, format StatsDFormat, logsReporter Reporter) (*statsDReporter, error) {
+	conn, err := net.Dial("udp", address)
+	if err != nil {
+		return nil, err
+	}
+	if format == "" {
+		format = StatsDFormatDogStatsD
+	}
+	return &statsDReporter{conn: conn, format: format, logsReporter: logsReporter}, nil
+}
+
+// Report sends the metrics in as few packets as possible, the lines in a packet are
+// separated by newline
+func (r *statsDReporter) Report(metrics []*protocol.Metric) error {
+	var packet strings.Builder
+	for _, metric := range metrics {
+		line := r.formatLine(metric)
+		if packet.Len() > 0 && packet.Len()+len(line)+1 > maxStatsDPacketSize {
+			if err := r.send(packet.String()); err != nil {
+				return err
+			}
+			packet.Reset()
+		}
+		if packet.Len() > 0 {
+			packet.WriteByte('\n')
+		}
+		packet.WriteString(line)
+	}
+	if packet.Len() == 0 {
+		return nil
+	}
+	return r.send(packet.String())
+}
+
+func (r *statsDReporter) ReportLogs(metricLogs []*protocol.MetricLog) error {
+	return r.logsReporter.ReportLogs(metricLogs)
+}
+
+func (r *statsDReporter) send(packet string) error {
+	if _, err := r.conn.Write([]byte(packet)); err != nil {
+		events.Publish(&events.Event{
+			Type: events.MetricsReportFailed,
+			URL:  "udp://" + r.conn.RemoteAddr().String(),
+			Err:  err,
+		})
+		return fmt.Errorf("[StatsDReporter] send metrics fail, err:%v addr:%s", err, r.conn.RemoteAddr())
+	}
+	return nil
+}
+
+func (r *statsDReporter) close() {
+	_ = r.conn.Close()
+}
+
+func (r *statsDReporter) formatLine(metric *protocol.Metric) string {
+	tagKeys := make([]string, 0, len(metric.Tags))
+	for key := range metric.Tags {
+		tagKeys = append(tagKeys, key)
+	}
+	sort.Strings(tagKeys)
+	name := sanitizeStatsD(metric.Name)
+	value := strconv.FormatFloat(metric.Value, 'f', -1, 64) + "|" + statsDType(metric.Type)
+	if len(tagKeys) == 0 {
+		return name + ":" + value
+	}
+	tags := make([]string, len(tagKeys))
+	if r.format == StatsDFormatStatsD {
+		for i, key := range tagKeys {
+			tags[i] = sanitizeStatsD(key) + "=" + sanitizeStatsD(metric.Tags[key])
+		}
+		return name + ";" + strings.Join(tags, ";") + ":" + value
+	}
+	for i, key := range tagKeys {
+		tags[i] = sanitizeStatsD(key) + ":" + sanitizeStatsD(metric.Tags[key])
+	}
+	return name + ":" + value + "|#" + strings.Join(tags, ",")
+}
+
+func statsDType(metricsType string) string {
+	switch metricsType {
+	case metricsTypeTimer:
+		return "ms"
+	case metricsTypeStore, metricsTypeGauge:
+		return "g"
+	default:
+		// rate_counter and meter are counted by the agent
+		return "c"
+	}
+}
+
+var statsDReplacer = strings.NewReplacer(":", "_", "|", "_", ",", "_", "#", "_", ";", "_",
+	"=", "_", "\n", "_", " ", "_")
+
+// sanitizeStatsD replaces the separators of the statsd line in names and tags
+func sanitizeStatsD(value string) string {
+	return statsDReplacer.Replace(value)
+}
@@ -0,0 +1,83 @@
+package metrics
+
+import (
+	"net"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/byteplus-sdk/byteplus-sdk-go-rec-core/metrics/protocol"
+)
+
+func TestStatsDReporter(t *testing.T) {
+	conn, err := net.ListenPacket("udp", "127.0.0.1:0")
+	if err != nil {
+		t.Skipf("listen udp fail: %v", err)
+	}
+	defer conn.Close()
+	metrics := []*protocol.Metric{
+		{Name: "byteplus.rec.sdk.request.count", Value: 1, Type: metricsTypeCounter,
+			Tags: map[string]string{"project_id": "1", "url": "http://host/predict"}},
+		{Name: "byteplus.rec.sdk.request.total.cost", Value: 12, Type: metricsTypeTimer},
+	}
+	tests := []struct {
+		format StatsDFormat
+		want   string
+	}{
+		{"", "byteplus.rec.sdk.request.count:1|c|#project_id:1,url:http_//host/predict\n" +
+			"byteplus.rec.sdk.request.total.cost:12|ms"},
+		{StatsDFormatStatsD, "byteplus.rec.sdk.request.count;project_id=1;url=http_//host/predict:1|c\n" +
+			"byteplus.rec.sdk.request.total.cost:12|ms"},
+	}
+	for _, tt := range tests {
+		t.Run(string(tt.format), func(t *testing.T) {
+			reporter, err := newStatsDReporter(conn.LocalAddr().String(), tt.format, NoopReporter{})
+			if err != nil {
+				t.Fatalf("newStatsDReporter() error = %v", err)
+			}
+			defer reporter.close()
+			if err = reporter.Report(metrics); err != nil {
+				t.Fatalf("reportMetrics() error = %v", err)
+			}
+			buf := make([]byte, maxStatsDPacketSize)
+			_ = conn.SetReadDeadline(time.Now().Add(time.Second))
+			n, _, err := conn.ReadFrom(buf)
+			if err != nil {
+				t.Fatalf("read packet error = %v", err)
+			}
+			if got := string(buf[:n]); got != tt.want {
+				t.Errorf("packet = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestStatsDReporter_splitPackets(t *testing.T) {
+	conn, err := net.ListenPacket("udp", "127.0.0.1:0")
+	if err != nil {
+		t.Skipf("listen udp fail: %v", err)
+	}
+	defer conn.Close()
+	reporter, _ := newStatsDReporter(conn.LocalAddr().String(), StatsDFormatDogStatsD, NoopReporter{})
+	defer reporter.close()
+	metrics := make([]*protocol.Metric, 100)
+	for i := range metrics {
+		metrics[i] = &protocol.Metric{Name: strings.Repeat("m", 50), Value: 1, Type: metricsTypeCounter}
+	}
+	if err = reporter.Report(metrics); err != nil {
+		t.Fatalf("reportMetrics() error = %v", err)
+	}
+	lines := 0
+	buf := make([]byte, 2*maxStatsDPacketSize)
+	for lines < len(metrics) {
+		_ = conn.SetReadDeadline(time.Now().Add(time.Second))
+		n, _, err := conn.ReadFrom(buf)
+		if err != nil {
+			t.Fatalf("read packet error = %v, %d lines received", err, lines)
+		}
+		if n > maxStatsDPacketSize {
+			t.Errorf("packet size = %d, want not over %d", n, maxStatsDPacketSize)
+		}
+		lines += strings.Count(string(buf[:n]), "\n") + 1
+	}
+}
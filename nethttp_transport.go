@@ -0,0 +1,90 @@
+package core
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"net/http"
+	"time"
+
+	"github.com/valyala/fasthttp"
+)
+
+// NetHTTPTransport sends the requests by a net/http client, so that the infra only
+// working with the standard library, such as HTTP/2, mTLS and tracing round trippers,
+// can be used. The requests are signed, retried and routed the same as the default
+// fasthttp transport, but the connection related fields of CallerConfig are ignored
+type NetHTTPTransport struct {
+	client *http.Client
+}
+
+// NewNetHTTPTransport http.DefaultClient is used if client is nil
+func NewNetHTTPTransport(client *http.Client) *NetHTTPTransport {
+	if client == nil {
+		client = http.DefaultClient
+	}
+	return &NetHTTPTransport{client: client}
+}
+
+func (t *NetHTTPTransport) DoDeadline(request *fasthttp.Request,
+	response *fasthttp.Response, deadline time.Time) error {
+	return t.DoContext(context.Background(), request, response, deadline)
+}
+
+// DoContext the connection of the request is closed by net/http once ctx is cancelled
+func (t *NetHTTPTransport) DoContext(ctx context.Context, request *fasthttp.Request,
+	response *fasthttp.Response, deadline time.Time) error {
+	ctx, cancel := context.WithDeadline(ctx, deadline)
+	defer cancel()
+	var body io.Reader = http.NoBody
+	if len(request.Body()) > 0 {
+		body = bytes.NewReader(request.Body())
+	}
+	httpRequest, err := http.NewRequestWithContext(ctx,
+		string(request.Header.Method()), request.URI().String(), body)
+	if err != nil {
+		return err
+	}
+	httpRequest.Host = string(request.Host())
+	request.Header.VisitAll(func(key, value []byte) {
+		if isHopHeader(string(key)) {
+			return
+		}
+		httpRequest.Header.Add(string(key), string(value))
+	})
+	httpResponse, err := t.client.Do(httpRequest)
+	if err != nil {
+		return err
+	}
+	defer httpResponse.Body.Close()
+	rspBytes, err := io.ReadAll(httpResponse.Body)
+	if err != nil {
+		return err
+	}
+	response.Reset()
+	response.SetStatusCode(httpResponse.StatusCode)
+	for key, values := range httpResponse.Header {
+		if isHopHeader(key) {
+			continue
+		}
+		for _, value := range values {
+			response.Header.Add(key, value)
+		}
+	}
+	response.SetBody(rspBytes)
+	return nil
+}
+
+func (t *NetHTTPTransport) CloseIdleConnections() {
+	t.client.CloseIdleConnections()
+}
+
+// isHopHeader the headers maintained by the transports themselves, they are not copied
+func isHopHeader(key string) bool {
+	switch http.CanonicalHeaderKey(key) {
+	case "Host", "Content-Length", "Connection", "Transfer-Encoding":
+		return true
+	default:
+		return false
+	}
+}
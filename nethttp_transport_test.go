@@ -0,0 +1,42 @@
+package core
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/valyala/fasthttp"
+)
+
+func TestNetHTTPTransport_DoDeadline(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		if r.Method != fasthttp.MethodPost || r.Header.Get("Request-Id") != "req" || string(body) != "hello" {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		w.Header().Set("ETag", "v1")
+		_, _ = w.Write([]byte("world"))
+	}))
+	defer server.Close()
+	transport := NewNetHTTPTransport(server.Client())
+	defer transport.CloseIdleConnections()
+
+	request := fasthttp.AcquireRequest()
+	response := fasthttp.AcquireResponse()
+	defer fasthttp.ReleaseRequest(request)
+	defer fasthttp.ReleaseResponse(response)
+	request.SetRequestURI(server.URL + "/predict")
+	request.Header.SetMethod(fasthttp.MethodPost)
+	request.Header.Set("Request-Id", "req")
+	request.SetBodyString("hello")
+	if err := transport.DoDeadline(request, response, time.Now().Add(time.Second)); err != nil {
+		t.Fatalf("DoDeadline() error = %v", err)
+	}
+	if response.StatusCode() != http.StatusOK || string(response.Body()) != "world" ||
+		string(response.Header.Peek("ETag")) != "v1" {
+		t.Errorf("DoDeadline() status:%d body:%s headers:\n%s", response.StatusCode(), response.Body(), &response.Header)
+	}
+}
@@ -1,6 +1,8 @@
 package option
 
 import (
+	"context"
+	"net/textproto"
 	"time"
 )
 
@@ -22,6 +24,15 @@ func WithRequestID(requestID string) Option {
 	}
 }
 
+// WithContext Specifies the context of this request. When the context is
+// cancelled, the in-flight request is aborted and its connection is closed,
+// the returned error wraps the context's error
+func WithContext(ctx context.Context) Option {
+	return func(options *Options) {
+		options.Context = ctx
+	}
+}
+
 // WithTimeout Specifies the timeout for this request
 func WithTimeout(timeout time.Duration) Option {
 	return func(options *Options) {
@@ -39,13 +50,14 @@ func WithServerTimeout(timeout time.Duration) Option {
 }
 
 // WithHTTPHeader Add an HTTP header to the request.
+// The key is case-insensitive, and the header overrides the one set by the sdk.
 // In general, you do not need to care this.
 func WithHTTPHeader(key, value string) Option {
 	return func(options *Options) {
 		if options.Headers == nil {
 			options.Headers = make(map[string]string)
 		}
-		options.Headers[key] = value
+		options.Headers[textproto.CanonicalMIMEHeaderKey(key)] = value
 	}
 }
 
@@ -59,3 +71,110 @@ func WithHTTPQuery(key, value string) Option {
 		options.Queries[key] = value
 	}
 }
+
+// WithHTTPQueryAdd Add a value to the HTTP query named key, the query can be
+// added several times to send multiple values.
+// In general, you do not need to care this.
+func WithHTTPQueryAdd(key, value string) Option {
+	return func(options *Options) {
+		if options.QueryValues == nil {
+			options.QueryValues = make(map[string][]string)
+		}
+		options.QueryValues[key] = append(options.QueryValues[key], value)
+	}
+}
+
+// WithStage Specifies the stage of the request, it is sent in the "stage" query.
+// The request with an unsupported stage is rejected by the SDK before sending.
+func WithStage(stage Stage) Option {
+	return func(options *Options) {
+		options.Stage = stage
+		WithHTTPQuery(stageQuery, string(stage))(options)
+	}
+}
+
+// WithCacheable Mark the request as cacheable. When the response cache is
+// enabled in CallerConfig, identical requests within the cache ttl will be
+// answered from the cache instead of hitting the network.
+// Only use it for read requests, such as predict.
+func WithCacheable() Option {
+	return func(options *Options) {
+		options.Cacheable = true
+	}
+}
+
+// WithHedging Send a duplicate request to the second-ranked host if no response
+// is received after delay, such as the p95 latency, and take whichever response
+// arrives first, the other request is cancelled.
+// Only use it for read requests, such as predict.
+func WithHedging(delay time.Duration) Option {
+	return func(options *Options) {
+		options.HedgeDelay = delay
+	}
+}
+
+// WithRetry Send the request at most maxAttempts times, waiting backoff between two
+// attempts, it overrides the retry config of the client for this request.
+// Only use it for idempotent requests, such as the writes with the request id fixed
+// by WithRequestID, maxAttempts 1 disables the retries.
+func WithRetry(maxAttempts int, backoff time.Duration) Option {
+	return func(options *Options) {
+		options.MaxAttempts = maxAttempts
+		options.RetryBackoff = backoff
+	}
+}
+
+// WithCredential Sign the request with the accessKeyID and secretAccessKey instead of the
+// client's, such as the requests of multi-tenant services. The region and service of the
+// client are still used, it only applies to the clients using AK/SK auth.
+func WithCredential(accessKeyID, secretAccessKey string) Option {
+	return func(options *Options) {
+		options.Credential = &Credential{AccessKeyID: accessKeyID, SecretAccessKey: secretAccessKey}
+	}
+}
+
+// WithSessionCredential Sign the request with the temporary credential of accessKeyID,
+// secretAccessKey and sessionToken instead of the client's, see WithCredential
+func WithSessionCredential(accessKeyID, secretAccessKey, sessionToken string) Option {
+	return func(options *Options) {
+		options.Credential = &Credential{
+			AccessKeyID:     accessKeyID,
+			SecretAccessKey: secretAccessKey,
+			SessionToken:    sessionToken,
+		}
+	}
+}
+
+// WithAirAuthToken Sign the request with the air auth token instead of the client's,
+// it only applies to the clients using air auth.
+func WithAirAuthToken(token string) Option {
+	return func(options *Options) {
+		options.AirAuthToken = token
+	}
+}
+
+// WithHTTPMethod Specifies the http method of the request, such as GET, PUT and DELETE,
+// default is POST. The body-less requests are signed with the hash of empty body.
+func WithHTTPMethod(method string) Option {
+	return func(options *Options) {
+		options.Method = method
+	}
+}
+
+// WithAffinityKey Send the requests of the same key, such as the user id, to the same
+// host while it is healthy, so that they hit the same backend cache. The keys of a host
+// are moved to the other hosts only if the host becomes unhealthy.
+// It applies to the host availablers implementing AffinityHostReader, such as the default.
+func WithAffinityKey(key string) Option {
+	return func(options *Options) {
+		options.AffinityKey = key
+	}
+}
+
+// WithResponseMetadata Fill metadata with the status, headers and cost
+// of the response when the request is done, for debugging
+func WithResponseMetadata(metadata *ResponseMetadata) Option {
+	return func(options *Options) {
+		options.ResponseMetadata = metadata
+	}
+}
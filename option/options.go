@@ -1,11 +1,64 @@
 package option
 
-import "time"
+import (
+	"context"
+	"net/http"
+	"time"
+)
 
 type Options struct {
-	Timeout       time.Duration
-	RequestID     string
-	Headers       map[string]string
-	Queries       map[string]string
+	Context   context.Context
+	Timeout   time.Duration
+	RequestID string
+	Headers   map[string]string
+	Queries   map[string]string
+	// The queries with multiple values added by WithHTTPQueryAdd,
+	// they are sent after Queries
+	QueryValues   map[string][]string
 	ServerTimeout time.Duration
+	Cacheable     bool
+	HedgeDelay    time.Duration
+	// The http method of the request, default is POST
+	Method string
+	// The credential overriding the client's, see WithCredential and WithSessionCredential
+	Credential *Credential
+	// The air auth token overriding the client's, see WithAirAuthToken
+	AirAuthToken string
+	// The stage specified by WithStage, it is validated before sending
+	Stage Stage
+	// The max attempts of the request including the first one,
+	// 0 means following MaxRetryTimes of CallerConfig
+	MaxAttempts int
+	// The interval between two attempts when MaxAttempts is set
+	RetryBackoff time.Duration
+	// filled when the request is done if it is not nil
+	ResponseMetadata *ResponseMetadata
+	// The key selecting the host by consistent hashing, see WithAffinityKey
+	AffinityKey string
+}
+
+// ResponseMetadata is the metadata of the response of a request, it is
+// filled by the SDK if the request is sent with WithResponseMetadata
+type ResponseMetadata struct {
+	// The http status of the response, it is 0 if no response is received
+	StatusCode int
+	// The headers of the response, such as the Request-Id echoed by server
+	Headers http.Header
+	// The host the response comes from
+	Host string
+	// The client-measured cost of the request, including the retries
+	Cost time.Duration
+	// Whether the response is answered from the response cache
+	FromCache bool
+	// Whether the response is shared from an identical in-flight request, the
+	// headers of the shared response are not filled
+	Shared bool
+}
+
+// Credential is the AK/SK credential signing a request
+type Credential struct {
+	AccessKeyID     string
+	SecretAccessKey string
+	// The session token of the temporary credential, it is optional
+	SessionToken string
 }
@@ -0,0 +1,25 @@
+package option
+
+// Stage is the stage of the request, which is sent in the "stage" query
+type Stage string
+
+const (
+	StagePre                      Stage = "pre"
+	StagePreSync                  Stage = "pre_sync"
+	StageHistorySync              Stage = "history_sync"
+	StageIncrementalSyncStreaming Stage = "incremental_sync_streaming"
+	StageIncrementalSyncDaily     Stage = "incremental_sync_daily"
+)
+
+// the name of the query carrying the stage
+const stageQuery = "stage"
+
+// Valid reports whether the stage is supported by the server
+func (s Stage) Valid() bool {
+	switch s {
+	case StagePre, StagePreSync, StageHistorySync, StageIncrementalSyncStreaming, StageIncrementalSyncDaily:
+		return true
+	default:
+		return false
+	}
+}
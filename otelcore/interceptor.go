@@ -0,0 +1,152 @@
+// Package otelcore emits the request count and cost metrics of core.HTTPClient through
+// OpenTelemetry, and traces each request by a span. It is a separate module, so that the
+// users of core do not depend on OpenTelemetry
+package otelcore
+
+import (
+	"context"
+	"time"
+
+	core "github.com/byteplus-sdk/byteplus-sdk-go-rec-core"
+	"github.com/byteplus-sdk/byteplus-sdk-go-rec-core/logs"
+	"github.com/valyala/fasthttp"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/metric"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/trace"
+)
+
+const (
+	instrumentationName = "github.com/byteplus-sdk/byteplus-sdk-go-rec-core/otelcore"
+	// the metrics are named as the ones reported by the metrics package of core
+	metricsKeyRequestCount     = "byteplus.rec.sdk.request.count"
+	metricsKeyRequestTotalCost = "byteplus.rec.sdk.request.total.cost"
+)
+
+// Config the config of NewInterceptor
+type Config struct {
+	// MeterProvider default is the global one of otel.GetMeterProvider
+	MeterProvider metric.MeterProvider
+	// TracerProvider default is the global one of otel.GetTracerProvider
+	TracerProvider trace.TracerProvider
+	// Propagator injects the span of the request into its headers, such as traceparent,
+	// default is the global one of otel.GetTextMapPropagator
+	Propagator propagation.TextMapPropagator
+}
+
+// NewInterceptor returns the interceptor creating a span around each request as a child of
+// the span in the context of option.WithContext, with the request id, host, path and status
+// attributes, and recording the request count and cost metrics. The span is propagated to
+// the server by the request headers. It is registered by
+// core.NewHTTPClientBuilder().ContextInterceptors
+func NewInterceptor(projectID string, config *Config) core.ContextInterceptor {
+	if config == nil {
+		config = &Config{}
+	}
+	meterProvider := config.MeterProvider
+	if meterProvider == nil {
+		meterProvider = otel.GetMeterProvider()
+	}
+	tracerProvider := config.TracerProvider
+	if tracerProvider == nil {
+		tracerProvider = otel.GetTracerProvider()
+	}
+	propagator := config.Propagator
+	if propagator == nil {
+		propagator = otel.GetTextMapPropagator()
+	}
+	meter := meterProvider.Meter(instrumentationName)
+	requestCount, err := meter.Int64Counter(metricsKeyRequestCount,
+		metric.WithDescription("the count of http requests"))
+	if err != nil {
+		logs.Warn("create otel counter fail, project_id:%s err:%v", projectID, err)
+	}
+	requestCost, err := meter.Int64Histogram(metricsKeyRequestTotalCost,
+		metric.WithDescription("the cost of http requests"), metric.WithUnit("ms"))
+	if err != nil {
+		logs.Warn("create otel histogram fail, project_id:%s err:%v", projectID, err)
+	}
+	i := &interceptor{
+		projectID:    projectID,
+		tracer:       tracerProvider.Tracer(instrumentationName),
+		propagator:   propagator,
+		requestCount: requestCount,
+		requestCost:  requestCost,
+	}
+	return i.intercept
+}
+
+type interceptor struct {
+	projectID    string
+	tracer       trace.Tracer
+	propagator   propagation.TextMapPropagator
+	requestCount metric.Int64Counter
+	requestCost  metric.Int64Histogram
+}
+
+func (i *interceptor) intercept(next core.ContextInvoker) core.ContextInvoker {
+	return func(ctx context.Context, request *fasthttp.Request, response *fasthttp.Response) error {
+		host, path := string(request.URI().Host()), string(request.URI().Path())
+		ctx, span := i.tracer.Start(ctx, "HTTP "+path, trace.WithSpanKind(trace.SpanKindClient),
+			trace.WithAttributes(
+				attribute.String("request_id", string(request.Header.Peek("Request-Id"))),
+				attribute.String("project_id", i.projectID),
+				attribute.String("host", host),
+				attribute.String("path", path),
+			))
+		// injected before next, so that the headers are signed too
+		i.propagator.Inject(ctx, headerCarrier{header: &request.Header})
+		start := time.Now()
+		err := next(ctx, request, response)
+		cost := time.Since(start)
+		// the response is only read if err is nil, as it may be still in use by an abandoned request
+		status := 0
+		if err == nil {
+			status = response.StatusCode()
+		}
+		span.SetAttributes(attribute.Int("status", status))
+		if err != nil {
+			span.RecordError(err)
+			span.SetStatus(codes.Error, err.Error())
+		} else if status != fasthttp.StatusOK {
+			span.SetStatus(codes.Error, fasthttp.StatusMessage(status))
+		}
+		span.End()
+		attributes := metric.WithAttributes(
+			attribute.String("project_id", i.projectID),
+			attribute.String("host", host),
+			attribute.String("path", path),
+			attribute.Int("status", status),
+		)
+		if i.requestCount != nil {
+			i.requestCount.Add(ctx, 1, attributes)
+		}
+		if i.requestCost != nil {
+			i.requestCost.Record(ctx, cost.Milliseconds(), attributes)
+		}
+		return err
+	}
+}
+
+// headerCarrier adapts the request headers to propagation.TextMapCarrier
+type headerCarrier struct {
+	header *fasthttp.RequestHeader
+}
+
+func (c headerCarrier) Get(key string) string {
+	return string(c.header.Peek(key))
+}
+
+func (c headerCarrier) Set(key, value string) {
+	c.header.Set(key, value)
+}
+
+func (c headerCarrier) Keys() []string {
+	var keys []string
+	c.header.VisitAll(func(key, _ []byte) {
+		keys = append(keys, string(key))
+	})
+	return keys
+}
@@ -0,0 +1,141 @@
+package otelcore
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	core "github.com/byteplus-sdk/byteplus-sdk-go-rec-core"
+	"github.com/byteplus-sdk/byteplus-sdk-go-rec-core/option"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/metric"
+	"go.opentelemetry.io/otel/metric/noop"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/trace"
+)
+
+type recordingMeterProvider struct {
+	noop.MeterProvider
+	counts int64
+}
+
+func (p *recordingMeterProvider) Meter(string, ...metric.MeterOption) metric.Meter {
+	return &recordingMeter{provider: p}
+}
+
+type recordingMeter struct {
+	noop.Meter
+	provider *recordingMeterProvider
+}
+
+func (m *recordingMeter) Int64Counter(string, ...metric.Int64CounterOption) (metric.Int64Counter, error) {
+	return &recordingCounter{provider: m.provider}, nil
+}
+
+type recordingCounter struct {
+	noop.Int64Counter
+	provider *recordingMeterProvider
+}
+
+func (c *recordingCounter) Add(_ context.Context, incr int64, _ ...metric.AddOption) {
+	c.provider.counts += incr
+}
+
+var testSpanContext = trace.NewSpanContext(trace.SpanContextConfig{
+	TraceID:    trace.TraceID{1},
+	SpanID:     trace.SpanID{2},
+	TraceFlags: trace.FlagsSampled,
+})
+
+type recordingTracerProvider struct {
+	spans []*recordingSpan
+}
+
+func (p *recordingTracerProvider) Tracer(string, ...trace.TracerOption) trace.Tracer {
+	return p
+}
+
+func (p *recordingTracerProvider) Start(ctx context.Context, name string,
+	opts ...trace.SpanStartOption) (context.Context, trace.Span) {
+	config := trace.NewSpanStartConfig(opts...)
+	span := &recordingSpan{Span: trace.SpanFromContext(ctx), name: name, attributes: config.Attributes()}
+	p.spans = append(p.spans, span)
+	return trace.ContextWithSpan(ctx, span), span
+}
+
+type recordingSpan struct {
+	trace.Span
+	name       string
+	attributes []attribute.KeyValue
+	ended      bool
+}
+
+func (s *recordingSpan) SpanContext() trace.SpanContext {
+	return testSpanContext
+}
+
+func (s *recordingSpan) SetAttributes(kv ...attribute.KeyValue) {
+	s.attributes = append(s.attributes, kv...)
+}
+
+func (s *recordingSpan) End(...trace.SpanEndOption) {
+	s.ended = true
+}
+
+func TestNewInterceptor(t *testing.T) {
+	traceparents := make(chan string, 1)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/predict" {
+			traceparents <- r.Header.Get("traceparent")
+		}
+		w.WriteHeader(http.StatusBadGateway)
+	}))
+	defer server.Close()
+	host := strings.TrimPrefix(server.URL, "http://")
+	meterProvider, tracerProvider := &recordingMeterProvider{}, &recordingTracerProvider{}
+	client, err := core.NewHTTPClientBuilder().
+		ProjectID("project").
+		TenantID("tenant").
+		AirAuthToken("token").
+		UseAirAuth(true).
+		Region(&core.RegionConfig{Hosts: []string{host}}).
+		Schema("http").
+		Hosts([]string{host}).
+		ContextInterceptors(NewInterceptor("project", &Config{
+			MeterProvider:  meterProvider,
+			TracerProvider: tracerProvider,
+			Propagator:     propagation.TraceContext{},
+		})).
+		Build()
+	if err != nil {
+		t.Fatalf("Build() error = %v", err)
+	}
+	defer client.Shutdown()
+	_, _ = client.DoRawRequest("/predict", "application/json", []byte("{}"), option.Conv2Options(
+		option.WithRequestID("req"), option.WithTimeout(time.Second)))
+	if meterProvider.counts != 1 {
+		t.Errorf("request count = %d, want 1", meterProvider.counts)
+	}
+	if len(tracerProvider.spans) != 1 || !tracerProvider.spans[0].ended {
+		t.Fatalf("spans = %v, want an ended span", tracerProvider.spans)
+	}
+	got := make(map[string]string)
+	for _, kv := range tracerProvider.spans[0].attributes {
+		got[string(kv.Key)] = kv.Value.Emit()
+	}
+	want := map[string]string{"request_id": "req", "project_id": "project",
+		"host": host, "path": "/predict", "status": "502"}
+	for key, value := range want {
+		if got[key] != value {
+			t.Errorf("span attribute %s = %s, want %s", key, got[key], value)
+		}
+	}
+	wantTraceparent := "00-" + testSpanContext.TraceID().String() + "-" +
+		testSpanContext.SpanID().String() + "-01"
+	if traceparent := <-traceparents; traceparent != wantTraceparent {
+		t.Errorf("traceparent = %s, want %s", traceparent, wantTraceparent)
+	}
+}
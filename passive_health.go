@@ -0,0 +1,88 @@
+package core
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/valyala/fasthttp"
+)
+
+const (
+	// the outcomes of the live requests in the duration are counted into the score
+	defaultPassiveWindow = 30 * time.Second
+	// the buckets of passiveWindow, the expired bucket is reset as a whole
+	passiveWindowBuckets = 10
+	// the failure rate of the live requests is not counted until the requests are enough
+	minPassiveRequests = 10
+)
+
+// passiveWindow counts the outcomes of the live requests of the recent duration, it is
+// separated from the ping window of fixed size, so that the burst of traffic does not
+// flush the ping results. It should be accessed with the lock of pingHostAvailabler held
+type passiveWindow struct {
+	bucketDuration time.Duration
+	buckets        [passiveWindowBuckets]passiveBucket
+}
+
+type passiveBucket struct {
+	// the index of the bucket duration since epoch
+	index    int64
+	total    int
+	failures int
+}
+
+func newPassiveWindow(duration time.Duration) *passiveWindow {
+	bucketDuration := duration / passiveWindowBuckets
+	if bucketDuration <= 0 {
+		bucketDuration = time.Millisecond
+	}
+	return &passiveWindow{bucketDuration: bucketDuration}
+}
+
+func (w *passiveWindow) put(success bool, now time.Time) {
+	index := now.UnixNano() / int64(w.bucketDuration)
+	bucket := &w.buckets[index%passiveWindowBuckets]
+	if bucket.index != index {
+		*bucket = passiveBucket{index: index}
+	}
+	bucket.total++
+	if !success {
+		bucket.failures++
+	}
+}
+
+// failureRate returns the failure rate and count of the requests in the window
+func (w *passiveWindow) failureRate(now time.Time) (float64, int) {
+	index := now.UnixNano() / int64(w.bucketDuration)
+	total, failures := 0, 0
+	for _, bucket := range w.buckets {
+		if bucket.index > index-passiveWindowBuckets && bucket.index <= index {
+			total += bucket.total
+			failures += bucket.failures
+		}
+	}
+	if total == 0 {
+		return 0, 0
+	}
+	return float64(failures) / float64(total), total
+}
+
+// passiveResult returns the outcome of the request reporting to the host availabler,
+// and whether it is reported. The requests failed by the client are not the failures
+// of host: the ones not sent by the transport, such as rejected by the interceptors,
+// and the ones timed out or cancelled by the client. The response must not be read
+// if err is not nil, as it may be still in use by an abandoned request
+func passiveResult(sent bool, err error, response *fasthttp.Response) (success, reported bool) {
+	if !sent {
+		return false, false
+	}
+	if err == nil {
+		return response.StatusCode() < fasthttp.StatusInternalServerError, true
+	}
+	if errors.Is(err, fasthttp.ErrTimeout) || errors.Is(err, context.Canceled) ||
+		errors.Is(err, context.DeadlineExceeded) {
+		return false, false
+	}
+	return false, true
+}
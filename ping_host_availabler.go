@@ -1,7 +1,9 @@
 package core
 
 import (
+	"crypto/tls"
 	"fmt"
+	"sync"
 	"time"
 
 	"github.com/byteplus-sdk/byteplus-sdk-go-rec-core/logs"
@@ -10,10 +12,12 @@ import (
 
 const (
 	defaultPingURLFormat     = "%s://%s/predict/api/ping"
+	defaultPingSchema        = "http"
 	defaultWindowSize        = 60
 	defaultPingTimeout       = 300 * time.Millisecond
 	defaultPingInterval      = time.Second
 	defaultFetchHostInterval = 10 * time.Second
+	defaultPingConcurrency   = 8
 )
 
 type PingHostAvailablerConfig struct {
@@ -21,6 +25,14 @@ type PingHostAvailablerConfig struct {
 	// {} will be replaced by schema which set in context
 	// %s will be dynamically formatted by hosts
 	PingUrlFormat string
+	// PathPingUrlFormats is the ping url format of the hosts serving the paths of the prefix,
+	// such as the data api and predict api served by different clusters. The host serving
+	// several paths is available only if all the pings succeed, default is PingUrlFormat
+	PathPingUrlFormats map[string]string
+	// the schema used to ping hosts, it should be the same as the schema of requests,
+	// so that the ping probes the same endpoint as the requests, default is http.
+	// the port of endpoint can be set in hosts, such as "byteplus.com:8443"
+	Schema string
 	// record the window size of each host's test status
 	WindowSize int
 	// timeout for requesting hosts
@@ -29,29 +41,131 @@ type PingHostAvailablerConfig struct {
 	PingInterval time.Duration
 	// Frequency of pulling hosts
 	FetchHostInterval time.Duration
+	// StateStore saves the host config and ping stats, and restores them on start, so that
+	// the client does not start with the default hosts when the host server is broken,
+	// such as FileStateStore. Default is not to save
+	StateStore StateStore
+	// Dial the dial of pinging hosts and fetching host config, such as the proxy dial
+	// of NewProxyDial, default is to dial directly
+	Dial fasthttp.DialFunc
+	// TLSConfig the tls config of pinging hosts and fetching host config,
+	// such as with the client certificates of mTLS
+	TLSConfig *tls.Config
+	// SelectionStrategy is how GetHost selects the host from the hosts ordered by score,
+	// default is HostSelectionTopOne
+	SelectionStrategy HostSelectionStrategy
+	// Scoring is how the hosts are scored by the ping results, default is HostScoringAvailability
+	Scoring HostScoring
+	// LatencyDecay is the weight of the latest ping latency in the EWMA latency of
+	// HostScoringLatency, in (0, 1], default is 0.3
+	LatencyDecay float64
+	// HostScorer takes place of scoring hosts by pings, such as scoring by the health
+	// data of the service mesh, default is to score by pings
+	HostScorer HostScorer
+	// CooldownFailures is how many consecutive failures quarantine a host, the quarantined
+	// host is excluded from selection for CooldownDuration then probed again, default is
+	// 0 which disables the cooldown
+	CooldownFailures int
+	// CooldownDuration is how long the host is quarantined, default is 30s
+	CooldownDuration time.Duration
+	// HostResolver discovers the hosts in place of fetching them from the host server on
+	// FetchHostInterval, such as SRVHostResolver. It is not used if the hosts are specified
+	HostResolver HostResolver
+	// FetchRequestSigner signs the requests fetching hosts from the host server, the hosts
+	// are fetched by Schema, default is anonymous. The client signs them with its auth
+	FetchRequestSigner FetchRequestSigner
+	// FetchHostsPath is the path of fetching hosts from the host server,
+	// default is /data/api/sdk/host
+	FetchHostsPath string
+	// FetchHostsQueries are the extra query params of fetching hosts, such as the cluster
+	// and env, project_id is always set by the availabler
+	FetchHostsQueries map[string]string
+	// FetchHostsHost is the host of the host server, default is to try the hosts in turn
+	FetchHostsHost string
+	// IntervalJitter randomizes PingInterval and FetchHostInterval by the ratio in [0, 0.5],
+	// so that the clients of a deployment do not ping at the same time, default is 0.1,
+	// negative means no jitter
+	IntervalJitter float64
+	// PingConcurrency is how many hosts are pinged concurrently in a scoring round, default is 8
+	PingConcurrency int
+	// PingRoundTimeout bounds a scoring round, the hosts not pinged before it are skipped
+	// in the round, default is PingInterval
+	PingRoundTimeout time.Duration
+	// HostTiers tags the hosts with the priority tier, the hosts of the lower tier are
+	// preferred, such as 0 for the hosts of the same region and 1 for the cross-region
+	// fallback. The hosts not tagged are of tier 0, default is no tier
+	HostTiers map[string]int
+	// TierFailoverThreshold is the failure rate of the best host of the preferred tier
+	// over which the hosts of the next healthy tier are preferred, default is 0.1
+	TierFailoverThreshold float64
+	// PassiveWindow is the duration of the outcomes of live requests counted into the
+	// score of host, see RequestResultReporter, default is 30s
+	PassiveWindow time.Duration
+	// MainHostHeader pings any host with the Host header and TLS server name of the main
+	// host, see CallerConfig.MainHostHeader, the client sets it by its CallerConfig
+	MainHostHeader bool
 }
 
 type pingHostAvailabler struct {
 	*HostAvailablerBase
 	config        *PingHostAvailablerConfig
+	lock          sync.Mutex
 	hostWindowMap map[string]*window
-	httpCli       *fasthttp.Client
+	// the outcomes of the live requests of each host
+	hostPassiveMap map[string]*passiveWindow
+	// the EWMA ping latency of each host
+	hostLatencyMap map[string]*ewmaLatency
+	// the latest ping of each host
+	hostPingStateMap map[string]*hostPingState
+	// the time of the latest ping result received from outside for each host
+	receivedPingTimeMap map[string]time.Time
+	httpCli             Transport
+	cooldown            *hostCooldown
 }
 
 func NewPingHostAvailabler(hosts []string, projectID string,
 	config *PingHostAvailablerConfig, mainHost string, skipFetchHosts bool) (HostAvailabler, error) {
+	config = fillDefaultConfig(config)
 	hostAvailabler := &pingHostAvailabler{
-		config: fillDefaultConfig(config),
+		config: config,
 		httpCli: &fasthttp.Client{
+			Dial:                config.Dial,
+			TLSConfig:           config.TLSConfig,
 			MaxIdleConnDuration: defaultKeepAliveDuration,
 		},
-		hostWindowMap: make(map[string]*window, len(hosts)),
+		hostWindowMap:       make(map[string]*window, len(hosts)),
+		hostLatencyMap:      make(map[string]*ewmaLatency, len(hosts)),
+		hostPingStateMap:    make(map[string]*hostPingState, len(hosts)),
+		receivedPingTimeMap: make(map[string]time.Time, len(hosts)),
+		cooldown:            newHostCooldown(projectID, config.CooldownFailures, config.CooldownDuration),
+	}
+	// the pings probe the same gateway route as the requests
+	if config.MainHostHeader {
+		hostAvailabler.httpCli, _ = newMainHostTransport(mainHost, hostAvailabler.httpCli)
 	}
 	hostAvailabler.HostAvailablerBase = &HostAvailablerBase{
-		projectID:      projectID,
-		hostScorer:     hostAvailabler,
-		skipFetchHosts: skipFetchHosts,
-		mainHost:       mainHost,
+		projectID:         projectID,
+		hostScorer:        hostAvailabler,
+		skipFetchHosts:    skipFetchHosts,
+		mainHost:          mainHost,
+		stateStore:        hostAvailabler.config.StateStore,
+		selectionStrategy: config.SelectionStrategy,
+		hostResolver:      config.HostResolver,
+		fetchSchema:       config.Schema,
+		fetchHostsSigner:  config.FetchRequestSigner,
+		fetchPath:         config.FetchHostsPath,
+		fetchQueries:      config.FetchHostsQueries,
+		fetchHost:         config.FetchHostsHost,
+		intervalJitter:    config.IntervalJitter,
+		hostTiers:         config.HostTiers,
+		tierThreshold:     config.TierFailoverThreshold,
+		fetchHostsHTTPClient: &fasthttp.Client{
+			Dial:      config.Dial,
+			TLSConfig: config.TLSConfig,
+		},
+	}
+	if config.HostScorer != nil {
+		hostAvailabler.hostScorer = config.HostScorer
 	}
 	err := hostAvailabler.Init(hosts, hostAvailabler.config.FetchHostInterval, hostAvailabler.config.PingInterval)
 	if err != nil {
@@ -67,6 +181,9 @@ func fillDefaultConfig(config *PingHostAvailablerConfig) *PingHostAvailablerConf
 	if config.PingUrlFormat == "" {
 		config.PingUrlFormat = defaultPingURLFormat
 	}
+	if config.Schema == "" {
+		config.Schema = defaultPingSchema
+	}
 	if config.PingTimeout <= 0 {
 		config.PingTimeout = defaultPingTimeout
 	}
@@ -79,6 +196,27 @@ func fillDefaultConfig(config *PingHostAvailablerConfig) *PingHostAvailablerConf
 	if config.FetchHostInterval <= 0 {
 		config.FetchHostInterval = defaultFetchHostInterval
 	}
+	if config.Scoring == "" {
+		config.Scoring = HostScoringAvailability
+	}
+	if config.PingConcurrency <= 0 {
+		config.PingConcurrency = defaultPingConcurrency
+	}
+	if config.PingRoundTimeout <= 0 {
+		config.PingRoundTimeout = config.PingInterval
+	}
+	if config.IntervalJitter == 0 {
+		config.IntervalJitter = defaultIntervalJitter
+	}
+	if config.TierFailoverThreshold <= 0 {
+		config.TierFailoverThreshold = defaultTierFailoverThreshold
+	}
+	if config.PassiveWindow <= 0 {
+		config.PassiveWindow = defaultPassiveWindow
+	}
+	if config.LatencyDecay <= 0 || config.LatencyDecay > 1 {
+		config.LatencyDecay = defaultLatencyDecay
+	}
 	return config
 }
 
@@ -89,22 +227,156 @@ func (receiver *pingHostAvailabler) ScoreHosts(hosts []string) []*HostAvailabili
 		result[0] = &HostAvailabilityScore{Host: hosts[0], Score: 0.0}
 		return result
 	}
+	pingingHosts := make([]string, 0, len(hosts))
 	for _, host := range hosts {
-		window, exist := receiver.hostWindowMap[host]
-		if !exist {
-			window = newWindow(receiver.config.WindowSize)
-			receiver.hostWindowMap[host] = window
+		// skip the host which is just pinged by others, its result is already in window
+		if receiver.isRecentlyPingedOutside(host) {
+			continue
+		}
+		// the quarantined host is probed again after the quarantine expires
+		if receiver.cooldown.isQuarantined(host) {
+			continue
 		}
-		window.put(Ping(receiver.projectID, receiver.httpCli, receiver.config.PingUrlFormat,
-			"http", host, receiver.config.PingTimeout))
+		pingingHosts = append(pingingHosts, host)
 	}
+	receiver.pingHosts(pingingHosts)
+	receiver.lock.Lock()
+	defer receiver.lock.Unlock()
 	for i, host := range hosts {
-		score := 1 - receiver.hostWindowMap[host].failureRate()
-		result[i] = &HostAvailabilityScore{host, score}
+		score, availability := receiver.score(host)
+		if receiver.cooldown.isQuarantined(host) {
+			score, availability = quarantinedHostScore, quarantinedHostScore
+		}
+		result[i] = &HostAvailabilityScore{Host: host, Score: score,
+			availability: availability, hasAvailability: true}
 	}
 	return result
 }
 
+// score returns the score of host ordering the hosts, and its success rate which the
+// health checks apply to. It should be called with lock held
+func (receiver *pingHostAvailabler) score(host string) (float64, float64) {
+	availability := 1 - receiver.getOrCreateWindow(host).failureRate()
+	// the live requests lower the score once they fail more than the pings
+	if passive, exist := receiver.hostPassiveMap[host]; exist {
+		failureRate, count := passive.failureRate(time.Now())
+		if count >= minPassiveRequests && 1-failureRate < availability {
+			availability = 1 - failureRate
+		}
+	}
+	latency, exist := receiver.hostLatencyMap[host]
+	if !exist {
+		latency = &ewmaLatency{}
+	}
+	switch receiver.config.Scoring {
+	case HostScoringLatency:
+		return latency.score(receiver.config.PingTimeout), availability
+	case HostScoringAvailabilityLatency:
+		return availability * latency.score(receiver.config.PingTimeout), availability
+	default:
+		return availability, availability
+	}
+}
+
+func (receiver *pingHostAvailabler) putPingLatency(host string, latency time.Duration) {
+	receiver.lock.Lock()
+	defer receiver.lock.Unlock()
+	ewma, exist := receiver.hostLatencyMap[host]
+	if !exist {
+		ewma = &ewmaLatency{}
+		receiver.hostLatencyMap[host] = ewma
+	}
+	ewma.put(latency, receiver.config.LatencyDecay)
+}
+
+// ReceivePingResult puts the ping result observed outside into the host's window,
+// and the next scoring round will not ping the host again
+func (receiver *pingHostAvailabler) ReceivePingResult(host string, success bool) {
+	receiver.putPingResult(host, success)
+	receiver.lock.Lock()
+	receiver.receivedPingTimeMap[host] = time.Now()
+	receiver.lock.Unlock()
+}
+
+// ReportResult puts the outcome of the live request into the host's passive window of
+// PassiveWindow, so that the failures of production traffic lower the score before the
+// pings catch them. The latency of request depends on the path and payload, only the
+// failure counts as ping timeout in the latency scoring
+func (receiver *pingHostAvailabler) ReportResult(host, path string, success bool, latency time.Duration) {
+	receiver.lock.Lock()
+	_, exist := receiver.hostWindowMap[host]
+	if exist {
+		if receiver.hostPassiveMap == nil {
+			receiver.hostPassiveMap = make(map[string]*passiveWindow)
+		}
+		passive, ok := receiver.hostPassiveMap[host]
+		if !ok {
+			passive = newPassiveWindow(receiver.config.PassiveWindow)
+			receiver.hostPassiveMap[host] = passive
+		}
+		passive.put(success, time.Now())
+	}
+	receiver.lock.Unlock()
+	// the host not scored by the availabler, such as the sidecar, is ignored
+	if !exist {
+		return
+	}
+	receiver.cooldown.put(host, success)
+	if !success {
+		receiver.putPingLatency(host, receiver.config.PingTimeout)
+		logs.Debug("request fails, project_id:%s host:%s path:%s cost:%s",
+			receiver.projectID, host, path, latency)
+	}
+}
+
+func (receiver *pingHostAvailabler) isRecentlyPingedOutside(host string) bool {
+	receiver.lock.Lock()
+	defer receiver.lock.Unlock()
+	receivedTime, exist := receiver.receivedPingTimeMap[host]
+	return exist && time.Since(receivedTime) < receiver.config.PingInterval
+}
+
+func (receiver *pingHostAvailabler) putPingResult(host string, success bool) {
+	receiver.cooldown.put(host, success)
+	receiver.lock.Lock()
+	defer receiver.lock.Unlock()
+	receiver.getOrCreateWindow(host).put(success)
+}
+
+// getOrCreateWindow should be called with lock held
+func (receiver *pingHostAvailabler) getOrCreateWindow(host string) *window {
+	window, exist := receiver.hostWindowMap[host]
+	if !exist {
+		window = newWindow(receiver.config.WindowSize)
+		receiver.hostWindowMap[host] = window
+	}
+	return window
+}
+
+func (receiver *pingHostAvailabler) hostFailureRates() map[string]float64 {
+	receiver.lock.Lock()
+	defer receiver.lock.Unlock()
+	result := make(map[string]float64, len(receiver.hostWindowMap))
+	for host, window := range receiver.hostWindowMap {
+		result[host] = window.failureRate()
+	}
+	return result
+}
+
+// restoreHostFailureRates fills the window of each host with failures of the rate
+func (receiver *pingHostAvailabler) restoreHostFailureRates(rates map[string]float64) {
+	receiver.lock.Lock()
+	defer receiver.lock.Unlock()
+	for host, rate := range rates {
+		window := newWindow(receiver.config.WindowSize)
+		failureCount := int(rate*float64(window.size) + 0.5)
+		for i := 0; i < failureCount && i < window.size; i++ {
+			window.put(false)
+		}
+		receiver.hostWindowMap[host] = window
+	}
+}
+
 func newWindow(size int) *window {
 	result := &window{
 		size:         size,
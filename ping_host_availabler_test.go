@@ -0,0 +1,71 @@
+package core
+
+import (
+	"crypto/tls"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/valyala/fasthttp"
+)
+
+func TestPingHostAvailabler_ReceivePingResult(t *testing.T) {
+	var pings int64
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt64(&pings, 1)
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}))
+	defer server.Close()
+	host := strings.TrimPrefix(server.URL, "http://")
+	availabler := &pingHostAvailabler{
+		HostAvailablerBase:  &HostAvailablerBase{projectID: "1"},
+		config:              fillDefaultConfig(&PingHostAvailablerConfig{WindowSize: 2}),
+		httpCli:             &fasthttp.Client{},
+		hostWindowMap:       make(map[string]*window),
+		hostLatencyMap:      make(map[string]*ewmaLatency),
+		receivedPingTimeMap: make(map[string]time.Time),
+	}
+	availabler.hostConfig.Store(map[string][]string{"*": {host}})
+	airAuthConfig, _ := fillDefaultAirAuthConfig(nil)
+	c := newHTTPCaller("project", "tenant", true, "token", airAuthConfig, credential{},
+		availabler, &CallerConfig{}, "http", false, nil)
+	// the heartbeat of caller pings the host, and shares the failure with the availabler
+	c.heartbeat()
+	if got := atomic.LoadInt64(&pings); got != 1 {
+		t.Fatalf("heartbeat pings = %d, want 1", got)
+	}
+	// the host just pinged by heartbeat is not pinged again
+	scores := availabler.ScoreHosts([]string{host, "127.0.0.1:1"})
+	if got := atomic.LoadInt64(&pings); got != 1 {
+		t.Errorf("pings after scoring = %d, want the heartbeat result reused", got)
+	}
+	if scores[0].Score != 0.5 {
+		t.Errorf("score = %v, want the failed heartbeat counted", scores[0].Score)
+	}
+}
+
+func TestPingHostAvailabler_schema(t *testing.T) {
+	server := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write([]byte("pong"))
+	}))
+	defer server.Close()
+	host := strings.TrimPrefix(server.URL, "https://")
+	for _, schema := range []string{"https", "http"} {
+		availabler := &pingHostAvailabler{
+			HostAvailablerBase:  &HostAvailablerBase{projectID: "1"},
+			config:              fillDefaultConfig(&PingHostAvailablerConfig{WindowSize: 2, Schema: schema}),
+			httpCli:             &fasthttp.Client{TLSConfig: &tls.Config{InsecureSkipVerify: true}},
+			hostWindowMap:       make(map[string]*window),
+			hostLatencyMap:      make(map[string]*ewmaLatency),
+			receivedPingTimeMap: make(map[string]time.Time),
+		}
+		scores := availabler.ScoreHosts([]string{host, "127.0.0.1:1"})
+		// the https endpoint is only healthy for the pings of https
+		if want := map[string]float64{"https": 1, "http": 0.5}[schema]; scores[0].Score != want {
+			t.Errorf("score of %s ping = %v, want %v", schema, scores[0].Score, want)
+		}
+	}
+}
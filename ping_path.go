@@ -0,0 +1,124 @@
+package core
+
+import (
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/byteplus-sdk/byteplus-sdk-go-rec-core/logs"
+)
+
+// pingHosts pings hosts concurrently by at most PingConcurrency workers, and returns when
+// all of them are pinged or the round times out. The pings are bounded by the round
+// deadline, the hosts not pinged before it are skipped, so that the workers are done
+// when pingHosts returns
+func (receiver *pingHostAvailabler) pingHosts(hosts []string) {
+	if len(hosts) == 0 {
+		return
+	}
+	// the host config being scored contains the hosts not in use yet, it is read with
+	// updateLock held by the scoring, the workers only read the snapshot of it
+	hostConfig := receiver.scoringHostConfig
+	if hostConfig == nil {
+		hostConfig = receiver.loadHostConfig()
+	}
+	deadline := time.Now().Add(receiver.config.PingRoundTimeout)
+	queue := make(chan string, len(hosts))
+	for _, host := range hosts {
+		queue <- host
+	}
+	close(queue)
+	workers := receiver.config.PingConcurrency
+	if workers > len(hosts) {
+		workers = len(hosts)
+	}
+	var wg sync.WaitGroup
+	wg.Add(workers)
+	for i := 0; i < workers; i++ {
+		AsyncExecute(func() {
+			defer wg.Done()
+			for host := range queue {
+				if !time.Now().Before(deadline) {
+					continue
+				}
+				receiver.pingAndRecord(hostConfig, host, deadline)
+			}
+		})
+	}
+	wg.Wait()
+	if !time.Now().Before(deadline) {
+		logs.Warn("ping hosts round timeout, project_id:%s hosts:%d timeout:%s",
+			receiver.projectID, len(hosts), receiver.config.PingRoundTimeout)
+	}
+}
+
+func (receiver *pingHostAvailabler) pingAndRecord(hostConfig map[string][]string, host string,
+	deadline time.Time) {
+	timeout := receiver.config.PingTimeout
+	if untilDeadline := time.Until(deadline); untilDeadline < timeout {
+		timeout = untilDeadline
+	}
+	start := time.Now()
+	err := receiver.pingHost(hostConfig, host, timeout)
+	latency := time.Since(start)
+	// the ping cut by the round deadline tells nothing about host, it is skipped
+	// as the hosts not pinged in the round
+	if err != nil && timeout < receiver.config.PingTimeout && !time.Now().Before(deadline) {
+		return
+	}
+	receiver.putPingState(host, latency, err)
+	receiver.putPingResult(host, err == nil)
+	if err != nil {
+		latency = receiver.config.PingTimeout
+	}
+	receiver.putPingLatency(host, latency)
+}
+
+// pingHost pings host by the ping url formats of the paths it serves in hostConfig
+func (receiver *pingHostAvailabler) pingHost(hostConfig map[string][]string, host string,
+	timeout time.Duration) error {
+	for _, format := range receiver.pingURLFormats(hostConfig, host) {
+		if err := ping(receiver.projectID, receiver.httpCli, format, receiver.config.Schema,
+			host, timeout); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// pingURLFormats returns the distinct ping url formats of the paths served by host
+func (receiver *pingHostAvailabler) pingURLFormats(hostConfig map[string][]string, host string) []string {
+	if len(receiver.config.PathPingUrlFormats) == 0 {
+		return []string{receiver.config.PingUrlFormat}
+	}
+	formatSet := make(map[string]bool)
+	for path, hosts := range hostConfig {
+		for _, pathHost := range hosts {
+			if pathHost == host {
+				formatSet[receiver.pathPingURLFormat(path)] = true
+				break
+			}
+		}
+	}
+	if len(formatSet) == 0 {
+		return []string{receiver.config.PingUrlFormat}
+	}
+	formats := make([]string, 0, len(formatSet))
+	for format := range formatSet {
+		formats = append(formats, format)
+	}
+	sort.Strings(formats)
+	return formats
+}
+
+// pathPingURLFormat returns the ping url format of the longest prefix matching path
+func (receiver *pingHostAvailabler) pathPingURLFormat(path string) string {
+	result, matched := receiver.config.PingUrlFormat, -1
+	for prefix, format := range receiver.config.PathPingUrlFormats {
+		if strings.HasPrefix(path, prefix) && len(prefix) > matched {
+			result, matched = format, len(prefix)
+		}
+	}
+	return result
+}
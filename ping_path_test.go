@@ -0,0 +1,103 @@
+package core
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/valyala/fasthttp"
+)
+
+func TestPingHostAvailabler_pathPingUrlFormats(t *testing.T) {
+	newServer := func(dataHealthy bool) string {
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if r.URL.Path == "/data/health" && !dataHealthy {
+				w.WriteHeader(http.StatusServiceUnavailable)
+				return
+			}
+			_, _ = w.Write([]byte("pong"))
+		}))
+		t.Cleanup(server.Close)
+		return strings.TrimPrefix(server.URL, "http://")
+	}
+	healthy, dataDown := newServer(true), newServer(false)
+	tests := []struct {
+		name         string
+		formats      map[string]string
+		wantDataDown float64
+	}{
+		{"default", nil, 1},
+		{"data_path", map[string]string{"Write": "%s://%s/data/health"}, 0.5},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			availabler := &pingHostAvailabler{
+				HostAvailablerBase: &HostAvailablerBase{projectID: "1"},
+				config: fillDefaultConfig(&PingHostAvailablerConfig{WindowSize: 2,
+					PathPingUrlFormats: tt.formats}),
+				httpCli:             &fasthttp.Client{},
+				hostWindowMap:       make(map[string]*window),
+				hostLatencyMap:      make(map[string]*ewmaLatency),
+				receivedPingTimeMap: make(map[string]time.Time),
+			}
+			availabler.scoringHostConfig = map[string][]string{
+				"*":          {healthy, dataDown},
+				"WriteUsers": {healthy, dataDown},
+			}
+			scores := availabler.ScoreHosts([]string{healthy, dataDown})
+			if scores[0].Score != 1 || scores[1].Score != tt.wantDataDown {
+				t.Errorf("ScoreHosts() = %v, want the data down host scored %v", scores, tt.wantDataDown)
+			}
+			if got := availabler.pathPingURLFormat("Predict"); got != defaultPingURLFormat {
+				t.Errorf("pathPingURLFormat() of unmatched path = %s, want default", got)
+			}
+		})
+	}
+}
+
+func TestPingHostAvailabler_pingHosts(t *testing.T) {
+	hosts := make([]string, 4)
+	for i := range hosts {
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			time.Sleep(200 * time.Millisecond)
+			_, _ = w.Write([]byte("pong"))
+		}))
+		defer server.Close()
+		hosts[i] = strings.TrimPrefix(server.URL, "http://")
+	}
+	newAvailabler := func(concurrency int, roundTimeout time.Duration) *pingHostAvailabler {
+		return &pingHostAvailabler{
+			HostAvailablerBase: &HostAvailablerBase{projectID: "1"},
+			config: fillDefaultConfig(&PingHostAvailablerConfig{PingTimeout: time.Second,
+				PingConcurrency: concurrency, PingRoundTimeout: roundTimeout}),
+			httpCli:             &fasthttp.Client{},
+			hostWindowMap:       make(map[string]*window),
+			hostLatencyMap:      make(map[string]*ewmaLatency),
+			receivedPingTimeMap: make(map[string]time.Time),
+		}
+	}
+	start := time.Now()
+	newAvailabler(len(hosts), time.Second).pingHosts(hosts)
+	if cost := time.Since(start); cost > 600*time.Millisecond {
+		t.Errorf("concurrent pingHosts() cost %v, want about one ping", cost)
+	}
+	start = time.Now()
+	availabler := newAvailabler(1, 300*time.Millisecond)
+	availabler.pingHosts(hosts)
+	if cost := time.Since(start); cost > 600*time.Millisecond {
+		t.Errorf("pingHosts() cost %v, want bounded by round timeout", cost)
+	}
+	// the workers are done when pingHosts returns, the ping cut by the deadline is skipped
+	availabler.lock.Lock()
+	pinged := len(availabler.hostWindowMap)
+	availabler.lock.Unlock()
+	time.Sleep(300 * time.Millisecond)
+	availabler.lock.Lock()
+	defer availabler.lock.Unlock()
+	if pinged != 1 || len(availabler.hostWindowMap) != pinged {
+		t.Errorf("pinged hosts = %d then %d, want the only one pinged before deadline",
+			pinged, len(availabler.hostWindowMap))
+	}
+}
@@ -0,0 +1,27 @@
+package core
+
+import (
+	"context"
+
+	"github.com/byteplus-sdk/byteplus-sdk-go-rec-core/coreerr"
+)
+
+// ErrQuotaRejected is returned without sending the request, when QuotaCoordinator
+// rejects the request because the quota shared by SDK instances is used up
+var ErrQuotaRejected = newClassifiedError("request is rejected by quota coordinator", coreerr.Throttled)
+
+// QuotaCoordinator is consulted before each request is sent, so that the SDK instances
+// of a large fleet can share one tenant quota, such as by a counter in Redis or memcached
+type QuotaCoordinator interface {
+	// Acquire asks for the quota of one request of path, returns false to reject it.
+	// The request is allowed if err is not nil, so that the outage of coordinator
+	// does not stop the traffic
+	Acquire(ctx context.Context, path string) (bool, error)
+}
+
+// QuotaCoordinatorFunc adapts a function to QuotaCoordinator
+type QuotaCoordinatorFunc func(ctx context.Context, path string) (bool, error)
+
+func (f QuotaCoordinatorFunc) Acquire(ctx context.Context, path string) (bool, error) {
+	return f(ctx, path)
+}
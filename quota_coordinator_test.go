@@ -0,0 +1,40 @@
+package core
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/byteplus-sdk/byteplus-sdk-go-rec-core/option"
+)
+
+func TestHTTPCaller_acquireQuota(t *testing.T) {
+	tests := []struct {
+		name    string
+		allowed bool
+		err     error
+		want    bool
+	}{
+		{name: "allowed", allowed: true, want: true},
+		{name: "rejected", allowed: false, want: false},
+		{name: "coordinator error", allowed: false, err: errors.New("redis down"), want: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var gotPath string
+			c := &httpCaller{
+				quota: QuotaCoordinatorFunc(func(ctx context.Context, path string) (bool, error) {
+					gotPath = path
+					return tt.allowed, tt.err
+				}),
+			}
+			got := c.acquireQuota("req", "https://byteplus.com/predict?stage=pre", &option.Options{})
+			if got != tt.want {
+				t.Errorf("acquireQuota() = %v, want %v", got, tt.want)
+			}
+			if gotPath != "/predict" {
+				t.Errorf("path = %v, want /predict", gotPath)
+			}
+		})
+	}
+}
@@ -1,7 +1,146 @@
 package core
 
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"sync"
+)
+
 type IRegion interface {
 	GetHosts() []string
 
 	GetAuthRegion() string
 }
+
+// DefaultEnvironment is the environment served by the Hosts of RegionConfig
+const DefaultEnvironment = "prod"
+
+// ErrRegionExists is returned by RegisterRegion when the region has been registered
+var ErrRegionExists = errors.New("region already exists")
+
+// RegionConfig is the hosts and auth region of a region registered by name
+type RegionConfig struct {
+	// The hosts serving the region
+	Hosts []string
+	// The region signing the requests by ak/sk
+	AuthRegion string
+	// The hosts of the other environments of the region, such as staging and sandbox,
+	// keyed by the environment selected by httpClientBuilder.Environment
+	Environments map[string][]string
+}
+
+func (c *RegionConfig) GetHosts() []string {
+	return c.Hosts
+}
+
+func (c *RegionConfig) GetAuthRegion() string {
+	return c.AuthRegion
+}
+
+// ForEnvironment returns the config serving the hosts of environment, the hosts of
+// the region are served if environment is empty or DefaultEnvironment
+func (c *RegionConfig) ForEnvironment(environment string) (*RegionConfig, error) {
+	if environment == "" || environment == DefaultEnvironment {
+		return c, nil
+	}
+	hosts := c.Environments[environment]
+	if len(hosts) == 0 {
+		return nil, fmt.Errorf("environment %s is not configured", environment)
+	}
+	return &RegionConfig{Hosts: hosts, AuthRegion: c.AuthRegion}, nil
+}
+
+var (
+	regionsLock sync.RWMutex
+	regions     = make(map[string]*RegionConfig)
+)
+
+// RegisterRegion registers the config of region, which can be selected by
+// httpClientBuilder.RegionName. It returns ErrRegionExists if the region has
+// been registered, use OverrideRegion to replace it
+func RegisterRegion(region string, config *RegionConfig) error {
+	if err := checkRegionConfig(region, config); err != nil {
+		return err
+	}
+	regionsLock.Lock()
+	defer regionsLock.Unlock()
+	if _, exist := regions[region]; exist {
+		return fmt.Errorf("%w: %s", ErrRegionExists, region)
+	}
+	regions[region] = copyRegionConfig(config)
+	return nil
+}
+
+// OverrideRegion registers the config of region, and replaces the registered one if it exists
+func OverrideRegion(region string, config *RegionConfig) error {
+	if err := checkRegionConfig(region, config); err != nil {
+		return err
+	}
+	regionsLock.Lock()
+	defer regionsLock.Unlock()
+	regions[region] = copyRegionConfig(config)
+	return nil
+}
+
+// DeregisterRegion removes the registered region, and reports whether it exists.
+// The clients built with the region are not affected
+func DeregisterRegion(region string) bool {
+	regionsLock.Lock()
+	defer regionsLock.Unlock()
+	_, exist := regions[region]
+	delete(regions, region)
+	return exist
+}
+
+// ListRegions returns the sorted names of the registered regions
+func ListRegions() []string {
+	regionsLock.RLock()
+	defer regionsLock.RUnlock()
+	names := make([]string, 0, len(regions))
+	for name := range regions {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// GetRegionConfig returns a copy of the config of the registered region,
+// and reports whether the region is registered
+func GetRegionConfig(region string) (*RegionConfig, bool) {
+	regionsLock.RLock()
+	defer regionsLock.RUnlock()
+	config, exist := regions[region]
+	if !exist {
+		return nil, false
+	}
+	return copyRegionConfig(config), true
+}
+
+func checkRegionConfig(region string, config *RegionConfig) error {
+	if region == "" {
+		return errors.New("region name is empty")
+	}
+	if config == nil || len(config.Hosts) == 0 {
+		return fmt.Errorf("hosts of region %s are empty", region)
+	}
+	for environment, hosts := range config.Environments {
+		if len(hosts) == 0 {
+			return fmt.Errorf("hosts of environment %s of region %s are empty", environment, region)
+		}
+	}
+	return nil
+}
+
+// copyRegionConfig copies config, so that the registered config is not modified by the caller
+func copyRegionConfig(config *RegionConfig) *RegionConfig {
+	result := *config
+	result.Hosts = append([]string(nil), config.Hosts...)
+	if config.Environments != nil {
+		result.Environments = make(map[string][]string, len(config.Environments))
+		for environment, hosts := range config.Environments {
+			result.Environments[environment] = append([]string(nil), hosts...)
+		}
+	}
+	return &result
+}
@@ -0,0 +1,118 @@
+package core
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+const (
+	// EnvRegionsFile is the path of the region config file loaded by LoadRegionsFromEnv
+	EnvRegionsFile = "BYTEPLUS_REGIONS_FILE"
+	// the env BYTEPLUS_REGION_<NAME>_HOSTS is the comma separated hosts of the region
+	// named by the lowercase NAME, and BYTEPLUS_REGION_<NAME>_AUTH_REGION is its auth region
+	envRegionPrefix           = "BYTEPLUS_REGION_"
+	envRegionHostsSuffix      = "_HOSTS"
+	envRegionAuthRegionSuffix = "_AUTH_REGION"
+)
+
+// regionsFile is the region config file like:
+//
+//	regions:
+//	  sg:
+//	    hosts: ["rec-ap-singapore-1.byteplusapi.com"]
+//	    auth_region: ap-singapore-1
+//	    environments:
+//	      sandbox: ["rec-sandbox-ap-singapore-1.byteplusapi.com"]
+type regionsFile struct {
+	Regions map[string]*regionFileConfig `json:"regions" yaml:"regions"`
+}
+
+type regionFileConfig struct {
+	Hosts      []string `json:"hosts" yaml:"hosts"`
+	AuthRegion string   `json:"auth_region" yaml:"auth_region"`
+	// The hosts of the other environments keyed by the environment
+	Environments map[string][]string `json:"environments" yaml:"environments"`
+}
+
+func (c *regionFileConfig) regionConfig() *RegionConfig {
+	return &RegionConfig{Hosts: c.Hosts, AuthRegion: c.AuthRegion, Environments: c.Environments}
+}
+
+// LoadRegionsFromFile reads the regions from the json or yaml file, which is decided
+// by the extension of path, and registers them by OverrideRegion, so that the regions
+// in the file take place of the registered ones
+func LoadRegionsFromFile(path string) error {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return err
+	}
+	file := &regionsFile{}
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".json":
+		err = json.Unmarshal(data, file)
+	case ".yaml", ".yml":
+		err = yaml.Unmarshal(data, file)
+	default:
+		return fmt.Errorf("unsupported region config file: %s", path)
+	}
+	if err != nil {
+		return fmt.Errorf("parse region config file %s fail: %w", path, err)
+	}
+	return registerRegions(file.Regions)
+}
+
+// LoadRegionsFromEnv loads the region config file of BYTEPLUS_REGIONS_FILE if it is set,
+// then the regions of the env BYTEPLUS_REGION_<NAME>_HOSTS and BYTEPLUS_REGION_<NAME>_AUTH_REGION,
+// in which NAME is the uppercase region name, such as BYTEPLUS_REGION_SG_HOSTS=host1,host2
+func LoadRegionsFromEnv() error {
+	if path := os.Getenv(EnvRegionsFile); path != "" {
+		if err := LoadRegionsFromFile(path); err != nil {
+			return err
+		}
+	}
+	configs := make(map[string]*regionFileConfig)
+	for _, env := range os.Environ() {
+		kv := strings.SplitN(env, "=", 2)
+		key := kv[0]
+		if !strings.HasPrefix(key, envRegionPrefix) || !strings.HasSuffix(key, envRegionHostsSuffix) ||
+			len(key) <= len(envRegionPrefix)+len(envRegionHostsSuffix) {
+			continue
+		}
+		name := key[len(envRegionPrefix) : len(key)-len(envRegionHostsSuffix)]
+		config := &regionFileConfig{
+			AuthRegion: os.Getenv(envRegionPrefix + name + envRegionAuthRegionSuffix),
+		}
+		for _, host := range strings.Split(kv[1], ",") {
+			if host = strings.TrimSpace(host); host != "" {
+				config.Hosts = append(config.Hosts, host)
+			}
+		}
+		configs[strings.ToLower(name)] = config
+	}
+	return registerRegions(configs)
+}
+
+// registerRegions registers none of configs if any of them is invalid
+func registerRegions(configs map[string]*regionFileConfig) error {
+	names := make([]string, 0, len(configs))
+	for name, config := range configs {
+		if config == nil {
+			return fmt.Errorf("config of region %s is empty", name)
+		}
+		if err := checkRegionConfig(name, config.regionConfig()); err != nil {
+			return err
+		}
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		_ = OverrideRegion(name, configs[name].regionConfig())
+	}
+	return nil
+}
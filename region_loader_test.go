@@ -0,0 +1,58 @@
+package core
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestLoadRegionsFromFile(t *testing.T) {
+	dir := t.TempDir()
+	tests := []struct {
+		name    string
+		file    string
+		content string
+	}{
+		{"json", "regions.json", `{"regions":{"test-json":{"hosts":["host1","host2"],"auth_region":"auth1"}}}`},
+		{"yaml", "regions.yaml", "regions:\n  test-yaml:\n    hosts: [host1, host2]\n    auth_region: auth1\n"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			path := filepath.Join(dir, tt.file)
+			if err := os.WriteFile(path, []byte(tt.content), 0600); err != nil {
+				t.Fatal(err)
+			}
+			if err := LoadRegionsFromFile(path); err != nil {
+				t.Fatalf("LoadRegionsFromFile() error = %v", err)
+			}
+			region := "test-" + tt.name
+			defer DeregisterRegion(region)
+			config, exist := GetRegionConfig(region)
+			if !exist || len(config.Hosts) != 2 || config.Hosts[1] != "host2" || config.AuthRegion != "auth1" {
+				t.Errorf("loaded region = %+v, exist = %v", config, exist)
+			}
+		})
+	}
+
+	path := filepath.Join(dir, "invalid.json")
+	_ = os.WriteFile(path, []byte(`{"regions":{"test-valid":{"hosts":["host"]},"test-invalid":{"hosts":[]}}}`), 0600)
+	if err := LoadRegionsFromFile(path); err == nil {
+		t.Errorf("LoadRegionsFromFile() of invalid region error = nil")
+	}
+	if _, exist := GetRegionConfig("test-valid"); exist {
+		t.Errorf("regions should not be registered when any of them is invalid")
+	}
+}
+
+func TestLoadRegionsFromEnv(t *testing.T) {
+	t.Setenv("BYTEPLUS_REGION_TEST_ENV_HOSTS", "host1, host2")
+	t.Setenv("BYTEPLUS_REGION_TEST_ENV_AUTH_REGION", "auth1")
+	if err := LoadRegionsFromEnv(); err != nil {
+		t.Fatalf("LoadRegionsFromEnv() error = %v", err)
+	}
+	defer DeregisterRegion("test_env")
+	config, exist := GetRegionConfig("test_env")
+	if !exist || len(config.Hosts) != 2 || config.Hosts[1] != "host2" || config.AuthRegion != "auth1" {
+		t.Errorf("loaded region = %+v, exist = %v", config, exist)
+	}
+}
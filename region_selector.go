@@ -0,0 +1,273 @@
+package core
+
+import (
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/byteplus-sdk/byteplus-sdk-go-rec-core/logs"
+	"github.com/byteplus-sdk/byteplus-sdk-go-rec-core/metrics"
+	"github.com/valyala/fasthttp"
+)
+
+const (
+	defaultRegionPingSchema       = "https"
+	defaultRegionPingTimes        = 3
+	defaultRegionEvaluateInterval = 5 * time.Minute
+	// the selected region is switched only if another one is faster by the margin,
+	// so that the selection does not flap between the regions of similar latency
+	defaultRegionSwitchMargin = 20 * time.Millisecond
+)
+
+// RegionSelectorConfig is the config of NewRegionSelector
+type RegionSelectorConfig struct {
+	// The names of candidate regions registered by RegisterRegion, default is all the
+	// registered regions
+	Regions []string
+	// The schema pinging the hosts, default is https
+	Schema string
+	// The url format of ping, default is "%s://%s/predict/api/ping", in which
+	// the schema and host are formatted
+	PingURLFormat string
+	// The timeout of each ping, default is 300ms
+	PingTimeout time.Duration
+	// How many times each host is pinged in an evaluation, default is 3
+	PingTimes int
+	// The interval of evaluating the regions again, default is 5 minutes,
+	// negative means evaluating only once at start
+	EvaluateInterval time.Duration
+	// The selected region is switched only if another one is faster by the margin, default is 20ms
+	SwitchMargin time.Duration
+	// OnRegionChanged is called when the selected region is switched, including
+	// failing over from the region whose hosts are all unreachable
+	OnRegionChanged func(from, to string)
+	// The transport pinging hosts, default is a fasthttp client
+	Transport Transport
+}
+
+// RegionSelector selects the registered region of the lowest ping latency, and
+// evaluates the regions periodically. It implements IRegion, so that it can be
+// passed to httpClientBuilder.Region in place of an explicit region. The clients
+// built with it fail over to the selected region: their hosts are switched to the
+// hosts of region, and the requests are signed by its auth region. The clients of
+// explicit hosts or custom host availablers only follow the auth region
+type RegionSelector struct {
+	config    *RegionSelectorConfig
+	lock      sync.RWMutex
+	region    string
+	selected  *RegionConfig
+	listeners []func(config *RegionConfig)
+	stop      chan bool
+	stopOnce  sync.Once
+}
+
+// NewRegionSelector evaluates the regions and selects the fastest one, it returns
+// error if no region is reachable
+func NewRegionSelector(config *RegionSelectorConfig) (*RegionSelector, error) {
+	config = fillDefaultRegionSelectorConfig(config)
+	selector := &RegionSelector{config: config, stop: make(chan bool)}
+	if err := selector.evaluate(); err != nil {
+		return nil, err
+	}
+	if config.EvaluateInterval > 0 {
+		go selector.scheduleEvaluate()
+	}
+	return selector, nil
+}
+
+func fillDefaultRegionSelectorConfig(config *RegionSelectorConfig) *RegionSelectorConfig {
+	result := &RegionSelectorConfig{}
+	if config != nil {
+		*result = *config
+	}
+	if result.Schema == "" {
+		result.Schema = defaultRegionPingSchema
+	}
+	if result.PingURLFormat == "" {
+		result.PingURLFormat = defaultPingURLFormat
+	}
+	if result.PingTimeout <= 0 {
+		result.PingTimeout = defaultPingTimeout
+	}
+	if result.PingTimes <= 0 {
+		result.PingTimes = defaultRegionPingTimes
+	}
+	if result.EvaluateInterval == 0 {
+		result.EvaluateInterval = defaultRegionEvaluateInterval
+	}
+	if result.SwitchMargin <= 0 {
+		result.SwitchMargin = defaultRegionSwitchMargin
+	}
+	if result.Transport == nil {
+		result.Transport = &fasthttp.Client{MaxIdleConnDuration: defaultKeepAliveDuration}
+	}
+	return result
+}
+
+// Region returns the name of the selected region
+func (s *RegionSelector) Region() string {
+	s.lock.RLock()
+	defer s.lock.RUnlock()
+	return s.region
+}
+
+func (s *RegionSelector) GetHosts() []string {
+	s.lock.RLock()
+	defer s.lock.RUnlock()
+	return append([]string(nil), s.selected.Hosts...)
+}
+
+func (s *RegionSelector) GetAuthRegion() string {
+	s.lock.RLock()
+	defer s.lock.RUnlock()
+	return s.selected.AuthRegion
+}
+
+// Shutdown stops evaluating the regions periodically
+func (s *RegionSelector) Shutdown() {
+	s.stopOnce.Do(func() {
+		close(s.stop)
+	})
+}
+
+func (s *RegionSelector) scheduleEvaluate() {
+	ticker := time.NewTicker(s.config.EvaluateInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-s.stop:
+			return
+		case <-ticker.C:
+			if err := s.evaluate(); err != nil {
+				logs.Warn("evaluate regions fail, keep region:%s err:%v", s.Region(), err)
+			}
+		}
+	}
+}
+
+// evaluate pings the candidate regions concurrently, and switches to the fastest one
+// if it is faster than the selected one by the switch margin
+func (s *RegionSelector) evaluate() error {
+	names := s.config.Regions
+	if len(names) == 0 {
+		names = ListRegions()
+	}
+	if len(names) == 0 {
+		return errors.New("no region is registered")
+	}
+	candidates := make([]*RegionConfig, len(names))
+	for i, name := range names {
+		config, exist := GetRegionConfig(name)
+		if !exist {
+			return fmt.Errorf("region %s is not registered", name)
+		}
+		candidates[i] = config
+	}
+	results := make([]time.Duration, len(names))
+	reachable := make([]bool, len(names))
+	var wg sync.WaitGroup
+	for i := range candidates {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			results[i], reachable[i] = s.regionLatency(candidates[i])
+		}(i)
+	}
+	wg.Wait()
+	latencies := make(map[string]time.Duration, len(names))
+	configs := make(map[string]*RegionConfig, len(names))
+	var fastest string
+	for i, name := range names {
+		if !reachable[i] {
+			continue
+		}
+		latencies[name], configs[name] = results[i], candidates[i]
+		if fastest == "" || results[i] < latencies[fastest] {
+			fastest = name
+		}
+	}
+	if fastest == "" {
+		return errors.New("no region is reachable")
+	}
+	s.lock.Lock()
+	old := s.region
+	currentLatency, currentOK := latencies[old]
+	if currentOK && latencies[fastest]+s.config.SwitchMargin > currentLatency {
+		// keep the selected region, but follow its latest config
+		s.selected = configs[old]
+		s.lock.Unlock()
+		return nil
+	}
+	s.region, s.selected = fastest, configs[fastest]
+	listeners := s.listeners
+	s.lock.Unlock()
+	if old == fastest {
+		return nil
+	}
+	metricsTags := []string{
+		"type:region_selected",
+		"region:" + fastest,
+	}
+	metrics.Counter(metricsKeyCommonInfo, 1, metricsTags...)
+	logs.Info("region is selected, from:%s to:%s latency:%s", old, fastest, latencies[fastest])
+	for _, listener := range listeners {
+		listener(configs[fastest])
+	}
+	if old != "" && s.config.OnRegionChanged != nil {
+		s.config.OnRegionChanged(old, fastest)
+	}
+	return nil
+}
+
+// onSelected registers the listener called with the config of region once another
+// region is selected, the built clients switch their hosts by it
+func (s *RegionSelector) onSelected(listener func(config *RegionConfig)) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	s.listeners = append(s.listeners, listener)
+}
+
+// regionLatency returns the average ping latency of the fastest host of region,
+// the hosts are pinged concurrently
+func (s *RegionSelector) regionLatency(config *RegionConfig) (time.Duration, bool) {
+	latencies := make([]time.Duration, len(config.Hosts))
+	reachable := make([]bool, len(config.Hosts))
+	var wg sync.WaitGroup
+	for i := range config.Hosts {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			latencies[i], reachable[i] = s.hostLatency(config.Hosts[i])
+		}(i)
+	}
+	wg.Wait()
+	var best time.Duration
+	found := false
+	for i := range latencies {
+		if reachable[i] && (!found || latencies[i] < best) {
+			best, found = latencies[i], true
+		}
+	}
+	return best, found
+}
+
+// hostLatency returns the average ping latency of host, the failed pings are counted
+// as the ping timeout
+func (s *RegionSelector) hostLatency(host string) (time.Duration, bool) {
+	var total time.Duration
+	success := 0
+	for i := 0; i < s.config.PingTimes; i++ {
+		start := time.Now()
+		if Ping("", s.config.Transport, s.config.PingURLFormat, s.config.Schema, host, s.config.PingTimeout) {
+			total += time.Since(start)
+			success++
+		} else {
+			total += s.config.PingTimeout
+		}
+	}
+	if success == 0 {
+		return 0, false
+	}
+	return total / time.Duration(s.config.PingTimes), true
+}
@@ -0,0 +1,141 @@
+package core
+
+import (
+	"errors"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/valyala/fasthttp"
+)
+
+func TestRegionSelector(t *testing.T) {
+	for name, hosts := range map[string][]string{
+		"test-near": {"near-down", "near"},
+		"test-far":  {"far"},
+	} {
+		if err := RegisterRegion(name, &RegionConfig{Hosts: hosts, AuthRegion: name}); err != nil {
+			t.Fatal(err)
+		}
+		defer DeregisterRegion(name)
+	}
+	var lock sync.Mutex
+	latencies := map[string]time.Duration{"near": 0, "far": 50 * time.Millisecond}
+	transport := TransportFunc(func(request *fasthttp.Request, response *fasthttp.Response, deadline time.Time) error {
+		host := string(request.Host())
+		lock.Lock()
+		latency, ok := latencies[host]
+		lock.Unlock()
+		if !ok {
+			return errors.New("unreachable")
+		}
+		time.Sleep(latency)
+		response.SetStatusCode(fasthttp.StatusOK)
+		response.SetBodyString("pong")
+		return nil
+	})
+	changed := make(chan string, 1)
+	selector, err := NewRegionSelector(&RegionSelectorConfig{
+		Regions:          []string{"test-far", "test-near"},
+		PingTimes:        1,
+		EvaluateInterval: -1,
+		Transport:        transport,
+		OnRegionChanged: func(from, to string) {
+			changed <- from + "->" + to
+		},
+	})
+	if err != nil {
+		t.Fatalf("NewRegionSelector() error = %v", err)
+	}
+	defer selector.Shutdown()
+	if selector.Region() != "test-near" || selector.GetAuthRegion() != "test-near" ||
+		strings.Join(selector.GetHosts(), ",") != "near-down,near" {
+		t.Errorf("selected %s %v, want test-near", selector.Region(), selector.GetHosts())
+	}
+
+	// the selected region fails over when its hosts are unreachable
+	lock.Lock()
+	delete(latencies, "near")
+	lock.Unlock()
+	if err = selector.evaluate(); err != nil {
+		t.Fatalf("evaluate() error = %v", err)
+	}
+	select {
+	case got := <-changed:
+		if got != "test-near->test-far" {
+			t.Errorf("OnRegionChanged() = %s, want test-near->test-far", got)
+		}
+	default:
+		t.Errorf("OnRegionChanged() is not called")
+	}
+
+	// a region faster within the switch margin does not take over
+	lock.Lock()
+	latencies["near"] = 40 * time.Millisecond
+	lock.Unlock()
+	if err = selector.evaluate(); err != nil || selector.Region() != "test-far" {
+		t.Errorf("evaluate() = %s, %v, want keeping test-far", selector.Region(), err)
+	}
+
+	lock.Lock()
+	latencies = map[string]time.Duration{}
+	lock.Unlock()
+	if err = selector.evaluate(); err == nil || selector.Region() != "test-far" {
+		t.Errorf("evaluate() without reachable region = %s, %v, want error", selector.Region(), err)
+	}
+}
+
+func TestRegionSelector_failover(t *testing.T) {
+	for name, hosts := range map[string][]string{
+		"test-near": {"near"},
+		"test-far":  {"far"},
+	} {
+		if err := RegisterRegion(name, &RegionConfig{Hosts: hosts, AuthRegion: name}); err != nil {
+			t.Fatal(err)
+		}
+		defer DeregisterRegion(name)
+	}
+	var nearDown int32
+	transport := TransportFunc(func(request *fasthttp.Request, response *fasthttp.Response, deadline time.Time) error {
+		if string(request.Host()) == "near" && atomic.LoadInt32(&nearDown) == 1 {
+			return errors.New("unreachable")
+		}
+		if string(request.Host()) == "far" {
+			time.Sleep(50 * time.Millisecond)
+		}
+		response.SetStatusCode(fasthttp.StatusOK)
+		response.SetBodyString("pong")
+		return nil
+	})
+	selector, err := NewRegionSelector(&RegionSelectorConfig{
+		Regions:          []string{"test-far", "test-near"},
+		PingTimes:        1,
+		EvaluateInterval: -1,
+		Transport:        transport,
+	})
+	if err != nil {
+		t.Fatalf("NewRegionSelector() error = %v", err)
+	}
+	defer selector.Shutdown()
+	availabler := &HostAvailablerBase{projectID: "1", skipFetchHosts: true, hostScorer: reversingHostScorer{}}
+	if err = availabler.Init(selector.GetHosts(), time.Hour, time.Hour); err != nil {
+		t.Fatalf("Init() error = %v", err)
+	}
+	defer availabler.Shutdown()
+	builder := &httpClientBuilder{region: selector, hostAvailabler: availabler}
+	builder.followRegionSelector()
+	caller := &httpCaller{region: selector}
+
+	atomic.StoreInt32(&nearDown, 1)
+	if err = selector.evaluate(); err != nil {
+		t.Fatalf("evaluate() error = %v", err)
+	}
+	if got := availabler.GetHosts(); strings.Join(got, ",") != "far" {
+		t.Errorf("hosts after failover = %v, want [far]", got)
+	}
+	if cred, _ := caller.credentialOf(nil); cred.region != "test-far" {
+		t.Errorf("auth region after failover = %s, want test-far", cred.region)
+	}
+}
@@ -0,0 +1,102 @@
+package core
+
+import (
+	"errors"
+	"strings"
+	"testing"
+)
+
+func TestRegisterRegion(t *testing.T) {
+	defer DeregisterRegion("test-region")
+	config := &RegionConfig{Hosts: []string{"host1"}, AuthRegion: "auth1"}
+	if err := RegisterRegion("test-region", config); err != nil {
+		t.Fatalf("RegisterRegion() error = %v", err)
+	}
+	// the registered config is not modified by the caller
+	config.Hosts[0] = "modified"
+	if err := RegisterRegion("test-region", config); !errors.Is(err, ErrRegionExists) {
+		t.Errorf("RegisterRegion() twice error = %v, want ErrRegionExists", err)
+	}
+	if got, _ := GetRegionConfig("test-region"); got.GetHosts()[0] != "host1" || got.GetAuthRegion() != "auth1" {
+		t.Errorf("GetRegionConfig() = %+v, want host1 and auth1", got)
+	}
+	if err := OverrideRegion("test-region", &RegionConfig{Hosts: []string{"host2"}}); err != nil {
+		t.Fatalf("OverrideRegion() error = %v", err)
+	}
+	if got, _ := GetRegionConfig("test-region"); got.GetHosts()[0] != "host2" {
+		t.Errorf("GetRegionConfig() after override = %+v, want host2", got)
+	}
+	if err := OverrideRegion("test-region", &RegionConfig{}); err == nil {
+		t.Errorf("OverrideRegion() without hosts error = nil")
+	}
+	if !DeregisterRegion("test-region") || DeregisterRegion("test-region") {
+		t.Errorf("DeregisterRegion() should report whether the region exists")
+	}
+	if _, exist := GetRegionConfig("test-region"); exist {
+		t.Errorf("GetRegionConfig() after deregistering exists")
+	}
+	if err := RegisterRegion("test-region", &RegionConfig{Hosts: []string{"host3"}}); err != nil {
+		t.Errorf("RegisterRegion() after deregistering error = %v", err)
+	}
+}
+
+func TestListRegions(t *testing.T) {
+	defer DeregisterRegion("test-b")
+	defer DeregisterRegion("test-a")
+	for _, region := range []string{"test-b", "test-a"} {
+		if err := RegisterRegion(region, &RegionConfig{Hosts: []string{region + "-host"}}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	var got []string
+	for _, region := range ListRegions() {
+		if strings.HasPrefix(region, "test-") {
+			got = append(got, region)
+		}
+	}
+	if strings.Join(got, ",") != "test-a,test-b" {
+		t.Errorf("ListRegions() = %v, want sorted test-a,test-b", got)
+	}
+	config, _ := GetRegionConfig("test-a")
+	config.Hosts[0] = "modified"
+	if config, _ = GetRegionConfig("test-a"); config.Hosts[0] != "test-a-host" {
+		t.Errorf("GetRegionConfig() returns the registered config, modified to %v", config.Hosts)
+	}
+}
+
+func TestHTTPClientBuilder_Environment(t *testing.T) {
+	defer DeregisterRegion("test-env")
+	err := RegisterRegion("test-env", &RegionConfig{Hosts: []string{"prod-host"}, AuthRegion: "auth",
+		Environments: map[string][]string{"sandbox": {"sandbox-host"}}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		environment string
+		wantHost    string
+		wantErr     bool
+	}{
+		{"", "prod-host", false},
+		{DefaultEnvironment, "prod-host", false},
+		{"sandbox", "sandbox-host", false},
+		{"staging", "", true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.environment, func(t *testing.T) {
+			builder := &httpClientBuilder{tenantID: "tenant", tokenSource: StaticTokenSource("token"),
+				regionName: "test-env", environment: tt.environment}
+			err := builder.checkRequiredField()
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("checkRequiredField() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if err == nil && (builder.region.GetHosts()[0] != tt.wantHost || builder.region.GetAuthRegion() != "auth") {
+				t.Errorf("region = %v %s, want %s", builder.region.GetHosts(), builder.region.GetAuthRegion(), tt.wantHost)
+			}
+		})
+	}
+	if err = RegisterRegion("test-env-empty", &RegionConfig{Hosts: []string{"host"},
+		Environments: map[string][]string{"sandbox": nil}}); err == nil {
+		DeregisterRegion("test-env-empty")
+		t.Errorf("RegisterRegion() with empty environment hosts error = nil")
+	}
+}
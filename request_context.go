@@ -0,0 +1,115 @@
+package core
+
+import (
+	"sync"
+	"time"
+
+	"github.com/byteplus-sdk/byteplus-sdk-go-rec-core/coreerr"
+	"github.com/byteplus-sdk/byteplus-sdk-go-rec-core/logs"
+	"github.com/byteplus-sdk/byteplus-sdk-go-rec-core/option"
+	"github.com/google/uuid"
+)
+
+// RequestContext holds everything about one call of HTTPClient, it is created
+// for each call and passed to RouteInterceptor and RequestHook, so that they can
+// correlate the call by it, and pass values to each other by SetValue
+type RequestContext struct {
+	// The request id sent in "Request-Id" header, generated if not specified by option
+	RequestID string
+	// The path of the request, it may be changed by RouteInterceptor
+	Path string
+	// The host of the latest attempt
+	Host string
+	// The number of the latest attempt, starts from 1
+	Attempt int
+	// The time the call starts
+	StartTime time.Time
+	// The options of the call
+	Options *option.Options
+	lock    sync.Mutex
+	values  map[interface{}]interface{}
+	// the url of the duplicate request when hedging is enabled
+	hedgeURL string
+	// the urls of the other hosts the request fails over to
+	failoverURLs []string
+}
+
+// ErrRequestIDRequired is returned when the request id is not specified in strict request id mode
+var ErrRequestIDRequired = newClassifiedError("request id is required, specify it by option.WithRequestID", coreerr.ClientError)
+
+// ErrInvalidStage is returned when the stage specified by option.WithStage is not supported
+var ErrInvalidStage = newClassifiedError("stage is not supported, use the stages of option", coreerr.ClientError)
+
+// RequestHook is called after each call of HTTPClient is finished, err is
+// the error returned to the caller, it is nil if the call succeeds
+type RequestHook func(reqCtx *RequestContext, err error)
+
+// newRequestContext creates the context of a call, and fixes the request id of options,
+// so that the interceptors, the request and the hooks see the same id
+func newRequestContext(path string, options *option.Options) *RequestContext {
+	if !hasRequestID(options) {
+		options = copyOptions(options)
+		options.RequestID = uuid.NewString()
+		logs.Info("requestID is generated by sdk: '%s' ", options.RequestID)
+	}
+	return &RequestContext{
+		RequestID: options.RequestID,
+		Path:      path,
+		StartTime: time.Now(),
+		Options:   options,
+	}
+}
+
+func hasRequestID(options *option.Options) bool {
+	return options != nil && options.RequestID != ""
+}
+
+// SetValue sets a user value with key, it is safe to call concurrently
+func (r *RequestContext) SetValue(key, value interface{}) {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	if r.values == nil {
+		r.values = make(map[interface{}]interface{})
+	}
+	r.values[key] = value
+}
+
+// Value returns the user value of key, or nil if not set
+func (r *RequestContext) Value(key interface{}) interface{} {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	return r.values[key]
+}
+
+// startAttempt records the attempt sending to url
+func (r *RequestContext) startAttempt(attempt int, url string) {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	r.Attempt = attempt
+	r.Host = hostOfURL(url)
+}
+
+// hedgeLeg returns the context of a leg of the hedged call, it shares the call with r but
+// records its own attempts, so that the losing leg running on does not overwrite the host
+// of the winner. The attempt of the winner is copied into r by copyAttempt
+func (r *RequestContext) hedgeLeg() *RequestContext {
+	return &RequestContext{
+		RequestID:    r.RequestID,
+		Path:         r.Path,
+		StartTime:    r.StartTime,
+		Options:      r.Options,
+		hedgeURL:     r.hedgeURL,
+		failoverURLs: r.failoverURLs,
+	}
+}
+
+// copyAttempt records the latest attempt of leg as the one of r
+func (r *RequestContext) copyAttempt(leg *RequestContext) {
+	leg.lock.Lock()
+	attempt, host := leg.Attempt, leg.Host
+	leg.lock.Unlock()
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	r.Attempt = attempt
+	r.Host = host
+}
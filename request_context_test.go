@@ -0,0 +1,54 @@
+package core
+
+import (
+	"testing"
+
+	"github.com/byteplus-sdk/byteplus-sdk-go-rec-core/option"
+)
+
+func TestNewRequestContext(t *testing.T) {
+	options := option.Conv2Options()
+	reqCtx := newRequestContext("/predict", options)
+	if reqCtx.RequestID == "" || reqCtx.Options.RequestID != reqCtx.RequestID {
+		t.Errorf("request id should be generated, got %q", reqCtx.RequestID)
+	}
+	if options.RequestID != "" {
+		t.Errorf("caller's options should not be modified")
+	}
+	reqCtx = newRequestContext("/predict", option.Conv2Options(option.WithRequestID("req-1")))
+	if reqCtx.RequestID != "req-1" {
+		t.Errorf("RequestID = %q, want req-1", reqCtx.RequestID)
+	}
+	reqCtx.startAttempt(2, "https://byteplus.com/predict")
+	if reqCtx.Attempt != 2 || reqCtx.Host != "byteplus.com" {
+		t.Errorf("attempt = %d, host = %s", reqCtx.Attempt, reqCtx.Host)
+	}
+	reqCtx.SetValue("k", "v")
+	if reqCtx.Value("k") != "v" || reqCtx.Value("none") != nil {
+		t.Errorf("unexpected values")
+	}
+}
+
+func TestHTTPClient_newRequestContextStrict(t *testing.T) {
+	client := &HTTPClient{strictRequestID: true}
+	if _, err := client.newRequestContext("/predict", option.Conv2Options()); err != ErrRequestIDRequired {
+		t.Errorf("err = %v, want ErrRequestIDRequired", err)
+	}
+	reqCtx, err := client.newRequestContext("/predict", option.Conv2Options(option.WithRequestID("req-1")))
+	if err != nil || reqCtx.RequestID != "req-1" {
+		t.Errorf("newRequestContext() = %v, %v", reqCtx, err)
+	}
+}
+
+func TestHTTPClient_newRequestContextStage(t *testing.T) {
+	client := &HTTPClient{}
+	options := option.Conv2Options(option.WithStage(option.StagePre))
+	reqCtx, err := client.newRequestContext("/predict", options)
+	if err != nil || reqCtx.Options.Queries["stage"] != "pre" {
+		t.Errorf("newRequestContext() = %v, %v, want stage query", reqCtx, err)
+	}
+	options = option.Conv2Options(option.WithStage("unknown"))
+	if _, err = client.newRequestContext("/predict", options); err != ErrInvalidStage {
+		t.Errorf("err = %v, want ErrInvalidStage", err)
+	}
+}
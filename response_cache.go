@@ -0,0 +1,106 @@
+package core
+
+import (
+	"container/list"
+	"sync"
+	"time"
+)
+
+const defaultResponseCacheMaxEntries = 1000
+
+// responseCache is a TTL cache with LRU eviction that holds decompressed
+// response bodies of calls marked with option.WithCacheable.
+type responseCache struct {
+	ttl        time.Duration
+	maxEntries int
+	lock       sync.Mutex
+	entries    map[string]*list.Element
+	lru        *list.List
+}
+
+type responseCacheEntry struct {
+	key          string
+	value        []byte
+	etag         string
+	lastModified string
+	expireAt     time.Time
+}
+
+func (e *responseCacheEntry) hasValidators() bool {
+	return e.etag != "" || e.lastModified != ""
+}
+
+func newResponseCache(ttl time.Duration, maxEntries int) *responseCache {
+	if maxEntries <= 0 {
+		maxEntries = defaultResponseCacheMaxEntries
+	}
+	return &responseCache{
+		ttl:        ttl,
+		maxEntries: maxEntries,
+		entries:    make(map[string]*list.Element, maxEntries),
+		lru:        list.New(),
+	}
+}
+
+// get returns a snapshot of the cached entry and whether it is still fresh.
+// Expired entries carrying validators (ETag/Last-Modified) are kept, so that
+// they can be revalidated with a conditional request instead of re-fetched.
+func (c *responseCache) get(key string) (*responseCacheEntry, bool) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	element, exist := c.entries[key]
+	if !exist {
+		return nil, false
+	}
+	entry := element.Value.(*responseCacheEntry)
+	if time.Now().After(entry.expireAt) {
+		if !entry.hasValidators() {
+			c.removeElement(element)
+			return nil, false
+		}
+		snapshot := *entry
+		return &snapshot, false
+	}
+	c.lru.MoveToFront(element)
+	snapshot := *entry
+	return &snapshot, true
+}
+
+func (c *responseCache) put(key string, value []byte, etag, lastModified string) {
+	// the value may be backed by a pooled buffer, so keep a private copy
+	value = append([]byte(nil), value...)
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	expireAt := time.Now().Add(c.ttl)
+	if element, exist := c.entries[key]; exist {
+		entry := element.Value.(*responseCacheEntry)
+		entry.value = value
+		entry.etag = etag
+		entry.lastModified = lastModified
+		entry.expireAt = expireAt
+		c.lru.MoveToFront(element)
+		return
+	}
+	entry := &responseCacheEntry{
+		key:          key,
+		value:        value,
+		etag:         etag,
+		lastModified: lastModified,
+		expireAt:     expireAt,
+	}
+	c.entries[key] = c.lru.PushFront(entry)
+	for c.lru.Len() > c.maxEntries {
+		c.removeElement(c.lru.Back())
+	}
+}
+
+func (c *responseCache) len() int {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	return c.lru.Len()
+}
+
+func (c *responseCache) removeElement(element *list.Element) {
+	c.lru.Remove(element)
+	delete(c.entries, element.Value.(*responseCacheEntry).key)
+}
@@ -0,0 +1,83 @@
+package core
+
+import (
+	"testing"
+	"time"
+
+	"github.com/byteplus-sdk/byteplus-sdk-go-rec-core/option"
+	"github.com/valyala/fasthttp"
+)
+
+func TestResponseCache(t *testing.T) {
+	cache := newResponseCache(50*time.Millisecond, 2)
+	cache.put("a", []byte("1"), "", "")
+	cache.put("b", []byte("2"), "", "")
+	if _, fresh := cache.get("a"); !fresh {
+		t.Errorf("get(a) miss, want hit")
+	}
+	// "b" is the least recently used entry, it should be evicted
+	cache.put("c", []byte("3"), "\"v1\"", "")
+	if entry, _ := cache.get("b"); entry != nil {
+		t.Errorf("get(b) hit, want evicted")
+	}
+	if got := cache.len(); got != 2 {
+		t.Errorf("len() = %v, want 2", got)
+	}
+	time.Sleep(60 * time.Millisecond)
+	if entry, _ := cache.get("a"); entry != nil {
+		t.Errorf("get(a) hit, want expired")
+	}
+	// expired entry with validators is kept for revalidation
+	entry, fresh := cache.get("c")
+	if entry == nil || fresh || entry.etag != "\"v1\"" {
+		t.Errorf("get(c) = %+v, %v, want stale entry with etag", entry, fresh)
+	}
+}
+
+func TestHTTPCaller_notModified(t *testing.T) {
+	var conditional string
+	transport := TransportFunc(func(request *fasthttp.Request, response *fasthttp.Response, deadline time.Time) error {
+		conditional = string(request.Header.Peek("If-None-Match"))
+		if conditional == "\"v1\"" || string(request.URI().Path()) == "/not_modified" {
+			response.SetStatusCode(fasthttp.StatusNotModified)
+			return nil
+		}
+		response.SetStatusCode(fasthttp.StatusOK)
+		response.Header.Set("ETag", "\"v1\"")
+		response.SetBody([]byte("1"))
+		return nil
+	})
+	airAuthConfig, _ := fillDefaultAirAuthConfig(nil)
+	c := newHTTPCaller("project", "tenant", true, "token", airAuthConfig, credential{},
+		&staticHostAvailabler{hosts: []string{"host"}}, &CallerConfig{ResponseCacheTTL: 20 * time.Millisecond},
+		"http", false, transport)
+	doRequest := func(path string, opts ...option.Option) ([]byte, error) {
+		options := option.Conv2Options(opts...)
+		return c.doRawRequest(newRequestContext(path, options), "http://host"+path, "application/json", nil, options)
+	}
+	// the 304 response not revalidating the cache is an error
+	_, err := doRequest("/not_modified", option.WithResponseMetadata(&option.ResponseMetadata{}))
+	if err == nil {
+		t.Errorf("304 response without cache entry error = nil, want status error")
+	}
+	_, err = doRequest("/not_modified", option.WithCacheable(), option.WithHTTPHeader("If-None-Match", "\"v0\""))
+	if err == nil || conditional != "" || c.responseCache.len() != 0 {
+		t.Errorf("304 response without cache entry error = %v, If-None-Match:%s cached:%d, "+
+			"want status error and nothing cached", err, conditional, c.responseCache.len())
+	}
+	// the stale entry is revalidated
+	if rspBytes, err := doRequest("/cached", option.WithCacheable()); err != nil || string(rspBytes) != "1" {
+		t.Fatalf("doRawRequest() = %s, %v", rspBytes, err)
+	}
+	time.Sleep(30 * time.Millisecond)
+	rspBytes, err := doRequest("/cached", option.WithCacheable())
+	if err != nil || string(rspBytes) != "1" || conditional != "\"v1\"" {
+		t.Fatalf("revalidated doRawRequest() = %s, %v, If-None-Match:%s, want the cached response",
+			rspBytes, err, conditional)
+	}
+	// the caller changing the response does not change the cache
+	rspBytes[0] = '2'
+	if rspBytes, err = doRequest("/cached", option.WithCacheable()); err != nil || string(rspBytes) != "1" {
+		t.Errorf("cached doRawRequest() = %s, %v, want the cached response unchanged", rspBytes, err)
+	}
+}
@@ -0,0 +1,80 @@
+package core
+
+import (
+	"errors"
+	"net/http"
+	"time"
+
+	"github.com/byteplus-sdk/byteplus-sdk-go-rec-core/option"
+	"github.com/valyala/fasthttp"
+)
+
+// fillResponseMetadata fills metadata by the response header of the last attempt,
+// the status is 0 if err happens before any response is received
+func fillResponseMetadata(metadata *option.ResponseMetadata, reqCtx *RequestContext,
+	rspHeader *fasthttp.ResponseHeader, err error) {
+	metadata.Host = reqCtx.Host
+	metadata.Cost = time.Since(reqCtx.StartTime)
+	metadata.StatusCode = responseStatus(rspHeader, err)
+	metadata.Headers = http.Header{}
+	if metadata.StatusCode == 0 {
+		return
+	}
+	rspHeader.VisitAll(func(key, value []byte) {
+		metadata.Headers.Add(string(key), string(value))
+	})
+}
+
+// responseStatus returns the status of the response of the last attempt,
+// rspHeader is only filled when the response is received
+func responseStatus(rspHeader *fasthttp.ResponseHeader, err error) int {
+	if err == nil {
+		return rspHeader.StatusCode()
+	}
+	if attemptErrs, ok := err.(AttemptErrors); ok {
+		err = attemptErrs.Last()
+	}
+	var requestErr *RequestError
+	if errors.As(err, &requestErr) {
+		return requestErr.HTTPStatus
+	}
+	return 0
+}
+
+func fillCachedResponseMetadata(metadata *option.ResponseMetadata, reqCtx *RequestContext) {
+	if metadata == nil {
+		return
+	}
+	metadata.StatusCode = fasthttp.StatusOK
+	metadata.Headers = http.Header{}
+	metadata.Host = ""
+	metadata.Cost = time.Since(reqCtx.StartTime)
+	metadata.FromCache = true
+}
+
+func fillSharedResponseMetadata(metadata *option.ResponseMetadata, reqCtx *RequestContext, err error) {
+	if metadata == nil {
+		return
+	}
+	metadata.StatusCode = 0
+	if err == nil {
+		metadata.StatusCode = fasthttp.StatusOK
+	}
+	metadata.Headers = http.Header{}
+	metadata.Cost = time.Since(reqCtx.StartTime)
+	metadata.Shared = true
+}
+
+// fillGRPCResponseMetadata fills metadata of the pb request sent by gRPC, the headers
+// are the header metadata of the last call, and the status is 200 if it succeeds
+func fillGRPCResponseMetadata(metadata *option.ResponseMetadata, reqCtx *RequestContext, err error) {
+	metadata.Host = reqCtx.Host
+	metadata.Cost = time.Since(reqCtx.StartTime)
+	metadata.StatusCode = 0
+	if err == nil {
+		metadata.StatusCode = fasthttp.StatusOK
+	}
+	if metadata.Headers == nil {
+		metadata.Headers = http.Header{}
+	}
+}
@@ -0,0 +1,58 @@
+package core
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/byteplus-sdk/byteplus-sdk-go-rec-core/option"
+	"github.com/valyala/fasthttp"
+)
+
+func TestHTTPCaller_responseMetadata(t *testing.T) {
+	transport := TransportFunc(func(request *fasthttp.Request, response *fasthttp.Response, deadline time.Time) error {
+		switch string(request.URI().Path()) {
+		case "/down":
+			return errors.New("connection refused")
+		case "/unavailable":
+			response.SetStatusCode(fasthttp.StatusServiceUnavailable)
+		default:
+			response.SetStatusCode(fasthttp.StatusOK)
+		}
+		response.Header.Set("Request-Id", string(request.Header.Peek("Request-Id")))
+		response.Header.Set("X-Region", "sg")
+		return nil
+	})
+	airAuthConfig, _ := fillDefaultAirAuthConfig(nil)
+	c := newHTTPCaller("project", "tenant", true, "token", airAuthConfig, credential{},
+		&staticHostAvailabler{hosts: []string{"host"}}, &CallerConfig{}, "http", false, transport)
+	tests := []struct {
+		path       string
+		wantStatus int
+		wantRegion string
+	}{
+		{"/predict", fasthttp.StatusOK, "sg"},
+		{"/unavailable", fasthttp.StatusServiceUnavailable, "sg"},
+		{"/down", 0, ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.path, func(t *testing.T) {
+			metadata := &option.ResponseMetadata{}
+			options := option.Conv2Options(option.WithRequestID("req"), option.WithResponseMetadata(metadata))
+			reqCtx := newRequestContext(tt.path, options)
+			_, _ = c.doRawRequest(reqCtx, "http://host"+tt.path, "text/csv", []byte("a,b"), options)
+			if metadata.StatusCode != tt.wantStatus {
+				t.Errorf("StatusCode = %d, want %d", metadata.StatusCode, tt.wantStatus)
+			}
+			if got := metadata.Headers.Get("X-Region"); got != tt.wantRegion {
+				t.Errorf("X-Region = %q, want %q", got, tt.wantRegion)
+			}
+			if tt.wantStatus != 0 && metadata.Headers.Get("Request-Id") != "req" {
+				t.Errorf("Request-Id = %q, want req", metadata.Headers.Get("Request-Id"))
+			}
+			if metadata.Host != "host" || metadata.Cost <= 0 {
+				t.Errorf("Host = %q, Cost = %v", metadata.Host, metadata.Cost)
+			}
+		})
+	}
+}
@@ -0,0 +1,82 @@
+package core
+
+import "github.com/byteplus-sdk/byteplus-sdk-go-rec-core/option"
+
+// RequestRoute is the target of a request, it can be changed by RouteInterceptor
+// before the request is signed, such as routing part of the traffic to another
+// project for A/B experiments
+type RequestRoute struct {
+	// The path of the request, such as "/predict/api/retail/demo/home"
+	Path string
+	// The host selected by HostAvailabler for the path. If Path is changed and
+	// Host is not, the host is reselected for the new path
+	Host string
+	// The options of the request, headers and queries can be changed. If it is set
+	// to nil, the request is sent with the options before interceptors
+	Options *option.Options
+	// The context of the call, it is read-only for interceptors except its values
+	Context *RequestContext
+}
+
+// RouteInterceptor is called with the route of each request in the order of registration
+type RouteInterceptor func(route *RequestRoute)
+
+// route applies the interceptors, and returns the url and options to send the request with
+func (h *HTTPClient) route(reqCtx *RequestContext) (string, *option.Options) {
+	path, options := reqCtx.Path, reqCtx.Options
+	host := h.pickHost(path, affinityKeyOf(options))
+	if len(h.routeInterceptors) == 0 {
+		h.routeHedge(reqCtx, host)
+		h.routeFailover(reqCtx, host)
+		return buildURL(h.schema, host, path), options
+	}
+	route := &RequestRoute{
+		Path:    path,
+		Host:    host,
+		Options: copyOptions(options),
+		Context: reqCtx,
+	}
+	for _, interceptor := range h.routeInterceptors {
+		interceptor(route)
+		if route.Options == nil {
+			route.Options = copyOptions(options)
+		}
+	}
+	if route.Path != path && route.Host == host {
+		route.Host = h.pickHost(route.Path, affinityKeyOf(route.Options))
+	}
+	reqCtx.Path = route.Path
+	reqCtx.Options = route.Options
+	h.routeHedge(reqCtx, route.Host)
+	h.routeFailover(reqCtx, route.Host)
+	return buildURL(h.schema, route.Host, route.Path), route.Options
+}
+
+// copyOptions copies the options including headers and queries,
+// so that interceptors do not modify the caller's options
+func copyOptions(options *option.Options) *option.Options {
+	result := &option.Options{}
+	if options == nil {
+		return result
+	}
+	*result = *options
+	if options.Headers != nil {
+		result.Headers = make(map[string]string, len(options.Headers))
+		for k, v := range options.Headers {
+			result.Headers[k] = v
+		}
+	}
+	if options.Queries != nil {
+		result.Queries = make(map[string]string, len(options.Queries))
+		for k, v := range options.Queries {
+			result.Queries[k] = v
+		}
+	}
+	if options.QueryValues != nil {
+		result.QueryValues = make(map[string][]string, len(options.QueryValues))
+		for k, v := range options.QueryValues {
+			result.QueryValues[k] = append([]string(nil), v...)
+		}
+	}
+	return result
+}
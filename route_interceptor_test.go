@@ -0,0 +1,60 @@
+package core
+
+import (
+	"testing"
+	"time"
+
+	"github.com/byteplus-sdk/byteplus-sdk-go-rec-core/option"
+	"github.com/valyala/fasthttp"
+)
+
+func TestHTTPClient_route(t *testing.T) {
+	var uri, experiment string
+	transport := TransportFunc(func(request *fasthttp.Request, response *fasthttp.Response, deadline time.Time) error {
+		uri = request.URI().String()
+		experiment = string(request.Header.Peek("Experiment"))
+		response.SetStatusCode(fasthttp.StatusOK)
+		return nil
+	})
+	airAuthConfig, _ := fillDefaultAirAuthConfig(nil)
+	availabler := &staticHostAvailabler{hosts: []string{"host"}}
+	newClient := func(interceptors ...RouteInterceptor) *HTTPClient {
+		return &HTTPClient{
+			cli: newHTTPCaller("project", "tenant", true, "token", airAuthConfig, credential{},
+				availabler, &CallerConfig{DisableCompression: true}, "http", false, transport),
+			hostAvailabler:    availabler,
+			schema:            "http",
+			projectID:         "project",
+			routeInterceptors: interceptors,
+		}
+	}
+	tests := []struct {
+		name           string
+		interceptor    RouteInterceptor
+		wantURI        string
+		wantExperiment string
+	}{
+		{"rewrite", func(route *RequestRoute) {
+			route.Host = "experiment-host"
+			route.Path = "/predict/experiment"
+			route.Options.Headers = map[string]string{"Experiment": "b"}
+		}, "http://experiment-host/predict/experiment", "b"},
+		{"nil options", func(route *RequestRoute) {
+			route.Options = nil
+		}, "http://host/predict", ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			uri, experiment = "", ""
+			h := newClient(tt.interceptor)
+			_, err := h.DoRawRequest("/predict", "application/json", []byte("{}"), &option.Options{})
+			if err != nil {
+				t.Fatalf("DoRawRequest() error = %v", err)
+			}
+			if uri != tt.wantURI || experiment != tt.wantExperiment {
+				t.Errorf("request uri = %s experiment = %s, want %s %s", uri, experiment,
+					tt.wantURI, tt.wantExperiment)
+			}
+		})
+	}
+}
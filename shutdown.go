@@ -0,0 +1,134 @@
+package core
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/byteplus-sdk/byteplus-sdk-go-rec-core/metrics"
+)
+
+const (
+	// the interval of checking whether the in-flight requests are done on shutdown
+	drainCheckInterval = 10 * time.Millisecond
+	// HTTPClient.Shutdown waits for the in-flight requests and goroutines at most the timeout
+	defaultShutdownTimeout = 30 * time.Second
+)
+
+// ErrClientShutdown is returned by the requests sent after HTTPClient is shut down
+var ErrClientShutdown = errors.New("client is shut down")
+
+// metricsOwners counts the clients sharing metrics.Collector initialed by a client, the
+// collector is shut down by the last one of them, and only flushed by the others. The
+// collector initialed by user, such as by metrics.Collector.InitWithOptions, is never
+// shut down by the clients
+var metricsOwners struct {
+	lock sync.Mutex
+	// increased whenever a client initials the collector, so that the owners of a
+	// collector shut down and initialed again do not release the new one
+	generation int64
+	count      int
+}
+
+// acquireMetrics initials metrics.Collector with cfg unless it is initialed, and returns
+// the generation of the collector owned by the client, 0 if the client does not own it
+func acquireMetrics(cfg *metrics.Config, hostReader metrics.HostReader) int64 {
+	metricsOwners.lock.Lock()
+	defer metricsOwners.lock.Unlock()
+	initialed := metrics.Collector.IsInitialed()
+	metrics.Collector.Init(cfg, hostReader)
+	if !initialed {
+		metricsOwners.generation++
+		metricsOwners.count = 0
+	} else if metricsOwners.count == 0 {
+		return 0
+	}
+	metricsOwners.count++
+	return metricsOwners.generation
+}
+
+// releaseMetrics flushes metrics.Collector, or shuts it down if the client is the last
+// owner of it, until ctx is done
+func releaseMetrics(ctx context.Context, generation int64) error {
+	metricsOwners.lock.Lock()
+	defer metricsOwners.lock.Unlock()
+	if generation != 0 && generation == metricsOwners.generation {
+		metricsOwners.count--
+		if metricsOwners.count == 0 {
+			return metrics.Collector.ShutdownWithContext(ctx)
+		}
+	}
+	return metrics.Collector.FlushWithContext(ctx)
+}
+
+// waitWithContext waits for wg until ctx is done, it returns the error of ctx if
+// the wait is not finished
+func waitWithContext(ctx context.Context, wg *sync.WaitGroup) error {
+	done := make(chan struct{})
+	go func() {
+		wg.Wait()
+		close(done)
+	}()
+	select {
+	case <-done:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// waitDrained waits for the counter of in-flight requests to be 0 until ctx is done
+func waitDrained(ctx context.Context, pending *int64) error {
+	if atomic.LoadInt64(pending) <= 0 {
+		return nil
+	}
+	ticker := time.NewTicker(drainCheckInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-ticker.C:
+			if atomic.LoadInt64(pending) <= 0 {
+				return nil
+			}
+		}
+	}
+}
+
+// startRequest counts a call in flight, which is done by finishRequest, it returns false
+// if the caller is shut down. The call is counted before checking closed, so that either
+// shutdown waits for it, or it sees closed and is rejected
+func (c *httpCaller) startRequest() bool {
+	atomic.AddInt64(&c.inflightRequests, 1)
+	if atomic.LoadInt32(&c.closed) == 1 {
+		atomic.AddInt64(&c.inflightRequests, -1)
+		return false
+	}
+	return true
+}
+
+func (c *httpCaller) finishRequest() {
+	atomic.AddInt64(&c.inflightRequests, -1)
+}
+
+// asyncExecute runs runnable as a background goroutine of the availabler,
+// which ShutdownWithContext waits for
+func (a *HostAvailablerBase) asyncExecute(runnable func()) {
+	a.goroutines.Add(1)
+	AsyncExecute(func() {
+		defer a.goroutines.Done()
+		runnable()
+	})
+}
+
+// asyncExecute runs runnable as a background goroutine of the caller, which shutdown waits for
+func (c *httpCaller) asyncExecute(runnable func()) {
+	c.goroutines.Add(1)
+	AsyncExecute(func() {
+		defer c.goroutines.Done()
+		runnable()
+	})
+}
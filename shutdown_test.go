@@ -0,0 +1,115 @@
+package core
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/byteplus-sdk/byteplus-sdk-go-rec-core/metrics"
+	"github.com/byteplus-sdk/byteplus-sdk-go-rec-core/option"
+)
+
+// blockingHostScorer blocks the scoring once block is set, until release is closed
+type blockingHostScorer struct {
+	block   int32
+	release chan struct{}
+}
+
+func (s *blockingHostScorer) ScoreHosts(hosts []string) []*HostAvailabilityScore {
+	if atomic.LoadInt32(&s.block) == 1 {
+		<-s.release
+	}
+	return reversingHostScorer{}.ScoreHosts(hosts)
+}
+
+func TestHostAvailablerBase_ShutdownWithContext(t *testing.T) {
+	scorer := &blockingHostScorer{release: make(chan struct{})}
+	a := &HostAvailablerBase{projectID: "1", skipFetchHosts: true, hostScorer: scorer}
+	if err := a.Init([]string{"a", "b"}, time.Hour, time.Millisecond); err != nil {
+		t.Fatalf("Init() error = %v", err)
+	}
+	atomic.StoreInt32(&scorer.block, 1)
+	time.Sleep(20 * time.Millisecond)
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	if err := a.ShutdownWithContext(ctx); err != context.DeadlineExceeded {
+		t.Errorf("ShutdownWithContext() error = %v, want deadline exceeded by the running scoring", err)
+	}
+	close(scorer.release)
+	a.goroutines.Wait()
+	// the later calls are no-op
+	a.Shutdown()
+}
+
+func TestHTTPClient_ShutdownWithTimeout(t *testing.T) {
+	var requests int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		// the first attempt fails, so that the request is in flight while waiting to retry
+		if atomic.AddInt32(&requests, 1) == 1 {
+			w.WriteHeader(http.StatusServiceUnavailable)
+		}
+	}))
+	defer server.Close()
+	airAuthConfig, _ := fillDefaultAirAuthConfig(nil)
+	availabler := &staticHostAvailabler{hosts: []string{"host"}}
+	c := newHTTPCaller("project", "tenant", true, "token", airAuthConfig, credential{}, availabler,
+		&CallerConfig{MaxRetryTimes: 1, RetryInterval: 100 * time.Millisecond}, "http", true, nil)
+	client := &HTTPClient{cli: c, hostAvailabler: availabler}
+	headers := map[string]string{"Request-Id": "req"}
+	done := make(chan error, 1)
+	go func() {
+		_, err := c.doHTTPRequestWithRetry(&RequestContext{}, server.URL+"/predict", headers, []byte("{}"),
+			&option.Options{}, nil)
+		done <- err
+	}()
+	time.Sleep(20 * time.Millisecond)
+	if err := client.ShutdownWithTimeout(time.Second); err != nil {
+		t.Errorf("ShutdownWithTimeout() error = %v", err)
+	}
+	select {
+	case err := <-done:
+		if err != nil {
+			t.Errorf("in-flight request error = %v", err)
+		}
+	// the request returns right after it is counted done
+	case <-time.After(50 * time.Millisecond):
+		t.Errorf("ShutdownWithTimeout() returns before the retry of in-flight request is done")
+	}
+	if _, err := c.doHTTPRequestWithRetry(&RequestContext{}, server.URL+"/predict", headers, []byte("{}"),
+		&option.Options{}, nil); err != ErrClientShutdown {
+		t.Errorf("request after shutdown error = %v, want ErrClientShutdown", err)
+	}
+	client.Shutdown()
+}
+
+func TestAcquireMetrics(t *testing.T) {
+	ctx := context.Background()
+	// the collector initialed by a client is shut down by the last client sharing it
+	first := acquireMetrics(metrics.NewConfig(), nil)
+	second := acquireMetrics(metrics.NewConfig(), nil)
+	if first == 0 || second != first {
+		t.Fatalf("generations = %d, %d, want the same owned generation", first, second)
+	}
+	_ = releaseMetrics(ctx, first)
+	if !metrics.Collector.IsInitialed() {
+		t.Errorf("collector is shut down while shared with the other client")
+	}
+	_ = releaseMetrics(ctx, second)
+	if metrics.Collector.IsInitialed() {
+		t.Errorf("collector is not shut down by the last client")
+	}
+	// the collector initialed by user is only flushed
+	metrics.Collector.InitWithOptions()
+	defer metrics.Collector.Shutdown()
+	generation := acquireMetrics(metrics.NewConfig(), nil)
+	if generation != 0 {
+		t.Errorf("generation = %d, want 0 for the collector initialed by user", generation)
+	}
+	_ = releaseMetrics(ctx, generation)
+	if !metrics.Collector.IsInitialed() {
+		t.Errorf("collector initialed by user is shut down by client")
+	}
+}
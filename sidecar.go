@@ -0,0 +1,54 @@
+package core
+
+import (
+	"fmt"
+	"net"
+	"strings"
+
+	"github.com/byteplus-sdk/byteplus-sdk-go-rec-core/logs"
+	"github.com/valyala/fasthttp"
+)
+
+const unixSocketPrefix = "unix://"
+
+// newDial returns the dial function of requests, all the connections are
+// dialed to the sidecar if config.SidecarAddress is set, otherwise the hosts of
+// HostProxies are dialed through their proxies, and the others through ProxyURL
+func newDial(config *CallerConfig) fasthttp.DialFunc {
+	if config.SidecarAddress != "" {
+		return newSidecarDial(config.SidecarAddress)
+	}
+	direct := fasthttp.Dial
+	if config.DNSRefreshInterval > 0 {
+		// resolved addresses are cached no longer than the refresh interval
+		direct = (&fasthttp.TCPDialer{DNSCacheDuration: config.DNSRefreshInterval}).Dial
+	}
+	proxyDial, err := newConfigProxyDial(config, direct)
+	if err != nil {
+		logs.Error("invalid proxy:%s, err:%v", redactProxyURL(config.ProxyURL), err)
+		proxyDial = func(addr string) (net.Conn, error) {
+			return nil, fmt.Errorf("invalid proxy: %w", err)
+		}
+	}
+	if proxyDial != nil {
+		direct = proxyDial
+	}
+	if len(config.HostProxies) > 0 {
+		return newHostProxyDial(config.HostProxies, direct)
+	}
+	return direct
+}
+
+// newSidecarDial returns a dial function ignoring the address of request,
+// and connecting to the sidecar address, which is a tcp address or a unix socket
+func newSidecarDial(sidecarAddress string) fasthttp.DialFunc {
+	if strings.HasPrefix(sidecarAddress, unixSocketPrefix) {
+		socketPath := strings.TrimPrefix(sidecarAddress, unixSocketPrefix)
+		return func(addr string) (net.Conn, error) {
+			return net.Dial("unix", socketPath)
+		}
+	}
+	return func(addr string) (net.Conn, error) {
+		return fasthttp.Dial(sidecarAddress)
+	}
+}
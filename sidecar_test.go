@@ -0,0 +1,35 @@
+package core
+
+import (
+	"net"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/valyala/fasthttp"
+)
+
+func TestSidecarDial(t *testing.T) {
+	socketPath := filepath.Join(t.TempDir(), "egress.sock")
+	ln, err := net.Listen("unix", socketPath)
+	if err != nil {
+		t.Skipf("unix socket is not supported: %v", err)
+	}
+	defer ln.Close()
+	var gotHost string
+	go fasthttp.Serve(ln, func(ctx *fasthttp.RequestCtx) {
+		gotHost = string(ctx.Host())
+	})
+	c := &httpCaller{config: &CallerConfig{SidecarHostHeader: "rec.byteplus.com"}}
+	cli := &fasthttp.Client{Dial: newSidecarDial(unixSocketPrefix + socketPath)}
+	request := c.acquireRequest(fasthttp.MethodPost, "http://unused.byteplus.com/predict", map[string]string{}, nil)
+	defer fasthttp.ReleaseRequest(request)
+	response := fasthttp.AcquireResponse()
+	defer fasthttp.ReleaseResponse(response)
+	if err = cli.DoTimeout(request, response, time.Second); err != nil {
+		t.Fatalf("request to sidecar fail, err:%v", err)
+	}
+	if gotHost != "rec.byteplus.com" {
+		t.Errorf("Host = %v, want rec.byteplus.com", gotHost)
+	}
+}
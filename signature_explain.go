@@ -0,0 +1,113 @@
+package core
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/byteplus-sdk/byteplus-sdk-go-rec-core/logs"
+	"github.com/byteplus-sdk/byteplus-sdk-go-rec-core/metrics"
+	"github.com/valyala/fasthttp"
+)
+
+const redactedValue = "***"
+
+// headers whose value should not appear in logs
+var sensitiveSignedHeaders = []string{"x-security-token"}
+
+// SignatureExplanation is the intermediate results of signing a request,
+// which can be compared with the server side to find out a signature mismatch
+type SignatureExplanation struct {
+	// "HMAC-SHA256" for ak/sk, or the air auth version, such as "v1"
+	Algorithm string
+	// The canonical request. For air auth v1, it is the signed fields joined by
+	// "\n" in order, with the token omitted and the body replaced by its sha256
+	CanonicalRequest string
+	// The string to sign, empty for air auth
+	StringToSign string
+	// The signed header names joined by ";", empty for air auth
+	SignedHeaders string
+	Signature     string
+}
+
+func (e *SignatureExplanation) String() string {
+	return fmt.Sprintf("algorithm:%s\nsigned_headers:%s\nsignature:%s\ncanonical_request:\n%s\nstring_to_sign:\n%s",
+		e.Algorithm, e.SignedHeaders, e.Signature, e.CanonicalRequest, e.StringToSign)
+}
+
+// redacted returns a copy with the values of sensitive headers masked
+func (e *SignatureExplanation) redacted() *SignatureExplanation {
+	result := *e
+	lines := strings.Split(result.CanonicalRequest, "\n")
+	for i, line := range lines {
+		for _, header := range sensitiveSignedHeaders {
+			if strings.HasPrefix(line, header+":") {
+				lines[i] = header + ":" + redactedValue
+			}
+		}
+	}
+	result.CanonicalRequest = strings.Join(lines, "\n")
+	return &result
+}
+
+// ExplainSignature recalculates the signature of the request with the client's
+// credentials, and returns the intermediate results, which can be compared with
+// the server's expectation offline. The request is not modified. For a request
+// already signed, the signing time and nonce in its headers are reused.
+func (h *HTTPClient) ExplainSignature(req *fasthttp.Request) *SignatureExplanation {
+	return h.cli.explainSignature(req)
+}
+
+// DebugCanonicalRequest returns the canonical request, string to sign and signed headers
+// of signing req by ak/sk with cred, which can be compared with the logs of the server
+// when it rejects the signature. The request is not modified, and the signing time in
+// its headers is reused if it has been signed. The sensitive header values are redacted
+func DebugCanonicalRequest(req *fasthttp.Request, cred SigningCredential) *SignatureExplanation {
+	return explainSignatureV4(req, cred.credential()).redacted()
+}
+
+func (c *httpCaller) explainSignature(req *fasthttp.Request) *SignatureExplanation {
+	if c.useAirAuth {
+		reqCopy := fasthttp.AcquireRequest()
+		defer fasthttp.ReleaseRequest(reqCopy)
+		req.CopyTo(reqCopy)
+		return c.explainAirAuthSignature(reqCopy)
+	}
+	cred, _ := c.credentialOf(nil)
+	return explainSignatureV4(req, cred)
+}
+
+func (c *httpCaller) explainAirAuthSignature(req *fasthttp.Request) *SignatureExplanation {
+	var (
+		ts       = string(req.Header.Peek("Tenant-Ts"))
+		nonce    = string(req.Header.Peek("Tenant-Nonce"))
+		version  = string(req.Header.Peek(airAuthVersionHeader))
+		reqBytes = req.Body()
+	)
+	if version == string(AirAuthVersionV2) {
+		canonicalRequest := buildAirAuthCanonicalRequestV2(req, c.tenantID, ts, nonce, reqBytes)
+		return &SignatureExplanation{
+			Algorithm:        version,
+			CanonicalRequest: canonicalRequest,
+			Signature:        CalAirAuthSignatureV2(c.getAirAuthToken(), canonicalRequest),
+		}
+	}
+	var path string
+	if version == airAuthVersionV1Path {
+		path = string(req.URI().Path())
+	} else {
+		version = string(AirAuthVersionV1)
+	}
+	return &SignatureExplanation{
+		Algorithm:        version,
+		CanonicalRequest: concat("\n", "<token>", hashSHA256(reqBytes), c.tenantID, ts, nonce, path),
+		Signature:        CalAirAuthSignature(c.getAirAuthToken(), c.tenantID, reqBytes, ts, nonce, path),
+	}
+}
+
+// logSignatureExplanation logs how the request is signed when it is rejected by auth
+func (c *httpCaller) logSignatureExplanation(reqID, url string, req *fasthttp.Request, statusCode int) {
+	explanation := c.explainSignature(req).redacted()
+	metrics.Warn(reqID, "[ByteplusSDK] request is rejected by auth, project_id:%s, url:%s, code:%d, signature:\n%s",
+		c.projectID, url, statusCode, explanation)
+	logs.Warn("request is rejected by auth, url:%s code:%d signature:\n%s", url, statusCode, explanation)
+}
@@ -0,0 +1,69 @@
+package core
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/valyala/fasthttp"
+)
+
+func TestHTTPCaller_explainSignature(t *testing.T) {
+	c := &httpCaller{
+		credentials: credential{
+			accessKeyID:     "ak",
+			secretAccessKey: "sk",
+			region:          "ap-singapore-1",
+			service:         "air",
+			sessionToken:    "session",
+		},
+	}
+	req := fasthttp.AcquireRequest()
+	defer fasthttp.ReleaseRequest(req)
+	req.Header.SetMethod(fasthttp.MethodPost)
+	req.SetRequestURI("https://byteplus.com/predict/api/x?stage=pre")
+	req.Header.Set("Content-Type", "application/json")
+	req.SetBodyRaw([]byte("{}"))
+	sign(req, c.credentials)
+
+	explanation := c.explainSignature(req)
+	authorization := string(req.Header.Peek("Authorization"))
+	if !strings.HasSuffix(authorization, "Signature="+explanation.Signature) {
+		t.Errorf("explained signature = %v, authorization = %v", explanation.Signature, authorization)
+	}
+	if strings.Contains(explanation.SignedHeaders, "x-security-token") {
+		t.Errorf("signed headers = %v, should not contain x-security-token", explanation.SignedHeaders)
+	}
+}
+
+func TestDebugCanonicalRequest(t *testing.T) {
+	cred := SigningCredential{AccessKeyID: "ak", SecretAccessKey: "sk", SessionToken: "session",
+		Region: "ap-singapore-1", Service: "air"}
+	req := fasthttp.AcquireRequest()
+	defer fasthttp.ReleaseRequest(req)
+	req.Header.SetMethod(fasthttp.MethodPost)
+	req.SetRequestURI("https://byteplus.com/predict/api/x?stage=pre")
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("X-Date", "20231110T080000Z")
+	req.SetBodyRaw([]byte("{}"))
+
+	explanation := DebugCanonicalRequest(req, cred)
+	if len(req.Header.Peek("Authorization")) != 0 {
+		t.Errorf("DebugCanonicalRequest() should not sign the request")
+	}
+	if !strings.HasPrefix(explanation.CanonicalRequest, "POST\n/predict/api/x\nstage=pre\n") ||
+		!strings.Contains(explanation.StringToSign, "20231110T080000Z") {
+		t.Errorf("canonical request = %q, string to sign = %q", explanation.CanonicalRequest, explanation.StringToSign)
+	}
+	if strings.Contains(explanation.CanonicalRequest, "session") {
+		t.Errorf("canonical request = %q, should not contain x-security-token", explanation.CanonicalRequest)
+	}
+	// the explanation matches the signature signed later
+	signed := SignRequest(req, cred)
+	if signed.Signature != explanation.Signature || signed.SignedHeaders != explanation.SignedHeaders {
+		t.Errorf("debug signature = %s %s, signed = %s %s", explanation.Signature, explanation.SignedHeaders,
+			signed.Signature, signed.SignedHeaders)
+	}
+	if resigned := DebugCanonicalRequest(req, cred); resigned.Signature != signed.Signature {
+		t.Errorf("debug signature of signed request = %s, want %s", resigned.Signature, signed.Signature)
+	}
+}
@@ -0,0 +1,72 @@
+package core
+
+import (
+	"crypto/hmac"
+	"strings"
+
+	"github.com/valyala/fasthttp"
+)
+
+// SigningCredential is the credential and the scope signing a request by ak/sk
+type SigningCredential struct {
+	AccessKeyID     string
+	SecretAccessKey string
+	// The session token of the temporary credential, it is optional
+	SessionToken string
+	// The region of the credential scope, such as 'ap-singapore-1'
+	Region string
+	// The service of the credential scope, such as 'air'
+	Service string
+}
+
+func (c SigningCredential) credential() credential {
+	return credential{
+		accessKeyID:     c.AccessKeyID,
+		secretAccessKey: c.SecretAccessKey,
+		sessionToken:    c.SessionToken,
+		region:          c.Region,
+		service:         c.Service,
+	}
+}
+
+// SignRequest signs the request by ak/sk the same as the requests sent by HTTPClient,
+// such as the requests built by a custom bulk loader, and returns the intermediate
+// results of signing. The X-Date header is kept if it is set, so that a request can be
+// signed reproducibly, otherwise it is set to the current time
+func SignRequest(req *fasthttp.Request, cred SigningCredential) *SignatureExplanation {
+	prepareRequestV4(req)
+	explanation, meta := calSignatureV4(req, cred.credential())
+	req.Header.Set("Authorization", buildAuthHeader(explanation.Signature, meta, cred.credential()))
+	if cred.SessionToken != "" {
+		req.Header.Set("X-Security-Token", cred.SessionToken)
+	}
+	return explanation
+}
+
+// VerifyRequest recalculates the signature of the signed request with cred offline,
+// and reports whether it matches the signature in the Authorization header. The
+// request is not modified
+func VerifyRequest(req *fasthttp.Request, cred SigningCredential) (bool, *SignatureExplanation) {
+	explanation := explainSignatureV4(req, cred.credential())
+	authorization := string(req.Header.Peek("Authorization"))
+	idx := strings.LastIndex(authorization, "Signature=")
+	if idx < 0 {
+		return false, explanation
+	}
+	signed := authorization[idx+len("Signature="):]
+	return hmac.Equal([]byte(signed), []byte(explanation.Signature)), explanation
+}
+
+// explainSignatureV4 recalculates the signature of a copy of req, the signing
+// time in its headers is reused if it has been signed
+func explainSignatureV4(req *fasthttp.Request, cred credential) *SignatureExplanation {
+	reqCopy := fasthttp.AcquireRequest()
+	defer fasthttp.ReleaseRequest(reqCopy)
+	req.CopyTo(reqCopy)
+	// the headers are added after signing
+	reqCopy.Header.Del("Authorization")
+	reqCopy.Header.Del("X-Security-Token")
+	prepareRequestV4(reqCopy)
+	explanation, _ := calSignatureV4(reqCopy, cred)
+	return explanation
+}
@@ -0,0 +1,83 @@
+package core
+
+import (
+	"encoding/json"
+	"os"
+	"testing"
+
+	"github.com/valyala/fasthttp"
+)
+
+// the vectors in testdata can be used to verify the signing of other implementations
+type signV4Vectors struct {
+	Credential struct {
+		AccessKeyID     string `json:"access_key_id"`
+		SecretAccessKey string `json:"secret_access_key"`
+		Region          string `json:"region"`
+		Service         string `json:"service"`
+	} `json:"credential"`
+	XDate   string `json:"x_date"`
+	Vectors []struct {
+		Name             string            `json:"name"`
+		Method           string            `json:"method"`
+		URL              string            `json:"url"`
+		Headers          map[string]string `json:"headers"`
+		Body             string            `json:"body"`
+		CanonicalRequest string            `json:"canonical_request"`
+		StringToSign     string            `json:"string_to_sign"`
+		SignedHeaders    string            `json:"signed_headers"`
+		Signature        string            `json:"signature"`
+		Authorization    string            `json:"authorization"`
+	} `json:"vectors"`
+}
+
+func TestSignRequest_vectors(t *testing.T) {
+	data, err := os.ReadFile("testdata/sign_v4_vectors.json")
+	if err != nil {
+		t.Fatal(err)
+	}
+	vectors := &signV4Vectors{}
+	if err = json.Unmarshal(data, vectors); err != nil {
+		t.Fatal(err)
+	}
+	cred := SigningCredential{
+		AccessKeyID:     vectors.Credential.AccessKeyID,
+		SecretAccessKey: vectors.Credential.SecretAccessKey,
+		Region:          vectors.Credential.Region,
+		Service:         vectors.Credential.Service,
+	}
+	for _, tt := range vectors.Vectors {
+		t.Run(tt.Name, func(t *testing.T) {
+			req := fasthttp.AcquireRequest()
+			defer fasthttp.ReleaseRequest(req)
+			req.Header.SetMethod(tt.Method)
+			req.SetRequestURI(tt.URL)
+			for k, v := range tt.Headers {
+				req.Header.Set(k, v)
+			}
+			req.Header.Set("X-Date", vectors.XDate)
+			req.SetBodyRaw([]byte(tt.Body))
+			explanation := SignRequest(req, cred)
+			if explanation.CanonicalRequest != tt.CanonicalRequest {
+				t.Errorf("canonical request = %q, want %q", explanation.CanonicalRequest, tt.CanonicalRequest)
+			}
+			if explanation.StringToSign != tt.StringToSign {
+				t.Errorf("string to sign = %q, want %q", explanation.StringToSign, tt.StringToSign)
+			}
+			if explanation.SignedHeaders != tt.SignedHeaders || explanation.Signature != tt.Signature {
+				t.Errorf("signed headers = %s signature = %s, want %s %s", explanation.SignedHeaders,
+					explanation.Signature, tt.SignedHeaders, tt.Signature)
+			}
+			if authorization := string(req.Header.Peek("Authorization")); authorization != tt.Authorization {
+				t.Errorf("Authorization = %s, want %s", authorization, tt.Authorization)
+			}
+			if ok, _ := VerifyRequest(req, cred); !ok {
+				t.Errorf("VerifyRequest() = false, want true")
+			}
+			req.SetBodyRaw([]byte(tt.Body + "tampered"))
+			if ok, _ := VerifyRequest(req, cred); ok {
+				t.Errorf("VerifyRequest() of tampered body = true, want false")
+			}
+		})
+	}
+}
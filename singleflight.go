@@ -0,0 +1,69 @@
+package core
+
+import (
+	"fmt"
+	"sync"
+)
+
+// singleflightGroup collapses concurrent calls with the same key into one
+// execution, all callers receive the result of the execution.
+type singleflightGroup struct {
+	lock  sync.Mutex
+	calls map[string]*singleflightCall
+}
+
+type singleflightCall struct {
+	wg    sync.WaitGroup
+	value []byte
+	err   error
+	// the number of the callers waiting for the call, it is changed with the lock of group held
+	dups int
+}
+
+// do executes fn for the key, if an execution for the same key is in flight,
+// waits for it and returns its result. shared reports whether the result
+// comes from another caller's execution. A panic of fn is returned as the
+// error to all the callers, instead of leaving the waiting ones a nil result.
+// Each caller sharing the result receives its own copy of value, so that a caller
+// changing it does not change the others'.
+func (g *singleflightGroup) do(key string, fn func() ([]byte, error)) (value []byte, err error, shared bool) {
+	g.lock.Lock()
+	if g.calls == nil {
+		g.calls = make(map[string]*singleflightCall)
+	}
+	if call, exist := g.calls[key]; exist {
+		call.dups++
+		g.lock.Unlock()
+		call.wg.Wait()
+		return copyBytes(call.value), call.err, true
+	}
+	call := &singleflightCall{}
+	call.wg.Add(1)
+	g.calls[key] = call
+	g.lock.Unlock()
+
+	defer func() {
+		if r := recover(); r != nil {
+			call.value, call.err = nil, fmt.Errorf("singleflight call panic: %v", r)
+			value, err = call.value, call.err
+		}
+		g.lock.Lock()
+		delete(g.calls, key)
+		dups := call.dups
+		g.lock.Unlock()
+		// no more caller joins once the call is deleted, the value is kept for the waiting ones
+		if dups > 0 {
+			value = copyBytes(call.value)
+		}
+		call.wg.Done()
+	}()
+	call.value, call.err = fn()
+	return call.value, call.err, false
+}
+
+func copyBytes(value []byte) []byte {
+	if value == nil {
+		return nil
+	}
+	return append([]byte(nil), value...)
+}
@@ -0,0 +1,75 @@
+package core
+
+import (
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestSingleflightGroup_panic(t *testing.T) {
+	g := &singleflightGroup{}
+	started := make(chan struct{})
+	var wg sync.WaitGroup
+	var followerErr error
+	var followerShared bool
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		<-started
+		_, followerErr, followerShared = g.do("key", func() ([]byte, error) {
+			return []byte("follower"), nil
+		})
+	}()
+	_, err, _ := g.do("key", func() ([]byte, error) {
+		close(started)
+		time.Sleep(50 * time.Millisecond)
+		panic("boom")
+	})
+	wg.Wait()
+	if err == nil {
+		t.Errorf("leader err = nil, want the panic as error")
+	}
+	if followerShared && followerErr == nil {
+		t.Errorf("follower got nil error of the panicked call")
+	}
+}
+
+func TestSingleflightGroup_copy(t *testing.T) {
+	g := &singleflightGroup{}
+	release := make(chan struct{})
+	shared := make(chan []byte, 1)
+	go func() {
+		// wait for the call to be in flight, then join it
+		for {
+			g.lock.Lock()
+			_, exist := g.calls["key"]
+			g.lock.Unlock()
+			if exist {
+				break
+			}
+			time.Sleep(time.Millisecond)
+		}
+		go func() {
+			value, _, _ := g.do("key", nil)
+			shared <- value
+		}()
+		for {
+			g.lock.Lock()
+			dups := g.calls["key"].dups
+			g.lock.Unlock()
+			if dups > 0 {
+				break
+			}
+			time.Sleep(time.Millisecond)
+		}
+		close(release)
+	}()
+	value, _, _ := g.do("key", func() ([]byte, error) {
+		<-release
+		return []byte("1"), nil
+	})
+	value[0] = '2'
+	if got := <-shared; string(got) != "1" {
+		t.Errorf("shared value = %s, want unchanged by the other caller", got)
+	}
+}
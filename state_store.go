@@ -0,0 +1,145 @@
+package core
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/byteplus-sdk/byteplus-sdk-go-rec-core/logs"
+)
+
+// AvailablerState is the state learned by host availabler, it is saved to StateStore,
+// so that the restarted processes can start with the learned host health
+type AvailablerState struct {
+	// the project of state, the state of other projects is not used
+	ProjectID string `json:"project_id,omitempty"`
+	// path->hosts ordered by availability, see HostAvailablerBase
+	HostConfig map[string][]string `json:"host_config"`
+	// the failure rate of each host in the recent pings
+	HostFailureRates map[string]float64 `json:"host_failure_rates,omitempty"`
+	SavedAt          time.Time          `json:"saved_at"`
+}
+
+// StateStore loads and saves the state of host availabler of a project.
+// It can be backed by a mounted volume or a ConfigMap to share the state
+// across pod restarts
+type StateStore interface {
+	// Load returns nil state without error if no state is saved
+	Load(projectID string) (*AvailablerState, error)
+	Save(projectID string, state *AvailablerState) error
+}
+
+// NoopStateStore neither loads nor saves any state
+type NoopStateStore struct {
+}
+
+func (s NoopStateStore) Load(projectID string) (*AvailablerState, error) {
+	return nil, nil
+}
+
+func (s NoopStateStore) Save(projectID string, state *AvailablerState) error {
+	return nil
+}
+
+// FileStateStore saves the state of each project as a json file in Dir
+type FileStateStore struct {
+	Dir string
+}
+
+func (s *FileStateStore) path(projectID string) string {
+	return filepath.Join(s.Dir, "byteplus_availabler_state_"+projectID+".json")
+}
+
+func (s *FileStateStore) Load(projectID string) (*AvailablerState, error) {
+	data, err := os.ReadFile(s.path(projectID))
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	state := &AvailablerState{}
+	if err = json.Unmarshal(data, state); err != nil {
+		return nil, err
+	}
+	return state, nil
+}
+
+// Save writes the state to a temp file and renames it, so that
+// the processes loading concurrently never see a partial file
+func (s *FileStateStore) Save(projectID string, state *AvailablerState) error {
+	data, err := json.Marshal(state)
+	if err != nil {
+		return err
+	}
+	return writeFileAtomically(s.path(projectID), data)
+}
+
+// writeFileAtomically writes data to a temp file in the same dir and renames it to path
+func writeFileAtomically(path string, data []byte) error {
+	tmpFile, err := os.CreateTemp(filepath.Dir(path), "."+filepath.Base(path)+".*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmpFile.Name())
+	if _, err = tmpFile.Write(data); err != nil {
+		tmpFile.Close()
+		return err
+	}
+	if err = tmpFile.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmpFile.Name(), path)
+}
+
+// hostStatsKeeper is implemented by the HostScorer which keeps the stats of hosts
+// in memory, the stats are saved and restored with AvailablerState
+type hostStatsKeeper interface {
+	hostFailureRates() map[string]float64
+	restoreHostFailureRates(rates map[string]float64)
+}
+
+// loadState restores the host stats of scorer from stateStore, and returns the
+// loaded state, or nil if there is no state
+func (a *HostAvailablerBase) loadState() *AvailablerState {
+	if a.stateStore == nil {
+		return nil
+	}
+	state, err := a.stateStore.Load(a.projectID)
+	if err != nil {
+		logs.Warn("load availabler state fail, project_id:%s err:%v", a.projectID, err)
+		return nil
+	}
+	if state == nil {
+		return nil
+	}
+	// the store may be shared by mistake, the hosts of other projects are not used
+	if state.ProjectID != "" && state.ProjectID != a.projectID {
+		logs.Warn("availabler state is not valid, project_id:%s saved_project_id:%s",
+			a.projectID, state.ProjectID)
+		return nil
+	}
+	if keeper, ok := a.hostScorer.(hostStatsKeeper); ok && len(state.HostFailureRates) > 0 {
+		keeper.restoreHostFailureRates(state.HostFailureRates)
+	}
+	logs.Info("load availabler state, project_id:%s saved_at:%s", a.projectID, state.SavedAt)
+	return state
+}
+
+func (a *HostAvailablerBase) saveState() {
+	if a.stateStore == nil {
+		return
+	}
+	state := &AvailablerState{
+		ProjectID:  a.projectID,
+		HostConfig: a.loadHostConfig(),
+		SavedAt:    time.Now(),
+	}
+	if keeper, ok := a.hostScorer.(hostStatsKeeper); ok {
+		state.HostFailureRates = keeper.hostFailureRates()
+	}
+	if err := a.stateStore.Save(a.projectID, state); err != nil {
+		logs.Warn("save availabler state fail, project_id:%s err:%v", a.projectID, err)
+	}
+}
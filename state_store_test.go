@@ -0,0 +1,41 @@
+package core
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestFileStateStore(t *testing.T) {
+	store := &FileStateStore{Dir: t.TempDir()}
+	state, err := store.Load("1")
+	if err != nil || state != nil {
+		t.Fatalf("Load() without saved state = %v, %v", state, err)
+	}
+	saved := &AvailablerState{
+		HostConfig:       map[string][]string{"*": {"a.byteplus.com", "b.byteplus.com"}},
+		HostFailureRates: map[string]float64{"a.byteplus.com": 0.5},
+	}
+	if err = store.Save("1", saved); err != nil {
+		t.Fatalf("Save() err:%v", err)
+	}
+	state, err = store.Load("1")
+	if err != nil {
+		t.Fatalf("Load() err:%v", err)
+	}
+	if !reflect.DeepEqual(state.HostConfig, saved.HostConfig) ||
+		!reflect.DeepEqual(state.HostFailureRates, saved.HostFailureRates) {
+		t.Errorf("Load() = %+v, want %+v", state, saved)
+	}
+}
+
+func TestPingHostAvailabler_restoreHostFailureRates(t *testing.T) {
+	availabler := &pingHostAvailabler{
+		config:        fillDefaultConfig(&PingHostAvailablerConfig{WindowSize: 10}),
+		hostWindowMap: make(map[string]*window),
+	}
+	availabler.restoreHostFailureRates(map[string]float64{"a.byteplus.com": 0.3})
+	rates := availabler.hostFailureRates()
+	if rates["a.byteplus.com"] != 0.3 {
+		t.Errorf("failure rate = %v, want 0.3", rates["a.byteplus.com"])
+	}
+}
@@ -1,18 +1,24 @@
 package core
 
+import "github.com/byteplus-sdk/byteplus-sdk-go-rec-core/coreerr"
+
+// Deprecated: use coreerr.IsUploadSuccessCode
 func IsUploadSuccess(code int32) bool {
 	// It is still considered as success, which is rejected for idempotent
-	return code == StatusCodeSuccess || code == StatusCodeIdempotent
+	return coreerr.IsUploadSuccessCode(code)
 }
 
+// Deprecated: use coreerr.IsSuccessCode
 func IsSuccess(code int32) bool {
-	return code == StatusCodeSuccess || code == 200
+	return coreerr.IsSuccessCode(code)
 }
 
+// Deprecated: use coreerr.ClassOfCode
 func IsServerOverload(code int32) bool {
-	return code == StatusCodeTooManyRequest
+	return coreerr.ClassOfCode(code) == coreerr.Throttled
 }
 
+// Deprecated: use coreerr.ClassOfCode
 func IsLossOperation(code int32) bool {
-	return code == StatusCodeOperationLoss
+	return code == coreerr.StatusCodeOperationLoss
 }
@@ -0,0 +1,223 @@
+package core
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"io"
+	"os"
+	"sync/atomic"
+	"time"
+
+	"github.com/byteplus-sdk/byteplus-sdk-go-rec-core/logs"
+	"github.com/byteplus-sdk/byteplus-sdk-go-rec-core/metrics"
+	"github.com/byteplus-sdk/byteplus-sdk-go-rec-core/option"
+	"github.com/valyala/fasthttp"
+)
+
+// DoStreamRequest sends the body read from body with contentType, such as the large
+// batch writes, and returns the response body. The body is compressed into a temporary
+// file while it is read, then the file is signed and streamed to the server, so that
+// the whole body is never held in memory. It is routed and retried the same as
+// DoRawRequest, but it is not hedged, cached or shared
+func (h *HTTPClient) DoStreamRequest(path, contentType string, body io.Reader,
+	options *option.Options) ([]byte, error) {
+	reqCtx, err := h.newRequestContext(path, options)
+	if err != nil {
+		return nil, err
+	}
+	url, options := h.route(reqCtx)
+	rspBytes, err := h.cli.doStreamRequest(reqCtx, url, contentType, body, options)
+	h.callRequestHooks(reqCtx, err)
+	return rspBytes, err
+}
+
+// spooledBody is the compressed body of the streaming request spooled to a file
+type spooledBody struct {
+	file *os.File
+	size int64
+	// the hex sha256 of the spooled bytes
+	hash string
+}
+
+// spoolBody compresses body by encoding into a temporary file, body is written
+// as is if encoding is empty
+func spoolBody(body io.Reader, encoding string) (*spooledBody, error) {
+	file, err := os.CreateTemp("", "byteplus-stream-*")
+	if err != nil {
+		return nil, err
+	}
+	spooled := &spooledBody{file: file}
+	hasher := sha256.New()
+	if err = writeSpooledBody(io.MultiWriter(file, hasher), body, encoding); err != nil {
+		spooled.close()
+		return nil, err
+	}
+	info, err := file.Stat()
+	if err != nil {
+		spooled.close()
+		return nil, err
+	}
+	spooled.size = info.Size()
+	spooled.hash = hex.EncodeToString(hasher.Sum(nil))
+	return spooled, nil
+}
+
+func writeSpooledBody(w io.Writer, body io.Reader, encoding string) error {
+	if encoding == "" {
+		_, err := io.Copy(w, body)
+		return err
+	}
+	compressWriter, err := newCompressWriter(encoding, w)
+	if err != nil {
+		return err
+	}
+	if _, err = io.Copy(compressWriter, body); err != nil {
+		_ = compressWriter.Close()
+		return err
+	}
+	return compressWriter.Close()
+}
+
+// reader returns a new reader of the whole body, it is not closed by fasthttp
+// after sending, so that the body can be sent again on retry
+func (b *spooledBody) reader() io.Reader {
+	return io.NewSectionReader(b.file, 0, b.size)
+}
+
+// payload returns the body to sign, its hash is the hash computed while spooling,
+// so that the file is not read again to hash it
+func (b *spooledBody) payload() signPayload {
+	return signPayload{
+		write: func(w io.Writer) {
+			_, _ = io.Copy(w, b.reader())
+		},
+		sum: b.hash,
+	}
+}
+
+func (b *spooledBody) close() {
+	_ = b.file.Close()
+	_ = os.Remove(b.file.Name())
+}
+
+func (c *httpCaller) doStreamRequest(reqCtx *RequestContext, url, contentType string, body io.Reader,
+	options *option.Options) ([]byte, error) {
+	headers := c.buildHeaders(options, contentType)
+	reqID := headers["Request-Id"]
+	reqCtx.RequestID = reqID
+	url = c.withOptionQueries(options, url)
+	encoding := c.config.RequestContentEncoding
+	if c.config.DisableCompression {
+		encoding = ""
+		delete(headers, "Content-Encoding")
+	}
+	spooled, err := spoolBody(body, encoding)
+	if err != nil {
+		metricsTags := []string{
+			"type:spool_stream_body_fail",
+			"project_id:" + c.projectID,
+			"url:" + escapeMetricsTagValue(url),
+		}
+		metrics.Counter(metricsKeyCommonError, 1, metricsTags...)
+		logs.Error("spool stream request body fail, request_id:%s url:%s err:%v", reqID, url, err)
+		return nil, err
+	}
+	defer spooled.close()
+	logs.Debug("spool stream request body, request_id:%s url:%s size:%d", reqID, url, spooled.size)
+
+	var rspBytes []byte
+	err = c.retry(reqCtx, reqID, url, options, func() error {
+		var err error
+		rspBytes, err = c.doStreamAttempt(reqID, url, headers, spooled, options)
+		return err
+	})
+	return rspBytes, err
+}
+
+// doStreamAttempt sends the spooled body to url once
+func (c *httpCaller) doStreamAttempt(reqID, url string, headers map[string]string,
+	spooled *spooledBody, options *option.Options) ([]byte, error) {
+	request := c.acquireRequest(requestMethod(options), url, headers, nil)
+	request.SetBodyStream(spooled.reader(), int(spooled.size))
+	response := fasthttp.AcquireResponse()
+	abandoned := false
+	defer func() {
+		// the abandoned request and response may be still in use by fasthttp
+		if abandoned {
+			return
+		}
+		fasthttp.ReleaseRequest(request)
+		fasthttp.ReleaseResponse(response)
+	}()
+	timeout := options.Timeout
+	if timeout <= 0 {
+		timeout = defaultTimeout
+	}
+	atomic.AddInt64(&c.stats.totalRequests, 1)
+	atomic.AddInt64(&c.stats.pendingRequests, 1)
+	start := time.Now()
+	// the outcome of transport is reported to the host availabler, see passiveResult
+	var sent bool
+	var sendErr error
+	invoker := func(request *fasthttp.Request, response *fasthttp.Response) error {
+		if err := c.withPayloadAuthHeaders(request, spooled.payload(), options); err != nil {
+			return err
+		}
+		sent = true
+		if options.Context == nil {
+			sendErr = c.transport.DoDeadline(request, response, time.Now().Add(timeout))
+			return sendErr
+		}
+		abandoned, sendErr = c.doWithContext(options.Context, request, response, timeout)
+		return sendErr
+	}
+	err := c.intercept(options.Context, invoker)(request, response)
+	atomic.AddInt64(&c.stats.pendingRequests, -1)
+	cost := time.Since(start)
+	if success, reported := passiveResult(sent, sendErr, response); reported {
+		c.reportResult(url, success, cost)
+	}
+	metricsTags := []string{
+		"project_id:" + c.projectID,
+		"url:" + escapeMetricsTagValue(url),
+	}
+	metrics.Timer(metricsKeyRequestTotalCost, cost.Milliseconds(), metricsTags...)
+	metrics.Counter(metricsKeyRequestCount, 1, metricsTags...)
+	metrics.Rate(metricsKeyRequestCount, 1, metricsTags...)
+	logs.Debug("stream url:%s, size:%d, cost:%dms", url, spooled.size, cost.Milliseconds())
+	if err != nil {
+		atomic.AddInt64(&c.stats.failedRequests, 1)
+		if options.Context == nil || options.Context.Err() == nil {
+			if c.breaker != nil {
+				c.breaker.record(hostOfURL(url), true)
+			}
+		}
+		metricsTags := []string{
+			"type:stream_request_occur_err",
+			"project_id:" + c.projectID,
+			"url:" + escapeMetricsTagValue(url),
+		}
+		metrics.Counter(metricsKeyCommonError, 1, metricsTags...)
+		metrics.Error(reqID, "[ByteplusSDK] do stream request occur err, project_id:%s, url:%s, err:%v",
+			c.projectID, url, err)
+		logs.Error("do stream request occur error, err:%v url:%s", err, url)
+		return nil, newRequestError(reqID, url, 0, nil, err)
+	}
+	if c.breaker != nil {
+		c.breaker.record(hostOfURL(url), response.StatusCode() >= fasthttp.StatusInternalServerError)
+	}
+	if response.StatusCode() != fasthttp.StatusOK {
+		atomic.AddInt64(&c.stats.failedRequests, 1)
+		c.logFailureStatus(reqID, url, response)
+		c.publishFailureStatus(reqID, url, response.StatusCode())
+		statusErr := &StatusError{
+			Status: response.StatusCode(),
+			retryAfter: parseRetryAfter(func(key string) string {
+				return string(response.Header.Peek(key))
+			}, time.Now()),
+		}
+		rawBody := failureBody(url, response)
+		return nil, newRequestError(reqID, url, response.StatusCode(), rawBody, statusErr)
+	}
+	return decompressResponse(url, response)
+}
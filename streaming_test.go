@@ -0,0 +1,80 @@
+package core
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/byteplus-sdk/byteplus-sdk-go-rec-core/option"
+	"github.com/valyala/fasthttp"
+)
+
+func TestHTTPCaller_doStreamRequest(t *testing.T) {
+	content := strings.Repeat(`{"id":"1","name":"item"}`+"\n", 1000)
+	var bodies [][]byte
+	var signature, ts, nonce, contentEncoding string
+	transport := TransportFunc(func(request *fasthttp.Request, response *fasthttp.Response, deadline time.Time) error {
+		if !request.IsBodyStream() {
+			t.Errorf("request body should be streamed")
+		}
+		bodies = append(bodies, append([]byte(nil), request.Body()...))
+		signature = string(request.Header.Peek("Tenant-Signature"))
+		ts = string(request.Header.Peek("Tenant-Ts"))
+		nonce = string(request.Header.Peek("Tenant-Nonce"))
+		contentEncoding = string(request.Header.Peek("Content-Encoding"))
+		if len(bodies) == 1 {
+			response.SetStatusCode(fasthttp.StatusServiceUnavailable)
+			return nil
+		}
+		response.SetStatusCode(fasthttp.StatusOK)
+		response.SetBodyString("ok")
+		return nil
+	})
+	airAuthConfig, _ := fillDefaultAirAuthConfig(nil)
+	c := newHTTPCaller("project", "tenant", true, "token", airAuthConfig, credential{},
+		&staticHostAvailabler{hosts: []string{"host"}}, &CallerConfig{MaxRetryTimes: 1}, "http", false, transport)
+	options := &option.Options{}
+	reqCtx := newRequestContext("/write", options)
+	rspBytes, err := c.doStreamRequest(reqCtx, "http://host/write", "application/x-ndjson",
+		strings.NewReader(content), options)
+	if err != nil || string(rspBytes) != "ok" {
+		t.Fatalf("doStreamRequest() = %s, %v", rspBytes, err)
+	}
+	if len(bodies) != 2 || !bytes.Equal(bodies[0], bodies[1]) {
+		t.Fatalf("the retried body should be the same as the first one")
+	}
+	if contentEncoding != ContentEncodingGzip {
+		t.Errorf("Content-Encoding = %q, want gzip", contentEncoding)
+	}
+	decompressed, err := fasthttp.AppendGunzipBytes(nil, bodies[1])
+	if err != nil || string(decompressed) != content {
+		t.Errorf("decompressed body is not the content, err:%v", err)
+	}
+	if want := CalAirAuthSignature("token", "tenant", bodies[1], ts, nonce, ""); signature != want {
+		t.Errorf("signature = %s, want %s", signature, want)
+	}
+}
+
+func TestHTTPCaller_doStreamRequestV4(t *testing.T) {
+	var payloadHash string
+	var body []byte
+	transport := TransportFunc(func(request *fasthttp.Request, response *fasthttp.Response, deadline time.Time) error {
+		payloadHash = string(request.Header.Peek("X-Content-Sha256"))
+		body = append([]byte(nil), request.Body()...)
+		response.SetStatusCode(fasthttp.StatusOK)
+		return nil
+	})
+	cred := credential{accessKeyID: "ak", secretAccessKey: "sk", region: "cn-north-1", service: "air"}
+	c := newHTTPCaller("project", "tenant", false, "", nil, cred,
+		&staticHostAvailabler{hosts: []string{"host"}}, &CallerConfig{DisableCompression: true}, "http", false, transport)
+	options := &option.Options{}
+	reqCtx := newRequestContext("/write", options)
+	if _, err := c.doStreamRequest(reqCtx, "http://host/write", "text/csv",
+		strings.NewReader("id,name\n1,a\n"), options); err != nil {
+		t.Fatalf("doStreamRequest() error = %v", err)
+	}
+	if string(body) != "id,name\n1,a\n" || payloadHash != hashSHA256(body) {
+		t.Errorf("body:%q payload hash:%s", body, payloadHash)
+	}
+}
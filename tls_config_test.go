@@ -0,0 +1,54 @@
+package core
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/byteplus-sdk/byteplus-sdk-go-rec-core/metrics"
+	"github.com/valyala/fasthttp"
+)
+
+func TestNewFastHTTPClient_TLSConfig(t *testing.T) {
+	server := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer server.Close()
+	rootCAs := x509.NewCertPool()
+	rootCAs.AddCert(server.Certificate())
+	config := fillDefaultCallerConfig(&CallerConfig{
+		TLSConfig: &tls.Config{RootCAs: rootCAs, MinVersion: tls.VersionTLS12},
+	})
+	client := newFastHTTPClient(config, fasthttp.Dial)
+	request := fasthttp.AcquireRequest()
+	response := fasthttp.AcquireResponse()
+	defer fasthttp.ReleaseRequest(request)
+	defer fasthttp.ReleaseResponse(response)
+	request.SetRequestURI(server.URL)
+	if err := client.DoTimeout(request, response, time.Second); err != nil {
+		t.Fatalf("request with custom root CA fail, err:%v", err)
+	}
+	if err := (&fasthttp.Client{}).DoTimeout(request, response, time.Second); err == nil {
+		t.Errorf("request without custom root CA should fail")
+	}
+}
+
+func TestHTTPClientBuilder_withConnConfig(t *testing.T) {
+	tlsConfig := &tls.Config{MinVersion: tls.VersionTLS13}
+	builder := &httpClientBuilder{
+		callerConfig:          &CallerConfig{TLSConfig: tlsConfig, ProxyURL: "socks5://127.0.0.1:1080"},
+		hostAvailablerFactory: &HostAvailablerFactoryBase{PingConfig: &PingHostAvailablerConfig{WindowSize: 10}},
+		metricsCfg:            metrics.NewConfig(),
+	}
+	builder.withConnConfig()
+	pingConfig := builder.hostAvailablerFactory.(*HostAvailablerFactoryBase).PingConfig
+	if pingConfig.TLSConfig != tlsConfig || pingConfig.Dial == nil || pingConfig.WindowSize != 10 {
+		t.Errorf("ping config should inherit the tls config and proxy, %+v", pingConfig)
+	}
+	if builder.metricsCfg.TLSConfig != tlsConfig || builder.metricsCfg.Dial == nil {
+		t.Errorf("metrics config should inherit the tls config and proxy")
+	}
+}
@@ -0,0 +1,86 @@
+package core
+
+import (
+	"errors"
+	"sync"
+	"time"
+
+	"github.com/valyala/fasthttp"
+)
+
+// the cached token is refreshed the window before it expires
+const tokenExpiryWindow = 10 * time.Second
+
+// Token is the bearer token, such as the OAuth2 access token fetched from an IdP
+type Token struct {
+	AccessToken string
+	// The type of the token in the Authorization header, default is 'Bearer'
+	TokenType string
+	// When the token expires, zero means it never expires
+	Expiry time.Time
+}
+
+// TokenSource provides the token placed in the Authorization header of requests
+type TokenSource interface {
+	Token() (*Token, error)
+}
+
+type staticTokenSource struct {
+	token *Token
+}
+
+// StaticTokenSource returns the TokenSource always providing the access token
+func StaticTokenSource(accessToken string) TokenSource {
+	return &staticTokenSource{token: &Token{AccessToken: accessToken}}
+}
+
+func (s *staticTokenSource) Token() (*Token, error) {
+	if s.token.AccessToken == "" {
+		return nil, errors.New("token is empty")
+	}
+	return s.token, nil
+}
+
+type reuseTokenSource struct {
+	source  TokenSource
+	lock    sync.Mutex
+	current *Token
+}
+
+// ReuseTokenSource returns the TokenSource caching the token of source until it
+// is about to expire, so that source is not called for every request
+func ReuseTokenSource(source TokenSource) TokenSource {
+	if reuse, ok := source.(*reuseTokenSource); ok {
+		return reuse
+	}
+	return &reuseTokenSource{source: source}
+}
+
+func (s *reuseTokenSource) Token() (*Token, error) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	if s.current != nil && (s.current.Expiry.IsZero() ||
+		time.Now().Add(tokenExpiryWindow).Before(s.current.Expiry)) {
+		return s.current, nil
+	}
+	token, err := s.source.Token()
+	if err != nil {
+		return nil, err
+	}
+	s.current = token
+	return token, nil
+}
+
+// withTokenHeaders sets the Authorization header by the token of tokenSource
+func (c *httpCaller) withTokenHeaders(req *fasthttp.Request) error {
+	token, err := c.tokenSource.Token()
+	if err != nil {
+		return &CredentialsError{Err: err}
+	}
+	tokenType := token.TokenType
+	if tokenType == "" {
+		tokenType = "Bearer"
+	}
+	req.Header.Set("Authorization", tokenType+" "+token.AccessToken)
+	return nil
+}
@@ -0,0 +1,69 @@
+package core
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/byteplus-sdk/byteplus-sdk-go-rec-core/coreerr"
+	"github.com/byteplus-sdk/byteplus-sdk-go-rec-core/option"
+	"github.com/valyala/fasthttp"
+)
+
+type countingTokenSource struct {
+	calls  int
+	expiry time.Duration
+	err    error
+}
+
+func (s *countingTokenSource) Token() (*Token, error) {
+	s.calls++
+	if s.err != nil {
+		return nil, s.err
+	}
+	return &Token{AccessToken: "token" + string(rune('0'+s.calls)), Expiry: time.Now().Add(s.expiry)}, nil
+}
+
+func TestReuseTokenSource(t *testing.T) {
+	source := &countingTokenSource{expiry: time.Hour}
+	reuse := ReuseTokenSource(source)
+	for i := 0; i < 3; i++ {
+		if token, err := reuse.Token(); err != nil || token.AccessToken != "token1" {
+			t.Fatalf("Token() = %v, %v, want token1", token, err)
+		}
+	}
+	// the token expiring within the window is fetched again
+	source = &countingTokenSource{expiry: time.Second}
+	reuse = ReuseTokenSource(source)
+	_, _ = reuse.Token()
+	if token, _ := reuse.Token(); token.AccessToken != "token2" || source.calls != 2 {
+		t.Errorf("Token() of expiring token = %s, calls = %d, want token2 and 2 calls", token.AccessToken, source.calls)
+	}
+}
+
+func TestHTTPCaller_bearerToken(t *testing.T) {
+	var authorization string
+	transport := TransportFunc(func(request *fasthttp.Request, response *fasthttp.Response, deadline time.Time) error {
+		authorization = string(request.Header.Peek("Authorization"))
+		response.SetStatusCode(fasthttp.StatusOK)
+		return nil
+	})
+	airAuthConfig, _ := fillDefaultAirAuthConfig(nil)
+	c := newHTTPCaller("project", "tenant", true, "token", airAuthConfig, credential{},
+		&staticHostAvailabler{hosts: []string{"host"}}, &CallerConfig{}, "http", false, transport)
+	c.tokenSource = ReuseTokenSource(StaticTokenSource("idp-token"))
+	if _, err := c.doHTTPRequest("req", "http://host/path", map[string]string{"Request-Id": "req"},
+		[]byte("{}"), &option.Options{}, nil); err != nil {
+		t.Fatalf("doHTTPRequest() error = %v", err)
+	}
+	if authorization != "Bearer idp-token" {
+		t.Errorf("Authorization = %q, want Bearer idp-token", authorization)
+	}
+
+	c.tokenSource = &countingTokenSource{err: errors.New("idp unavailable")}
+	_, err := c.doHTTPRequest("req", "http://host/path", map[string]string{"Request-Id": "req"},
+		[]byte("{}"), &option.Options{}, nil)
+	if !coreerr.IsAuthFailure(err) {
+		t.Errorf("doHTTPRequest() without token error = %v, want auth failure", err)
+	}
+}
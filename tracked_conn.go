@@ -0,0 +1,206 @@
+package core
+
+import (
+	"bytes"
+	"context"
+	"net"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/valyala/fasthttp"
+)
+
+// newTrackedDial returns a dial function connecting by dial, the connections
+// are counted by stats and registered into conns by their local addresses
+func newTrackedDial(dial fasthttp.DialFunc, stats *callerStats, conns *connRegistry) fasthttp.DialFunc {
+	return func(addr string) (net.Conn, error) {
+		conn, err := dial(addr)
+		if err != nil {
+			return nil, err
+		}
+		atomic.AddInt64(&stats.openedConns, 1)
+		tracked := &trackedConn{Conn: conn, stats: stats, conns: conns}
+		conns.add(tracked)
+		return tracked, nil
+	}
+}
+
+type trackedConn struct {
+	net.Conn
+	stats     *callerStats
+	conns     *connRegistry
+	closeOnce sync.Once
+}
+
+func (c *trackedConn) Close() error {
+	c.closeOnce.Do(func() {
+		atomic.AddInt64(&c.stats.closedConns, 1)
+		c.conns.remove(c)
+	})
+	return c.Conn.Close()
+}
+
+// connRegistry finds the connection used by a request, so that the connection can be
+// closed when the request is cancelled. fasthttp does not expose the connection of a
+// request, but records its local address in the response before writing the request,
+// so the connection is found by the local address once the body is being written.
+// Only the tcp connections are registered, the local addresses of the others, such as
+// the unix sockets, do not identify the connections
+type connRegistry struct {
+	lock sync.Mutex
+	// local address -> connection
+	conns map[string]*trackedConn
+}
+
+func newConnRegistry() *connRegistry {
+	return &connRegistry{conns: make(map[string]*trackedConn)}
+}
+
+func (r *connRegistry) add(conn *trackedConn) {
+	key, ok := connKey(conn.LocalAddr())
+	if !ok {
+		return
+	}
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	r.conns[key] = conn
+}
+
+func (r *connRegistry) remove(conn *trackedConn) {
+	key, ok := connKey(conn.LocalAddr())
+	if !ok {
+		return
+	}
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	if r.conns[key] == conn {
+		delete(r.conns, key)
+	}
+}
+
+func (r *connRegistry) get(addr net.Addr) *trackedConn {
+	key, ok := connKey(addr)
+	if !ok {
+		return nil
+	}
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	return r.conns[key]
+}
+
+func connKey(addr net.Addr) (string, bool) {
+	if addr == nil || addr.Network() != "tcp" {
+		return "", false
+	}
+	return addr.String(), true
+}
+
+// fastHTTPDoer is implemented by the fasthttp clients, it sends the request without deadline
+type fastHTTPDoer interface {
+	Do(request *fasthttp.Request, response *fasthttp.Response) error
+}
+
+// boundRequest is a request sent by connRegistry.doWithContext, it is bound to the
+// connection once fasthttp starts writing its body
+type boundRequest struct {
+	lock     sync.Mutex
+	conn     *trackedConn
+	canceled bool
+}
+
+// bind records the connection of the request, the body is not written if the request
+// is already canceled
+func (b *boundRequest) bind(conn *trackedConn) error {
+	b.lock.Lock()
+	defer b.lock.Unlock()
+	if b.canceled {
+		return context.Canceled
+	}
+	b.conn = conn
+	return nil
+}
+
+// cancel closes the connection of the request, it reports whether the request is bound,
+// the unbound request fails once fasthttp writes its body
+func (b *boundRequest) cancel() bool {
+	b.lock.Lock()
+	b.canceled = true
+	conn := b.conn
+	b.lock.Unlock()
+	if conn == nil {
+		return false
+	}
+	_ = conn.Close()
+	return true
+}
+
+// bindingBody is the body stream of the request, it binds the request to the
+// connection on the first read, which is after fasthttp acquires the connection.
+// The reader is not embedded, so that the body is always written by Read instead
+// of the WriteTo of bytes.Reader
+type bindingBody struct {
+	reader   *bytes.Reader
+	bindOnce sync.Once
+	bind     func() error
+	err      error
+}
+
+func (b *bindingBody) Read(p []byte) (int, error) {
+	b.bindOnce.Do(func() {
+		b.err = b.bind()
+	})
+	if b.err != nil {
+		return 0, b.err
+	}
+	return b.reader.Read(p)
+}
+
+// doWithContext sends the request by doer until it is done, the ctx is cancelled or the
+// deadline passes. On cancellation or timeout, the connection of the request is closed,
+// so that it is not kept busy, and the result of the request is waited for. If the
+// request has not got a connection yet, it is abandoned instead, as fasthttp may still
+// access the request and response, abandoned reports whether it happens.
+func (r *connRegistry) doWithContext(ctx context.Context, doer fastHTTPDoer, request *fasthttp.Request,
+	response *fasthttp.Response, deadline time.Time) (abandoned bool, err error) {
+	ctx, cancel := context.WithDeadline(ctx, deadline)
+	defer cancel()
+	// the body is backed by the buffer of request, which is reset by SetBodyStream
+	body := append([]byte(nil), request.Body()...)
+	bound := &boundRequest{}
+	request.SetBodyStream(&bindingBody{
+		reader: bytes.NewReader(body),
+		bind: func() error {
+			conn := r.get(response.LocalAddr())
+			if conn == nil {
+				return nil
+			}
+			return bound.bind(conn)
+		},
+	}, len(body))
+	done := make(chan error, 1)
+	go func() {
+		done <- doer.Do(request, response)
+	}()
+	select {
+	case err = <-done:
+	case <-ctx.Done():
+		if !bound.cancel() {
+			return true, ctxErr(ctx)
+		}
+		<-done
+		err = ctxErr(ctx)
+	}
+	// the body is kept for the logs and signature explanation of the response
+	request.SetBodyRaw(body)
+	return false, err
+}
+
+// ctxErr returns fasthttp.ErrTimeout if ctx is done by its deadline, which is the
+// timeout of request, instead of cancelled by the caller
+func ctxErr(ctx context.Context) error {
+	if ctx.Err() == context.DeadlineExceeded {
+		return fasthttp.ErrTimeout
+	}
+	return ctx.Err()
+}
@@ -0,0 +1,65 @@
+package core
+
+import (
+	"context"
+	"crypto/tls"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/byteplus-sdk/byteplus-sdk-go-rec-core/option"
+)
+
+func TestHTTPCaller_cancelOverHTTPS(t *testing.T) {
+	disconnected := make(chan struct{}, 2)
+	server := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		// the context of server request is done once the client closes the connection,
+		// which is watched after the body is read
+		_, _ = io.ReadAll(r.Body)
+		select {
+		case <-r.Context().Done():
+			disconnected <- struct{}{}
+		case <-time.After(5 * time.Second):
+		}
+	}))
+	defer server.Close()
+	airAuthConfig, _ := fillDefaultAirAuthConfig(nil)
+	config := &CallerConfig{TLSConfig: &tls.Config{InsecureSkipVerify: true}}
+	c := newHTTPCaller("project", "tenant", true, "token", airAuthConfig, credential{},
+		&staticHostAvailabler{hosts: []string{"host"}}, config, "https", false, nil)
+	// the hedged legs of the same request id are cancelled independently
+	ctx, cancel := context.WithCancel(context.Background())
+	otherCtx, otherCancel := context.WithCancel(context.Background())
+	defer otherCancel()
+	time.AfterFunc(200*time.Millisecond, cancel)
+	otherDone := make(chan error, 1)
+	go func() {
+		_, err := c.doHTTPRequest("req", server.URL+"/predict", map[string]string{"Request-Id": "req"},
+			[]byte("{}"), &option.Options{Context: otherCtx, Timeout: 10 * time.Second}, nil)
+		otherDone <- err
+	}()
+	start := time.Now()
+	_, err := c.doHTTPRequest("req", server.URL+"/predict", map[string]string{"Request-Id": "req"},
+		[]byte("{}"), &option.Options{Context: ctx, Timeout: 10 * time.Second}, nil)
+	if !errors.Is(err, context.Canceled) || time.Since(start) > 2*time.Second {
+		t.Fatalf("doHTTPRequest() err = %v cost:%s, want canceled in time", err, time.Since(start))
+	}
+	select {
+	case <-disconnected:
+	case <-time.After(2 * time.Second):
+		t.Fatalf("the connection of the canceled request is not closed")
+	}
+	if got := c.stats.snapshot().ClosedConns; got != 1 {
+		t.Errorf("ClosedConns = %v, want 1", got)
+	}
+	select {
+	case err = <-otherDone:
+		t.Errorf("the other request of the same request id is canceled, err = %v", err)
+	default:
+	}
+	otherCancel()
+	<-otherDone
+}
@@ -0,0 +1,42 @@
+package core
+
+import (
+	"context"
+	"time"
+
+	"github.com/valyala/fasthttp"
+)
+
+// Transport sends the http requests of HTTPClient, including the pings of heartbeat.
+// *fasthttp.Client and *fasthttp.HostClient implement it, and a fasthttp client
+// built from CallerConfig is used by default. Implement it to send requests by
+// other means, such as a corporate proxy, or a test double
+type Transport interface {
+	// DoDeadline sends request and fills response, it returns an error
+	// if the response is not received before deadline
+	DoDeadline(request *fasthttp.Request, response *fasthttp.Response, deadline time.Time) error
+	// CloseIdleConnections closes the idle connections kept by the transport,
+	// it is called when the dns of hosts changes
+	CloseIdleConnections()
+}
+
+// ContextTransport is implemented by the Transport able to cancel the in-flight request
+// when the context of request, set by option.WithContext, is cancelled, such as by closing
+// its connection. NetHTTPTransport implements it. The requests of the injected transports
+// not implementing it are abandoned on cancellation, and may keep their connections busy
+// until deadline
+type ContextTransport interface {
+	Transport
+	// DoContext is DoDeadline, and it returns once ctx is done, with the request cancelled
+	DoContext(ctx context.Context, request *fasthttp.Request, response *fasthttp.Response, deadline time.Time) error
+}
+
+// TransportFunc adapts a function sending requests to Transport, it keeps no connection
+type TransportFunc func(request *fasthttp.Request, response *fasthttp.Response, deadline time.Time) error
+
+func (f TransportFunc) DoDeadline(request *fasthttp.Request, response *fasthttp.Response, deadline time.Time) error {
+	return f(request, response, deadline)
+}
+
+func (f TransportFunc) CloseIdleConnections() {
+}
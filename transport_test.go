@@ -0,0 +1,34 @@
+package core
+
+import (
+	"testing"
+	"time"
+
+	"github.com/byteplus-sdk/byteplus-sdk-go-rec-core/option"
+	"github.com/valyala/fasthttp"
+)
+
+func TestHTTPCaller_Transport(t *testing.T) {
+	var requestURI, requestID string
+	transport := TransportFunc(func(request *fasthttp.Request, response *fasthttp.Response, deadline time.Time) error {
+		requestURI = request.URI().String()
+		requestID = string(request.Header.Peek("Request-Id"))
+		if time.Until(deadline) <= 0 {
+			t.Errorf("deadline should be in the future")
+		}
+		response.SetStatusCode(fasthttp.StatusOK)
+		response.SetBodyString("ok")
+		return nil
+	})
+	airAuthConfig, _ := fillDefaultAirAuthConfig(nil)
+	c := newHTTPCaller("project", "tenant", true, "token", airAuthConfig, credential{},
+		&staticHostAvailabler{hosts: []string{"host"}}, &CallerConfig{}, "http", false, transport)
+	headers := map[string]string{"Request-Id": "req"}
+	rspBytes, err := c.doHTTPRequest("req", "http://host/path", headers, nil, &option.Options{}, nil)
+	if err != nil {
+		t.Fatalf("doHTTPRequest() error = %v", err)
+	}
+	if string(rspBytes) != "ok" || requestURI != "http://host/path" || requestID != "req" {
+		t.Errorf("doHTTPRequest() = %s, uri:%s request id:%s", rspBytes, requestURI, requestID)
+	}
+}
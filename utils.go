@@ -1,6 +1,8 @@
 package core
 
 import (
+	"crypto/sha256"
+	"encoding/hex"
 	"errors"
 	"fmt"
 	"math"
@@ -37,6 +39,7 @@ func DoWithRetry(maxRetryTimes int, runnable func() error) error {
 	return err
 }
 
+// Deprecated: use coreerr.ClassOf, or the predicates like coreerr.IsRetryable
 func IsNetError(err error) bool {
 	if err == nil {
 		return false
@@ -44,6 +47,7 @@ func IsNetError(err error) bool {
 	return strings.Contains(err.Error(), netErrMark)
 }
 
+// Deprecated: use coreerr.IsTransport
 func IsTimeoutError(err error) bool {
 	return strings.Contains(strings.ToLower(err.Error()), "timeout")
 }
@@ -55,8 +59,14 @@ func buildURL(schema, host, path string) string {
 	return fmt.Sprintf("%s://%s/%s", schema, host, path)
 }
 
-func Ping(projectID string, httpCli *fasthttp.Client, pingURLFormat,
+func Ping(projectID string, transport Transport, pingURLFormat,
 	schema, host string, pingTimeout time.Duration) bool {
+	return ping(projectID, transport, pingURLFormat, schema, host, pingTimeout) == nil
+}
+
+// ping returns the error of the failed ping, such as the error of transport or the unexpected status
+func ping(projectID string, transport Transport, pingURLFormat,
+	schema, host string, pingTimeout time.Duration) error {
 	request := fasthttp.AcquireRequest()
 	response := fasthttp.AcquireResponse()
 	defer func() {
@@ -70,24 +80,24 @@ func Ping(projectID string, httpCli *fasthttp.Client, pingURLFormat,
 	request.Header.Set("Request-Id", reqID)
 	request.Header.Set("Project-Id", projectID)
 	start := time.Now()
-	err := httpCli.DoTimeout(request, response, pingTimeout)
+	err := transport.DoDeadline(request, response, start.Add(pingTimeout))
 	cost := time.Since(start)
 	if err != nil {
 		metrics.Warn(reqID, "[ByteplusSDK] ping find err, project_id:%s, host:%s, cost:%dms, err:%v",
 			projectID, host, cost.Milliseconds(), err)
 		logs.Warn("ping find err, host:%s cost:%dms err:%v", host, cost.Milliseconds(), err)
-		return false
+		return err
 	}
 	if IsPingSuccess(response) {
 		metrics.Info(reqID, "[ByteplusSDK] ping success, project_id:%s, host:%s, cost:%dms",
 			projectID, host, cost.Milliseconds())
 		logs.Debug("ping success host:%s cost:%dms", host, cost.Milliseconds())
-		return true
+		return nil
 	}
 	metrics.Warn(reqID, "[ByteplusSDK] ping fail, project_id:%s, host:%s, cost:%dms, status:%d",
 		projectID, host, cost.Milliseconds(), response.StatusCode())
 	logs.Warn("ping fail, host:%s cost:%dms status:%d", host, cost.Milliseconds(), response.StatusCode())
-	return false
+	return fmt.Errorf("unexpected ping response, status:%d", response.StatusCode())
 }
 
 func IsPingSuccess(httpRsp *fasthttp.Response) bool {
@@ -108,3 +118,42 @@ func escapeMetricsTagValue(value string) string {
 	value = strings.ReplaceAll(value, "=", "-eq-")
 	return value
 }
+
+// hostOfURL returns the host part of url, such as "byteplus.com" of "https://byteplus.com/path"
+func hostOfURL(url string) string {
+	if idx := strings.Index(url, "://"); idx >= 0 {
+		url = url[idx+3:]
+	}
+	if idx := strings.IndexAny(url, "/?"); idx >= 0 {
+		url = url[:idx]
+	}
+	return url
+}
+
+// pathOfURL returns the path part of url, such as "/path" of "https://byteplus.com/path?stage=pre"
+func pathOfURL(url string) string {
+	if idx := strings.Index(url, "://"); idx >= 0 {
+		url = url[idx+3:]
+	}
+	if idx := strings.Index(url, "?"); idx >= 0 {
+		url = url[:idx]
+	}
+	if idx := strings.Index(url, "/"); idx >= 0 {
+		return url[idx:]
+	}
+	return "/"
+}
+
+// buildRequestKey
+// key is composed of the url without schema and host, and the hash of request body,
+// so the same request sent to different hosts is identified by the same key
+func buildRequestKey(url string, reqBytes []byte) string {
+	if idx := strings.Index(url, "://"); idx >= 0 {
+		url = url[idx+3:]
+	}
+	if idx := strings.Index(url, "/"); idx >= 0 {
+		url = url[idx:]
+	}
+	hash := sha256.Sum256(reqBytes)
+	return url + "#" + hex.EncodeToString(hash[:])
+}
@@ -0,0 +1,59 @@
+package core
+
+import (
+	"testing"
+
+	"github.com/byteplus-sdk/byteplus-sdk-go-rec-core/option"
+)
+
+func TestBuildRequestKey(t *testing.T) {
+	body := []byte("body")
+	keyA := buildRequestKey("https://a.com/predict/api/x?stage=pre", body)
+	keyB := buildRequestKey("http://b.com/predict/api/x?stage=pre", body)
+	if keyA != keyB {
+		t.Errorf("keys of different hosts = %v, %v, want equal", keyA, keyB)
+	}
+	keyC := buildRequestKey("https://a.com/predict/api/x", body)
+	if keyA == keyC {
+		t.Errorf("keys of different queries are equal: %v", keyA)
+	}
+	url := "https://a.com/items/1"
+	if requestKey(&option.Options{}, url, nil) != buildRequestKey(url, nil) {
+		t.Errorf("key of POST request should be the same as buildRequestKey")
+	}
+	if requestKey(&option.Options{Method: "GET"}, url, nil) == requestKey(&option.Options{Method: "DELETE"}, url, nil) {
+		t.Errorf("keys of different methods are equal")
+	}
+}
+
+func TestHostOfURL(t *testing.T) {
+	tests := []struct {
+		url  string
+		want string
+	}{
+		{url: "https://byteplus.com/predict/api/x", want: "byteplus.com"},
+		{url: "http://byteplus.com:8080?stage=pre", want: "byteplus.com:8080"},
+		{url: "byteplus.com", want: "byteplus.com"},
+	}
+	for _, tt := range tests {
+		if got := hostOfURL(tt.url); got != tt.want {
+			t.Errorf("hostOfURL(%v) = %v, want %v", tt.url, got, tt.want)
+		}
+	}
+}
+
+func TestPathOfURL(t *testing.T) {
+	tests := []struct {
+		url  string
+		want string
+	}{
+		{url: "https://byteplus.com/predict/api/x?stage=pre", want: "/predict/api/x"},
+		{url: "http://byteplus.com:8080", want: "/"},
+		{url: "http://byteplus.com?stage=pre", want: "/"},
+	}
+	for _, tt := range tests {
+		if got := pathOfURL(tt.url); got != tt.want {
+			t.Errorf("pathOfURL(%v) = %v, want %v", tt.url, got, tt.want)
+		}
+	}
+}
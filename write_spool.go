@@ -0,0 +1,317 @@
+package core
+
+import (
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"hash/crc32"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/byteplus-sdk/byteplus-sdk-go-rec-core/coreerr"
+	"github.com/byteplus-sdk/byteplus-sdk-go-rec-core/logs"
+	"github.com/byteplus-sdk/byteplus-sdk-go-rec-core/option"
+)
+
+const (
+	spoolSegmentSuffix          = ".seg"
+	spoolRecordHeaderSize       = 8
+	defaultSpoolSegmentMaxBytes = 64 << 20
+	defaultSpoolReplayInterval  = 30 * time.Second
+	spoolSegmentFileMode        = 0644
+	spoolDirFileMode            = 0755
+	spoolSegmentNameFormat      = "%020d" + spoolSegmentSuffix
+	spoolTempSegmentSuffix      = ".tmp"
+	spoolDeadSegmentSuffix      = ".dead"
+	spoolMaxRecordBytes         = 256 << 20
+	defaultSpoolMaxReplayTimes  = 5
+)
+
+var errSpoolRecordCorrupted = errors.New("spool record is corrupted")
+
+// writeSpool persists write requests into append-only segment files.
+// Each record is formatted as: | length(4B) | crc32(4B) | json payload |
+// Segments are replayed in the order of creation, the active segment is
+// sealed before replaying, so records appended later go into a new segment.
+// The records failing maxReplayTimes, or unable to be decoded, are moved aside
+// into the ".dead" file of their segment, so that the later records are not blocked.
+type writeSpool struct {
+	dir             string
+	segmentMaxBytes int64
+	maxReplayTimes  int
+	lock            sync.Mutex
+	active          *os.File
+	activeSize      int64
+	nextSeq         uint64
+}
+
+type spoolRecord struct {
+	Path          string              `json:"path"`
+	ContentType   string              `json:"content_type"`
+	Body          []byte              `json:"body"`
+	RequestID     string              `json:"request_id"`
+	Method        string              `json:"method,omitempty"`
+	Headers       map[string]string   `json:"headers,omitempty"`
+	Queries       map[string]string   `json:"queries,omitempty"`
+	QueryValues   map[string][]string `json:"query_values,omitempty"`
+	Timeout       time.Duration       `json:"timeout,omitempty"`
+	ServerTimeout time.Duration       `json:"server_timeout,omitempty"`
+	// the times of replaying the record failed
+	ReplayFailures int `json:"replay_failures,omitempty"`
+}
+
+func newWriteSpool(dir string, segmentMaxBytes int64, maxReplayTimes int) (*writeSpool, error) {
+	if segmentMaxBytes <= 0 {
+		segmentMaxBytes = defaultSpoolSegmentMaxBytes
+	}
+	if maxReplayTimes <= 0 {
+		maxReplayTimes = defaultSpoolMaxReplayTimes
+	}
+	if err := os.MkdirAll(dir, spoolDirFileMode); err != nil {
+		return nil, err
+	}
+	spool := &writeSpool{
+		dir:             dir,
+		segmentMaxBytes: segmentMaxBytes,
+		maxReplayTimes:  maxReplayTimes,
+	}
+	segments, err := spool.listSegments()
+	if err != nil {
+		return nil, err
+	}
+	if len(segments) > 0 {
+		lastSeq, _ := strconv.ParseUint(strings.TrimSuffix(segments[len(segments)-1], spoolSegmentSuffix), 10, 64)
+		spool.nextSeq = lastSeq + 1
+	}
+	return spool, nil
+}
+
+func (s *writeSpool) append(item *asyncWriteItem) error {
+	recordBytes, err := encodeSpoolRecord(item, 0)
+	if err != nil {
+		return err
+	}
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	if s.active != nil && s.activeSize+int64(len(recordBytes)) > s.segmentMaxBytes {
+		s.sealActiveSegment()
+	}
+	if s.active == nil {
+		name := filepath.Join(s.dir, fmt.Sprintf(spoolSegmentNameFormat, s.nextSeq))
+		file, err := os.OpenFile(name, os.O_CREATE|os.O_WRONLY|os.O_APPEND, spoolSegmentFileMode)
+		if err != nil {
+			return err
+		}
+		s.nextSeq++
+		s.active = file
+		s.activeSize = 0
+	}
+	n, err := s.active.Write(recordBytes)
+	s.activeSize += int64(n)
+	return err
+}
+
+func (s *writeSpool) sealActiveSegment() {
+	if s.active == nil {
+		return
+	}
+	if err := s.active.Close(); err != nil {
+		logs.Warn("close spool segment fail, name:%s err:%v", s.active.Name(), err)
+	}
+	s.active = nil
+	s.activeSize = 0
+}
+
+// listSegments returns the file names of segments, sorted by creation order
+func (s *writeSpool) listSegments() ([]string, error) {
+	files, err := os.ReadDir(s.dir)
+	if err != nil {
+		return nil, err
+	}
+	segments := make([]string, 0, len(files))
+	for _, file := range files {
+		if !file.IsDir() && strings.HasSuffix(file.Name(), spoolSegmentSuffix) {
+			segments = append(segments, file.Name())
+		}
+	}
+	sort.Strings(segments)
+	return segments, nil
+}
+
+// replay sends the records of all the segments in order, sent segments are removed.
+// Replay stops at the first failure of the network, throttling or auth, which fails
+// the later records too, and the unsent records are kept for the next replay. It also
+// stops once stop is closed.
+func (s *writeSpool) replay(send func(item *asyncWriteItem) error, stop <-chan struct{}) {
+	s.lock.Lock()
+	s.sealActiveSegment()
+	segments, err := s.listSegments()
+	s.lock.Unlock()
+	if err != nil {
+		logs.Error("list spool segments fail, dir:%s err:%v", s.dir, err)
+		return
+	}
+	for _, segment := range segments {
+		if !s.replaySegment(filepath.Join(s.dir, segment), send, stop) {
+			return
+		}
+	}
+}
+
+func (s *writeSpool) replaySegment(name string, send func(item *asyncWriteItem) error,
+	stop <-chan struct{}) bool {
+	data, err := os.ReadFile(name)
+	if err != nil {
+		logs.Error("read spool segment fail, name:%s err:%v", name, err)
+		return false
+	}
+	for offset := 0; offset < len(data); {
+		select {
+		case <-stop:
+			s.rewriteSegment(name, data[offset:])
+			return false
+		default:
+		}
+		item, failures, size, err := decodeSpoolRecord(data[offset:])
+		if err != nil && size == 0 {
+			// the tail may be torn by a crash while appending
+			logs.Warn("skip corrupted spool records, name:%s offset:%d err:%v", name, offset, err)
+			break
+		}
+		if err != nil {
+			logs.Warn("move aside undecodable spool record, name:%s offset:%d err:%v", name, offset, err)
+			s.moveAside(name, data[offset:offset+size])
+			offset += size
+			continue
+		}
+		if err = send(item); err != nil {
+			logs.Warn("replay spooled request fail, name:%s request_id:%s err:%v",
+				name, item.options.RequestID, err)
+			if !isRecordReplayFailure(err) {
+				s.rewriteSegment(name, data[offset:])
+				return false
+			}
+			recordBytes, _ := encodeSpoolRecord(item, failures+1)
+			if failures+1 < s.maxReplayTimes && recordBytes != nil {
+				remaining := append(recordBytes, data[offset+size:]...)
+				s.rewriteSegment(name, remaining)
+				return false
+			}
+			logs.Error("move aside spooled request failing %d times, name:%s request_id:%s",
+				failures+1, name, item.options.RequestID)
+			s.moveAside(name, data[offset:offset+size])
+		}
+		offset += size
+	}
+	if err = os.Remove(name); err != nil {
+		logs.Error("remove replayed spool segment fail, name:%s err:%v", name, err)
+		return false
+	}
+	return true
+}
+
+// isRecordReplayFailure reports whether the replay failure is caused by the record itself,
+// the failures of network, throttling and auth fail all the records, they are not counted
+func isRecordReplayFailure(err error) bool {
+	return !coreerr.IsTransport(err) && !coreerr.IsThrottled(err) && !coreerr.IsAuthFailure(err)
+}
+
+// moveAside appends the record into the ".dead" file of the segment, it is kept
+// for inspection and not replayed anymore
+func (s *writeSpool) moveAside(name string, record []byte) {
+	deadName := name + spoolDeadSegmentSuffix
+	file, err := os.OpenFile(deadName, os.O_CREATE|os.O_WRONLY|os.O_APPEND, spoolSegmentFileMode)
+	if err != nil {
+		logs.Error("open dead spool segment fail, name:%s err:%v", deadName, err)
+		return
+	}
+	defer file.Close()
+	if _, err = file.Write(record); err != nil {
+		logs.Error("write dead spool segment fail, name:%s err:%v", deadName, err)
+	}
+}
+
+// rewriteSegment keeps only the unsent records, so that they are not resent
+func (s *writeSpool) rewriteSegment(name string, remaining []byte) {
+	tempName := name + spoolTempSegmentSuffix
+	if err := os.WriteFile(tempName, remaining, spoolSegmentFileMode); err != nil {
+		logs.Warn("write spool segment fail, name:%s err:%v", tempName, err)
+		return
+	}
+	if err := os.Rename(tempName, name); err != nil {
+		logs.Warn("rename spool segment fail, name:%s err:%v", tempName, err)
+	}
+}
+
+func (s *writeSpool) close() {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	s.sealActiveSegment()
+}
+
+func encodeSpoolRecord(item *asyncWriteItem, replayFailures int) ([]byte, error) {
+	payload, err := json.Marshal(&spoolRecord{
+		Path:           item.path,
+		ContentType:    item.contentType,
+		Body:           item.reqBytes,
+		RequestID:      item.options.RequestID,
+		Method:         item.options.Method,
+		Headers:        item.options.Headers,
+		Queries:        item.options.Queries,
+		QueryValues:    item.options.QueryValues,
+		Timeout:        item.options.Timeout,
+		ServerTimeout:  item.options.ServerTimeout,
+		ReplayFailures: replayFailures,
+	})
+	if err != nil {
+		return nil, err
+	}
+	recordBytes := make([]byte, spoolRecordHeaderSize+len(payload))
+	binary.BigEndian.PutUint32(recordBytes[0:4], uint32(len(payload)))
+	binary.BigEndian.PutUint32(recordBytes[4:8], crc32.ChecksumIEEE(payload))
+	copy(recordBytes[spoolRecordHeaderSize:], payload)
+	return recordBytes, nil
+}
+
+// decodeSpoolRecord returns the item, the replay failures and the bytes size of the record.
+// The size is returned with the error if the header of the record is intact, so that the
+// undecodable record can be skipped, it is 0 if the record is torn
+func decodeSpoolRecord(data []byte) (*asyncWriteItem, int, int, error) {
+	if len(data) < spoolRecordHeaderSize {
+		return nil, 0, 0, errSpoolRecordCorrupted
+	}
+	length := binary.BigEndian.Uint32(data[0:4])
+	if length > spoolMaxRecordBytes || int(length) > len(data)-spoolRecordHeaderSize {
+		return nil, 0, 0, errSpoolRecordCorrupted
+	}
+	size := spoolRecordHeaderSize + int(length)
+	payload := data[spoolRecordHeaderSize:size]
+	if crc32.ChecksumIEEE(payload) != binary.BigEndian.Uint32(data[4:8]) {
+		return nil, 0, size, errSpoolRecordCorrupted
+	}
+	record := &spoolRecord{}
+	if err := json.Unmarshal(payload, record); err != nil {
+		return nil, 0, size, err
+	}
+	item := &asyncWriteItem{
+		path:        record.Path,
+		contentType: record.ContentType,
+		reqBytes:    record.Body,
+		options: &option.Options{
+			RequestID:     record.RequestID,
+			Method:        record.Method,
+			Headers:       record.Headers,
+			Queries:       record.Queries,
+			QueryValues:   record.QueryValues,
+			Timeout:       record.Timeout,
+			ServerTimeout: record.ServerTimeout,
+		},
+	}
+	return item, record.ReplayFailures, size, nil
+}
@@ -0,0 +1,154 @@
+package core
+
+import (
+	"encoding/binary"
+	"errors"
+	"hash/crc32"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/byteplus-sdk/byteplus-sdk-go-rec-core/option"
+)
+
+func TestWriteSpool_replay(t *testing.T) {
+	dir := t.TempDir()
+	spool, err := newWriteSpool(dir, 100, 0)
+	if err != nil {
+		t.Fatalf("newWriteSpool() err = %v", err)
+	}
+	for i := 0; i < 5; i++ {
+		item := &asyncWriteItem{
+			path:        "/data/api/write",
+			contentType: "application/json",
+			reqBytes:    []byte(`{"i":` + strconv.Itoa(i) + `}`),
+			options:     &option.Options{RequestID: strconv.Itoa(i)},
+		}
+		if err = spool.append(item); err != nil {
+			t.Fatalf("append() err = %v", err)
+		}
+	}
+	spool.close()
+
+	// fail at the third record, the first two records should not be replayed again
+	var sent []string
+	spool.replay(func(item *asyncWriteItem) error {
+		if item.options.RequestID == "2" && len(sent) == 2 {
+			return errors.New("mock net error")
+		}
+		sent = append(sent, item.options.RequestID)
+		return nil
+	}, nil)
+	// a restarted process should continue from the unsent records
+	spool, err = newWriteSpool(dir, 100, 0)
+	if err != nil {
+		t.Fatalf("newWriteSpool() err = %v", err)
+	}
+	spool.replay(func(item *asyncWriteItem) error {
+		sent = append(sent, item.options.RequestID)
+		return nil
+	}, nil)
+	want := []string{"0", "1", "2", "3", "4"}
+	if len(sent) != len(want) {
+		t.Fatalf("replayed = %v, want %v", sent, want)
+	}
+	for i := range want {
+		if sent[i] != want[i] {
+			t.Fatalf("replayed = %v, want %v", sent, want)
+		}
+	}
+	segments, _ := spool.listSegments()
+	if len(segments) != 0 {
+		t.Errorf("segments = %v, want empty", segments)
+	}
+}
+
+func TestWriteSpool_replayMethod(t *testing.T) {
+	spool, err := newWriteSpool(t.TempDir(), 0, 0)
+	if err != nil {
+		t.Fatalf("newWriteSpool() err = %v", err)
+	}
+	item := &asyncWriteItem{
+		path:    "/data/api/write",
+		options: option.Conv2Options(option.WithRequestID("0"), option.WithHTTPMethod("DELETE")),
+	}
+	if err = spool.append(item); err != nil {
+		t.Fatalf("append() err = %v", err)
+	}
+	spool.close()
+	var methods []string
+	spool.replay(func(item *asyncWriteItem) error {
+		methods = append(methods, requestMethod(item.options))
+		return nil
+	}, nil)
+	if len(methods) != 1 || methods[0] != "DELETE" {
+		t.Errorf("replayed methods = %v, want DELETE", methods)
+	}
+}
+
+func TestWriteSpool_replayMoveAside(t *testing.T) {
+	dir := t.TempDir()
+	spool, err := newWriteSpool(dir, 1<<20, 2)
+	if err != nil {
+		t.Fatalf("newWriteSpool() err = %v", err)
+	}
+	for i := 0; i < 3; i++ {
+		item := &asyncWriteItem{
+			path:    "/data/api/write",
+			options: &option.Options{RequestID: strconv.Itoa(i)},
+		}
+		if err = spool.append(item); err != nil {
+			t.Fatalf("append() err = %v", err)
+		}
+	}
+	// an undecodable record with intact header
+	payload := []byte("not json")
+	record := make([]byte, spoolRecordHeaderSize+len(payload))
+	binary.BigEndian.PutUint32(record[0:4], uint32(len(payload)))
+	binary.BigEndian.PutUint32(record[4:8], crc32.ChecksumIEEE(payload))
+	copy(record[spoolRecordHeaderSize:], payload)
+	spool.lock.Lock()
+	_, _ = spool.active.Write(record)
+	spool.lock.Unlock()
+	if err = spool.append(&asyncWriteItem{options: &option.Options{RequestID: "3"}}); err != nil {
+		t.Fatalf("append() err = %v", err)
+	}
+	spool.close()
+
+	var sent []string
+	send := func(item *asyncWriteItem) error {
+		if item.options.RequestID == "1" {
+			return &StatusError{Status: 400}
+		}
+		sent = append(sent, item.options.RequestID)
+		return nil
+	}
+	// the network failures are not counted
+	for i := 0; i < 3; i++ {
+		spool.replay(func(item *asyncWriteItem) error {
+			return newRequestError("", "", 0, nil, errors.New("mock net error"))
+		}, nil)
+	}
+	for i := 0; i < 2; i++ {
+		spool.replay(send, nil)
+	}
+	want := []string{"0", "2", "3"}
+	if strings.Join(sent, ",") != strings.Join(want, ",") {
+		t.Errorf("replayed = %v, want %v", sent, want)
+	}
+	segments, _ := spool.listSegments()
+	if len(segments) != 0 {
+		t.Errorf("segments = %v, want empty", segments)
+	}
+	deads, _ := filepath.Glob(filepath.Join(dir, "*"+spoolDeadSegmentSuffix))
+	if len(deads) != 1 {
+		t.Fatalf("dead segments = %v, want 1", deads)
+	}
+	data, _ := os.ReadFile(deads[0])
+	item, _, size, err := decodeSpoolRecord(data)
+	if err != nil || item.options.RequestID != "1" || size+len(record) != len(data) {
+		t.Errorf("dead records = %q, want the failing and the undecodable ones", data)
+	}
+}